@@ -0,0 +1,175 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package admission runs the patch engine inside admission webhooks: it compares the objects of an admission request
+// and builds the JSON Patch responses of mutating webhooks.
+package admission
+
+import (
+	"bytes"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+type options struct {
+	patchMaker       patch.Maker
+	calculateOptions []patch.CalculateOption
+	scheme           *runtime.Scheme
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		patchMaker: patch.DefaultPatchMaker,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Option customizes Calculate and Mutate.
+type Option func(*options)
+
+// WithPatchMaker sets the patch maker comparing the objects, patch.DefaultPatchMaker by default.
+func WithPatchMaker(patchMaker patch.Maker) Option {
+	return func(o *options) {
+		o.patchMaker = patchMaker
+	}
+}
+
+// WithCalculateOptions sets the options passed to Calculate.
+func WithCalculateOptions(opts ...patch.CalculateOption) Option {
+	return func(o *options) {
+		o.calculateOptions = append(o.calculateOptions, opts...)
+	}
+}
+
+// WithScheme sets the scheme decoding the objects into typed objects, in addition to the client-go scheme.
+// The objects of the kinds registered in neither are decoded as unstructured objects.
+func WithScheme(scheme *runtime.Scheme) Option {
+	return func(o *options) {
+		o.scheme = scheme
+	}
+}
+
+// Calculate compares the object stored in the cluster with the object of an update request, like Calculate compares the
+// current and modified objects, so webhooks can tell whether the update actually changes something according to the
+// comparison options, e.g. to reject the updates of immutable fields with result.ImmutableChanges.
+func Calculate(req *admissionv1.AdmissionRequest, opts ...Option) (*patch.PatchResult, error) {
+	if len(req.OldObject.Raw) == 0 {
+		return nil, errors.Errorf("%s request has no old object", req.Operation)
+	}
+
+	o := newOptions(opts)
+	current, err := o.decode(req, req.OldObject.Raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode old object")
+	}
+	modified, err := o.decode(req, req.Object.Raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode object")
+	}
+
+	return o.patchMaker.Calculate(current, modified, o.calculateOptions...)
+}
+
+// MutateFunc changes the object of an admission request in place.
+type MutateFunc func(obj runtime.Object) error
+
+// Mutate decodes the object of the request, applies the mutation on it and returns the response allowing the request
+// with the JSON Patch turning the submitted object into the mutated one. The patch is created from the raw submitted
+// object, like the API server applies it, so it also sets the empty fields the typed objects marshal. The response has no
+// patch when the mutation doesn't change the object.
+func Mutate(req *admissionv1.AdmissionRequest, mutate MutateFunc, opts ...Option) (*admissionv1.AdmissionResponse, error) {
+	o := newOptions(opts)
+	obj, err := o.decode(req, req.Object.Raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode object")
+	}
+
+	original, err := json.ConfigCompatibleWithStandardLibrary.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal object")
+	}
+
+	if err := mutate(obj); err != nil {
+		return nil, errors.Wrap(err, "could not mutate object")
+	}
+
+	mutated, err := json.ConfigCompatibleWithStandardLibrary.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal mutated object")
+	}
+	if bytes.Equal(original, mutated) {
+		return PatchResponse(req, nil), nil
+	}
+
+	jsonPatch, err := patch.CreateJSONPatch(req.Object.Raw, mutated)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create JSON patch")
+	}
+
+	return PatchResponse(req, jsonPatch), nil
+}
+
+// PatchResponse returns the response allowing the request with the given JSON Patch, an empty patch is left out.
+func PatchResponse(req *admissionv1.AdmissionRequest, jsonPatch []byte) *admissionv1.AdmissionResponse {
+	response := &admissionv1.AdmissionResponse{
+		UID:     req.UID,
+		Allowed: true,
+	}
+	if len(jsonPatch) > 0 && string(jsonPatch) != "[]" {
+		patchType := admissionv1.PatchTypeJSONPatch
+		response.PatchType = &patchType
+		response.Patch = jsonPatch
+	}
+	return response
+}
+
+// decode decodes an object of the request into a typed object when its kind is registered, or into an unstructured object.
+func (o options) decode(req *admissionv1.AdmissionRequest, raw []byte) (runtime.Object, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("empty object")
+	}
+
+	gvk := schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind}
+	for _, scheme := range []*runtime.Scheme{o.scheme, clientgoscheme.Scheme} {
+		if scheme == nil || !scheme.Recognizes(gvk) {
+			continue
+		}
+		obj, err := scheme.New(gvk)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, obj); err != nil {
+			return nil, err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		return obj, nil
+	}
+
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(raw, &u.Object); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
@@ -0,0 +1,141 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package admission
+
+import (
+	"fmt"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+const deployment = `{
+	"apiVersion": "apps/v1",
+	"kind": "Deployment",
+	"metadata": {"name": "app", "namespace": "default"},
+	"spec": {
+		"replicas": %d,
+		"selector": {"matchLabels": {"app": "app"}},
+		"template": {
+			"metadata": {"labels": {"app": "app"}},
+			"spec": {"containers": [{"name": "app", "image": "app:%s"}]}
+		}
+	}
+}`
+
+func newRequest(operation admissionv1.Operation, kind v1.GroupVersionKind, object, oldObject string) *admissionv1.AdmissionRequest {
+	req := &admissionv1.AdmissionRequest{
+		UID:       types.UID("uid"),
+		Kind:      kind,
+		Operation: operation,
+		Object:    runtime.RawExtension{Raw: []byte(object)},
+	}
+	if oldObject != "" {
+		req.OldObject = runtime.RawExtension{Raw: []byte(oldObject)}
+	}
+	return req
+}
+
+var deploymentKind = v1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+
+func TestCalculate(t *testing.T) {
+	req := newRequest(admissionv1.Update, deploymentKind, fmt.Sprintf(deployment, 3, "v2"), fmt.Sprintf(deployment, 1, "v1"))
+
+	result, err := Calculate(req)
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+	assert.Equal(t, types.StrategicMergePatchType, result.PatchType())
+
+	result, err = Calculate(req, WithCalculateOptions(patch.IgnoreField("spec")))
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+
+	_, err = Calculate(newRequest(admissionv1.Create, deploymentKind, fmt.Sprintf(deployment, 1, "v1"), ""))
+	assert.Error(t, err)
+}
+
+func TestMutate(t *testing.T) {
+	req := newRequest(admissionv1.Create, deploymentKind, fmt.Sprintf(deployment, 1, "v1"), "")
+
+	response, err := Mutate(req, func(obj runtime.Object) error {
+		deployment := obj.(*appsv1.Deployment)
+		deployment.Spec.Template.Spec.Containers[0].Image = "registry.example.com/app:v1"
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Equal(t, req.UID, response.UID)
+	require.NotNil(t, response.PatchType)
+	assert.Equal(t, admissionv1.PatchTypeJSONPatch, *response.PatchType)
+	assert.Contains(t, string(response.Patch), `{"op":"replace","path":"/spec/template/spec/containers/0/image","value":"registry.example.com/app:v1"}`)
+	patched := mustApplyPatch(t, req.Object.Raw, response.Patch)
+	assert.Equal(t, "registry.example.com/app:v1", patched.Spec.Template.Spec.Containers[0].Image)
+
+	response, err = Mutate(req, func(obj runtime.Object) error { return nil })
+	require.NoError(t, err)
+	assert.True(t, response.Allowed)
+	assert.Nil(t, response.PatchType)
+	assert.Nil(t, response.Patch)
+}
+
+func TestMutatePatchAppliesToTheRawObject(t *testing.T) {
+	req := newRequest(admissionv1.Create, deploymentKind, fmt.Sprintf(deployment, 1, "v1"), "")
+
+	// The raw object has no resources, the typed object marshals them empty
+	response, err := Mutate(req, func(obj runtime.Object) error {
+		deployment := obj.(*appsv1.Deployment)
+		deployment.Spec.Template.Spec.Containers[0].Resources.Limits = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")}
+		return nil
+	})
+	require.NoError(t, err)
+
+	patched := mustApplyPatch(t, req.Object.Raw, response.Patch)
+	assert.Equal(t, "128Mi", patched.Spec.Template.Spec.Containers[0].Resources.Limits.Memory().String())
+}
+
+func mustApplyPatch(t *testing.T, raw, patchData []byte) *appsv1.Deployment {
+	jsonPatch, err := jsonpatch.DecodePatch(patchData)
+	require.NoError(t, err)
+	patchedRaw, err := jsonPatch.Apply(raw)
+	require.NoError(t, err)
+
+	patched := &appsv1.Deployment{}
+	require.NoError(t, json.Unmarshal(patchedRaw, patched))
+	return patched
+}
+
+func TestMutateUnstructured(t *testing.T) {
+	kind := v1.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "App"}
+	req := newRequest(admissionv1.Create, kind, `{"apiVersion":"example.com/v1","kind":"App","metadata":{"name":"app"},"spec":{}}`, "")
+
+	response, err := Mutate(req, func(obj runtime.Object) error {
+		return unstructured.SetNestedField(obj.(*unstructured.Unstructured).Object, int64(2), "spec", "replicas")
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"op":"add","path":"/spec/replicas","value":2}]`, string(response.Patch))
+}
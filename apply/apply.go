@@ -0,0 +1,219 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apply creates or patches objects the way kubectl apply does, on top of the patch package:
+// it reads the current object, calculates the patch against the desired one and sends it with the patch type
+// matching the object, keeping the last-applied annotation up to date.
+package apply
+
+import (
+	"context"
+	"reflect"
+
+	"emperror.dev/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// Client is the subset of a Kubernetes client used by Apply. Get and Create behave like the controller-runtime client,
+// Patch sends the patch and decodes the response into obj.
+type Client interface {
+	Get(ctx context.Context, key types.NamespacedName, obj runtime.Object) error
+	Create(ctx context.Context, obj runtime.Object) error
+	Patch(ctx context.Context, obj runtime.Object, patch Patch) error
+}
+
+// Patch is the request sent by Apply to update an existing object.
+type Patch = patch.PatchRequest
+
+// Operation tells what Apply did with the object.
+type Operation string
+
+const (
+	// OperationCreated means the object didn't exist and has been created.
+	OperationCreated Operation = "Created"
+	// OperationPatched means the object has been patched.
+	OperationPatched Operation = "Patched"
+	// OperationUnchanged means the object is up to date, nothing has been sent.
+	OperationUnchanged Operation = "Unchanged"
+)
+
+// Result describes the outcome of Apply.
+type Result struct {
+	Operation Operation
+	// PatchResult is the patch calculated against the current object, it is nil when the object has been created.
+	PatchResult *patch.PatchResult
+}
+
+type options struct {
+	annotator        *patch.Annotator
+	patchMaker       patch.Maker
+	calculateOptions []patch.CalculateOption
+	backoff          wait.Backoff
+}
+
+// Option customizes Apply.
+type Option func(*options)
+
+// WithAnnotator sets the annotator storing the last-applied configuration, patch.DefaultAnnotator by default.
+// The default patch maker uses it as well.
+func WithAnnotator(annotator *patch.Annotator) Option {
+	return func(o *options) {
+		o.annotator = annotator
+	}
+}
+
+// WithPatchMaker sets the patch maker comparing the current and desired objects. It should use the same annotator
+// as the one set with WithAnnotator, which annotates the created objects.
+func WithPatchMaker(patchMaker patch.Maker) Option {
+	return func(o *options) {
+		o.patchMaker = patchMaker
+	}
+}
+
+// WithCalculateOptions sets the options passed to Calculate.
+func WithCalculateOptions(opts ...patch.CalculateOption) Option {
+	return func(o *options) {
+		o.calculateOptions = append(o.calculateOptions, opts...)
+	}
+}
+
+// WithBackoff sets how Apply retries on conflicts, retry.DefaultRetry by default.
+func WithBackoff(backoff wait.Backoff) Option {
+	return func(o *options) {
+		o.backoff = backoff
+	}
+}
+
+// Apply creates the desired object if it doesn't exist, or patches it when it differs from the current object.
+// The last-applied annotation is set on the created object and updated by the patch. Conflicts, raised when the object
+// is modified or created concurrently, are retried with a fresh current object.
+//
+// Typed objects known by the client-go scheme are patched with a strategic merge patch, the other ones with a JSON
+// merge patch, and server-side apply results with an apply patch. The patches carry the resourceVersion of the current
+// object, so they fail with a conflict instead of overwriting concurrent changes. desired is updated with the object
+// returned by the API server.
+func Apply(ctx context.Context, client Client, desired runtime.Object, opts ...Option) (*Result, error) {
+	o := options{
+		backoff: retry.DefaultRetry,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.annotator == nil {
+		o.annotator = patch.DefaultAnnotator
+	}
+	if o.patchMaker == nil {
+		o.patchMaker = patch.NewPatchMaker(o.annotator, &patch.K8sStrategicMergePatcher{}, &patch.BaseJSONMergePatcher{})
+	}
+
+	metaObject, err := meta.Accessor(desired)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not access the metadata of the desired object")
+	}
+	key := types.NamespacedName{Namespace: metaObject.GetNamespace(), Name: metaObject.GetName()}
+
+	var result *Result
+	err = retry.OnError(o.backoff, isRetriable, func() error {
+		var err error
+		result, err = apply(ctx, client, key, desired, o)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func apply(ctx context.Context, client Client, key types.NamespacedName, desired runtime.Object, o options) (*Result, error) {
+	current, err := newEmptyObject(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.Get(ctx, key, current); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, errors.WrapWithDetails(err, "could not get the current object", "name", key.String())
+		}
+
+		if err := o.annotator.SetLastAppliedAnnotation(desired); err != nil {
+			return nil, errors.Wrap(err, "could not set the last-applied annotation")
+		}
+		if err := client.Create(ctx, desired); err != nil {
+			return nil, errors.WrapWithDetails(err, "could not create the object", "name", key.String())
+		}
+		return &Result{Operation: OperationCreated}, nil
+	}
+
+	patchResult, err := calculate(ctx, o.patchMaker, current, desired, o.calculateOptions)
+	if err != nil {
+		return nil, errors.WrapWithDetails(err, "could not calculate the patch", "name", key.String())
+	}
+	if patchResult.IsEmpty() {
+		return &Result{Operation: OperationUnchanged, PatchResult: patchResult}, nil
+	}
+
+	patched, err := patchResult.Apply(ctx, client, patch.WithResourceVersionCheck())
+	if err != nil {
+		return nil, errors.WrapWithDetails(err, "could not patch the object", "name", key.String())
+	}
+	// The patched object has the type of the current object, which is the type of desired
+	reflect.ValueOf(desired).Elem().Set(reflect.ValueOf(patched).Elem())
+	return &Result{Operation: OperationPatched, PatchResult: patchResult}, nil
+}
+
+// calculate calculates the patch with the context of the caller when the patch maker supports it, so the calls it
+// makes to the API server are canceled with the caller.
+func calculate(ctx context.Context, patchMaker patch.Maker, current, desired runtime.Object, opts []patch.CalculateOption) (*patch.PatchResult, error) {
+	ctxMaker, ok := patchMaker.(patch.CtxMaker)
+	if !ok {
+		return patchMaker.Calculate(current, desired, opts...)
+	}
+
+	ctxOpts := make([]patch.CalculateOptionCtx, 0, len(opts))
+	for _, opt := range opts {
+		ctxOpts = append(ctxOpts, patch.WithoutContext(opt))
+	}
+	return ctxMaker.CalculateWithContext(ctx, current, desired, ctxOpts...)
+}
+
+// newEmptyObject returns an empty object of the same type as obj to read the current object into.
+func newEmptyObject(obj runtime.Object) (runtime.Object, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		empty := &unstructured.Unstructured{}
+		empty.SetGroupVersionKind(u.GroupVersionKind())
+		return empty, nil
+	}
+
+	t := reflect.TypeOf(obj)
+	if t.Kind() != reflect.Ptr {
+		return nil, errors.Errorf("expected a pointer to an object, got %T", obj)
+	}
+	empty, ok := reflect.New(t.Elem()).Interface().(runtime.Object)
+	if !ok {
+		return nil, errors.Errorf("could not create an object of type %T", obj)
+	}
+	return empty, nil
+}
+
+func isRetriable(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+}
@@ -0,0 +1,266 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// fakeClient stores the objects as JSON documents and checks the resourceVersion sent with the patches.
+type fakeClient struct {
+	objects         map[types.NamespacedName][]byte
+	resourceVersion int
+	patches         []Patch
+	// modifyBeforePatch bumps the resourceVersion of the stored object before the next patches, as a concurrent writer would.
+	modifyBeforePatch int
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{objects: map[types.NamespacedName][]byte{}}
+}
+
+func (c *fakeClient) Get(_ context.Context, key types.NamespacedName, obj runtime.Object) error {
+	if _, ok := c.objects[key]; !ok {
+		return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+	}
+	data, err := c.currentWithResourceVersion(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, obj)
+}
+
+func (c *fakeClient) Create(_ context.Context, obj runtime.Object) error {
+	key := keyOf(obj)
+	if _, ok := c.objects[key]; ok {
+		return apierrors.NewAlreadyExists(schema.GroupResource{}, key.Name)
+	}
+	return c.store(key, obj)
+}
+
+func (c *fakeClient) Patch(_ context.Context, obj runtime.Object, p Patch) error {
+	c.patches = append(c.patches, p)
+	key := keyOf(obj)
+	if c.modifyBeforePatch > 0 {
+		c.modifyBeforePatch--
+		c.resourceVersion++
+	}
+
+	current, err := c.currentWithResourceVersion(key)
+	if err != nil {
+		return err
+	}
+	var patchMap map[string]interface{}
+	if err := json.Unmarshal(p.Data, &patchMap); err != nil {
+		return err
+	}
+	if rv, ok := patchMap["metadata"].(map[string]interface{})["resourceVersion"]; ok && rv != strconv.Itoa(c.resourceVersion) {
+		return apierrors.NewConflict(schema.GroupResource{}, key.Name, nil)
+	}
+
+	var patched []byte
+	if p.Type == types.StrategicMergePatchType {
+		patched, err = strategicpatch.StrategicMergePatch(current, p.Data, obj)
+	} else {
+		patched, err = jsonpatch.MergePatch(current, p.Data)
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(patched, obj); err != nil {
+		return err
+	}
+	return c.store(key, obj)
+}
+
+func (c *fakeClient) currentWithResourceVersion(key types.NamespacedName) ([]byte, error) {
+	var current map[string]interface{}
+	if err := json.Unmarshal(c.objects[key], &current); err != nil {
+		return nil, err
+	}
+	current["metadata"].(map[string]interface{})["resourceVersion"] = strconv.Itoa(c.resourceVersion)
+	return json.Marshal(current)
+}
+
+func (c *fakeClient) store(key types.NamespacedName, obj runtime.Object) error {
+	c.resourceVersion++
+	metaObject, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	metaObject.SetResourceVersion(strconv.Itoa(c.resourceVersion))
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	c.objects[key] = data
+	return nil
+}
+
+func keyOf(obj runtime.Object) types.NamespacedName {
+	metaObject, _ := meta.Accessor(obj)
+	return types.NamespacedName{Namespace: metaObject.GetNamespace(), Name: metaObject.GetName()}
+}
+
+func TestApply(t *testing.T) {
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "config",
+				Namespace: "default",
+			},
+			Data: map[string]string{"key": value},
+		}
+	}
+
+	client := newFakeClient()
+
+	result, err := Apply(context.Background(), client, newConfigMap("a"))
+	require.NoError(t, err)
+	assert.Equal(t, OperationCreated, result.Operation)
+
+	current := &corev1.ConfigMap{}
+	require.NoError(t, client.Get(context.Background(), keyOf(newConfigMap("a")), current))
+	assert.Contains(t, current.Annotations, patch.LastAppliedConfig)
+
+	result, err = Apply(context.Background(), client, newConfigMap("a"))
+	require.NoError(t, err)
+	assert.Equal(t, OperationUnchanged, result.Operation)
+	assert.Empty(t, client.patches)
+
+	desired := newConfigMap("b")
+	result, err = Apply(context.Background(), client, desired)
+	require.NoError(t, err)
+	assert.Equal(t, OperationPatched, result.Operation)
+	require.Len(t, client.patches, 1)
+	assert.Equal(t, types.StrategicMergePatchType, client.patches[0].Type)
+	assert.Equal(t, "b", desired.Data["key"])
+
+	// The last-applied annotation has been updated by the patch
+	result, err = Apply(context.Background(), client, newConfigMap("b"))
+	require.NoError(t, err)
+	assert.Equal(t, OperationUnchanged, result.Operation)
+}
+
+func TestApplyRetriesOnConflict(t *testing.T) {
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "config",
+				Namespace: "default",
+			},
+			Data: map[string]string{"key": value},
+		}
+	}
+
+	client := newFakeClient()
+	_, err := Apply(context.Background(), client, newConfigMap("a"))
+	require.NoError(t, err)
+
+	client.modifyBeforePatch = 1
+	result, err := Apply(context.Background(), client, newConfigMap("b"))
+	require.NoError(t, err)
+	assert.Equal(t, OperationPatched, result.Operation)
+	assert.Len(t, client.patches, 2)
+}
+
+func TestApplyUnstructured(t *testing.T) {
+	newApp := func(replicas int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "App",
+			"metadata": map[string]interface{}{
+				"name":      "app",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"replicas": replicas,
+			},
+		}}
+	}
+
+	client := newFakeClient()
+	result, err := Apply(context.Background(), client, newApp(1))
+	require.NoError(t, err)
+	assert.Equal(t, OperationCreated, result.Operation)
+
+	result, err = Apply(context.Background(), client, newApp(2))
+	require.NoError(t, err)
+	assert.Equal(t, OperationPatched, result.Operation)
+	require.Len(t, client.patches, 1)
+	assert.Equal(t, types.MergePatchType, client.patches[0].Type)
+
+	result, err = Apply(context.Background(), client, newApp(2))
+	require.NoError(t, err)
+	assert.Equal(t, OperationUnchanged, result.Operation)
+}
+
+// contextRecordingMaker records the context given to CalculateWithContext.
+type contextRecordingMaker struct {
+	patch.CtxMaker
+	ctx context.Context
+}
+
+func (m *contextRecordingMaker) Calculate(currentObject, modifiedObject runtime.Object, opts ...patch.CalculateOption) (*patch.PatchResult, error) {
+	return m.CtxMaker.(patch.Maker).Calculate(currentObject, modifiedObject, opts...)
+}
+
+func (m *contextRecordingMaker) CalculateWithContext(ctx context.Context, currentObject, modifiedObject runtime.Object, opts ...patch.CalculateOptionCtx) (*patch.PatchResult, error) {
+	m.ctx = ctx
+	return m.CtxMaker.CalculateWithContext(ctx, currentObject, modifiedObject, opts...)
+}
+
+func TestApplyPassesTheContextToThePatchMaker(t *testing.T) {
+	type contextKey struct{}
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "config",
+				Namespace: "default",
+			},
+			Data: map[string]string{"key": value},
+		}
+	}
+
+	client := newFakeClient()
+	_, err := Apply(context.Background(), client, newConfigMap("a"))
+	require.NoError(t, err)
+
+	maker := &contextRecordingMaker{CtxMaker: patch.DefaultPatchMaker.(patch.CtxMaker)}
+	ctx := context.WithValue(context.Background(), contextKey{}, "caller")
+	result, err := Apply(ctx, client, newConfigMap("b"), WithPatchMaker(maker), WithCalculateOptions(patch.IgnoreField("data")))
+	require.NoError(t, err)
+	assert.Equal(t, OperationUnchanged, result.Operation)
+	require.NotNil(t, maker.ctx)
+	assert.Equal(t, "caller", maker.ctx.Value(contextKey{}))
+}
@@ -0,0 +1,169 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// ApplyUnstructured is Apply for unstructured objects with a dynamic client, the RESTMapper resolves the resource
+// of the object. See DynamicClient for the handling of the status subresource.
+func ApplyUnstructured(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, desired *unstructured.Unstructured, opts ...Option) (*Result, error) {
+	return Apply(ctx, DynamicClient(client, mapper), desired, opts...)
+}
+
+// DynamicClient adapts a dynamic client to the Client interface, it only handles unstructured objects.
+//
+// The API server ignores the status of the objects having a status subresource, unless it is sent to the subresource.
+// When the patch maker compares the status, see patch.WithStatusInMainResource, the status changes of the JSON merge
+// patches are sent to the status subresource after the patch of the object, which also makes them work for the objects
+// without a status subresource: the second patch is then a no-op.
+func DynamicClient(client dynamic.Interface, mapper meta.RESTMapper) Client {
+	return dynamicClient{client: client, mapper: mapper}
+}
+
+type dynamicClient struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+}
+
+func (c dynamicClient) Get(ctx context.Context, key types.NamespacedName, obj runtime.Object) error {
+	u, resource, err := c.resourceFor(obj, key.Namespace)
+	if err != nil {
+		return err
+	}
+	current, err := resource.Get(ctx, key.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	u.Object = current.Object
+	return nil
+}
+
+func (c dynamicClient) Create(ctx context.Context, obj runtime.Object) error {
+	u, resource, err := c.resourceFor(obj, "")
+	if err != nil {
+		return err
+	}
+	created, err := resource.Create(ctx, u, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	u.Object = created.Object
+	return nil
+}
+
+func (c dynamicClient) Patch(ctx context.Context, obj runtime.Object, patch Patch) error {
+	u, resource, err := c.resourceFor(obj, "")
+	if err != nil {
+		return err
+	}
+
+	var dryRun []string
+	if patch.DryRun {
+		dryRun = []string{metav1.DryRunAll}
+	}
+
+	if patch.Type == types.ApplyPatchType {
+		patched, err := resource.Patch(ctx, u.GetName(), patch.Type, patch.Data, metav1.PatchOptions{
+			DryRun:       dryRun,
+			FieldManager: patch.FieldManager,
+			Force:        &patch.Force,
+		})
+		if err != nil {
+			return err
+		}
+		u.Object = patched.Object
+		return nil
+	}
+
+	data, status, err := splitStatus(patch.Data)
+	if err != nil {
+		return err
+	}
+
+	patched, err := resource.Patch(ctx, u.GetName(), patch.Type, data, metav1.PatchOptions{DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+
+	if status != nil {
+		// The object has just been patched, the status patch is checked against the new resourceVersion
+		statusPatch, err := json.ConfigCompatibleWithStandardLibrary.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{"resourceVersion": patched.GetResourceVersion()},
+			"status":   status,
+		})
+		if err != nil {
+			return errors.Wrap(err, "could not marshal the status patch")
+		}
+		patched, err = resource.Patch(ctx, u.GetName(), patch.Type, statusPatch, metav1.PatchOptions{DryRun: dryRun}, "status")
+		if err != nil {
+			return err
+		}
+	}
+
+	u.Object = patched.Object
+	return nil
+}
+
+// resourceFor returns the dynamic resource of the object, in the namespace of the object unless namespace is set.
+func (c dynamicClient) resourceFor(obj runtime.Object, namespace string) (*unstructured.Unstructured, dynamic.ResourceInterface, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, nil, errors.Errorf("the dynamic client expects *unstructured.Unstructured objects, got %T", obj)
+	}
+
+	gvk := u.GroupVersionKind()
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, nil, errors.WrapWithDetails(err, "could not find the resource of the object", "kind", gvk.String())
+	}
+
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return u, c.client.Resource(mapping.Resource), nil
+	}
+	if namespace == "" {
+		namespace = u.GetNamespace()
+	}
+	return u, c.client.Resource(mapping.Resource).Namespace(namespace), nil
+}
+
+// splitStatus removes the status from the patch and returns it separately, it is nil when the patch has no status.
+func splitStatus(data []byte) ([]byte, interface{}, error) {
+	var patchMap map[string]interface{}
+	if err := json.Unmarshal(data, &patchMap); err != nil {
+		return nil, nil, errors.Wrap(err, "could not unmarshal the patch")
+	}
+	status, ok := patchMap["status"]
+	if !ok {
+		return data, nil, nil
+	}
+	delete(patchMap, "status")
+
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(patchMap)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not marshal the patch")
+	}
+	return data, status, nil
+}
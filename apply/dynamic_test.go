@@ -0,0 +1,93 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apply
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+func TestApplyUnstructuredWithDynamicClient(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "App"}
+	gvr := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "apps"}
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(gvk, meta.RESTScopeNamespace)
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{gvr: "AppList"})
+
+	newApp := func(replicas int64, phase string) *unstructured.Unstructured {
+		app := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "App",
+			"metadata": map[string]interface{}{
+				"name":      "app",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"replicas": replicas,
+			},
+		}}
+		if phase != "" {
+			app.Object["status"] = map[string]interface{}{"phase": phase}
+		}
+		return app
+	}
+
+	result, err := ApplyUnstructured(context.Background(), client, mapper, newApp(1, ""))
+	require.NoError(t, err)
+	assert.Equal(t, OperationCreated, result.Operation)
+
+	result, err = ApplyUnstructured(context.Background(), client, mapper, newApp(1, ""))
+	require.NoError(t, err)
+	assert.Equal(t, OperationUnchanged, result.Operation)
+
+	client.ClearActions()
+	desired := newApp(2, "Ready")
+	// The status is only compared by the patch makers comparing it with the main resource
+	patchMaker := patch.NewPatchMaker(patch.DefaultAnnotator, &patch.K8sStrategicMergePatcher{}, &patch.BaseJSONMergePatcher{}, patch.WithStatusInMainResource())
+	result, err = ApplyUnstructured(context.Background(), client, mapper, desired, WithPatchMaker(patchMaker))
+	require.NoError(t, err)
+	assert.Equal(t, OperationPatched, result.Operation)
+
+	var patches []clienttesting.PatchAction
+	for _, action := range client.Actions() {
+		if patchAction, ok := action.(clienttesting.PatchAction); ok {
+			patches = append(patches, patchAction)
+		}
+	}
+	require.Len(t, patches, 2)
+	assert.Equal(t, "", patches[0].GetSubresource())
+	assert.NotContains(t, string(patches[0].GetPatch()), "status")
+	assert.Equal(t, "status", patches[1].GetSubresource())
+	assert.Contains(t, string(patches[1].GetPatch()), "Ready")
+
+	current, err := client.Resource(gvr).Namespace("default").Get(context.Background(), "app", v1.GetOptions{})
+	require.NoError(t, err)
+	replicas, _, _ := unstructured.NestedInt64(current.Object, "spec", "replicas")
+	assert.Equal(t, int64(2), replicas)
+	assert.Equal(t, current.GetResourceVersion(), desired.GetResourceVersion())
+}
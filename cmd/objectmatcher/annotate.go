@@ -0,0 +1,132 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"io"
+	"os"
+
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// runAnnotate sets the last-applied annotation on every object of the manifest and writes them to stdout. Objects created
+// outside of the operator can then be patched with their manifest as original configuration, instead of being compared
+// without one on the first reconciliation.
+func runAnnotate(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("annotate", flag.ContinueOnError)
+	filename := flags.String("f", "", "manifest to annotate, - to read the standard input")
+	key := flags.String("annotation", patch.LastAppliedConfig, "annotation storing the original configuration")
+	compressed := flags.Bool("compressed", false, "gzip the original configuration instead of zipping it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *filename == "" {
+		return errors.New("the manifest must be set with -f")
+	}
+
+	input := stdin
+	if *filename != "-" {
+		file, err := os.Open(*filename)
+		if err != nil {
+			return errors.Wrap(err, "could not open the manifest")
+		}
+		defer file.Close()
+		input = file
+	}
+
+	annotator := patch.NewAnnotator(*key)
+	if *compressed {
+		annotator = patch.NewCompressedAnnotator(*key)
+	}
+
+	objects, err := readManifest(input)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if err := annotate(annotator, obj); err != nil {
+			return errors.WrapWithDetails(err, "could not annotate the object", "kind", obj.GetKind(), "name", obj.GetName())
+		}
+	}
+	return writeManifest(stdout, objects)
+}
+
+// annotate sets the last-applied annotation on the object, or on the items of a list.
+func annotate(annotator *patch.Annotator, obj *unstructured.Unstructured) error {
+	if obj.IsList() {
+		return obj.EachListItem(func(item runtime.Object) error {
+			return annotator.SetLastAppliedAnnotation(item)
+		})
+	}
+	return annotator.SetLastAppliedAnnotation(obj)
+}
+
+// readManifest reads the objects of a multi-document YAML or JSON manifest, the empty documents are skipped.
+func readManifest(r io.Reader) ([]*unstructured.Unstructured, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+
+	var objects []*unstructured.Unstructured
+	for {
+		document, err := reader.Read()
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read the manifest")
+		}
+
+		data, err := yaml.YAMLToJSON(document)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not convert the manifest to JSON")
+		}
+		if len(bytes.TrimSpace(data)) == 0 || string(data) == "null" {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(data); err != nil {
+			return nil, errors.Wrap(err, "could not decode the manifest")
+		}
+		objects = append(objects, obj)
+	}
+}
+
+// writeManifest writes the objects as a multi-document YAML manifest.
+func writeManifest(w io.Writer, objects []*unstructured.Unstructured) error {
+	for i, obj := range objects {
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		data, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal the object")
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
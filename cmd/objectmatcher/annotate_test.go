@@ -0,0 +1,86 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+const manifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: default
+data:
+  key: value
+---
+---
+apiVersion: example.com/v1
+kind: App
+metadata:
+  name: app
+spec:
+  replicas: 1
+`
+
+func TestAnnotate(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		args := []string{"-f", "-"}
+		if compressed {
+			args = append(args, "--compressed")
+		}
+
+		var out bytes.Buffer
+		require.NoError(t, runAnnotate(args, strings.NewReader(manifest), &out))
+
+		annotated, err := readManifest(&out)
+		require.NoError(t, err)
+		require.Len(t, annotated, 2)
+
+		desired, err := readManifest(strings.NewReader(manifest))
+		require.NoError(t, err)
+
+		for i, obj := range annotated {
+			assert.Contains(t, obj.GetAnnotations(), patch.LastAppliedConfig)
+
+			original, err := patch.DefaultAnnotator.GetOriginalConfiguration(obj)
+			require.NoError(t, err)
+			assert.NotEmpty(t, original)
+
+			result, err := patch.DefaultPatchMaker.Calculate(obj, desired[i])
+			require.NoError(t, err)
+			assert.True(t, result.IsEmpty(), result.String())
+		}
+	}
+}
+
+func TestAnnotateCustomAnnotation(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, runAnnotate([]string{"-f", "-", "--annotation", "example.com/original"}, strings.NewReader(manifest), &out))
+
+	annotated, err := readManifest(&out)
+	require.NoError(t, err)
+	assert.Contains(t, annotated[0].GetAnnotations(), "example.com/original")
+	assert.NotContains(t, annotated[0].GetAnnotations(), patch.LastAppliedConfig)
+
+	assert.Error(t, runAnnotate(nil, strings.NewReader(manifest), &out))
+}
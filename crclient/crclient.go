@@ -0,0 +1,131 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crclient integrates the patch and apply packages with controller-runtime.
+package crclient
+
+import (
+	"context"
+	"reflect"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/disaster37/k8s-objectmatcher/apply"
+)
+
+// CreateOrPatch is like controllerutil.CreateOrUpdate, but compares the objects with a three-way merge
+// and sends a patch instead of an update, see apply.Apply.
+//
+// The object must hold the name and the namespace. Unlike CreateOrUpdate, f isn't called on the current object:
+// it sets the desired state on obj, which may hold nothing but the key, and the fields it doesn't manage are left as they
+// are on the current object. Once done obj holds the object as stored by the API server.
+func CreateOrPatch(ctx context.Context, c client.Client, obj client.Object, f controllerutil.MutateFn, opts ...apply.Option) (controllerutil.OperationResult, error) {
+	key := client.ObjectKeyFromObject(obj)
+	if err := f(); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if key != client.ObjectKeyFromObject(obj) {
+		return controllerutil.OperationResultNone, errors.New("MutateFn cannot mutate object name and/or object namespace")
+	}
+
+	result, err := apply.Apply(ctx, ApplyClient(c), obj, opts...)
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	switch result.Operation {
+	case apply.OperationCreated:
+		return controllerutil.OperationResultCreated, nil
+	case apply.OperationPatched:
+		return controllerutil.OperationResultUpdated, nil
+	}
+
+	// Nothing has been sent, obj still holds the desired state
+	if err := copyInto(obj, result.PatchResult.Patched); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	return controllerutil.OperationResultNone, nil
+}
+
+// ApplyClient adapts a controller-runtime client to the apply.Client interface.
+func ApplyClient(c client.Client) apply.Client {
+	return applyClient{client: c}
+}
+
+type applyClient struct {
+	client client.Client
+}
+
+func (c applyClient) Get(ctx context.Context, key types.NamespacedName, obj runtime.Object) error {
+	clientObject, err := asClientObject(obj)
+	if err != nil {
+		return err
+	}
+	return c.client.Get(ctx, key, clientObject)
+}
+
+func (c applyClient) Create(ctx context.Context, obj runtime.Object) error {
+	clientObject, err := asClientObject(obj)
+	if err != nil {
+		return err
+	}
+	return c.client.Create(ctx, clientObject)
+}
+
+func (c applyClient) Patch(ctx context.Context, obj runtime.Object, patch apply.Patch) error {
+	clientObject, err := asClientObject(obj)
+	if err != nil {
+		return err
+	}
+
+	var opts []client.PatchOption
+	if patch.FieldManager != "" {
+		opts = append(opts, client.FieldOwner(patch.FieldManager))
+	}
+	if patch.Force {
+		opts = append(opts, client.ForceOwnership)
+	}
+	if patch.DryRun {
+		opts = append(opts, client.DryRunAll)
+	}
+	return c.client.Patch(ctx, clientObject, client.RawPatch(patch.Type, patch.Data), opts...)
+}
+
+func asClientObject(obj runtime.Object) (client.Object, error) {
+	clientObject, ok := obj.(client.Object)
+	if !ok {
+		return nil, errors.Errorf("%T is not a client.Object", obj)
+	}
+	return clientObject, nil
+}
+
+// copyInto replaces the content of obj by the content of source, an object of the same type.
+func copyInto(obj client.Object, source interface{}) error {
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(source)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal the current object")
+	}
+	// Unmarshaling merges the maps, start from an empty object
+	value := reflect.ValueOf(obj).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := json.Unmarshal(data, obj); err != nil {
+		return errors.Wrap(err, "could not unmarshal the current object")
+	}
+	return nil
+}
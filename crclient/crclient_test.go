@@ -0,0 +1,106 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+func TestCreateOrPatch(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().Build()
+
+	createOrPatch := func(value string) (*corev1.ConfigMap, controllerutil.OperationResult) {
+		configMap := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default"}}
+		result, err := CreateOrPatch(ctx, c, configMap, func() error {
+			configMap.Data = map[string]string{"key": value}
+			return nil
+		})
+		require.NoError(t, err)
+		return configMap, result
+	}
+
+	configMap, result := createOrPatch("a")
+	assert.Equal(t, controllerutil.OperationResultCreated, result)
+	assert.Contains(t, configMap.Annotations, patch.LastAppliedConfig)
+
+	// Fields set by others are kept
+	configMap.Labels = map[string]string{"team": "platform"}
+	require.NoError(t, c.Update(ctx, configMap))
+
+	configMap, result = createOrPatch("a")
+	assert.Equal(t, controllerutil.OperationResultNone, result)
+	assert.Equal(t, "platform", configMap.Labels["team"])
+
+	configMap, result = createOrPatch("b")
+	assert.Equal(t, controllerutil.OperationResultUpdated, result)
+	assert.Equal(t, "b", configMap.Data["key"])
+	assert.Equal(t, "platform", configMap.Labels["team"])
+
+	stored := &corev1.ConfigMap{}
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(configMap), stored))
+	assert.Equal(t, "b", stored.Data["key"])
+
+	configMap = &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default"}}
+	_, err := CreateOrPatch(ctx, c, configMap, func() error {
+		configMap.Name = "renamed"
+		return nil
+	})
+	assert.Error(t, err)
+}
+
+func TestPredicates(t *testing.T) {
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default"},
+			Data:       map[string]string{"key": value},
+		}
+	}
+
+	unmanaged := newConfigMap("a")
+	managed := newConfigMap("a")
+	require.NoError(t, patch.DefaultAnnotator.SetLastAppliedAnnotation(managed))
+
+	hasLastApplied := HasLastApplied(patch.DefaultAnnotator)
+	assert.False(t, hasLastApplied.Create(event.CreateEvent{Object: unmanaged}))
+	assert.True(t, hasLastApplied.Create(event.CreateEvent{Object: managed}))
+
+	drifted := DriftedFromLastApplied(patch.DefaultAnnotator)
+	assert.True(t, drifted.Update(event.UpdateEvent{ObjectOld: unmanaged, ObjectNew: unmanaged}))
+
+	labeled := managed.DeepCopy()
+	labeled.Labels = map[string]string{"team": "platform"}
+	assert.False(t, drifted.Update(event.UpdateEvent{ObjectOld: managed, ObjectNew: labeled}))
+
+	modified := managed.DeepCopy()
+	modified.Data["key"] = "b"
+	assert.True(t, drifted.Update(event.UpdateEvent{ObjectOld: managed, ObjectNew: modified}))
+
+	reapplied := newConfigMap("b")
+	require.NoError(t, patch.DefaultAnnotator.SetLastAppliedAnnotation(reapplied))
+	assert.False(t, drifted.Update(event.UpdateEvent{ObjectOld: managed, ObjectNew: reapplied}))
+}
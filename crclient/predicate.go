@@ -0,0 +1,84 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crclient
+
+import (
+	"reflect"
+
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// HasLastApplied filters the events of the objects holding the last-applied annotation of the annotator,
+// i.e. the objects created or patched with this library.
+func HasLastApplied(annotator *patch.Annotator) predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		original, err := annotator.GetOriginalConfiguration(obj)
+		return err != nil || original != nil
+	})
+}
+
+// DriftedFromLastApplied filters the update events of the objects whose fields set in their last-applied configuration
+// have been modified by someone else. The updates made with this library, which also update the annotation,
+// and the updates of other fields such as the status are filtered out. The objects without annotation, and the objects
+// whose annotation can't be read, always pass. The options are passed to Calculate, to ignore defaulted fields for example.
+// The create, delete and generic events pass.
+func DriftedFromLastApplied(annotator *patch.Annotator, opts ...patch.CalculateOption) predicate.Funcs {
+	patchMaker := patch.NewPatchMaker(annotator, &patch.K8sStrategicMergePatcher{}, &patch.BaseJSONMergePatcher{})
+
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectNew == nil {
+				return true
+			}
+			original, err := annotator.GetOriginalConfiguration(e.ObjectNew)
+			if err != nil || original == nil {
+				return true
+			}
+
+			lastApplied, err := newObjectFromJSON(e.ObjectNew, original)
+			if err != nil {
+				return true
+			}
+			// Compare against the last-applied configuration: the patch reverts what has drifted from it
+			result, err := patchMaker.Calculate(e.ObjectNew, lastApplied, opts...)
+			return err != nil || !result.IsEmpty()
+		},
+	}
+}
+
+// newObjectFromJSON decodes data into a new object of the same type as obj.
+func newObjectFromJSON(obj client.Object, data []byte) (runtime.Object, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		decoded := &unstructured.Unstructured{}
+		if err := json.Unmarshal(data, &decoded.Object); err != nil {
+			return nil, err
+		}
+		decoded.SetGroupVersionKind(u.GroupVersionKind())
+		return decoded, nil
+	}
+
+	decoded := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(runtime.Object)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
@@ -0,0 +1,169 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package drift classifies the differences found by the patch package, so controllers can react differently
+// to cosmetic and structural drift.
+package drift
+
+import (
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// Category is the kind of drift between the current and the modified object.
+type Category string
+
+const (
+	// CategoryNone means there is no drift.
+	CategoryNone Category = "None"
+	// CategoryMetadataOnly means only the metadata (labels, annotations...) drifted.
+	CategoryMetadataOnly Category = "MetadataOnly"
+	// CategoryScaleOnly means only the replicas, and possibly the metadata, drifted.
+	CategoryScaleOnly Category = "ScaleOnly"
+	// CategorySpec means the desired state drifted.
+	CategorySpec Category = "Spec"
+	// CategoryImmutableField means an immutable field drifted, the object can't be patched in place.
+	CategoryImmutableField Category = "ImmutableField"
+)
+
+// Severity orders the categories from cosmetic to structural drift.
+type Severity int
+
+const (
+	SeverityNone Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityNone:
+		return "None"
+	case SeverityLow:
+		return "Low"
+	case SeverityMedium:
+		return "Medium"
+	case SeverityHigh:
+		return "High"
+	case SeverityCritical:
+		return "Critical"
+	}
+	return "Unknown"
+}
+
+var categorySeverities = map[Category]Severity{
+	CategoryNone:           SeverityNone,
+	CategoryMetadataOnly:   SeverityLow,
+	CategoryScaleOnly:      SeverityMedium,
+	CategorySpec:           SeverityHigh,
+	CategoryImmutableField: SeverityCritical,
+}
+
+// DriftReport is the classification of the changes of a patch.
+type DriftReport struct {
+	Category Category
+	Severity Severity
+
+	// Changes are all the changes of the patch.
+	Changes []patch.FieldChange
+	// ImmutableChanges are the changes touching immutable fields.
+	ImmutableChanges []patch.FieldChange
+}
+
+// HasDrift tells whether the current object drifted from the modified object.
+func (r *DriftReport) HasDrift() bool {
+	return r.Category != CategoryNone
+}
+
+type config struct {
+	immutablePaths []string
+	scalePaths     []string
+}
+
+// Option customizes the classification.
+type Option func(*config)
+
+// WithImmutablePaths declares fields which can't be changed in place, in addition to the ones the patch package knows
+// about (see patch.PatchResult.ImmutableChanges), using the path syntax of patch.IgnoreJSONPath.
+func WithImmutablePaths(paths ...string) Option {
+	return func(c *config) {
+		c.immutablePaths = append(c.immutablePaths, paths...)
+	}
+}
+
+// WithScalePaths replaces the fields considered as scaling, .spec.replicas by default.
+func WithScalePaths(paths ...string) Option {
+	return func(c *config) {
+		c.scalePaths = paths
+	}
+}
+
+// Classify classifies the changes of the patch result.
+func Classify(result *patch.PatchResult, opts ...Option) *DriftReport {
+	c := &config{
+		scalePaths: []string{".spec.replicas"},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	report := &DriftReport{
+		Changes: result.Changes(),
+	}
+
+	immutable := map[string]bool{}
+	for _, change := range result.ImmutableChanges {
+		immutable[change.Path] = true
+	}
+
+	metadataOnly, scaleOnly := true, true
+	for _, change := range report.Changes {
+		if immutable[change.Path] || isUnderAny(change, c.immutablePaths) {
+			report.ImmutableChanges = append(report.ImmutableChanges, change)
+		}
+		if change.IsUnder(".metadata") {
+			continue
+		}
+		metadataOnly = false
+		if !isUnderAny(change, c.scalePaths) {
+			scaleOnly = false
+		}
+	}
+
+	switch {
+	case len(report.Changes) == 0:
+		report.Category = CategoryNone
+	case len(report.ImmutableChanges) > 0:
+		report.Category = CategoryImmutableField
+	case metadataOnly:
+		report.Category = CategoryMetadataOnly
+	case scaleOnly:
+		report.Category = CategoryScaleOnly
+	default:
+		report.Category = CategorySpec
+	}
+	report.Severity = categorySeverities[report.Category]
+
+	return report
+}
+
+func isUnderAny(change patch.FieldChange, paths []string) bool {
+	for _, path := range paths {
+		if change.IsUnder(path) {
+			return true
+		}
+	}
+	return false
+}
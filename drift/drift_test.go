@@ -0,0 +1,125 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package drift
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+func newDeployment() *appsv1.Deployment {
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &v1.LabelSelector{
+				MatchLabels: map[string]string{"app": "nginx"},
+			},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "nginx:1.22"}},
+				},
+			},
+		},
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name         string
+		modify       func(*appsv1.Deployment)
+		opts         []Option
+		wantCategory Category
+		wantSeverity Severity
+	}{
+		{
+			name:         "no drift",
+			modify:       func(d *appsv1.Deployment) {},
+			wantCategory: CategoryNone,
+			wantSeverity: SeverityNone,
+		},
+		{
+			name: "metadata only",
+			modify: func(d *appsv1.Deployment) {
+				d.Labels = map[string]string{"team": "a"}
+			},
+			wantCategory: CategoryMetadataOnly,
+			wantSeverity: SeverityLow,
+		},
+		{
+			name: "scale only",
+			modify: func(d *appsv1.Deployment) {
+				replicas := int32(3)
+				d.Spec.Replicas = &replicas
+			},
+			wantCategory: CategoryScaleOnly,
+			wantSeverity: SeverityMedium,
+		},
+		{
+			name: "spec",
+			modify: func(d *appsv1.Deployment) {
+				replicas := int32(3)
+				d.Spec.Replicas = &replicas
+				d.Spec.Template.Spec.Containers[0].Image = "nginx:1.23"
+			},
+			wantCategory: CategorySpec,
+			wantSeverity: SeverityHigh,
+		},
+		{
+			name: "custom immutable field",
+			modify: func(d *appsv1.Deployment) {
+				d.Spec.MinReadySeconds = 10
+			},
+			opts:         []Option{WithImmutablePaths(".spec.minReadySeconds")},
+			wantCategory: CategoryImmutableField,
+			wantSeverity: SeverityCritical,
+		},
+		{
+			name: "immutable field",
+			modify: func(d *appsv1.Deployment) {
+				d.Spec.Selector.MatchLabels["app"] = "other"
+			},
+			wantCategory: CategoryImmutableField,
+			wantSeverity: SeverityCritical,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := newDeployment()
+			assert.NoError(t, patch.DefaultAnnotator.SetLastAppliedAnnotation(current))
+			modified := newDeployment()
+			tt.modify(modified)
+
+			result, err := patch.DefaultPatchMaker.Calculate(current, modified)
+			assert.NoError(t, err)
+
+			report := Classify(result, tt.opts...)
+			assert.Equal(t, tt.wantCategory, report.Category)
+			assert.Equal(t, tt.wantSeverity, report.Severity)
+			assert.Equal(t, tt.wantCategory != CategoryNone, report.HasDrift())
+		})
+	}
+}
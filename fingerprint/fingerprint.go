@@ -0,0 +1,56 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fingerprint computes stable hashes of objects, normalized like the patch package normalizes them before
+// comparing, to check objects for equality without calculating a patch.
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// Prefix of the hashes returned by Hash.
+const Prefix = "sha256:"
+
+// Hash returns a stable hash of the object after cleaning its metadata (see patch.CleanMetadata), applying the
+// options and removing the null fields, see patch.Normalize. The options receive the object as both the current and the
+// modified object, the modified one is hashed. Two objects with the same hash are compared by Calculate with the same
+// options as equal, as long as no original configuration is involved.
+func Hash(obj runtime.Object, opts ...patch.CalculateOption) (string, error) {
+	document, err := patch.Normalize(obj, append([]patch.CalculateOption{patch.CleanMetadata()}, opts...)...)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(document)
+	return Prefix + hex.EncodeToString(sum[:]), nil
+}
+
+// Equal tells whether the objects have the same hash with the given options.
+func Equal(a, b runtime.Object, opts ...patch.CalculateOption) (bool, error) {
+	hashA, err := Hash(a, opts...)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := Hash(b, opts...)
+	if err != nil {
+		return false, err
+	}
+	return hashA == hashB, nil
+}
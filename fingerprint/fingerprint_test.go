@@ -0,0 +1,81 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+func newPod(image string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "pod",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "nginx", "tier": "web"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: image}},
+		},
+	}
+}
+
+func TestHash(t *testing.T) {
+	hash, err := Hash(newPod("nginx:1.22"))
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, Prefix))
+
+	// Stable across calls, whatever the map ordering
+	for i := 0; i < 10; i++ {
+		again, err := Hash(newPod("nginx:1.22"))
+		assert.NoError(t, err)
+		assert.Equal(t, hash, again)
+	}
+
+	other, err := Hash(newPod("nginx:1.23"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+
+	// Metadata set by the API server and the status are ignored
+	pod := newPod("nginx:1.22")
+	pod.ResourceVersion = "12"
+	pod.CreationTimestamp = v1.Now()
+	pod.Status.Phase = corev1.PodRunning
+	equal, err := Equal(newPod("nginx:1.22"), pod, patch.IgnoreStatusFields())
+	assert.NoError(t, err)
+	assert.True(t, equal)
+}
+
+func TestHashWithOptions(t *testing.T) {
+	pod := newPod("nginx:1.22")
+	pod.Spec.Containers[0].Image = "nginx:1.23"
+
+	equal, err := Equal(newPod("nginx:1.22"), pod)
+	assert.NoError(t, err)
+	assert.False(t, equal)
+
+	equal, err = Equal(newPod("nginx:1.22"), pod, patch.IgnoreJSONPath(".spec.containers[*].image"))
+	assert.NoError(t, err)
+	assert.True(t, equal)
+
+	_, err = Hash(pod, patch.IgnoreJSONPath(".spec["))
+	assert.Error(t, err)
+}
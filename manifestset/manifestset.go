@@ -0,0 +1,221 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package manifestset compares a set of desired objects, e.g. a rendered Helm chart or kustomization, with the live objects
+// and tells which objects must be created, patched or pruned.
+package manifestset
+
+import (
+	"fmt"
+
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// Key identifies an object of a set. Objects are matched by group, kind, namespace and name: the version is the one of
+// the object, kept for display, as the API server serves the same object in every version of its kind.
+type Key struct {
+	GVK       schema.GroupVersionKind
+	Namespace string
+	Name      string
+}
+
+// objectID is the part of a Key identifying an object, without the version.
+type objectID struct {
+	groupKind schema.GroupKind
+	namespace string
+	name      string
+}
+
+func (k Key) id() objectID {
+	return objectID{groupKind: k.GVK.GroupKind(), namespace: k.Namespace, name: k.Name}
+}
+
+func (k Key) String() string {
+	if k.Namespace == "" {
+		return fmt.Sprintf("%s %s", k.GVK.String(), k.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", k.GVK.String(), k.Namespace, k.Name)
+}
+
+// ObjectPatch is the comparison of a desired object with its live object.
+type ObjectPatch struct {
+	Key     Key
+	Current runtime.Object
+	Desired runtime.Object
+	Result  *patch.PatchResult
+}
+
+// Result lists what must be done to turn the live objects into the desired ones.
+type Result struct {
+	// Create holds the desired objects without live object, in the order of the desired set.
+	Create []runtime.Object
+	// Patches holds the desired objects with a live object, including the ones which don't need to be patched.
+	Patches []ObjectPatch
+	// Prune holds the live objects without desired object, in the order of the live set.
+	Prune []runtime.Object
+
+	// createKeys and pruneKeys are the keys of the objects of Create and Prune.
+	createKeys []Key
+	pruneKeys  []Key
+}
+
+// Changed returns the patches which aren't empty.
+func (r *Result) Changed() []ObjectPatch {
+	var changed []ObjectPatch
+	for _, objectPatch := range r.Patches {
+		if !objectPatch.Result.IsEmpty() {
+			changed = append(changed, objectPatch)
+		}
+	}
+	return changed
+}
+
+// IsEmpty tells whether the live objects are in sync with the desired ones.
+func (r *Result) IsEmpty() bool {
+	return len(r.Create) == 0 && len(r.Prune) == 0 && len(r.Changed()) == 0
+}
+
+type options struct {
+	patchMaker       patch.Maker
+	calculateOptions []patch.CalculateOption
+	scheme           *runtime.Scheme
+	owned            OwnerSelector
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		patchMaker: patch.DefaultPatchMaker,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Option customizes Compare.
+type Option func(*options)
+
+// WithPatchMaker sets the patch maker comparing the objects, patch.DefaultPatchMaker by default.
+func WithPatchMaker(patchMaker patch.Maker) Option {
+	return func(o *options) {
+		o.patchMaker = patchMaker
+	}
+}
+
+// WithCalculateOptions sets the options passed to Calculate.
+func WithCalculateOptions(opts ...patch.CalculateOption) Option {
+	return func(o *options) {
+		o.calculateOptions = append(o.calculateOptions, opts...)
+	}
+}
+
+// WithScheme sets the scheme resolving the kind of the typed objects without TypeMeta, in addition to the client-go scheme.
+func WithScheme(scheme *runtime.Scheme) Option {
+	return func(o *options) {
+		o.scheme = scheme
+	}
+}
+
+// Compare matches the desired and live objects by group, kind, namespace and name, whatever their version, and calculates the patches of the matching
+// objects concurrently with CalculateAll. A desired object without live object is to create, and a live object without
+// desired object is to prune. The live set should therefore only hold the objects managed by the caller, or the pruned objects
+// be restricted to them with WithOwnerSelector.
+func Compare(desired, live []runtime.Object, opts ...Option) (*Result, error) {
+	o := newOptions(opts)
+
+	liveObjects := make(map[objectID]runtime.Object, len(live))
+	for _, obj := range live {
+		key, err := o.keyOf(obj)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := liveObjects[key.id()]; ok {
+			return nil, errors.Errorf("duplicate live object %s", key)
+		}
+		liveObjects[key.id()] = obj
+	}
+
+	result := &Result{}
+	desiredKeys := make(map[objectID]bool, len(desired))
+	var pairs []patch.ObjectPair
+	for _, obj := range desired {
+		key, err := o.keyOf(obj)
+		if err != nil {
+			return nil, err
+		}
+		if desiredKeys[key.id()] {
+			return nil, errors.Errorf("duplicate desired object %s", key)
+		}
+		desiredKeys[key.id()] = true
+
+		current, ok := liveObjects[key.id()]
+		if !ok {
+			result.Create = append(result.Create, obj)
+			result.createKeys = append(result.createKeys, key)
+			continue
+		}
+		result.Patches = append(result.Patches, ObjectPatch{Key: key, Current: current, Desired: obj})
+		pairs = append(pairs, patch.ObjectPair{Current: current, Modified: obj})
+	}
+
+	var err error
+	result.Prune, result.pruneKeys, err = o.orphans(live, desiredKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pairs) > 0 {
+		patchResults, err := patch.CalculateAll(o.patchMaker, pairs, o.calculateOptions...)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not calculate the patches")
+		}
+		for i := range result.Patches {
+			result.Patches[i].Result = patchResults[i]
+		}
+	}
+
+	return result, nil
+}
+
+// keyOf returns the key of the object, its kind is resolved from the schemes when it has no TypeMeta.
+func (o options) keyOf(obj runtime.Object) (Key, error) {
+	metaObject, err := meta.Accessor(obj)
+	if err != nil {
+		return Key{}, errors.Wrap(err, "could not access the metadata of the object")
+	}
+
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		for _, scheme := range []*runtime.Scheme{o.scheme, clientgoscheme.Scheme} {
+			if scheme == nil {
+				continue
+			}
+			if gvks, _, err := scheme.ObjectKinds(obj); err == nil && len(gvks) > 0 {
+				gvk = gvks[0]
+				break
+			}
+		}
+	}
+	if gvk.Empty() {
+		return Key{}, errors.Errorf("the kind of %T %s is unknown", obj, metaObject.GetName())
+	}
+
+	return Key{GVK: gvk, Namespace: metaObject.GetNamespace(), Name: metaObject.GetName()}, nil
+}
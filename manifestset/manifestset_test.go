@@ -0,0 +1,167 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+func newConfigMap(name, value string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Data: map[string]string{"key": value},
+	}
+}
+
+func newApp(name string, replicas int64) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "App",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	}}
+}
+
+func annotated(obj runtime.Object) runtime.Object {
+	if err := patch.DefaultAnnotator.SetLastAppliedAnnotation(obj); err != nil {
+		panic(err)
+	}
+	return obj
+}
+
+func TestCompare(t *testing.T) {
+	desired := []runtime.Object{
+		newConfigMap("unchanged", "a"),
+		newConfigMap("changed", "b"),
+		newConfigMap("new", "a"),
+		newApp("app", 2),
+	}
+	live := []runtime.Object{
+		annotated(newConfigMap("unchanged", "a")),
+		annotated(newConfigMap("changed", "a")),
+		annotated(newConfigMap("removed", "a")),
+		annotated(newApp("app", 1)),
+		// Same name, other kind
+		annotated(newApp("new", 1)),
+	}
+
+	result, err := Compare(desired, live)
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	require.Len(t, result.Create, 1)
+	assert.Equal(t, "new", result.Create[0].(*corev1.ConfigMap).Name)
+
+	require.Len(t, result.Prune, 2)
+	assert.Equal(t, "removed", result.Prune[0].(*corev1.ConfigMap).Name)
+	assert.Equal(t, "new", result.Prune[1].(*unstructured.Unstructured).GetName())
+
+	require.Len(t, result.Patches, 3)
+	assert.Equal(t, Key{GVK: corev1.SchemeGroupVersion.WithKind("ConfigMap"), Namespace: "default", Name: "unchanged"}, result.Patches[0].Key)
+	assert.True(t, result.Patches[0].Result.IsEmpty())
+
+	changed := result.Changed()
+	require.Len(t, changed, 2)
+	assert.Equal(t, "changed", changed[0].Key.Name)
+	assert.Equal(t, "app", changed[1].Key.Name)
+	assert.Equal(t, "example.com/v1, Kind=App default/app", changed[1].Key.String())
+}
+
+func TestCompareInSync(t *testing.T) {
+	result, err := Compare(
+		[]runtime.Object{newConfigMap("config", "a")},
+		[]runtime.Object{annotated(newConfigMap("config", "a"))},
+	)
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+
+	_, err = Compare([]runtime.Object{newConfigMap("config", "a"), newConfigMap("config", "b")}, nil)
+	assert.Error(t, err)
+}
+
+func TestCompareVersionSkew(t *testing.T) {
+	// The live object is read in another version of its kind
+	liveApp := annotated(newApp("app", 1)).(*unstructured.Unstructured)
+	liveApp.SetAPIVersion("example.com/v1beta1")
+
+	result, err := Compare([]runtime.Object{newApp("app", 2)}, []runtime.Object{liveApp})
+	require.NoError(t, err)
+	assert.Empty(t, result.Create)
+	assert.Empty(t, result.Prune)
+	require.Len(t, result.Patches, 1)
+	assert.Equal(t, "example.com/v1, Kind=App default/app", result.Patches[0].Key.String())
+	assert.Same(t, liveApp, result.Patches[0].Current)
+
+	orphans, err := DetectOrphans([]runtime.Object{liveApp}, []runtime.Object{newApp("app", 2)}, func(v1.Object) bool { return true })
+	require.NoError(t, err)
+	assert.Empty(t, orphans)
+}
+
+func TestDetectOrphans(t *testing.T) {
+	owned := func(obj runtime.Object, managedBy string) runtime.Object {
+		obj.(v1.Object).SetLabels(map[string]string{"app.kubernetes.io/managed-by": managedBy})
+		return obj
+	}
+	desired := []runtime.Object{
+		newConfigMap("kept", "a"),
+	}
+	live := []runtime.Object{
+		owned(newConfigMap("kept", "a"), "operator"),
+		owned(newConfigMap("orphan", "a"), "operator"),
+		owned(newConfigMap("foreign", "a"), "helm"),
+		newConfigMap("unlabeled", "a"),
+		owned(newApp("orphan-app", 1), "operator"),
+	}
+
+	selector, err := labels.Parse("app.kubernetes.io/managed-by=operator")
+	require.NoError(t, err)
+
+	orphans, err := DetectOrphans(live, desired, OwnedByLabels(selector))
+	require.NoError(t, err)
+	require.Len(t, orphans, 2)
+	assert.Equal(t, "orphan", orphans[0].(v1.Object).GetName())
+	assert.Equal(t, "orphan-app", orphans[1].(v1.Object).GetName())
+
+	result, err := Compare(desired, live, WithOwnerSelector(OwnedByLabels(selector)))
+	require.NoError(t, err)
+	assert.Equal(t, orphans, result.Prune)
+}
+
+func TestOwnedByAnnotation(t *testing.T) {
+	obj := newConfigMap("owned", "a")
+	obj.Annotations = map[string]string{"example.com/owner": "operator"}
+
+	assert.True(t, OwnedByAnnotation("example.com/owner", "operator")(obj))
+	assert.False(t, OwnedByAnnotation("example.com/owner", "other")(obj))
+	assert.False(t, OwnedByAnnotation("example.com/other", "operator")(newConfigMap("unowned", "a")))
+}
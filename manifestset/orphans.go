@@ -0,0 +1,95 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestset
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// OwnerSelector tells whether a live object is owned by the caller, usually from the ownership labels or annotations
+// set on the objects it creates.
+type OwnerSelector func(obj metav1.Object) bool
+
+// OwnedByLabels selects the objects whose labels match the selector, e.g. app.kubernetes.io/managed-by=my-operator.
+func OwnedByLabels(selector labels.Selector) OwnerSelector {
+	return func(obj metav1.Object) bool {
+		return selector.Matches(labels.Set(obj.GetLabels()))
+	}
+}
+
+// OwnedByAnnotation selects the objects having the annotation with the given value.
+func OwnedByAnnotation(key, value string) OwnerSelector {
+	return func(obj metav1.Object) bool {
+		annotationValue, ok := obj.GetAnnotations()[key]
+		return ok && annotationValue == value
+	}
+}
+
+// WithOwnerSelector restricts the objects Compare prunes to the live objects owned by the caller, see DetectOrphans.
+// The other live objects without desired object are ignored.
+func WithOwnerSelector(owned OwnerSelector) Option {
+	return func(o *options) {
+		o.owned = owned
+	}
+}
+
+// DetectOrphans returns the live objects owned by the caller but absent from the desired set, which can be garbage collected.
+// The objects are matched like Compare does, and returned in the order of the live set.
+func DetectOrphans(live, desired []runtime.Object, owned OwnerSelector, opts ...Option) ([]runtime.Object, error) {
+	o := newOptions(append(opts[:len(opts):len(opts)], WithOwnerSelector(owned)))
+
+	desiredKeys := make(map[objectID]bool, len(desired))
+	for _, obj := range desired {
+		key, err := o.keyOf(obj)
+		if err != nil {
+			return nil, err
+		}
+		desiredKeys[key.id()] = true
+	}
+
+	orphans, _, err := o.orphans(live, desiredKeys)
+	return orphans, err
+}
+
+// orphans returns the live objects without desired object and their keys, restricted to the owned ones when an owner
+// selector is set.
+func (o options) orphans(live []runtime.Object, desiredKeys map[objectID]bool) ([]runtime.Object, []Key, error) {
+	var orphans []runtime.Object
+	var keys []Key
+	for _, obj := range live {
+		key, err := o.keyOf(obj)
+		if err != nil {
+			return nil, nil, err
+		}
+		if desiredKeys[key.id()] {
+			continue
+		}
+		if o.owned != nil {
+			metaObject, err := meta.Accessor(obj)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !o.owned(metaObject) {
+				continue
+			}
+		}
+		orphans = append(orphans, obj)
+		keys = append(keys, key)
+	}
+	return orphans, keys, nil
+}
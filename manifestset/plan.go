@@ -0,0 +1,171 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestset
+
+import (
+	"fmt"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// ActionType is the kind of change an action makes to an object.
+type ActionType string
+
+const (
+	ActionCreate   ActionType = "create"
+	ActionPatch    ActionType = "patch"
+	ActionRecreate ActionType = "recreate"
+	ActionDelete   ActionType = "delete"
+)
+
+// Action is a change to make to a single object.
+type Action struct {
+	Type       ActionType `json:"type"`
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Name       string     `json:"name"`
+	// PatchType and Patch are set for the patch and recreate actions, the values of the redacted fields are masked.
+	PatchType types.PatchType `json:"patchType,omitempty"`
+	Patch     json.RawMessage `json:"patch,omitempty"`
+	// ImmutableFields lists the paths of the immutable fields changed by the recreate actions.
+	ImmutableFields []string `json:"immutableFields,omitempty"`
+}
+
+// Plan is the ordered list of actions turning the live objects into the desired ones: the creations in the order of the
+// desired set, then the patches and recreations in the order of the desired set, then the deletions in the order of the
+// live set. It can be logged or stored as JSON or YAML.
+type Plan struct {
+	Actions []Action `json:"actions"`
+}
+
+// Summary counts the actions of a plan by type.
+type Summary struct {
+	Create   int `json:"create"`
+	Patch    int `json:"patch"`
+	Recreate int `json:"recreate"`
+	Delete   int `json:"delete"`
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d to create, %d to patch, %d to recreate, %d to delete", s.Create, s.Patch, s.Recreate, s.Delete)
+}
+
+// Plan returns the actions of the result, the objects which don't need to be patched are left out.
+func (r *Result) Plan() *Plan {
+	plan := &Plan{Actions: []Action{}}
+	for i, obj := range r.Create {
+		plan.Actions = append(plan.Actions, newAction(ActionCreate, keyAt(r.createKeys, i, obj)))
+	}
+	for _, objectPatch := range r.Changed() {
+		result := objectPatch.Result.Redacted()
+		action := newAction(ActionPatch, objectPatch.Key)
+		if result.RequiresRecreate {
+			action.Type = ActionRecreate
+			for _, change := range result.ImmutableChanges {
+				action.ImmutableFields = append(action.ImmutableFields, change.Path)
+			}
+		}
+		action.PatchType = result.PatchType()
+		action.Patch = json.RawMessage(result.Patch)
+		plan.Actions = append(plan.Actions, action)
+	}
+	for i, obj := range r.Prune {
+		plan.Actions = append(plan.Actions, newAction(ActionDelete, keyAt(r.pruneKeys, i, obj)))
+	}
+	return plan
+}
+
+// Summary counts the actions of the plan by type.
+func (p *Plan) Summary() Summary {
+	var summary Summary
+	for _, action := range p.Actions {
+		switch action.Type {
+		case ActionCreate:
+			summary.Create++
+		case ActionPatch:
+			summary.Patch++
+		case ActionRecreate:
+			summary.Recreate++
+		case ActionDelete:
+			summary.Delete++
+		}
+	}
+	return summary
+}
+
+// IsEmpty tells whether the plan has no action.
+func (p *Plan) IsEmpty() bool {
+	return len(p.Actions) == 0
+}
+
+// JSON returns the plan as JSON.
+func (p *Plan) JSON() ([]byte, error) {
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(p)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal plan")
+	}
+	return data, nil
+}
+
+// YAML returns the plan as YAML.
+func (p *Plan) YAML() ([]byte, error) {
+	data, err := p.JSON()
+	if err != nil {
+		return nil, err
+	}
+	data, err = yaml.JSONToYAML(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert plan to YAML")
+	}
+	return data, nil
+}
+
+// ParsePlan reads a plan written as JSON or YAML.
+func ParsePlan(data []byte) (*Plan, error) {
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert plan to JSON")
+	}
+	plan := &Plan{}
+	if err := json.ConfigCompatibleWithStandardLibrary.Unmarshal(data, plan); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal plan")
+	}
+	return plan, nil
+}
+
+func newAction(actionType ActionType, key Key) Action {
+	apiVersion, kind := key.GVK.ToAPIVersionAndKind()
+	return Action{
+		Type:       actionType,
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  key.Namespace,
+		Name:       key.Name,
+	}
+}
+
+// keyAt returns the key of the object computed by Compare, or resolves it for the results built by the caller.
+func keyAt(keys []Key, i int, obj runtime.Object) Key {
+	if i < len(keys) {
+		return keys[i]
+	}
+	key, _ := options{}.keyOf(obj)
+	return key
+}
@@ -0,0 +1,110 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifestset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newService(clusterIP string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "service",
+			Namespace: "default",
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: clusterIP,
+		},
+	}
+}
+
+func TestPlan(t *testing.T) {
+	desired := []runtime.Object{
+		newConfigMap("unchanged", "a"),
+		newConfigMap("changed", "b"),
+		newConfigMap("new", "a"),
+		newService("10.0.0.2"),
+	}
+	live := []runtime.Object{
+		annotated(newConfigMap("unchanged", "a")),
+		annotated(newConfigMap("changed", "a")),
+		annotated(newService("10.0.0.1")),
+		annotated(newConfigMap("removed", "a")),
+	}
+
+	result, err := Compare(desired, live)
+	require.NoError(t, err)
+
+	plan := result.Plan()
+	require.Len(t, plan.Actions, 4)
+	assert.Equal(t, Action{Type: ActionCreate, APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "new"}, plan.Actions[0])
+	assert.Equal(t, ActionPatch, plan.Actions[1].Type)
+	assert.Equal(t, "changed", plan.Actions[1].Name)
+	assert.Equal(t, types.StrategicMergePatchType, plan.Actions[1].PatchType)
+	assert.JSONEq(t, `{"data":{"key":"b"}}`, string(plan.Actions[1].Patch))
+	assert.Equal(t, ActionRecreate, plan.Actions[2].Type)
+	assert.Equal(t, "Service", plan.Actions[2].Kind)
+	assert.Equal(t, []string{".spec.clusterIP"}, plan.Actions[2].ImmutableFields)
+	assert.Equal(t, Action{Type: ActionDelete, APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "removed"}, plan.Actions[3])
+
+	summary := plan.Summary()
+	assert.Equal(t, Summary{Create: 1, Patch: 1, Recreate: 1, Delete: 1}, summary)
+	assert.Equal(t, "1 to create, 1 to patch, 1 to recreate, 1 to delete", summary.String())
+	assert.False(t, plan.IsEmpty())
+
+	for _, marshal := range []func() ([]byte, error){plan.JSON, plan.YAML} {
+		data, err := marshal()
+		require.NoError(t, err)
+		parsed, err := ParsePlan(data)
+		require.NoError(t, err)
+		assert.Equal(t, plan.Summary(), parsed.Summary())
+		assert.JSONEq(t, string(plan.Actions[1].Patch), string(parsed.Actions[1].Patch))
+	}
+}
+
+func TestPlanRedactsSecrets(t *testing.T) {
+	newSecret := func(value string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{Name: "secret", Namespace: "default"},
+			StringData: map[string]string{"password": value},
+		}
+	}
+
+	result, err := Compare([]runtime.Object{newSecret("new")}, []runtime.Object{annotated(newSecret("old"))})
+	require.NoError(t, err)
+
+	data, err := result.Plan().JSON()
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "new")
+	assert.Contains(t, string(data), "[redacted]")
+}
+
+func TestPlanEmpty(t *testing.T) {
+	result, err := Compare([]runtime.Object{newConfigMap("unchanged", "a")}, []runtime.Object{annotated(newConfigMap("unchanged", "a"))})
+	require.NoError(t, err)
+
+	plan := result.Plan()
+	assert.True(t, plan.IsEmpty())
+	data, err := plan.JSON()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"actions":[]}`, string(data))
+}
@@ -0,0 +1,63 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package objectmatcher tells whether two objects are semantically equal after being normalized like the patch
+// package normalizes them, without calculating a patch, e.g. for admission webhooks and tests.
+package objectmatcher
+
+import (
+	"reflect"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// Match tells whether the objects are equal after cleaning their metadata (see patch.CleanMetadata), applying the
+// options to both of them, like Calculate does (see patch.ApplyOptions), and removing the null fields. Unlike Calculate, no original
+// configuration is involved: the fields set on the current object only, e.g. by the API server, make the objects
+// differ unless an option removes them, like patch.IgnoreStatusFields.
+func Match(current, desired runtime.Object, opts ...patch.CalculateOption) (bool, error) {
+	currentDocument, desiredDocument, err := patch.ApplyOptions(current, desired, append([]patch.CalculateOption{patch.CleanMetadata()}, opts...)...)
+	if err != nil {
+		return false, err
+	}
+
+	currentResource, err := normalizedResource(currentDocument)
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to normalize current object")
+	}
+	desiredResource, err := normalizedResource(desiredDocument)
+	if err != nil {
+		return false, errors.Wrap(err, "Failed to normalize desired object")
+	}
+
+	return reflect.DeepEqual(currentResource, desiredResource), nil
+}
+
+// normalizedResource removes the null fields of the document and parses it.
+func normalizedResource(document []byte) (map[string]interface{}, error) {
+	document, err := patch.DeleteNullInJsonBytes(document)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to delete null from object")
+	}
+
+	resource := map[string]interface{}{}
+	if err := json.Unmarshal(document, &resource); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal byte sequence")
+	}
+	return resource, nil
+}
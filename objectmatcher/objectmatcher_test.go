@@ -0,0 +1,95 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package objectmatcher
+
+import (
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+func newDeployment() *appsv1.Deployment {
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &v1.LabelSelector{
+				MatchLabels: map[string]string{"app": "nginx"},
+			},
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "nginx:1.22"}},
+				},
+			},
+		},
+	}
+}
+
+func TestMatch(t *testing.T) {
+	current := newDeployment()
+	current.UID = types.UID("uid")
+	current.ResourceVersion = "42"
+	current.Status.ReadyReplicas = 1
+	desired := newDeployment()
+
+	match, err := Match(current, desired)
+	require.NoError(t, err)
+	assert.False(t, match)
+
+	match, err = Match(current, desired, patch.IgnoreStatusFields())
+	require.NoError(t, err)
+	assert.True(t, match)
+
+	desired.Spec.Template.Spec.Containers[0].Image = "nginx:1.23"
+	match, err = Match(current, desired, patch.IgnoreStatusFields())
+	require.NoError(t, err)
+	assert.False(t, match)
+
+	desired = newDeployment()
+	desired.Labels["team"] = "a"
+	match, err = Match(current, desired, patch.IgnoreStatusFields())
+	require.NoError(t, err)
+	assert.False(t, match)
+
+	match, err = Match(current, desired, patch.IgnoreStatusFields(), patch.IgnoreLabels("team"))
+	require.NoError(t, err)
+	assert.True(t, match)
+}
+
+func TestMatchGivesTheKindToTheOptions(t *testing.T) {
+	var kinds []string
+	recordKind := func(current, modified []byte) ([]byte, []byte, error) {
+		kinds = append(kinds, json.Get(current, "kind").ToString(), json.Get(modified, "apiVersion").ToString())
+		return current, modified, nil
+	}
+
+	match, err := Match(newDeployment(), newDeployment(), recordKind)
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.Equal(t, []string{"Deployment", "apps/v1"}, kinds)
+}
@@ -17,6 +17,7 @@ package patch
 import (
 	"archive/zip"
 	"bytes"
+	"compress/gzip"
 	"encoding/base64"
 	"io/ioutil"
 	"net/http"
@@ -29,18 +30,52 @@ import (
 
 const LastAppliedConfig = "banzaicloud.com/last-applied"
 
+// KubectlLastAppliedConfig is the annotation kubectl apply stores the original configuration in.
+const KubectlLastAppliedConfig = "kubectl.kubernetes.io/last-applied-configuration"
+
 var DefaultAnnotator = NewAnnotator(LastAppliedConfig)
 
 type Annotator struct {
 	metadataAccessor meta.MetadataAccessor
 	key              string
+	encode           func(original []byte) (string, error)
+	hashData         bool
+	fallbackKeys     []string
+	hashOnly         bool
 }
 
 func NewAnnotator(key string) *Annotator {
 	return &Annotator{
 		key:              key,
 		metadataAccessor: meta.NewAccessor(),
+		encode:           zipAndBase64EncodeAnnotation,
+	}
+}
+
+// NewCompressedAnnotator creates an Annotator that gzips and base64 encodes the original configuration,
+// which makes the annotation noticeably smaller than the default zip archive for large objects.
+// Annotations written by other annotators, compressed or not, can still be read.
+func NewCompressedAnnotator(key string) *Annotator {
+	return &Annotator{
+		key:              key,
+		metadataAccessor: meta.NewAccessor(),
+		encode:           gzipAndBase64EncodeAnnotation,
+	}
+}
+
+// WithFallbackKeys returns a copy of the annotator reading the original configuration from the first of the given
+// annotations when its own annotation is missing, e.g. LastAppliedConfig or KubectlLastAppliedConfig to migrate objects
+// managed by the upstream library or kubectl apply. The fallback annotations are removed once the original configuration
+// is stored under the annotator's key.
+func (a *Annotator) WithFallbackKeys(keys ...string) *Annotator {
+	migrating := *a
+	migrating.fallbackKeys = nil
+	for _, key := range append(append([]string{}, a.fallbackKeys...), keys...) {
+		if key != a.key {
+			migrating.fallbackKeys = append(migrating.fallbackKeys, key)
+		}
 	}
+	return &migrating
 }
 
 // GetOriginalConfiguration retrieves the original configuration of the object
@@ -56,19 +91,17 @@ func (a *Annotator) GetOriginalConfiguration(obj runtime.Object) ([]byte, error)
 	}
 
 	original, ok := annots[a.key]
+	for _, key := range a.fallbackKeys {
+		if ok {
+			break
+		}
+		original, ok = annots[key]
+	}
 	if !ok {
 		return nil, nil
 	}
 
-	// Try to base64 decode, and fallback to non-base64 encoded content for backwards compatibility.
-	if decoded, err := base64.StdEncoding.DecodeString(original); err == nil {
-		if http.DetectContentType(decoded) == "application/zip" {
-			return unZipAnnotation(decoded)
-		}
-		return decoded, nil
-	}
-
-	return []byte(original), nil
+	return decodeOriginalConfiguration(original)
 }
 
 // SetOriginalConfiguration sets the original configuration of the object
@@ -87,10 +120,13 @@ func (a *Annotator) SetOriginalConfiguration(obj runtime.Object, original []byte
 		annots = map[string]string{}
 	}
 
-	annots[a.key], err = zipAndBase64EncodeAnnotation(original)
+	annots[a.key], err = a.encode(original)
 	if err != nil {
 		return err
 	}
+	for _, key := range a.fallbackKeys {
+		delete(annots, key)
+	}
 	return a.metadataAccessor.SetAnnotations(obj, annots)
 }
 
@@ -116,6 +152,13 @@ func (a *Annotator) GetModifiedConfiguration(obj runtime.Object, annotate bool)
 
 	original := annots[a.key]
 	delete(annots, a.key)
+	fallbacks := map[string]string{}
+	for _, key := range a.fallbackKeys {
+		if value, ok := annots[key]; ok {
+			fallbacks[key] = value
+			delete(annots, key)
+		}
+	}
 	if err := a.metadataAccessor.SetAnnotations(obj, annots); err != nil {
 		return nil, err
 	}
@@ -129,9 +172,15 @@ func (a *Annotator) GetModifiedConfiguration(obj runtime.Object, annotate bool)
 	if err != nil {
 		return nil, err
 	}
+	if a.hashData {
+		modified, err = hashObjectData(obj, modified)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	if annotate {
-		annots[a.key], err = zipAndBase64EncodeAnnotation(modified)
+		annots[a.key], err = a.encode(modified)
 		if err != nil {
 			return nil, err
 		}
@@ -147,6 +196,9 @@ func (a *Annotator) GetModifiedConfiguration(obj runtime.Object, annotate bool)
 
 	// Restore the object to its original condition.
 	annots[a.key] = original
+	for key, value := range fallbacks {
+		annots[key] = value
+	}
 	if err := a.metadataAccessor.SetAnnotations(obj, annots); err != nil {
 		return nil, err
 	}
@@ -157,31 +209,56 @@ func (a *Annotator) GetModifiedConfiguration(obj runtime.Object, annotate bool)
 // SetLastAppliedAnnotation gets the modified configuration of the object,
 // without embedding it again, and then sets it on the object as the annotation.
 func (a *Annotator) SetLastAppliedAnnotation(obj runtime.Object) error {
-	modified, err := a.GetModifiedConfiguration(obj, false)
+	modified, err := a.lastAppliedConfiguration(obj)
 	if err != nil {
 		return err
 	}
-	// Remove nulls from json
-	modifiedWithoutNulls, _, err := DeleteNullInJson(modified)
-	if err != nil {
-		return err
-	}
-	return a.SetOriginalConfiguration(obj, modifiedWithoutNulls)
+	return a.SetOriginalConfiguration(obj, modified)
 }
 
 // SetLastAppliedAnnotation gets the modified configuration of the object,
 // without embedding it again, and then sets it on the object as the annotation.
 func (a *Annotator) SetLastAppliedAnnotationToObject(objModified runtime.Object, objExpected runtime.Object) error {
-	modified, err := a.GetModifiedConfiguration(objExpected, false)
+	modified, err := a.lastAppliedConfiguration(objExpected)
 	if err != nil {
 		return err
 	}
+	return a.SetOriginalConfiguration(objModified, modified)
+}
+
+// lastAppliedConfiguration returns the canonical configuration of the object without nulls, as stored in the annotation.
+func (a *Annotator) lastAppliedConfiguration(obj runtime.Object) ([]byte, error) {
+	modified, err := a.GetModifiedConfiguration(obj, false)
+	if err != nil {
+		return nil, err
+	}
 	// Remove nulls from json
-	modifiedWithoutNulls, _, err := DeleteNullInJson(modified)
+	modifiedWithoutNulls, err := DeleteNullInJsonBytes(modified)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return CanonicalJSON(modifiedWithoutNulls)
+}
+
+// decodeOriginalConfiguration decodes a stored original configuration.
+func decodeOriginalConfiguration(original string) ([]byte, error) {
+	// The configuration can't be restored from its hash
+	if isHashAnnotation(original) {
+		return nil, nil
+	}
+
+	// Try to base64 decode, and fallback to non-base64 encoded content for backwards compatibility.
+	if decoded, err := base64.StdEncoding.DecodeString(original); err == nil {
+		switch http.DetectContentType(decoded) {
+		case "application/zip":
+			return unZipAnnotation(decoded)
+		case "application/x-gzip":
+			return gunzipAnnotation(decoded)
+		}
+		return decoded, nil
 	}
-	return a.SetOriginalConfiguration(objModified, modifiedWithoutNulls)
+
+	return []byte(original), nil
 }
 
 func zipAndBase64EncodeAnnotation(original []byte) (string, error) {
@@ -209,6 +286,36 @@ func zipAndBase64EncodeAnnotation(original []byte) (string, error) {
 	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
 }
 
+func gzipAndBase64EncodeAnnotation(original []byte) (string, error) {
+	buf := new(bytes.Buffer)
+
+	w, err := gzip.NewWriterLevel(buf, gzip.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	_, err = w.Write(original)
+	if err != nil {
+		return "", err
+	}
+
+	// Make sure to check the error on Close.
+	err = w.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func gunzipAnnotation(original []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(original))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
 func unZipAnnotation(original []byte) ([]byte, error) {
 	annotation, err := ioutil.ReadAll(bytes.NewReader(original))
 	if err != nil {
@@ -15,9 +15,16 @@
 package patch
 
 import (
+	"encoding/base64"
+	"net/http"
+	"strings"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 func TestAnnotationRemovedWhenEmpty(t *testing.T) {
@@ -33,3 +40,193 @@ func TestAnnotationRemovedWhenEmpty(t *testing.T) {
 		t.Fatalf("Expected {\"metadata\":{} got %s", string(modified))
 	}
 }
+
+func TestCompressedAnnotator(t *testing.T) {
+	compressed := NewCompressedAnnotator(LastAppliedConfig)
+
+	u := unstructured.Unstructured{}
+	u.SetName("test")
+	u.SetLabels(map[string]string{"foo": "bar"})
+	if err := compressed.SetLastAppliedAnnotation(&u); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := u.GetAnnotations()[LastAppliedConfig]
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType := http.DetectContentType(decoded); contentType != "application/x-gzip" {
+		t.Fatalf("Expected gzip content got %s", contentType)
+	}
+
+	// Both annotators read the compressed annotation
+	for _, annotator := range []*Annotator{compressed, DefaultAnnotator} {
+		original, err := annotator.GetOriginalConfiguration(&u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if "{\"metadata\":{\"labels\":{\"foo\":\"bar\"},\"name\":\"test\"}}" != string(original) {
+			t.Fatalf("Unexpected original configuration %s", string(original))
+		}
+	}
+
+	// Uncompressed annotations are still supported
+	u.SetAnnotations(map[string]string{
+		LastAppliedConfig: "{\"a\":\"b\"}",
+	})
+	original, err := compressed.GetOriginalConfiguration(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if "{\"a\":\"b\"}" != string(original) {
+		t.Fatalf("Expected {\"a\":\"b\"} got %s", string(original))
+	}
+}
+
+func TestAnnotatorWithFallbackKeys(t *testing.T) {
+	annotator := NewAnnotator("example.com/last-applied").WithFallbackKeys(LastAppliedConfig, KubectlLastAppliedConfig)
+
+	u := unstructured.Unstructured{}
+	u.SetName("test")
+	u.SetAnnotations(map[string]string{
+		KubectlLastAppliedConfig: "{\"kubectl\":true}",
+	})
+
+	original, err := annotator.GetOriginalConfiguration(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if "{\"kubectl\":true}" != string(original) {
+		t.Fatalf("Expected the kubectl configuration got %s", string(original))
+	}
+
+	// The upstream annotation takes precedence over the kubectl one
+	if err := DefaultAnnotator.SetOriginalConfiguration(&u, []byte("{\"upstream\":true}")); err != nil {
+		t.Fatal(err)
+	}
+	original, err = annotator.GetOriginalConfiguration(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if "{\"upstream\":true}" != string(original) {
+		t.Fatalf("Expected the upstream configuration got %s", string(original))
+	}
+
+	// The fallback annotations are neither stored in the configuration nor kept once migrated
+	if err := annotator.SetLastAppliedAnnotation(&u); err != nil {
+		t.Fatal(err)
+	}
+	annotations := u.GetAnnotations()
+	if _, ok := annotations[LastAppliedConfig]; ok {
+		t.Fatalf("Expected the upstream annotation to be removed")
+	}
+	if _, ok := annotations[KubectlLastAppliedConfig]; ok {
+		t.Fatalf("Expected the kubectl annotation to be removed")
+	}
+	original, err = annotator.GetOriginalConfiguration(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if "{\"metadata\":{\"name\":\"test\"}}" != string(original) {
+		t.Fatalf("Unexpected original configuration %s", string(original))
+	}
+}
+
+func TestPatchMakerAnnotator(t *testing.T) {
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "config"},
+			Data:       map[string]string{"key": value},
+		}
+	}
+
+	annotator := NewAnnotator("example.com/last-applied")
+	current := newConfigMap("value1")
+	if err := annotator.SetLastAppliedAnnotation(current); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, typed := range []bool{true, false} {
+		var currentObject, modifiedObject runtime.Object = current, newConfigMap("value2")
+		if !typed {
+			currentObject, modifiedObject = toUnstructured(t, currentObject), toUnstructured(t, modifiedObject)
+		}
+
+		result, err := NewPatchMaker(annotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}).Calculate(currentObject, modifiedObject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patched := result.Patched.(runtime.Object)
+		if _, ok := annotations(t, patched)[LastAppliedConfig]; ok {
+			t.Fatalf("Expected the patched object not to be annotated by the default annotator")
+		}
+		original, err := annotator.GetOriginalConfiguration(patched)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(original), "\"data\":{\"key\":\"value2\"}") {
+			t.Fatalf("Unexpected original configuration %s", string(original))
+		}
+
+		// Without annotator the patched object is not annotated and there is no original configuration
+		result, err = NewPatchMaker(nil, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}).Calculate(currentObject, modifiedObject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Original != nil {
+			t.Fatalf("Expected no original configuration got %s", string(result.Original))
+		}
+		patched = result.Patched.(runtime.Object)
+		if value := annotations(t, patched)[annotator.key]; value != annotations(t, currentObject)[annotator.key] {
+			t.Fatalf("Expected the annotation of the current object to be left untouched")
+		}
+	}
+}
+
+func toUnstructured(t *testing.T, obj runtime.Object) *unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetAPIVersion("v1")
+	u.SetKind("ConfigMap")
+	return u
+}
+
+func annotations(t *testing.T, obj runtime.Object) map[string]string {
+	annotations, err := meta.NewAccessor().Annotations(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return annotations
+}
+
+func TestWithoutAnnotatingPatched(t *testing.T) {
+	current := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "config"},
+		Data:       map[string]string{"key": "value1"},
+	}
+	if err := DefaultAnnotator.SetLastAppliedAnnotation(current); err != nil {
+		t.Fatal(err)
+	}
+	modified := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "config"},
+		Data:       map[string]string{"key": "value2"},
+	}
+
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithoutAnnotatingPatched())
+	result, err := patchMaker.Calculate(current, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patched := result.Patched.(*corev1.ConfigMap)
+	if "value2" != patched.Data["key"] {
+		t.Fatalf("Expected value2 got %s", patched.Data["key"])
+	}
+	if current.Annotations[LastAppliedConfig] != patched.Annotations[LastAppliedConfig] {
+		t.Fatalf("Expected the last-applied annotation of the current object to be left untouched")
+	}
+}
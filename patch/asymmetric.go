@@ -0,0 +1,60 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import "k8s.io/apimachinery/pkg/runtime"
+
+// OnCurrentOnly applies the option to the current object only, e.g. to strip the injected sidecars from the current
+// object without touching the modified one. The modified object is left as it is.
+func OnCurrentOnly(opt CalculateOption) CalculateOption {
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		current, _, err := opt(current, copyBytes(modified))
+		if err != nil {
+			return []byte{}, []byte{}, err
+		}
+		return current, modified, nil
+	}
+}
+
+// OnModifiedOnly applies the option to the modified object only, e.g. to add defaults to the modified object.
+// The current object is left as it is.
+func OnModifiedOnly(opt CalculateOption) CalculateOption {
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		_, modified, err := opt(copyBytes(current), modified)
+		if err != nil {
+			return []byte{}, []byte{}, err
+		}
+		return current, modified, nil
+	}
+}
+
+// OnCurrentOnlyMap is the map option of OnCurrentOnly.
+func OnCurrentOnlyMap(opt CalculateMapOption) CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		return opt(current, runtime.DeepCopyJSON(modified))
+	}
+}
+
+// OnModifiedOnlyMap is the map option of OnModifiedOnly.
+func OnModifiedOnlyMap(opt CalculateMapOption) CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		return opt(runtime.DeepCopyJSON(current), modified)
+	}
+}
+
+// copyBytes copies the document given to an option whose result is dropped, as options may modify it in place.
+func copyBytes(document []byte) []byte {
+	return append([]byte(nil), document...)
+}
@@ -0,0 +1,50 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnCurrentOnly(t *testing.T) {
+	document := []byte(`{"metadata":{"name":"test"},"status":{"ready":true}}`)
+
+	current, modified, err := OnCurrentOnly(IgnoreStatusFields())(document, document)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"name":"test"}}`, string(current))
+	assert.JSONEq(t, string(document), string(modified))
+
+	current, modified, err = OnModifiedOnly(IgnoreStatusFields())(document, document)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(document), string(current))
+	assert.JSONEq(t, `{"metadata":{"name":"test"}}`, string(modified))
+}
+
+func TestOnCurrentOnlyMap(t *testing.T) {
+	document := []byte(`{"metadata":{"name":"test"},"status":{"ready":true}}`)
+
+	current, modified, err := MapOptions(OnCurrentOnlyMap(IgnoreStatusFieldsMap()))(document, document)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"name":"test"}}`, string(current))
+	assert.JSONEq(t, string(document), string(modified))
+
+	current, modified, err = MapOptions(OnModifiedOnlyMap(IgnoreStatusFieldsMap()))(document, document)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(document), string(current))
+	assert.JSONEq(t, `{"metadata":{"name":"test"}}`, string(modified))
+}
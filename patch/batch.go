@@ -0,0 +1,98 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"runtime"
+	"sync"
+
+	"emperror.dev/errors"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// ObjectPair is a current and modified object to compare with CalculateAll.
+type ObjectPair struct {
+	Current  k8sruntime.Object
+	Modified k8sruntime.Object
+}
+
+// WithConcurrency sets the number of patches CalculateAll computes concurrently, it defaults to GOMAXPROCS.
+func WithConcurrency(workers int) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.concurrency = workers
+	}
+}
+
+// CalculateAll calculates the patches of the pairs with the maker, concurrently if it is a BatchMaker, one after the
+// other otherwise. The results are in the order of the pairs, the result of a pair which failed is nil and its error
+// is part of the returned aggregated error.
+func CalculateAll(maker Maker, pairs []ObjectPair, opts ...CalculateOption) ([]*PatchResult, error) {
+	if batchMaker, ok := maker.(BatchMaker); ok {
+		return batchMaker.CalculateAll(pairs, opts...)
+	}
+
+	results := make([]*PatchResult, len(pairs))
+	errs := make([]error, len(pairs))
+	for index, pair := range pairs {
+		result, err := maker.Calculate(pair.Current, pair.Modified, opts...)
+		if err != nil {
+			errs[index] = errors.WrapWithDetails(err, "could not calculate patch", "index", index)
+			continue
+		}
+		results[index] = result
+	}
+
+	return results, errors.Combine(errs...)
+}
+
+// CalculateAll calculates the patches of the pairs concurrently. The results are in the order of the pairs,
+// the result of a pair which failed is nil and its error is part of the returned aggregated error.
+func (p *PatchMaker) CalculateAll(pairs []ObjectPair, opts ...CalculateOption) ([]*PatchResult, error) {
+	workers := p.concurrency
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(pairs) {
+		workers = len(pairs)
+	}
+
+	results := make([]*PatchResult, len(pairs))
+	errs := make([]error, len(pairs))
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				result, err := p.Calculate(pairs[index].Current, pairs[index].Modified, opts...)
+				if err != nil {
+					errs[index] = errors.WrapWithDetails(err, "could not calculate patch", "index", index)
+					continue
+				}
+				results[index] = result
+			}
+		}()
+	}
+
+	for index := range pairs {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results, errors.Combine(errs...)
+}
@@ -0,0 +1,91 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCalculateAll(t *testing.T) {
+	maker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithConcurrency(3))
+
+	var pairs []ObjectPair
+	for i := 0; i < 10; i++ {
+		current := &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      fmt.Sprintf("config-%d", i),
+				Namespace: "default",
+			},
+			Data: map[string]string{"key": "a"},
+		}
+		mustAnnotate(current)
+		modified := current.DeepCopy()
+		modified.Annotations = nil
+		if i%2 == 0 {
+			modified.Data["key"] = "b"
+		}
+		pairs = append(pairs, ObjectPair{Current: current, Modified: modified})
+	}
+
+	results, err := maker.(BatchMaker).CalculateAll(pairs)
+	assert.NoError(t, err)
+	assert.Len(t, results, len(pairs))
+	for i, result := range results {
+		assert.Equal(t, i%2 != 0, result.IsEmpty(), "pair %d", i)
+	}
+
+	// Errors are aggregated, the other results are still returned
+	failing := func(current, modified []byte) ([]byte, []byte, error) {
+		return nil, nil, fmt.Errorf("failure")
+	}
+	results, err = maker.(BatchMaker).CalculateAll(pairs[:2], failing)
+	assert.Error(t, err)
+	assert.Equal(t, []*PatchResult{nil, nil}, results)
+
+	results, err = maker.(BatchMaker).CalculateAll(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+// calculateOnlyMaker is a Maker implementing only Calculate, like the mocks of Maker.
+type calculateOnlyMaker struct {
+	Maker
+}
+
+func TestCalculateAllWithMaker(t *testing.T) {
+	current := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default"},
+		Data:       map[string]string{"key": "a"},
+	}
+	mustAnnotate(current)
+	modified := current.DeepCopy()
+	modified.Annotations = nil
+	modified.Data["key"] = "b"
+	pairs := []ObjectPair{{Current: current, Modified: modified}, {Current: current, Modified: current.DeepCopy()}}
+
+	for _, maker := range []Maker{DefaultPatchMaker, calculateOnlyMaker{DefaultPatchMaker}} {
+		results, err := CalculateAll(maker, pairs)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.False(t, results[0].IsEmpty())
+		assert.True(t, results[1].IsEmpty())
+	}
+}
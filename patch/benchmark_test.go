@@ -0,0 +1,83 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newBenchmarkDeployment() *appsv1.Deployment {
+	replicas := int32(3)
+	containers := make([]corev1.Container, 0, 5)
+	for i := 0; i < 5; i++ {
+		env := make([]corev1.EnvVar, 0, 20)
+		for j := 0; j < 20; j++ {
+			env = append(env, corev1.EnvVar{Name: fmt.Sprintf("VAR_%d", j), Value: fmt.Sprintf("value-%d", j)})
+		}
+		containers = append(containers, corev1.Container{
+			Name:  fmt.Sprintf("container-%d", i),
+			Image: "nginx:1.23",
+			Env:   env,
+			Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+		})
+	}
+
+	return &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "nginx"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "nginx"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"app": "nginx"}},
+				Spec:       corev1.PodSpec{Containers: containers},
+			},
+		},
+	}
+}
+
+func BenchmarkCalculate(b *testing.B) {
+	current := newBenchmarkDeployment()
+	if err := DefaultAnnotator.SetLastAppliedAnnotation(current); err != nil {
+		b.Fatal(err)
+	}
+	modified := newBenchmarkDeployment()
+
+	b.Run("without options", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := DefaultPatchMaker.Calculate(current, modified); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("with options", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := DefaultPatchMaker.Calculate(current, modified, IgnoreStatusFields(), CleanMetadata()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,135 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"container/list"
+	"sync"
+
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// WithResultCache memoizes up to size results of Calculate, keyed by the kind, namespace, name and resourceVersion
+// of the current object and a hash of the modified object, so reconciling unchanged objects doesn't compare them
+// again. Objects without resourceVersion are not cached, neither are the calls with options as they aren't part of the
+// key: register the options with WithKindOptions to cache the results of kinds requiring options. The cache assumes
+// the original configuration only changes along with the current object, which isn't the case for stores set with
+// WithOriginalConfigurationStore other than the annotation.
+func WithResultCache(size int) PatchMakerOption {
+	return func(p *PatchMaker) {
+		if size > 0 {
+			p.cache = newResultCache(size)
+		}
+	}
+}
+
+type resultCacheKey struct {
+	gvk             schema.GroupVersionKind
+	namespace       string
+	name            string
+	resourceVersion string
+	modifiedHash    string
+}
+
+type resultCacheEntry struct {
+	key    resultCacheKey
+	result *PatchResult
+}
+
+// resultCache is a least recently used cache of patch results.
+type resultCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[resultCacheKey]*list.Element
+}
+
+func newResultCache(size int) *resultCache {
+	return &resultCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[resultCacheKey]*list.Element, size),
+	}
+}
+
+// get returns a copy of the cached result.
+func (c *resultCache) get(key resultCacheKey) (*PatchResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+
+	return element.Value.(*resultCacheEntry).result.copy(), true
+}
+
+// add stores a copy of the result, evicting the least recently used one when the cache is full.
+func (c *resultCache) add(key resultCacheKey, result *PatchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*resultCacheEntry).result = result.copy()
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&resultCacheEntry{key: key, result: result.copy()})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*resultCacheEntry).key)
+	}
+}
+
+// resultCacheKey returns the cache key of the compared objects, false if the result can't be cached.
+func (p *PatchMaker) resultCacheKey(ctx CalculateContext) (resultCacheKey, bool, error) {
+	current, err := meta.Accessor(ctx.CurrentObject)
+	if err != nil || current.GetResourceVersion() == "" {
+		return resultCacheKey{}, false, nil
+	}
+
+	modifiedHash, err := hashJSON(ctx.ModifiedObject)
+	if err != nil {
+		return resultCacheKey{}, false, errors.Wrap(err, "Failed to hash modified object")
+	}
+
+	return resultCacheKey{
+		gvk:             ctx.GVK,
+		namespace:       current.GetNamespace(),
+		name:            current.GetName(),
+		resourceVersion: current.GetResourceVersion(),
+		modifiedHash:    modifiedHash,
+	}, true, nil
+}
+
+// copy returns a copy of the result which can be modified without altering the cached one.
+func (p *PatchResult) copy() *PatchResult {
+	result := *p
+	if patched, ok := p.Patched.(runtime.Object); ok {
+		result.Patched = patched.DeepCopyObject()
+	}
+	if p.modifiedObject != nil {
+		result.modifiedObject = p.modifiedObject.DeepCopyObject()
+	}
+	result.ImmutableChanges = append([]FieldChange(nil), p.ImmutableChanges...)
+	return &result
+}
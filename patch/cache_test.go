@@ -0,0 +1,101 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWithResultCache(t *testing.T) {
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithResultCache(1)).(*PatchMaker)
+
+	newConfigMap := func(name, value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string]string{"key": value},
+		}
+	}
+
+	current := newConfigMap("config", "value1")
+	assert.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+	current.ResourceVersion = "1"
+
+	result, err := patchMaker.Calculate(current, newConfigMap("config", "value2"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, patchMaker.cache.order.Len())
+
+	cached, err := patchMaker.Calculate(current, newConfigMap("config", "value2"))
+	assert.NoError(t, err)
+	assert.Equal(t, result.Patch, cached.Patch)
+	assert.Equal(t, result.Patched, cached.Patched)
+
+	// Cached results are copies
+	cached.Patched.(*corev1.ConfigMap).Data["key"] = "changed"
+	cached, err = patchMaker.Calculate(current, newConfigMap("config", "value2"))
+	assert.NoError(t, err)
+	assert.Equal(t, "value2", cached.Patched.(*corev1.ConfigMap).Data["key"])
+
+	// A different modified object or resourceVersion misses the cache
+	result, err = patchMaker.Calculate(current, newConfigMap("config", "value3"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"key":"value3"}}`, string(result.Patch))
+
+	current.ResourceVersion = "2"
+	current.Data["key"] = "value3"
+	result, err = patchMaker.Calculate(current, newConfigMap("config", "value3"))
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+
+	// Only the most recently used result is kept
+	assert.Equal(t, 1, patchMaker.cache.order.Len())
+}
+
+func TestWithResultCacheWithOptions(t *testing.T) {
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithResultCache(10),
+		WithKindOptions(corev1.SchemeGroupVersion.WithKind("ConfigMap"), IgnoreJSONPath(".data.ignored"))).(*PatchMaker)
+
+	current := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default"}, Data: map[string]string{"key": "value1"}}
+	assert.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+	current.ResourceVersion = "1"
+	modified := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default"}, Data: map[string]string{"key": "value2", "ignored": "value"}}
+
+	// The results of the calls with options are not cached, nor served from the cache
+	result, err := patchMaker.Calculate(current, modified, IgnoreField("data"))
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+	assert.Equal(t, 0, patchMaker.cache.order.Len())
+
+	result, err = patchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"key":"value2"}}`, string(result.Patch))
+	assert.Equal(t, 1, patchMaker.cache.order.Len())
+
+	result, err = patchMaker.Calculate(current, modified, IgnoreField("data"))
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+}
+
+func TestWithResultCacheWithoutResourceVersion(t *testing.T) {
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithResultCache(10)).(*PatchMaker)
+
+	current := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config"}, Data: map[string]string{"key": "value1"}}
+	_, err := patchMaker.Calculate(current, current.DeepCopy())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, patchMaker.cache.order.Len())
+}
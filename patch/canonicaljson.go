@@ -0,0 +1,56 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"bytes"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+// CanonicalJSON returns the JSON document with the keys of its objects sorted and without insignificant whitespace, so
+// equal documents give the same bytes whatever the order they were marshaled in, e.g. as typed or unstructured objects.
+// Numbers are kept as written. The annotations, the documents of the patch results and Normalize are canonical.
+func CanonicalJSON(document []byte) ([]byte, error) {
+	if len(document) == 0 {
+		return document, nil
+	}
+
+	var value interface{}
+	decoder := json.ConfigCompatibleWithStandardLibrary.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal byte sequence")
+	}
+
+	canonical, err := json.ConfigCompatibleWithStandardLibrary.Marshal(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal byte sequence")
+	}
+	return canonical, nil
+}
+
+// canonicalResult makes the documents of the result canonical.
+func canonicalResult(result *PatchResult) error {
+	for _, document := range []*[]byte{&result.Patch, &result.Current, &result.Modified, &result.Original} {
+		canonical, err := CanonicalJSON(*document)
+		if err != nil {
+			return err
+		}
+		*document = canonical
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	canonical, err := CanonicalJSON([]byte(`{"b": {"d": 1, "c": [ {"f": 9007199254740993, "e": 1.50} ]}, "a": null}`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":null,"b":{"c":[{"e":1.50,"f":9007199254740993}],"d":1}}`, string(canonical))
+
+	_, err = CanonicalJSON([]byte(`{"a":`))
+	require.Error(t, err)
+}
+
+func TestCanonicalAnnotationAndResult(t *testing.T) {
+	typed := newBenchmarkDeployment()
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(typed.DeepCopy())
+	require.NoError(t, err)
+	untyped := &unstructured.Unstructured{Object: content}
+
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(typed))
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(untyped))
+	assert.Equal(t, typed.Annotations[LastAppliedConfig], untyped.GetAnnotations()[LastAppliedConfig])
+
+	modified := newBenchmarkDeployment()
+	modified.Spec.Template.Spec.Containers[0].Image = "nginx:1.24"
+	result, err := DefaultPatchMaker.Calculate(typed, modified)
+	require.NoError(t, err)
+	for _, document := range [][]byte{result.Patch, result.Current, result.Modified, result.Original} {
+		canonical, err := CanonicalJSON(document)
+		require.NoError(t, err)
+		assert.Equal(t, string(canonical), string(document))
+	}
+}
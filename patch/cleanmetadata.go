@@ -0,0 +1,170 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"regexp"
+	"strings"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+type cleanMetadataConfig struct {
+	keepLabels      []*regexp.Regexp
+	keepAnnotations []*regexp.Regexp
+	dropLabels      []*regexp.Regexp
+	dropAnnotations []*regexp.Regexp
+	keepFinalizers  bool
+}
+
+// CleanMetadataOption customizes the metadata kept by CleanMetadata.
+type CleanMetadataOption func(*cleanMetadataConfig)
+
+// WithKeepLabels only keeps the labels matching one of the patterns, where * matches any sequence of characters,
+// e.g. app.kubernetes.io/*. All the labels are kept by default.
+func WithKeepLabels(patterns ...string) CleanMetadataOption {
+	return func(c *cleanMetadataConfig) {
+		c.keepLabels = append(c.keepLabels, keyPatterns(patterns)...)
+	}
+}
+
+// WithKeepAnnotations only keeps the annotations matching one of the patterns, where * matches any sequence of characters.
+// All the annotations are kept by default.
+func WithKeepAnnotations(patterns ...string) CleanMetadataOption {
+	return func(c *cleanMetadataConfig) {
+		c.keepAnnotations = append(c.keepAnnotations, keyPatterns(patterns)...)
+	}
+}
+
+// WithDropLabelsMatching drops the labels whose key matches one of the regular expressions.
+func WithDropLabelsMatching(expressions ...*regexp.Regexp) CleanMetadataOption {
+	return func(c *cleanMetadataConfig) {
+		c.dropLabels = append(c.dropLabels, expressions...)
+	}
+}
+
+// WithDropAnnotationsMatching drops the annotations whose key matches one of the regular expressions.
+func WithDropAnnotationsMatching(expressions ...*regexp.Regexp) CleanMetadataOption {
+	return func(c *cleanMetadataConfig) {
+		c.dropAnnotations = append(c.dropAnnotations, expressions...)
+	}
+}
+
+// WithKeepFinalizers keeps the finalizers, which are dropped by default.
+func WithKeepFinalizers() CleanMetadataOption {
+	return func(c *cleanMetadataConfig) {
+		c.keepFinalizers = true
+	}
+}
+
+// CleanMetadata removes the metadata set by the API server from both objects before comparing them: only the labels,
+// the annotations but the kubectl last-applied annotation, and the owner references are kept. The options choose the
+// labels and annotations kept and whether the finalizers are kept. The labels and annotations of the embedded pod, job
+// and volume claim templates are filtered the same way.
+func CleanMetadata(opts ...CleanMetadataOption) CalculateOption {
+	config := &cleanMetadataConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		current, err := config.cleanMetadata(current)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not clean metadata field from current byte sequence")
+		}
+
+		modified, err = config.cleanMetadata(modified)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not clean metadata field from modified byte sequence")
+		}
+
+		return current, modified, nil
+	}
+}
+
+func (c *cleanMetadataConfig) cleanMetadata(obj []byte) ([]byte, error) {
+	resource := map[string]any{}
+	err := json.Unmarshal(obj, &resource)
+	if err != nil {
+		return []byte{}, errors.Wrap(err, "could not unmarshal byte sequence")
+	}
+
+	if metadata, ok := resource["metadata"]; ok {
+		if metadata, ok := metadata.(map[string]any); ok {
+			if annotations, ok := metadata["annotations"].(map[string]any); ok {
+				delete(annotations, KubectlLastAppliedConfig)
+			}
+			cleaned := map[string]any{
+				"labels":          filterMetadataKeys(metadata["labels"], c.keepLabels, c.dropLabels),
+				"annotations":     filterMetadataKeys(metadata["annotations"], c.keepAnnotations, c.dropAnnotations),
+				"ownerReferences": metadata["ownerReferences"],
+				"fake":            "fake", // Need to put this to avoid to have nil metadata
+			}
+			if c.keepFinalizers {
+				cleaned["finalizers"] = metadata["finalizers"]
+			}
+			resource["metadata"] = cleaned
+		}
+	}
+
+	// The keys are filtered in place
+	for _, metadata := range templateMetadata(resource) {
+		filterMetadataKeys(metadata["labels"], c.keepLabels, c.dropLabels)
+		filterMetadataKeys(metadata["annotations"], c.keepAnnotations, c.dropAnnotations)
+	}
+
+	obj, err = json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+	if err != nil {
+		return []byte{}, errors.Wrap(err, "could not marshal byte sequence")
+	}
+
+	return obj, nil
+}
+
+// filterMetadataKeys removes the keys of the labels or annotations which don't match one of the kept patterns, when there
+// are some, or match one of the dropped expressions.
+func filterMetadataKeys(values any, keep, drop []*regexp.Regexp) any {
+	typedValues, ok := values.(map[string]any)
+	if !ok || (len(keep) == 0 && len(drop) == 0) {
+		return values
+	}
+
+	for key := range typedValues {
+		if (len(keep) > 0 && !matchesAny(keep, key)) || matchesAny(drop, key) {
+			delete(typedValues, key)
+		}
+	}
+	return typedValues
+}
+
+func matchesAny(expressions []*regexp.Regexp, key string) bool {
+	for _, expression := range expressions {
+		if expression.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyPatterns compiles patterns where * matches any sequence of characters into anchored regular expressions.
+func keyPatterns(patterns []string) []*regexp.Regexp {
+	expressions := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		quoted := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+		expressions = append(expressions, regexp.MustCompile("^"+quoted+"$"))
+	}
+	return expressions
+}
@@ -0,0 +1,132 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cleanMetadataObject = `{
+	"metadata": {
+		"name": "test",
+		"resourceVersion": "1",
+		"labels": {"app.kubernetes.io/name": "test", "pod-template-hash": "abc"},
+		"annotations": {
+			"example.com/config": "a",
+			"sidecar.istio.io/status": "injected",
+			"kubectl.kubernetes.io/last-applied-configuration": "{}"
+		},
+		"finalizers": ["example.com/finalizer"]
+	},
+	"spec": {"replicas": 1}
+}`
+
+func TestCleanMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []CleanMetadataOption
+		expected string
+	}{
+		{
+			name: "default",
+			expected: `{
+				"metadata": {
+					"fake": "fake",
+					"labels": {"app.kubernetes.io/name": "test", "pod-template-hash": "abc"},
+					"annotations": {"example.com/config": "a", "sidecar.istio.io/status": "injected"},
+					"ownerReferences": null
+				},
+				"spec": {"replicas": 1}
+			}`,
+		},
+		{
+			name: "keep labels",
+			opts: []CleanMetadataOption{WithKeepLabels("app.kubernetes.io/*")},
+			expected: `{
+				"metadata": {
+					"fake": "fake",
+					"labels": {"app.kubernetes.io/name": "test"},
+					"annotations": {"example.com/config": "a", "sidecar.istio.io/status": "injected"},
+					"ownerReferences": null
+				},
+				"spec": {"replicas": 1}
+			}`,
+		},
+		{
+			name: "drop annotations and keep finalizers",
+			opts: []CleanMetadataOption{WithDropAnnotationsMatching(regexp.MustCompile(`\.istio\.io/`)), WithKeepFinalizers()},
+			expected: `{
+				"metadata": {
+					"fake": "fake",
+					"labels": {"app.kubernetes.io/name": "test", "pod-template-hash": "abc"},
+					"annotations": {"example.com/config": "a"},
+					"ownerReferences": null,
+					"finalizers": ["example.com/finalizer"]
+				},
+				"spec": {"replicas": 1}
+			}`,
+		},
+		{
+			name: "keep annotations and drop labels",
+			opts: []CleanMetadataOption{WithKeepAnnotations("example.com/*"), WithDropLabelsMatching(regexp.MustCompile(`-hash$`))},
+			expected: `{
+				"metadata": {
+					"fake": "fake",
+					"labels": {"app.kubernetes.io/name": "test"},
+					"annotations": {"example.com/config": "a"},
+					"ownerReferences": null
+				},
+				"spec": {"replicas": 1}
+			}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			current, modified, err := CleanMetadata(test.opts...)([]byte(cleanMetadataObject), []byte(cleanMetadataObject))
+			require.NoError(t, err)
+			assert.JSONEq(t, test.expected, string(current))
+			assert.JSONEq(t, test.expected, string(modified))
+		})
+	}
+}
+
+func TestCleanMetadataEmbeddedTemplates(t *testing.T) {
+	object := []byte(`{
+		"metadata": {"name": "test"},
+		"spec": {
+			"template": {"metadata": {"labels": {"app": "test"}, "annotations": {"sidecar.istio.io/status": "injected", "example.com/config": "a"}}},
+			"volumeClaimTemplates": [{"metadata": {"name": "data", "labels": {"app": "test", "pod-template-hash": "abc"}}}]
+		}
+	}`)
+
+	current, _, err := CleanMetadata(
+		WithDropAnnotationsMatching(regexp.MustCompile(`\.istio\.io/`)),
+		WithDropLabelsMatching(regexp.MustCompile(`-hash$`)),
+	)(object, object)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"metadata": {"fake": "fake", "labels": null, "annotations": null, "ownerReferences": null},
+		"spec": {
+			"template": {"metadata": {"labels": {"app": "test"}, "annotations": {"example.com/config": "a"}}},
+			"volumeClaimTemplates": [{"metadata": {"name": "data", "labels": {"app": "test"}}}]
+		}
+	}`, string(current))
+}
@@ -0,0 +1,218 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"sync"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+// ScopedOption is a CalculateOption applied to the values at a path of both objects only.
+type ScopedOption struct {
+	// Path of the field the option receives, in the syntax accepted by IgnoreJSONPath without wildcards and indexes,
+	// e.g. .spec.template
+	Path   string
+	Option CalculateOption
+}
+
+// ForPath scopes the option to the values at the path, see ScopedOption.
+func ForPath(path string, opt CalculateOption) ScopedOption {
+	return ScopedOption{Path: path, Option: opt}
+}
+
+// Concurrently applies options scoped to disjoint paths concurrently. Both objects are parsed once, each option
+// receives only the JSON documents of its field (null when the field is missing) and the results are put back
+// before marshaling the objects once. It fails when a path contains a wildcard or an index, or when a path
+// is the parent of another one.
+func Concurrently(opts ...ScopedOption) CalculateOption {
+	paths := make([][]string, 0, len(opts))
+	var parseErr error
+	for _, opt := range opts {
+		path, err := parseFieldPath(opt.Path)
+		if err != nil {
+			parseErr = errors.Append(parseErr, err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if parseErr == nil {
+		parseErr = checkDisjointPaths(opts, paths)
+	}
+
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		if parseErr != nil {
+			return []byte{}, []byte{}, parseErr
+		}
+
+		currentResource := map[string]interface{}{}
+		if err := json.Unmarshal(current, &currentResource); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for current")
+		}
+		modifiedResource := map[string]interface{}{}
+		if err := json.Unmarshal(modified, &modifiedResource); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for modified")
+		}
+
+		type scopedResult struct {
+			current, modified interface{}
+			err               error
+		}
+		results := make([]scopedResult, len(opts))
+
+		var wg sync.WaitGroup
+		for i := range opts {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				result := &results[i]
+				result.current, result.modified, result.err = applyScopedOption(opts[i].Option,
+					fieldValue(currentResource, paths[i]), fieldValue(modifiedResource, paths[i]))
+				if result.err != nil {
+					result.err = errors.WrapIfWithDetails(result.err, "could not apply scoped option", "path", opts[i].Path)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		var err error
+		for i, result := range results {
+			if result.err != nil {
+				err = errors.Append(err, result.err)
+				continue
+			}
+			setFieldValue(currentResource, paths[i], result.current)
+			setFieldValue(modifiedResource, paths[i], result.modified)
+		}
+		if err != nil {
+			return []byte{}, []byte{}, err
+		}
+
+		current, err = json.ConfigCompatibleWithStandardLibrary.Marshal(currentResource)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not marshal byte sequence for current")
+		}
+		modified, err = json.ConfigCompatibleWithStandardLibrary.Marshal(modifiedResource)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not marshal byte sequence for modified")
+		}
+
+		return current, modified, nil
+	}
+}
+
+// applyScopedOption applies the option on the JSON documents of the values. The values are only read,
+// the results are new values.
+func applyScopedOption(opt CalculateOption, current, modified interface{}) (interface{}, interface{}, error) {
+	currentValue, err := json.ConfigCompatibleWithStandardLibrary.Marshal(current)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not marshal byte sequence for current")
+	}
+	modifiedValue, err := json.ConfigCompatibleWithStandardLibrary.Marshal(modified)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not marshal byte sequence for modified")
+	}
+
+	currentValue, modifiedValue, err = opt(currentValue, modifiedValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var currentResult, modifiedResult interface{}
+	if err := json.Unmarshal(currentValue, &currentResult); err != nil {
+		return nil, nil, errors.Wrap(err, "could not unmarshal byte sequence for current")
+	}
+	if err := json.Unmarshal(modifiedValue, &modifiedResult); err != nil {
+		return nil, nil, errors.Wrap(err, "could not unmarshal byte sequence for modified")
+	}
+
+	return currentResult, modifiedResult, nil
+}
+
+// parseFieldPath parses a path made of field names only.
+func parseFieldPath(path string) ([]string, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if segment.kind != fieldSegment {
+			return nil, errors.Errorf("invalid path %q: only field names are supported", path)
+		}
+		fields = append(fields, segment.name)
+	}
+	return fields, nil
+}
+
+func checkDisjointPaths(opts []ScopedOption, paths [][]string) error {
+	for i := range paths {
+		for j := i + 1; j < len(paths); j++ {
+			if isFieldPathPrefix(paths[i], paths[j]) || isFieldPathPrefix(paths[j], paths[i]) {
+				return errors.Errorf("paths %q and %q overlap", opts[i].Path, opts[j].Path)
+			}
+		}
+	}
+	return nil
+}
+
+func isFieldPathPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldValue returns the value at the path, nil if it's missing.
+func fieldValue(resource map[string]interface{}, path []string) interface{} {
+	var node interface{} = resource
+	for _, field := range path {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		node = object[field]
+	}
+	return node
+}
+
+// setFieldValue sets the value at the path, creating the missing parents. A nil value removes the field.
+func setFieldValue(resource map[string]interface{}, path []string, value interface{}) {
+	node := resource
+	for _, field := range path[:len(path)-1] {
+		child, ok := node[field].(map[string]interface{})
+		if !ok {
+			if value == nil {
+				return
+			}
+			child = map[string]interface{}{}
+			node[field] = child
+		}
+		node = child
+	}
+
+	if value == nil {
+		delete(node, path[len(path)-1])
+		return
+	}
+	node[path[len(path)-1]] = value
+}
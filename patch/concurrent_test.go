@@ -0,0 +1,55 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrently(t *testing.T) {
+	current := []byte(`{"metadata":{"name":"app","labels":{"a":"b"}},"spec":{"replicas":1,"template":{"x":"y"}},"status":{"ready":true}}`)
+	modified := []byte(`{"metadata":{"name":"app","labels":{"a":"c"}},"spec":{"replicas":2,"template":{"x":"y"}}}`)
+
+	opt := Concurrently(
+		ForPath(".metadata", IgnoreField("labels")),
+		ForPath(".spec", IgnoreField("replicas")),
+		ForPath(".status", IgnoreStatusFields()),
+		ForPath(".missing.field", IgnoreField("anything")),
+	)
+	current, modified, err := opt(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"name":"app"},"spec":{"template":{"x":"y"}},"status":{"ready":true}}`, string(current))
+	assert.JSONEq(t, `{"metadata":{"name":"app"},"spec":{"template":{"x":"y"}}}`, string(modified))
+
+	// Options returning null remove the field
+	removeAll := func(current, modified []byte) ([]byte, []byte, error) {
+		return []byte("null"), []byte("null"), nil
+	}
+	current, _, err = Concurrently(ForPath(".status", removeAll))(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"name":"app"},"spec":{"template":{"x":"y"}}}`, string(current))
+}
+
+func TestConcurrentlyInvalidPaths(t *testing.T) {
+	document := []byte(`{"spec":{}}`)
+
+	_, _, err := Concurrently(ForPath(".spec", IgnoreStatusFields()), ForPath(".spec.template", IgnoreStatusFields()))(document, document)
+	assert.Error(t, err)
+
+	_, _, err = Concurrently(ForPath(".spec.containers[*]", IgnoreStatusFields()))(document, document)
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+// Conflict is a field changed by a patch while owned by another field manager.
+type Conflict struct {
+	// Manager owning the field and the Operation (Apply or Update) it was set with, as found in the managedFields
+	Manager   string
+	Operation string
+	// Path of the field in the syntax accepted by IgnoreJSONPath, e.g. .spec.replicas
+	Path string
+}
+
+// Conflicts returns the fields changed by the patch which are owned by other field managers than the FieldManager
+// of the result, according to the metadata.managedFields of the current object, sorted by path and manager.
+// It tells which managers would be overwritten before force-applying the patch with server-side apply or sending
+// a strategic merge patch. The last-applied annotation is not reported. It returns nil if the patch is empty or
+// the result doesn't contain the patched object.
+func (p *PatchResult) Conflicts() []Conflict {
+	conflicts, err := p.conflicts()
+	if err != nil {
+		return nil
+	}
+	return conflicts
+}
+
+func (p *PatchResult) conflicts() ([]Conflict, error) {
+	if p.IsEmpty() {
+		return nil, nil
+	}
+	if p.patchedCurrent == nil {
+		return nil, errors.New("patch result does not contain the patched object")
+	}
+
+	var current map[string]interface{}
+	if err := json.Unmarshal(p.currentOrg, &current); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal current byte sequence")
+	}
+	metadata, _ := current["metadata"].(map[string]interface{})
+	managedFields, _ := metadata["managedFields"].([]interface{})
+	if len(managedFields) == 0 {
+		return nil, nil
+	}
+
+	changes, err := diffJSON(p.currentOrg, p.patchedCurrent)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[Conflict]bool{}
+	var conflicts []Conflict
+	for _, entry := range managedFields {
+		entry, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		manager, _ := entry["manager"].(string)
+		if manager == p.FieldManager && manager != "" {
+			continue
+		}
+		fields, ok := entry["fieldsV1"].(map[string]interface{})
+		if !ok || len(fields) == 0 {
+			continue
+		}
+		operation, _ := entry["operation"].(string)
+
+		for _, change := range changes {
+			if p.isLastAppliedPath(change.path) || !ownsPath(fields, current, change.path) {
+				continue
+			}
+			conflict := Conflict{Manager: manager, Operation: operation, Path: change.jsonPath()}
+			if !seen[conflict] {
+				seen[conflict] = true
+				conflicts = append(conflicts, conflict)
+			}
+		}
+	}
+
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		if conflicts[i].Path != conflicts[j].Path {
+			return conflicts[i].Path < conflicts[j].Path
+		}
+		return conflicts[i].Manager < conflicts[j].Manager
+	})
+
+	return conflicts, nil
+}
+
+// isLastAppliedPath tells whether the path is the last-applied annotation of the annotator.
+func (p *PatchResult) isLastAppliedPath(path []interface{}) bool {
+	annotator, ok := p.annotator.(*Annotator)
+	if !ok || len(path) != 3 {
+		return false
+	}
+	return path[0] == "metadata" && path[1] == "annotations" && path[2] == annotator.key
+}
+
+// ownsPath tells whether the fieldsV1 set owns the value at the path of the document, the value itself, one of its
+// children or one of its parents as a whole.
+func ownsPath(fields map[string]interface{}, document interface{}, path []interface{}) bool {
+	set := fields
+	node := document
+	for _, segment := range path {
+		var child map[string]interface{}
+		var ok bool
+
+		switch typedSegment := segment.(type) {
+		case string:
+			child, ok = set["f:"+typedSegment].(map[string]interface{})
+			object, _ := node.(map[string]interface{})
+			node = object[typedSegment]
+		case int:
+			list, _ := node.([]interface{})
+			if typedSegment >= len(list) {
+				return false
+			}
+			child, ok = listItemFieldSet(set, list, typedSegment)
+			node = list[typedSegment]
+		}
+
+		if !ok {
+			return false
+		}
+		if isLeafFieldSet(child) {
+			return true
+		}
+		set = child
+	}
+	return true
+}
+
+// listItemFieldSet returns the field set of the list item at index.
+func listItemFieldSet(set map[string]interface{}, list []interface{}, index int) (map[string]interface{}, bool) {
+	for key, value := range set {
+		child, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(key, "i:") {
+			if i, err := strconv.Atoi(strings.TrimPrefix(key, "i:")); err == nil && i == index {
+				return child, true
+			}
+			continue
+		}
+		if len(listItemsForField(list[index:index+1], key)) > 0 {
+			return child, true
+		}
+	}
+	return nil, false
+}
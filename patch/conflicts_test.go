@@ -0,0 +1,84 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConflicts(t *testing.T) {
+	newDeployment := func(replicas int32, image string, env string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "app"}},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"app": "app"}},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{
+						Name:  "app",
+						Image: image,
+						Env:   []corev1.EnvVar{{Name: "MODE", Value: env}},
+					}}},
+				},
+			},
+		}
+	}
+
+	current := newDeployment(1, "nginx:1", "a")
+	mustAnnotate(current)
+	current.ManagedFields = []v1.ManagedFieldsEntry{
+		{
+			Manager:    "operator",
+			Operation:  v1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1: &v1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{".":{},"f:banzai.cloud/last-applied":{}}},` +
+				`"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"app\"}":{".":{},"f:name":{},"f:env":{}}}}}}}`)},
+		},
+		{
+			Manager:    "hpa-controller",
+			Operation:  v1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &v1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)},
+		},
+		{
+			Manager:    "image-updater",
+			Operation:  v1.ManagedFieldsOperationApply,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &v1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"app\"}":{"f:image":{}}}}}}}`)},
+		},
+	}
+
+	result, err := DefaultPatchMaker.Calculate(current, newDeployment(3, "nginx:2", "b"))
+	assert.NoError(t, err)
+	assert.Equal(t, []Conflict{
+		{Manager: "hpa-controller", Operation: "Update", Path: ".spec.replicas"},
+		{Manager: "operator", Operation: "Update", Path: ".spec.template.spec.containers[0].env[0].value"},
+		{Manager: "image-updater", Operation: "Apply", Path: ".spec.template.spec.containers[0].image"},
+	}, result.Conflicts())
+
+	// The field manager of the result is not reported
+	result.FieldManager = "operator"
+	assert.Len(t, result.Conflicts(), 2)
+
+	result, err = DefaultPatchMaker.Calculate(current, current)
+	assert.NoError(t, err)
+	assert.Nil(t, result.Conflicts())
+}
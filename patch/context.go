@@ -0,0 +1,126 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// CalculateContext describes the objects being compared to a CalculateOptionCtx.
+type CalculateContext struct {
+	CurrentObject  runtime.Object
+	ModifiedObject runtime.Object
+
+	// GVK of the compared objects, resolved from the scheme for typed objects without TypeMeta.
+	// It is empty if the kind is unknown.
+	GVK schema.GroupVersionKind
+
+	// Context given to CalculateWithContext, used for the calls to the API server made while calculating the patch.
+	Context context.Context
+}
+
+// callerContext returns the context of the caller, or the background context if there is none.
+func (c CalculateContext) callerContext() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
+}
+
+// CalculateOptionCtx is a CalculateOption receiving the objects being compared, to implement kind specific logic
+// without inspecting the JSON documents.
+type CalculateOptionCtx func(ctx CalculateContext, current, modified []byte) ([]byte, []byte, error)
+
+// WithoutContext adapts a CalculateOption to a CalculateOptionCtx.
+func WithoutContext(opt CalculateOption) CalculateOptionCtx {
+	return func(_ CalculateContext, current, modified []byte) ([]byte, []byte, error) {
+		return opt(current, modified)
+	}
+}
+
+// ForGroupKinds applies the option only when the compared objects have one of the given group kinds.
+func ForGroupKinds(opt CalculateOption, groupKinds ...schema.GroupKind) CalculateOptionCtx {
+	return func(ctx CalculateContext, current, modified []byte) ([]byte, []byte, error) {
+		for _, groupKind := range groupKinds {
+			if ctx.GVK.GroupKind() == groupKind {
+				return opt(current, modified)
+			}
+		}
+		return current, modified, nil
+	}
+}
+
+// OptionPredicate tells whether options apply to the compared objects, from their kind and documents.
+type OptionPredicate func(gvk schema.GroupVersionKind, current, modified []byte) bool
+
+// When applies the options in order only when the predicate matches the compared objects, so a single option list
+// can be shared by many kinds with kind specific behavior. The documents given to the predicate are the ones the
+// previous options produced.
+func When(predicate OptionPredicate, opts ...CalculateOption) CalculateOptionCtx {
+	return func(ctx CalculateContext, current, modified []byte) ([]byte, []byte, error) {
+		if !predicate(ctx.GVK, current, modified) {
+			return current, modified, nil
+		}
+
+		var err error
+		for _, opt := range opts {
+			current, modified, err = opt(current, modified)
+			if err != nil {
+				return []byte{}, []byte{}, err
+			}
+		}
+		return current, modified, nil
+	}
+}
+
+// newCalculateContext resolves the kind of the compared objects, from the defaulting scheme if it's configured
+// or from the client-go scheme when the objects don't carry it.
+func (p *PatchMaker) newCalculateContext(callerCtx context.Context, currentObject, modifiedObject runtime.Object) CalculateContext {
+	ctx := CalculateContext{
+		CurrentObject:  currentObject,
+		ModifiedObject: modifiedObject,
+		Context:        callerCtx,
+	}
+
+	ctx.GVK = currentObject.GetObjectKind().GroupVersionKind()
+	if ctx.GVK.Empty() {
+		ctx.GVK = objectGroupVersionKind(modifiedObject, p.defaultingScheme)
+	}
+
+	return ctx
+}
+
+// objectGroupVersionKind returns the kind the object carries, or the one registered in the given schemes
+// or the client-go scheme. It is empty if the kind is unknown.
+func objectGroupVersionKind(obj runtime.Object, schemes ...*runtime.Scheme) schema.GroupVersionKind {
+	if gvk := obj.GetObjectKind().GroupVersionKind(); !gvk.Empty() {
+		return gvk
+	}
+
+	for _, scheme := range append(schemes, clientgoscheme.Scheme) {
+		if scheme == nil {
+			continue
+		}
+		if gvks, _, err := scheme.ObjectKinds(obj); err == nil && len(gvks) > 0 {
+			return gvks[0]
+		}
+	}
+
+	return schema.GroupVersionKind{}
+}
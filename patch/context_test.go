@@ -0,0 +1,103 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCalculateCtx(t *testing.T) {
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "config",
+				Namespace: "default",
+			},
+			Data: map[string]string{
+				"key": value,
+			},
+		}
+	}
+
+	current := newConfigMap("a")
+	mustAnnotate(current)
+
+	// The kind of typed objects is resolved from the scheme
+	var gotContext CalculateContext
+	recordContext := func(ctx CalculateContext, current, modified []byte) ([]byte, []byte, error) {
+		gotContext = ctx
+		return current, modified, nil
+	}
+	_, err := DefaultPatchMaker.(CtxMaker).CalculateCtx(current, newConfigMap("b"), recordContext)
+	assert.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, gotContext.GVK)
+	assert.Equal(t, current, gotContext.CurrentObject)
+
+	patch, err := DefaultPatchMaker.(CtxMaker).CalculateCtx(current, newConfigMap("b"), ForGroupKinds(IgnoreField("data"), schema.GroupKind{Kind: "ConfigMap"}))
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.(CtxMaker).CalculateCtx(current, newConfigMap("b"), ForGroupKinds(IgnoreField("data"), schema.GroupKind{Kind: "Secret"}))
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	// Unstructured objects carry their kind
+	unstructuredCurrent := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Foo",
+		"metadata": map[string]interface{}{
+			"name": "foo",
+		},
+	}}
+	_, err = DefaultPatchMaker.(CtxMaker).CalculateCtx(unstructuredCurrent, unstructuredCurrent.DeepCopy(), recordContext)
+	assert.NoError(t, err)
+	assert.Equal(t, schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"}, gotContext.GVK)
+}
+
+func TestWhen(t *testing.T) {
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "config",
+				Namespace: "default",
+			},
+			Data: map[string]string{
+				"key": value,
+			},
+		}
+	}
+	isConfigMap := func(gvk schema.GroupVersionKind, current, modified []byte) bool {
+		return gvk.GroupKind() == schema.GroupKind{Kind: "ConfigMap"}
+	}
+	isSecret := func(gvk schema.GroupVersionKind, current, modified []byte) bool {
+		return gvk.GroupKind() == schema.GroupKind{Kind: "Secret"}
+	}
+
+	current := mustAnnotate(newConfigMap("a"))
+
+	result, err := DefaultPatchMaker.(CtxMaker).CalculateCtx(current, newConfigMap("b"), When(isSecret, IgnoreField("data")))
+	assert.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.(CtxMaker).CalculateCtx(current, newConfigMap("b"), When(isConfigMap, IgnoreStatusFields(), IgnoreField("data")))
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+}
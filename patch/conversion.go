@@ -0,0 +1,99 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// WithConversion makes Calculate convert the modified object to the version of the current object with the conversion
+// functions registered in the scheme when they are different versions of the same kind, e.g. a policy/v1beta1
+// PodDisruptionBudget compared with the policy/v1 one served by the API server. Without conversion the documents of
+// both versions are compared as they are. Calculate fails when the scheme can't convert the object. The patched object is
+// annotated with the converted object.
+func WithConversion(scheme *runtime.Scheme) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.conversionScheme = scheme
+	}
+}
+
+// UnstructuredConverter converts unstructured objects to another version of their group, e.g. custom resources with the
+// conversion webhook of their CustomResourceDefinition, see ConversionWebhookConverter.
+type UnstructuredConverter interface {
+	ConvertUnstructured(obj *unstructured.Unstructured, version schema.GroupVersion) (*unstructured.Unstructured, error)
+}
+
+// UnstructuredConverterFunc is an UnstructuredConverter function.
+type UnstructuredConverterFunc func(obj *unstructured.Unstructured, version schema.GroupVersion) (*unstructured.Unstructured, error)
+
+func (f UnstructuredConverterFunc) ConvertUnstructured(obj *unstructured.Unstructured, version schema.GroupVersion) (*unstructured.Unstructured, error) {
+	return f(obj, version)
+}
+
+// WithUnstructuredConverter makes Calculate convert the unstructured modified object to the version of the current object
+// with the converter when they are different versions of the same kind, like WithConversion for the kinds unknown to a
+// scheme, e.g. while the served version of a custom resource is migrated. The converter is used instead of the scheme
+// for unstructured modified objects.
+func WithUnstructuredConverter(converter UnstructuredConverter) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.unstructuredConverter = converter
+	}
+}
+
+// converted returns the modified object converted to the version of the current object, or the modified object itself
+// when no conversion is needed.
+func (p *PatchMaker) converted(currentObject, modifiedObject runtime.Object) (runtime.Object, error) {
+	if p.conversionScheme == nil && p.unstructuredConverter == nil {
+		return modifiedObject, nil
+	}
+
+	currentGVK := objectGroupVersionKind(currentObject, p.conversionScheme)
+	modifiedGVK := objectGroupVersionKind(modifiedObject, p.conversionScheme)
+	if currentGVK.Empty() || modifiedGVK.Empty() || currentGVK.GroupKind() != modifiedGVK.GroupKind() || currentGVK.Version == modifiedGVK.Version {
+		return modifiedObject, nil
+	}
+
+	var converted runtime.Object
+	var err error
+	if unstructuredModified, ok := modifiedObject.(*unstructured.Unstructured); ok && p.unstructuredConverter != nil {
+		converted, err = p.unstructuredConverter.ConvertUnstructured(unstructuredModified.DeepCopy(), currentGVK.GroupVersion())
+	} else if p.conversionScheme != nil {
+		converted, err = p.conversionScheme.ConvertToVersion(modifiedObject.DeepCopyObject(), currentGVK.GroupVersion())
+	} else {
+		return modifiedObject, nil
+	}
+	if err != nil {
+		return nil, errors.WrapIfWithDetails(err, "could not convert modified object", "from", modifiedGVK.String(), "to", currentGVK.String())
+	}
+
+	if _, ok := currentObject.(*unstructured.Unstructured); ok {
+		if _, ok := converted.(*unstructured.Unstructured); !ok {
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(converted)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not convert modified object to unstructured")
+			}
+			converted = &unstructured.Unstructured{Object: content}
+		}
+		converted.GetObjectKind().SetGroupVersionKind(currentGVK)
+	} else if currentObject.GetObjectKind().GroupVersionKind().Empty() {
+		// Typed objects usually don't carry their kind, keep it unset like on the current object
+		converted.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+	}
+
+	return converted, nil
+}
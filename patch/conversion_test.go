@@ -0,0 +1,128 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func newPDBConversionScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, policyv1.AddToScheme(scheme))
+	require.NoError(t, policyv1beta1.AddToScheme(scheme))
+	require.NoError(t, scheme.AddConversionFunc((*policyv1beta1.PodDisruptionBudget)(nil), (*policyv1.PodDisruptionBudget)(nil),
+		func(a, b interface{}, _ conversion.Scope) error {
+			in, out := a.(*policyv1beta1.PodDisruptionBudget), b.(*policyv1.PodDisruptionBudget)
+			out.ObjectMeta = in.ObjectMeta
+			out.Spec = policyv1.PodDisruptionBudgetSpec{
+				MinAvailable:   in.Spec.MinAvailable,
+				MaxUnavailable: in.Spec.MaxUnavailable,
+				Selector:       in.Spec.Selector,
+			}
+			return nil
+		}))
+	return scheme
+}
+
+func newConvertedPDB(minAvailable int) *policyv1.PodDisruptionBudget {
+	value := intstr.FromInt(minAvailable)
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: v1.ObjectMeta{Name: "pdb", Namespace: "default"},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MinAvailable: &value,
+		},
+	}
+}
+
+func newLegacyPDB(minAvailable int) *policyv1beta1.PodDisruptionBudget {
+	value := intstr.FromInt(minAvailable)
+	return &policyv1beta1.PodDisruptionBudget{
+		TypeMeta:   v1.TypeMeta{APIVersion: "policy/v1beta1", Kind: "PodDisruptionBudget"},
+		ObjectMeta: v1.ObjectMeta{Name: "pdb", Namespace: "default"},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &value,
+		},
+	}
+}
+
+func TestWithConversion(t *testing.T) {
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithConversion(newPDBConversionScheme(t)))
+
+	current := newConvertedPDB(1)
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	result, err := patchMaker.Calculate(current, newLegacyPDB(1))
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+
+	result, err = patchMaker.Calculate(current, newLegacyPDB(2))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"minAvailable":2}}`, string(result.Patch))
+	patched := result.Patched.(*policyv1.PodDisruptionBudget)
+	original, err := DefaultAnnotator.GetOriginalConfiguration(patched)
+	require.NoError(t, err)
+	assert.NotContains(t, string(original), "v1beta1")
+
+	// Unstructured objects are converted too
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(current)
+	require.NoError(t, err)
+	unstructuredCurrent := &unstructured.Unstructured{Object: content}
+	unstructuredCurrent.SetAPIVersion("policy/v1")
+	unstructuredCurrent.SetKind("PodDisruptionBudget")
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(unstructuredCurrent))
+	content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(newLegacyPDB(2))
+	require.NoError(t, err)
+
+	result, err = patchMaker.Calculate(unstructuredCurrent, &unstructured.Unstructured{Object: content})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"minAvailable":2}}`, string(result.Patch))
+
+	// Versions without conversion fail instead of producing a patch mixing them
+	patchMaker = NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithConversion(runtime.NewScheme()))
+	_, err = patchMaker.Calculate(current, newLegacyPDB(2))
+	require.Error(t, err)
+}
+
+func TestWithUnstructuredConverter(t *testing.T) {
+	converter := UnstructuredConverterFunc(func(obj *unstructured.Unstructured, version schema.GroupVersion) (*unstructured.Unstructured, error) {
+		obj.SetAPIVersion(version.String())
+		return obj, nil
+	})
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithUnstructuredConverter(converter))
+
+	current := newVersionedApp("v1", map[string]interface{}{"replicas": int64(1)})
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+	modified := newVersionedApp("v1beta1", map[string]interface{}{"replicas": int64(1)})
+
+	result, err := patchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+	assert.Equal(t, "example.com/v1beta1", modified.GetAPIVersion())
+
+	result, err = DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"apiVersion":"example.com/v1beta1"}`, string(result.Patch))
+}
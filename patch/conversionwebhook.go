@@ -0,0 +1,132 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ConversionWebhookConverter converts custom resources like the API server does, by sending a ConversionReview to the
+// conversion webhook of their CustomResourceDefinition.
+type ConversionWebhookConverter struct {
+	// URL of the webhook, e.g. https://webhook.operators.svc:443/convert
+	URL string
+	// Client sends the reviews, http.DefaultClient if nil. It must trust the CA bundle of the webhook.
+	Client *http.Client
+}
+
+var _ UnstructuredConverter = &ConversionWebhookConverter{}
+
+// conversionReview is the apiextensions.k8s.io/v1 ConversionReview.
+type conversionReview struct {
+	APIVersion string              `json:"apiVersion"`
+	Kind       string              `json:"kind"`
+	Request    *conversionRequest  `json:"request,omitempty"`
+	Response   *conversionResponse `json:"response,omitempty"`
+}
+
+type conversionRequest struct {
+	UID               types.UID              `json:"uid"`
+	DesiredAPIVersion string                 `json:"desiredAPIVersion"`
+	Objects           []runtime.RawExtension `json:"objects"`
+}
+
+type conversionResponse struct {
+	UID              types.UID              `json:"uid"`
+	ConvertedObjects []runtime.RawExtension `json:"convertedObjects"`
+	Result           struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"result"`
+}
+
+func (c *ConversionWebhookConverter) ConvertUnstructured(obj *unstructured.Unstructured, version schema.GroupVersion) (*unstructured.Unstructured, error) {
+	document, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal object")
+	}
+	uid, err := newReviewUID()
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.ConfigCompatibleWithStandardLibrary.Marshal(conversionReview{
+		APIVersion: "apiextensions.k8s.io/v1",
+		Kind:       "ConversionReview",
+		Request: &conversionRequest{
+			UID:               uid,
+			DesiredAPIVersion: version.String(),
+			Objects:           []runtime.RawExtension{{Raw: document}},
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal conversion review")
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Post(c.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not send conversion review")
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.NewWithDetails("conversion webhook failed", "status", response.Status)
+	}
+
+	var review conversionReview
+	if err := json.ConfigCompatibleWithStandardLibrary.NewDecoder(response.Body).Decode(&review); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal conversion review")
+	}
+	switch {
+	case review.Response == nil:
+		return nil, errors.New("conversion review has no response")
+	case review.Response.UID != uid:
+		return nil, errors.NewWithDetails("conversion review response doesn't match the request", "uid", review.Response.UID)
+	case review.Response.Result.Status != "Success":
+		return nil, errors.NewWithDetails("conversion webhook rejected the object", "message", review.Response.Result.Message)
+	case len(review.Response.ConvertedObjects) != 1:
+		return nil, errors.NewWithDetails("conversion webhook returned an unexpected number of objects", "count", len(review.Response.ConvertedObjects))
+	}
+
+	converted := &unstructured.Unstructured{}
+	if err := converted.UnmarshalJSON(review.Response.ConvertedObjects[0].Raw); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal converted object")
+	}
+	if converted.GetAPIVersion() != version.String() {
+		return nil, errors.NewWithDetails("conversion webhook returned another version", "apiVersion", converted.GetAPIVersion())
+	}
+	return converted, nil
+}
+
+// newReviewUID returns a random identifier of conversion review.
+func newReviewUID() (types.UID, error) {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return "", errors.Wrap(err, "could not generate conversion review uid")
+	}
+	return types.UID(hex.EncodeToString(data)), nil
+}
@@ -0,0 +1,86 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// newConversionWebhook converts the example.com App objects between v1alpha1, with spec.size, and v1, with spec.replicas.
+func newConversionWebhook(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review conversionReview
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&review))
+
+		response := &conversionResponse{UID: review.Request.UID}
+		response.Result.Status = "Success"
+		for _, object := range review.Request.Objects {
+			obj := &unstructured.Unstructured{}
+			require.NoError(t, obj.UnmarshalJSON(object.Raw))
+			if size, found, _ := unstructured.NestedFieldCopy(obj.Object, "spec", "size"); found {
+				unstructured.RemoveNestedField(obj.Object, "spec", "size")
+				require.NoError(t, unstructured.SetNestedField(obj.Object, size, "spec", "replicas"))
+			}
+			obj.SetAPIVersion(review.Request.DesiredAPIVersion)
+			document, err := obj.MarshalJSON()
+			require.NoError(t, err)
+			response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: document})
+		}
+
+		review.Request, review.Response = nil, response
+		require.NoError(t, json.NewEncoder(w).Encode(review))
+	}))
+}
+
+func newVersionedApp(version string, spec map[string]interface{}) *unstructured.Unstructured {
+	app := newNullableApp(spec)
+	app.SetAPIVersion("example.com/" + version)
+	return app
+}
+
+func TestConversionWebhookConverter(t *testing.T) {
+	webhook := newConversionWebhook(t)
+	defer webhook.Close()
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		WithUnstructuredConverter(&ConversionWebhookConverter{URL: webhook.URL, Client: webhook.Client()}))
+
+	current := newVersionedApp("v1", map[string]interface{}{"replicas": int64(1)})
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	result, err := patchMaker.Calculate(current, newVersionedApp("v1alpha1", map[string]interface{}{"size": int64(1)}))
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+
+	result, err = patchMaker.Calculate(current, newVersionedApp("v1alpha1", map[string]interface{}{"size": int64(2)}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"replicas":2}}`, string(result.Patch))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	patchMaker = NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		WithUnstructuredConverter(&ConversionWebhookConverter{URL: failing.URL}))
+	_, err = patchMaker.Calculate(current, newVersionedApp("v1alpha1", map[string]interface{}{"size": int64(2)}))
+	require.Error(t, err)
+}
@@ -0,0 +1,40 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// WithSchemeDefaulting runs the defaulting functions registered in the scheme on a copy of the
+// modified object before comparing it, so values defaulted by the API server (e.g. imagePullPolicy
+// or terminationMessagePath) don't show up as differences.
+// The last-applied configuration is still computed from the modified object as it was given.
+func WithSchemeDefaulting(scheme *runtime.Scheme) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.defaultingScheme = scheme
+	}
+}
+
+// defaulted returns a defaulted copy of obj when a defaulting scheme is configured.
+func (p *PatchMaker) defaulted(obj runtime.Object) runtime.Object {
+	if p.defaultingScheme == nil {
+		return obj
+	}
+
+	defaulted := obj.DeepCopyObject()
+	p.defaultingScheme.Default(defaulted)
+	return defaulted
+}
@@ -0,0 +1,62 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestWithSchemeDefaulting(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddTypeDefaultingFunc(&corev1.Pod{}, func(obj interface{}) {
+		pod := obj.(*corev1.Pod)
+		for i := range pod.Spec.Tolerations {
+			if pod.Spec.Tolerations[i].Operator == "" {
+				pod.Spec.Tolerations[i].Operator = corev1.TolerationOpEqual
+			}
+		}
+	})
+	maker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithSchemeDefaulting(scheme))
+
+	modified := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "pod",
+		},
+		Spec: corev1.PodSpec{
+			Tolerations: []corev1.Toleration{
+				{Key: "dedicated", Value: "infra", Effect: corev1.TaintEffectNoSchedule},
+			},
+		},
+	}
+	current := modified.DeepCopy()
+	mustAnnotate(current)
+	current.Spec.Tolerations[0].Operator = corev1.TolerationOpEqual
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = maker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	// The modified object is left untouched
+	assert.Empty(t, modified.Spec.Tolerations[0].Operator)
+}
@@ -0,0 +1,188 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"reflect"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultRule declares that the field at Path of the objects of a kind is the same as Default when it's absent.
+type DefaultRule struct {
+	// Group and Kind of the objects the rule applies to. An empty Group matches every group, an empty Kind every kind.
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	// Path of the field in the syntax of IgnoreJSONPath, e.g. .spec.template.spec.containers[*].imagePullPolicy
+	Path    string      `json:"path"`
+	Default interface{} `json:"default"`
+}
+
+type parsedDefaultRule struct {
+	DefaultRule
+	path         []pathSegment
+	defaultValue interface{}
+}
+
+// LoadDefaultRules reads default rules from a YAML or JSON list, e.g.
+//
+//	# defaults of the Deployments
+//	- group: apps
+//	  kind: Deployment
+//	  path: .spec.revisionHistoryLimit
+//	  default: 10
+func LoadDefaultRules(data []byte) ([]DefaultRule, error) {
+	var rules []DefaultRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal default rules")
+	}
+	return rules, nil
+}
+
+// DefaultRules makes the fields declared by the rules compare equal whether they are absent or set to their default:
+// when the field is absent from one object and set to the default value in the other one, it is removed from the other
+// one. Rules are only applied to the objects of their kind.
+func DefaultRules(rules ...DefaultRule) CalculateOptionCtx {
+	parsedRules := make([]parsedDefaultRule, 0, len(rules))
+	var parseErr error
+	for _, rule := range rules {
+		path, err := parseJSONPath(rule.Path)
+		if err != nil {
+			parseErr = errors.Append(parseErr, err)
+			continue
+		}
+		defaultValue, err := normalizeJSONValue(rule.Default)
+		if err != nil {
+			parseErr = errors.Append(parseErr, errors.WrapIfWithDetails(err, "invalid default value", "path", rule.Path))
+			continue
+		}
+		parsedRules = append(parsedRules, parsedDefaultRule{DefaultRule: rule, path: path, defaultValue: defaultValue})
+	}
+
+	return func(ctx CalculateContext, current, modified []byte) ([]byte, []byte, error) {
+		if parseErr != nil {
+			return []byte{}, []byte{}, parseErr
+		}
+
+		var matchingRules []CalculateMapOption
+		for _, rule := range parsedRules {
+			if (rule.Group == "" || rule.Group == ctx.GVK.Group) && (rule.Kind == "" || rule.Kind == ctx.GVK.Kind) {
+				rule := rule
+				matchingRules = append(matchingRules, func(current, modified map[string]interface{}) error {
+					applyDefaultRule(current, modified, rule.path, rule.defaultValue)
+					return nil
+				})
+			}
+		}
+		if len(matchingRules) == 0 {
+			return current, modified, nil
+		}
+
+		return MapOptions(matchingRules...)(current, modified)
+	}
+}
+
+// normalizeJSONValue returns the value as decoded from JSON, e.g. with float64 numbers.
+func normalizeJSONValue(value interface{}) (interface{}, error) {
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// applyDefaultRule walks both documents along the path and removes the field at its end from one of them when it is
+// absent from the other one and set to the default value. A missing parent object is considered empty.
+func applyDefaultRule(current, modified interface{}, path []pathSegment, defaultValue interface{}) {
+	if len(path) == 0 {
+		return
+	}
+	segment, rest := path[0], path[1:]
+
+	if segment.kind == indexSegment || (segment.kind == wildcardSegment && isList(current, modified)) {
+		currentList, _ := current.([]interface{})
+		modifiedList, _ := modified.([]interface{})
+		if len(rest) == 0 {
+			return
+		}
+		for i, modifiedItem := range modifiedList {
+			if segment.kind == indexSegment && segment.index != i {
+				continue
+			}
+			currentItem, _ := pairListItem(currentList, i, modifiedItem)
+			applyDefaultRule(currentItem, modifiedItem, rest, defaultValue)
+		}
+		return
+	}
+
+	currentObject, currentOk := objectOrEmpty(current)
+	modifiedObject, modifiedOk := objectOrEmpty(modified)
+	if !currentOk || !modifiedOk {
+		return
+	}
+
+	var fields []string
+	switch segment.kind {
+	case fieldSegment:
+		fields = []string{segment.name}
+	case wildcardSegment:
+		for field := range currentObject {
+			fields = append(fields, field)
+		}
+		for field := range modifiedObject {
+			if _, ok := currentObject[field]; !ok {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	for _, field := range fields {
+		if len(rest) > 0 {
+			applyDefaultRule(currentObject[field], modifiedObject[field], rest, defaultValue)
+			continue
+		}
+
+		switch {
+		case modifiedObject[field] == nil && reflect.DeepEqual(currentObject[field], defaultValue):
+			delete(currentObject, field)
+		case currentObject[field] == nil && reflect.DeepEqual(modifiedObject[field], defaultValue):
+			delete(modifiedObject, field)
+		}
+	}
+}
+
+func isList(values ...interface{}) bool {
+	for _, value := range values {
+		if _, ok := value.([]interface{}); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// objectOrEmpty returns the value as an object, nil being an empty object.
+func objectOrEmpty(value interface{}) (map[string]interface{}, bool) {
+	if value == nil {
+		return map[string]interface{}{}, true
+	}
+	object, ok := value.(map[string]interface{})
+	return object, ok
+}
@@ -0,0 +1,86 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDefaultRules(t *testing.T) {
+	rules, err := LoadDefaultRules([]byte(`
+- group: example.com
+  kind: App
+  path: .spec.replicas
+  default: 1
+- kind: App
+  path: .spec.containers[*].pullPolicy
+  default: IfNotPresent
+- group: other.example.com
+  path: .spec.mode
+  default: fast
+`))
+	assert.NoError(t, err)
+	assert.Len(t, rules, 3)
+
+	newApp := func(spec map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "App",
+			"metadata":   map[string]interface{}{"name": "app", "namespace": "default"},
+			"spec":       spec,
+		}}
+	}
+
+	current := newApp(map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": "app", "image": "nginx"},
+			map[string]interface{}{"name": "sidecar", "image": "envoy"},
+		},
+	})
+	mustAnnotate(current)
+	// Defaults set by the controller of the custom resource
+	spec := current.Object["spec"].(map[string]interface{})
+	spec["replicas"] = int64(1)
+	spec["mode"] = "fast"
+	for _, container := range spec["containers"].([]interface{}) {
+		container.(map[string]interface{})["pullPolicy"] = "IfNotPresent"
+	}
+
+	modified := newApp(map[string]interface{}{
+		"mode": "fast",
+		"containers": []interface{}{
+			map[string]interface{}{"name": "sidecar", "image": "envoy"},
+			map[string]interface{}{"name": "app", "image": "nginx", "pullPolicy": "IfNotPresent"},
+		},
+	})
+
+	patch, err := DefaultPatchMaker.(CtxMaker).CalculateCtx(current, modified, DefaultRules(rules...))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"containers":[{"image":"envoy","name":"sidecar"},{"image":"nginx","name":"app","pullPolicy":"IfNotPresent"}]}}`, string(patch.Patch))
+
+	// Values differing from the default are still compared
+	modified.Object["spec"].(map[string]interface{})["replicas"] = int64(3)
+	patch, err = DefaultPatchMaker.(CtxMaker).CalculateCtx(current, modified, DefaultRules(rules...))
+	assert.NoError(t, err)
+	assert.Contains(t, string(patch.Patch), `"replicas":3`)
+}
+
+func TestDefaultRulesInvalidPath(t *testing.T) {
+	_, _, err := DefaultRules(DefaultRule{Path: "spec["})(CalculateContext{}, []byte(`{}`), []byte(`{}`))
+	assert.Error(t, err)
+}
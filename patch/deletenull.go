@@ -25,6 +25,8 @@ import (
 
 type CalculateOption func([]byte, []byte) ([]byte, []byte, error)
 
+// IgnoreStatusFields removes the status field from both objects before comparing them.
+// It works the same way for typed and unstructured objects.
 func IgnoreStatusFields() CalculateOption {
 	return func(current, modified []byte) ([]byte, []byte, error) {
 		current, err := deleteStatusField(current)
@@ -57,36 +59,11 @@ func IgnoreField(field string) CalculateOption {
 	}
 }
 
+// IgnoreVolumeClaimTemplateTypeMetaAndStatus normalizes the volumeClaimTemplates of StatefulSets before comparing them:
+// apiVersion, kind, status and volumeMode are dropped, and the storageClassName defaulted by the API server
+// is removed from the current object when the modified template doesn't set it.
 func IgnoreVolumeClaimTemplateTypeMetaAndStatus() CalculateOption {
-	return func(current, modified []byte) ([]byte, []byte, error) {
-		current, err := deleteVolumeClaimTemplateFields(current)
-		if err != nil {
-			return []byte{}, []byte{}, errors.Wrap(err, "could not delete status field from current byte sequence")
-		}
-
-		modified, err = deleteVolumeClaimTemplateFields(modified)
-		if err != nil {
-			return []byte{}, []byte{}, errors.Wrap(err, "could not delete status field from modified byte sequence")
-		}
-
-		return current, modified, nil
-	}
-}
-
-func CleanMetadata() CalculateOption {
-	return func(current, modified []byte) ([]byte, []byte, error) {
-		current, err := cleanMetadata(current)
-		if err != nil {
-			return []byte{}, []byte{}, errors.Wrap(err, "could not clean metadata field from current byte sequence")
-		}
-
-		modified, err = cleanMetadata(modified)
-		if err != nil {
-			return []byte{}, []byte{}, errors.Wrap(err, "could not clean metadata field from modified byte sequence")
-		}
-
-		return current, modified, nil
-	}
+	return MapOptions(IgnoreVolumeClaimTemplateTypeMetaAndStatusMap())
 }
 
 func init() {
@@ -221,86 +198,71 @@ func deleteDataField(obj []byte, fieldName string) ([]byte, error) {
 }
 
 func deleteStatusField(obj []byte) ([]byte, error) {
-	var objectMap map[string]interface{}
-	err := json.Unmarshal(obj, &objectMap)
-	if err != nil {
-		return []byte{}, errors.Wrap(err, "could not unmarshal byte sequence")
-	}
-	delete(objectMap, "status")
-	obj, err = json.ConfigCompatibleWithStandardLibrary.Marshal(objectMap)
-	if err != nil {
-		return []byte{}, errors.Wrap(err, "could not marshal byte sequence")
-	}
-
-	return obj, nil
+	return deleteDataField(obj, "status")
 }
 
-func deleteVolumeClaimTemplateFields(obj []byte) ([]byte, error) {
-	resource := map[string]interface{}{}
-	err := json.Unmarshal(obj, &resource)
-	if err != nil {
-		return []byte{}, errors.Wrap(err, "could not unmarshal byte sequence")
+func deleteVolumeClaimTemplateFields(resource map[string]interface{}) {
+	for _, vct := range getVolumeClaimTemplates(resource) {
+		vct["kind"] = ""
+		vct["apiVersion"] = ""
+		vct["status"] = map[string]string{
+			"phase": "Pending",
+		}
+		if vctSpec, ok := vct["spec"].(map[string]interface{}); ok {
+			delete(vctSpec, "volumeClaimTemplates")
+			delete(vctSpec, "volumeMode")
+		}
 	}
+}
 
-	if spec, ok := resource["spec"]; ok {
-		if spec, ok := spec.(map[string]interface{}); ok {
-			if vcts, ok := spec["volumeClaimTemplates"]; ok {
-				if vcts, ok := vcts.([]interface{}); ok {
-					for _, vct := range vcts {
-						if vct, ok := vct.(map[string]interface{}); ok {
-							vct["kind"] = ""
-							vct["apiVersion"] = ""
-							vct["status"] = map[string]string{
-								"phase": "Pending",
-							}
-							if vctSpec, ok := vct["spec"].(map[string]interface{}); ok {
-								delete(vctSpec, "volumeClaimTemplates")
-								delete(vctSpec, "volumeMode")
-							}
-						}
-					}
-				}
+// deleteDefaultedStorageClassNames removes storageClassName from the current templates
+// when the modified template with the same name doesn't set it.
+func deleteDefaultedStorageClassNames(current, modified map[string]interface{}) {
+	modifiedVcts := map[string]map[string]interface{}{}
+	for _, vct := range getVolumeClaimTemplates(modified) {
+		if metadata, ok := vct["metadata"].(map[string]interface{}); ok {
+			if name, ok := metadata["name"].(string); ok {
+				modifiedVcts[name] = vct
 			}
 		}
 	}
 
-	obj, err = json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
-	if err != nil {
-		return []byte{}, errors.Wrap(err, "could not marshal byte sequence")
+	for _, vct := range getVolumeClaimTemplates(current) {
+		metadata, ok := vct["metadata"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, ok := metadata["name"].(string)
+		if !ok {
+			continue
+		}
+		modifiedVct, ok := modifiedVcts[name]
+		if !ok {
+			continue
+		}
+		if modifiedSpec, ok := modifiedVct["spec"].(map[string]interface{}); ok {
+			if _, ok := modifiedSpec["storageClassName"]; ok {
+				continue
+			}
+		}
+		if vctSpec, ok := vct["spec"].(map[string]interface{}); ok {
+			delete(vctSpec, "storageClassName")
+		}
 	}
-
-	return obj, nil
 }
 
-func cleanMetadata(obj []byte) ([]byte, error) {
-	resource := map[string]any{}
-	err := json.Unmarshal(obj, &resource)
-	if err != nil {
-		return []byte{}, errors.Wrap(err, "could not unmarshal byte sequence")
-	}
-
-	if metadata, ok := resource["metadata"]; ok {
-		if metadata, ok := metadata.(map[string]any); ok {
-			if annotations, ok := metadata["annotations"]; ok {
-				if annotations, ok := annotations.(map[string]string); ok {
-					delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+func getVolumeClaimTemplates(resource map[string]interface{}) []map[string]interface{} {
+	var vcts []map[string]interface{}
+	if spec, ok := resource["spec"].(map[string]interface{}); ok {
+		if items, ok := spec["volumeClaimTemplates"].([]interface{}); ok {
+			for _, item := range items {
+				if vct, ok := item.(map[string]interface{}); ok {
+					vcts = append(vcts, vct)
 				}
 			}
-			resource["metadata"] = map[string]any{
-				"labels":          metadata["labels"],
-				"annotations":     metadata["annotations"],
-				"ownerReferences": metadata["ownerReferences"],
-				"fake":            "fake", // Need to put this to avoid to have nil metadata
-			}
 		}
 	}
-
-	obj, err = json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
-	if err != nil {
-		return []byte{}, errors.Wrap(err, "could not marshal byte sequence")
-	}
-
-	return obj, nil
+	return vcts
 }
 
 func isZero(v reflect.Value) bool {
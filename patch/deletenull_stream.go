@@ -0,0 +1,173 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"sort"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+// DeleteNullInJsonBytes is DeleteNullInJson without the filtered map. It rewrites the document in a single pass over
+// the token stream instead of unmarshaling, walking and marshaling it, and produces the same document: the null and
+// empty string fields of objects are removed, along with the objects left empty, and the keys are sorted.
+func DeleteNullInJsonBytes(jsonBytes []byte) ([]byte, error) {
+	iter := json.ConfigCompatibleWithStandardLibrary.BorrowIterator(jsonBytes)
+	defer json.ConfigCompatibleWithStandardLibrary.ReturnIterator(iter)
+	stream := json.ConfigCompatibleWithStandardLibrary.BorrowStream(nil)
+	pooled := stream.Buffer()
+	defer func() {
+		// The stream writes to the filtered document, give its own buffer back before returning it to the pool
+		stream.SetBuffer(pooled)
+		json.ConfigCompatibleWithStandardLibrary.ReturnStream(stream)
+	}()
+
+	filter := &nullFilter{iter: iter, stream: stream}
+
+	var filtered []byte
+	switch iter.WhatIsNext() {
+	case json.ObjectValue:
+		filtered, _ = filter.appendObject(nil, true)
+	case json.NilValue:
+		iter.ReadNil()
+		filtered = []byte("{}")
+	default:
+		return nil, errors.New("could not unmarshal json patch: not an object")
+	}
+	if iter.Error != nil {
+		return nil, errors.Wrap(iter.Error, "could not unmarshal json patch")
+	}
+	if stream.Error != nil {
+		return nil, errors.Wrap(stream.Error, "could not marshal filtered patch")
+	}
+
+	return filtered, nil
+}
+
+// nullFilter copies the values read from the iterator to byte slices, encoding the scalars with the stream
+// like json.ConfigCompatibleWithStandardLibrary.Marshal does.
+type nullFilter struct {
+	iter   *json.Iterator
+	stream *json.Stream
+}
+
+type filteredField struct {
+	key   string
+	value []byte
+}
+
+// appendObject appends the filtered object to dst, it returns false if the object was left empty by the filtering.
+// Objects in lists and the document itself are kept even if they are left empty.
+func (f *nullFilter) appendObject(dst []byte, inList bool) ([]byte, bool) {
+	var fields []filteredField
+	var values []byte
+	read := 0
+
+	f.iter.ReadMapCB(func(iter *json.Iterator, key string) bool {
+		read++
+		var value []byte
+		var keep bool
+		switch iter.WhatIsNext() {
+		case json.NilValue:
+			iter.Skip()
+		case json.StringValue:
+			if str := iter.ReadString(); str != "" {
+				value, keep = f.appendString(values, str), true
+			}
+		case json.ObjectValue:
+			value, keep = f.appendObject(values, false)
+		default:
+			value, keep = f.appendValue(values)
+		}
+
+		field := filteredField{key: key}
+		if keep {
+			field.value = value[len(values):]
+			values = value
+		}
+		fields = append(fields, field)
+		return true
+	})
+
+	// Like unmarshaling to a map, the last value of a duplicated key wins
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].key < fields[j].key
+	})
+
+	start := len(dst)
+	dst = append(dst, '{')
+	empty := true
+	for i, field := range fields {
+		if field.value == nil || (i+1 < len(fields) && fields[i+1].key == field.key) {
+			continue
+		}
+		if !empty {
+			dst = append(dst, ',')
+		}
+		empty = false
+		dst = f.appendString(dst, field.key)
+		dst = append(dst, ':')
+		dst = append(dst, field.value...)
+	}
+
+	if empty && read > 0 && !inList {
+		return dst[:start], false
+	}
+	return append(dst, '}'), true
+}
+
+// appendValue appends a value which is never removed from objects: numbers, booleans and lists.
+// In lists the values are kept as they are, except the objects which are filtered.
+func (f *nullFilter) appendValue(dst []byte) ([]byte, bool) {
+	switch f.iter.WhatIsNext() {
+	case json.NilValue:
+		f.iter.ReadNil()
+		return append(dst, "null"...), true
+	case json.StringValue:
+		return f.appendString(dst, f.iter.ReadString()), true
+	case json.NumberValue:
+		f.stream.SetBuffer(dst)
+		f.stream.WriteFloat64(f.iter.ReadFloat64())
+		return f.stream.Buffer(), true
+	case json.BoolValue:
+		f.stream.SetBuffer(dst)
+		f.stream.WriteBool(f.iter.ReadBool())
+		return f.stream.Buffer(), true
+	case json.ObjectValue:
+		return f.appendObject(dst, true)
+	case json.ArrayValue:
+		dst = append(dst, '[')
+		first := true
+		f.iter.ReadArrayCB(func(iter *json.Iterator) bool {
+			if !first {
+				dst = append(dst, ',')
+			}
+			first = false
+			dst, _ = f.appendValue(dst)
+			return true
+		})
+		return append(dst, ']'), true
+	default:
+		f.iter.ReportError("DeleteNullInJsonBytes", "unexpected value")
+		return dst, false
+	}
+}
+
+func (f *nullFilter) appendString(dst []byte, str string) []byte {
+	f.stream.SetBuffer(dst)
+	f.stream.WriteStringWithHTMLEscaped(str)
+	return f.stream.Buffer()
+}
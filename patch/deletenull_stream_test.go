@@ -0,0 +1,95 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeleteNullInJsonBytes(t *testing.T) {
+	deployment, err := json.ConfigCompatibleWithStandardLibrary.Marshal(newBenchmarkDeployment())
+	assert.NoError(t, err)
+
+	documents := []string{
+		`{}`,
+		`null`,
+		`{"a":null,"b":"","c":"c","d":0,"e":false,"f":[],"g":{}}`,
+		`{"z":1,"a":{"y":null,"x":""},"m":{"k":{"j":null}},"b":{"c":{"d":1}}}`,
+		`{"list":[null,"",1,{"a":null},{},[null,{"b":""}]]}`,
+		`{"numbers":[1.0,1e21,1e-7,0.1,-5,12345678901234567890,3.14159]}`,
+		`{"html":"<a href=\"x\">&amp;</a>","unicode":"héllo   😀","escaped":"tab\tnew\nline"}`,
+		`{"dup":1,"dup":2,"other":{"dup":"x","dup":null}}`,
+		`{ "spaced" : [ 1 , 2 ] , "nested" : { "a" : true } }`,
+		`{"metadata":{"annotations":{"a.b/c":"","d.e/f":"g"},"labels":null}}`,
+		string(deployment),
+	}
+	for _, document := range documents {
+		expected, _, err := DeleteNullInJson([]byte(document))
+		assert.NoError(t, err, document)
+
+		actual, err := DeleteNullInJsonBytes([]byte(document))
+		assert.NoError(t, err, document)
+		assert.Equal(t, string(expected), string(actual), document)
+	}
+}
+
+func TestDeleteNullInJsonBytesResultIsNotShared(t *testing.T) {
+	first, err := DeleteNullInJsonBytes([]byte(`{"a":"first"}`))
+	assert.NoError(t, err)
+
+	// The pooled streams must not write to previous results
+	for i := 0; i < 100; i++ {
+		_, err := DeleteNullInJsonBytes([]byte(`{"b":"second"}`))
+		assert.NoError(t, err)
+		_, err = hashJSON(map[string]string{"c": "third"})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, `{"a":"first"}`, string(first))
+}
+
+func TestDeleteNullInJsonBytesInvalid(t *testing.T) {
+	for _, document := range []string{``, `[]`, `"a"`, `{"a":`, `{"a":[1,}`} {
+		_, err := DeleteNullInJsonBytes([]byte(document))
+		assert.Error(t, err, document)
+	}
+}
+
+func BenchmarkDeleteNullInJson(b *testing.B) {
+	document, err := json.ConfigCompatibleWithStandardLibrary.Marshal(newBenchmarkDeployment())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("map", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, _, err := DeleteNullInJson(document); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := DeleteNullInJsonBytes(document); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
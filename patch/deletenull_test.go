@@ -0,0 +1,153 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestIgnoreStatusFields(t *testing.T) {
+	current := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "pod",
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+		},
+	}
+	modified := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "pod",
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodPending,
+		},
+	}
+
+	// The status is left out unless it is compared with the main resource
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	statusPatchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithStatusInMainResource())
+	patch, err = statusPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = statusPatchMaker.Calculate(current, modified, IgnoreStatusFields())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+	assert.Equal(t, corev1.PodRunning, patch.Patched.(*corev1.Pod).Status.Phase)
+}
+
+func TestIgnoreStatusFieldsUnstructured(t *testing.T) {
+	current := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Foo",
+		"metadata": map[string]interface{}{
+			"name": "foo",
+		},
+		"status": map[string]interface{}{
+			"ready": true,
+		},
+	}}
+	modified := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Foo",
+		"metadata": map[string]interface{}{
+			"name": "foo",
+		},
+	}}
+	mustAnnotate(current)
+
+	// The status is left out unless it is compared with the main resource
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	statusPatchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithStatusInMainResource())
+	patch, err = statusPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = statusPatchMaker.Calculate(current, modified, IgnoreStatusFields())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+}
+
+func TestIgnoreVolumeClaimTemplateTypeMetaAndStatus(t *testing.T) {
+	filesystem := corev1.PersistentVolumeFilesystem
+	standard := "standard"
+	newStatefulSet := func(storage string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "statefulset",
+			},
+			Spec: appsv1.StatefulSetSpec{
+				VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+					{
+						ObjectMeta: v1.ObjectMeta{
+							Name: "data",
+						},
+						Spec: corev1.PersistentVolumeClaimSpec{
+							AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceStorage: resource.MustParse(storage),
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	current := newStatefulSet("1Gi")
+	mustAnnotate(current)
+	current.Spec.VolumeClaimTemplates[0].TypeMeta = v1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"}
+	current.Spec.VolumeClaimTemplates[0].Spec.StorageClassName = &standard
+	current.Spec.VolumeClaimTemplates[0].Spec.VolumeMode = &filesystem
+	current.Spec.VolumeClaimTemplates[0].Status = corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending}
+
+	modified := newStatefulSet("1Gi")
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, modified, IgnoreVolumeClaimTemplateTypeMetaAndStatus())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	// An explicitly set storage class is compared
+	fast := "fast"
+	modified.Spec.VolumeClaimTemplates[0].Spec.StorageClassName = &fast
+	patch, err = DefaultPatchMaker.Calculate(current, modified, IgnoreVolumeClaimTemplateTypeMetaAndStatus())
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	// Other changes are detected
+	patch, err = DefaultPatchMaker.Calculate(current, newStatefulSet("2Gi"), IgnoreVolumeClaimTemplateTypeMetaAndStatus())
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+}
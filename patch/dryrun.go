@@ -0,0 +1,75 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// WithDryRunValidation makes Calculate submit the non-empty patches to the API server in dry-run mode, see PatchRequest.DryRun.
+// The rejections, such as changes of immutable fields or admission webhook denials, are recorded in PatchResult.ValidationErrors
+// instead of being discovered when the patch is applied. The other errors, e.g. when the API server can't be reached,
+// are returned by Calculate. Every calculation then makes a request, the option is meant for the objects which may be
+// rejected rather than for every reconciliation. The request is made with the context given to CalculateWithContext,
+// and times out after DryRunTimeout.
+func WithDryRunValidation(client PatchClient) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.dryRunClient = client
+	}
+}
+
+// DryRunTimeout bounds the dry-run requests of WithDryRunValidation, so Calculate doesn't hang on an unresponsive
+// API server when the context of the caller has no deadline.
+const DryRunTimeout = 10 * time.Second
+
+// validateWithDryRun submits the patch in dry-run mode and records the rejection of the API server.
+func (p *PatchMaker) validateWithDryRun(calculateContext CalculateContext, result *PatchResult) error {
+	if p.dryRunClient == nil || result.IsEmpty() {
+		return nil
+	}
+	patched, ok := result.Patched.(runtime.Object)
+	if !ok {
+		return nil
+	}
+
+	request, err := result.PatchRequest()
+	if err != nil {
+		return errors.Wrap(err, "Failed to create the dry-run patch request")
+	}
+	request.DryRun = true
+
+	ctx, cancel := context.WithTimeout(calculateContext.callerContext(), DryRunTimeout)
+	defer cancel()
+	err = p.dryRunClient.Patch(ctx, patched.DeepCopyObject(), request)
+	switch {
+	case err == nil:
+		return nil
+	case isRejection(err):
+		result.ValidationErrors = append(result.ValidationErrors, err)
+		return nil
+	default:
+		return errors.Wrap(err, "Failed to submit the dry-run patch")
+	}
+}
+
+// isRejection tells whether the API server refused the patch itself.
+func isRejection(err error) bool {
+	return apierrors.IsInvalid(err) || apierrors.IsForbidden(err) || apierrors.IsBadRequest(err)
+}
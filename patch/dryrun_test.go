@@ -0,0 +1,113 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+type patchClientFunc func(ctx context.Context, obj runtime.Object, request PatchRequest) error
+
+func (f patchClientFunc) Patch(ctx context.Context, obj runtime.Object, request PatchRequest) error {
+	return f(ctx, obj, request)
+}
+
+func TestWithDryRunValidation(t *testing.T) {
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "config",
+				Namespace: "default",
+			},
+			Data: map[string]string{"key": value},
+		}
+	}
+	current := newConfigMap("a")
+	mustAnnotate(current)
+
+	var requests []PatchRequest
+	var response error
+	client := patchClientFunc(func(_ context.Context, _ runtime.Object, request PatchRequest) error {
+		requests = append(requests, request)
+		return response
+	})
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithDryRunValidation(client))
+
+	// Empty patches aren't submitted
+	result, err := patchMaker.Calculate(current, newConfigMap("a"))
+	require.NoError(t, err)
+	assert.Empty(t, requests)
+	assert.Empty(t, result.ValidationErrors)
+
+	result, err = patchMaker.Calculate(current, newConfigMap("b"))
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	assert.True(t, requests[0].DryRun)
+	assert.Empty(t, result.ValidationErrors)
+
+	response = apierrors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, "config", field.ErrorList{
+		field.Invalid(field.NewPath("data"), "b", "field is immutable"),
+	})
+	result, err = patchMaker.Calculate(current, newConfigMap("b"))
+	require.NoError(t, err)
+	require.Len(t, result.ValidationErrors, 1)
+	assert.True(t, apierrors.IsInvalid(result.ValidationErrors[0]))
+
+	response = apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "config", errors.New("denied by the admission webhook"))
+	result, err = patchMaker.Calculate(current, newConfigMap("b"))
+	require.NoError(t, err)
+	assert.Len(t, result.ValidationErrors, 1)
+
+	response = errors.New("connection refused")
+	_, err = patchMaker.Calculate(current, newConfigMap("b"))
+	assert.Error(t, err)
+}
+
+func TestWithDryRunValidationContext(t *testing.T) {
+	current := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default"}, Data: map[string]string{"key": "a"}}
+	mustAnnotate(current)
+	modified := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default"}, Data: map[string]string{"key": "b"}}
+
+	type key struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "caller"))
+	client := patchClientFunc(func(requestCtx context.Context, _ runtime.Object, _ PatchRequest) error {
+		assert.Equal(t, "caller", requestCtx.Value(key{}))
+		deadline, ok := requestCtx.Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(DryRunTimeout), deadline, time.Second)
+		return requestCtx.Err()
+	})
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithDryRunValidation(client))
+
+	_, err := patchMaker.(CtxMaker).CalculateWithContext(ctx, current, modified)
+	require.NoError(t, err)
+
+	// The request is canceled along with the context of the caller
+	cancel()
+	_, err = patchMaker.(CtxMaker).CalculateWithContext(ctx, current, modified)
+	assert.True(t, errors.Is(err, context.Canceled), err)
+}
@@ -0,0 +1,114 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+)
+
+// TreatEmptyAsAbsent makes empty objects and lists compare equal to absent fields: when a field is absent from one
+// object and set to {} or [] in the other one, it is removed from the other one. Typed objects often marshal empty maps
+// the API server doesn't store. Without paths it applies to every field, objects only holding empty values being empty
+// too, otherwise only to the fields at the paths, in the syntax of IgnoreJSONPath.
+func TreatEmptyAsAbsent(paths ...string) CalculateOption {
+	return MapOptions(TreatEmptyAsAbsentMap(paths...))
+}
+
+// TreatEmptyAsAbsentMap is the map option of TreatEmptyAsAbsent.
+func TreatEmptyAsAbsentMap(paths ...string) CalculateMapOption {
+	parsedPaths := make([][]pathSegment, 0, len(paths))
+	var parseErr error
+	for _, path := range paths {
+		segments, err := parseJSONPath(path)
+		if err != nil {
+			parseErr = errors.Append(parseErr, err)
+			continue
+		}
+		parsedPaths = append(parsedPaths, segments)
+	}
+
+	return func(current, modified map[string]interface{}) error {
+		if parseErr != nil {
+			return parseErr
+		}
+		if len(parsedPaths) == 0 {
+			deleteEmptyMissingFrom(current, modified)
+			return nil
+		}
+		for _, path := range parsedPaths {
+			applyDefaultRule(current, modified, path, map[string]interface{}{})
+			applyDefaultRule(current, modified, path, []interface{}{})
+		}
+		return nil
+	}
+}
+
+// deleteEmptyMissingFrom removes the empty objects and lists of each object absent from the other one, recursively.
+// List items are paired by name or index.
+func deleteEmptyMissingFrom(current, modified map[string]interface{}) {
+	for field, modifiedValue := range modified {
+		if current[field] == nil {
+			deleteEmpty(modified, field)
+			continue
+		}
+		deleteEmptyMissingFromValues(current[field], modifiedValue)
+	}
+	for field := range current {
+		if modified[field] == nil {
+			deleteEmpty(current, field)
+		}
+	}
+}
+
+// deleteEmptyMissingFromValues applies deleteEmptyMissingFrom to the objects of both values.
+
+func deleteEmptyMissingFromValues(current, modified interface{}) {
+	switch typedModified := modified.(type) {
+	case map[string]interface{}:
+		if typedCurrent, ok := current.(map[string]interface{}); ok {
+			deleteEmptyMissingFrom(typedCurrent, typedModified)
+		}
+	case []interface{}:
+		typedCurrent, ok := current.([]interface{})
+		if !ok {
+			return
+		}
+		for i, modifiedItem := range typedModified {
+			currentItem, _ := pairListItem(typedCurrent, i, modifiedItem)
+			deleteEmptyMissingFromValues(currentItem, modifiedItem)
+		}
+	}
+}
+
+// deleteEmpty removes the field from the object if its value is empty once its own empty values are removed.
+func deleteEmpty(object map[string]interface{}, field string) {
+	if isEmptyValue(object[field]) {
+		delete(object, field)
+	}
+}
+
+// isEmptyValue tells whether the value is an empty list or an object only holding empty values, which are removed.
+func isEmptyValue(value interface{}) bool {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		for field := range typedValue {
+			deleteEmpty(typedValue, field)
+		}
+		return len(typedValue) == 0
+	case []interface{}:
+		return len(typedValue) == 0
+	}
+	return false
+}
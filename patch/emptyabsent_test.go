@@ -0,0 +1,67 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreatEmptyAsAbsent(t *testing.T) {
+	current := newNullableApp(map[string]interface{}{
+		"replicas": int64(1),
+		"template": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:1"},
+			},
+		},
+	})
+	mustAnnotate(current)
+	current.Object["spec"].(map[string]interface{})["args"] = []interface{}{}
+
+	modified := newNullableApp(map[string]interface{}{
+		"replicas": int64(1),
+		"selector": map[string]interface{}{},
+		"template": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "app", "image": "app:1", "resources": map[string]interface{}{"limits": map[string]interface{}{}}},
+			},
+		},
+	})
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.Calculate(current, modified, TreatEmptyAsAbsent())
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+
+	result, err = DefaultPatchMaker.Calculate(current, modified, TreatEmptyAsAbsent(".spec.selector"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(result.Patch), "selector")
+	assert.Contains(t, string(result.Patch), "resources")
+
+	// Empty values replacing set ones are kept
+	modified.Object["spec"].(map[string]interface{})["template"] = map[string]interface{}{"containers": []interface{}{}}
+	result, err = DefaultPatchMaker.Calculate(current, modified, TreatEmptyAsAbsent())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"template":{"containers":[]}}}`, string(result.Patch))
+
+	_, err = DefaultPatchMaker.Calculate(current, modified, TreatEmptyAsAbsent(".spec["))
+	require.Error(t, err)
+}
@@ -0,0 +1,79 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// ObjectDriftedEventReason is the reason of the events emitted by EventRecorderHook.
+const ObjectDriftedEventReason = "ObjectDrifted"
+
+// maxEventChanges is the number of changes listed in the drift events.
+const maxEventChanges = 10
+
+// maxEventMessageLength is the length the event messages are truncated to.
+const maxEventMessageLength = 512
+
+// EventRecorderHook emits a Normal event with the ObjectDrifted reason on the current object each time Calculate
+// returns a non-empty patch, cached results included. The message summarizes the changes the patch makes, like
+// PatchResult.Report does, with the Secret values redacted. The events show up in `kubectl get events`.
+func EventRecorderHook(recorder record.EventRecorder) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.eventRecorder = recorder
+	}
+}
+
+// emitDriftEvent emits the drift event of the result when the patch is not empty.
+func (p *PatchMaker) emitDriftEvent(ctx CalculateContext, result *PatchResult) {
+	if p.eventRecorder == nil || result.IsEmpty() {
+		return
+	}
+
+	p.eventRecorder.Event(ctx.CurrentObject, corev1.EventTypeNormal, ObjectDriftedEventReason, driftSummary(result))
+}
+
+// driftSummary lists the first changes of the result, or shows the patch if the changes can't be computed.
+func driftSummary(result *PatchResult) string {
+	redacted := result.Redacted()
+	changes, err := redacted.fieldChanges()
+	if err != nil || len(changes) == 0 {
+		return truncateEventMessage("Object drifted, patch " + string(redacted.Patch))
+	}
+
+	config := &reportConfig{}
+	lines := make([]string, 0, maxEventChanges)
+	for i, change := range changes {
+		if i == maxEventChanges {
+			lines = append(lines, fmt.Sprintf("and %d more", len(changes)-maxEventChanges))
+			break
+		}
+		lines = append(lines, config.renderChange(change))
+	}
+
+	return truncateEventMessage(fmt.Sprintf("Object drifted, %d field(s) changed: %s", len(changes), strings.Join(lines, ", ")))
+}
+
+// truncateEventMessage truncates the message to maxEventMessageLength.
+func truncateEventMessage(message string) string {
+	if len(message) > maxEventMessageLength {
+		return message[:maxEventMessageLength] + "..."
+	}
+	return message
+}
@@ -0,0 +1,78 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEventRecorderHook(t *testing.T) {
+	eventRecorder := record.NewFakeRecorder(10)
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		EventRecorderHook(eventRecorder))
+
+	current := newBenchmarkDeployment()
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	result, err := patchMaker.Calculate(current, newBenchmarkDeployment())
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+	assert.Empty(t, eventRecorder.Events)
+
+	modified := newBenchmarkDeployment()
+	modified.Spec.Template.Spec.Containers[0].Image = "nginx:1.24"
+	result, err = patchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+	require.Len(t, eventRecorder.Events, 1)
+	assert.Equal(t, `Normal ObjectDrifted Object drifted, 1 field(s) changed: ~ .spec.template.spec.containers[0].image: "nginx:1.23" -> "nginx:1.24"`, <-eventRecorder.Events)
+}
+
+func TestEventRecorderHookSummary(t *testing.T) {
+	eventRecorder := record.NewFakeRecorder(10)
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		EventRecorderHook(eventRecorder))
+
+	newSecret := func(value string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{},
+		}
+		for i := 0; i < 12; i++ {
+			secret.Data["key"+strconv.Itoa(i)] = []byte(value)
+		}
+		return secret
+	}
+	current := newSecret("secret1")
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	_, err := patchMaker.Calculate(current, newSecret("secret2"))
+	require.NoError(t, err)
+	require.Len(t, eventRecorder.Events, 1)
+	event := <-eventRecorder.Events
+	assert.Contains(t, event, "Normal ObjectDrifted Object drifted, 12 field(s) changed: ~ .data.key0: \"[redacted]\" -> \"[redacted]\"")
+	assert.Contains(t, event, "and 2 more")
+	assert.NotContains(t, event, "c2VjcmV0")
+}
@@ -0,0 +1,205 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	json "github.com/json-iterator/go"
+)
+
+// ExplanationReason tells why an element of a patch was produced.
+type ExplanationReason string
+
+const (
+	// ReasonAddedInModified is a field present in the modified object but not in the current one.
+	ReasonAddedInModified ExplanationReason = "AddedInModified"
+	// ReasonChangedInModified is a field the modified object sets to another value than the last applied one.
+	ReasonChangedInModified ExplanationReason = "ChangedInModified"
+	// ReasonOverriddenCurrent is a field the modified object sets to another value than the current one, while it was
+	// not in the last applied configuration, e.g. a value defaulted by the API server or set by another controller.
+	ReasonOverriddenCurrent ExplanationReason = "OverriddenCurrent"
+	// ReasonRemovedFromModified is a field of the last applied configuration the modified object doesn't set anymore.
+	ReasonRemovedFromModified ExplanationReason = "RemovedFromModified"
+	// ReasonRemovedDefault is a field removed from the current object although it was not in the last applied
+	// configuration, e.g. a value defaulted by the API server.
+	ReasonRemovedDefault ExplanationReason = "RemovedDefault"
+	// ReasonSetElementOrder is a $setElementOrder directive of a strategic merge patch.
+	ReasonSetElementOrder ExplanationReason = "SetElementOrder"
+	// ReasonPatchDirective is another directive of a strategic merge patch, like $retainKeys or $patch.
+	ReasonPatchDirective ExplanationReason = "PatchDirective"
+	// ReasonAnnotationMismatch is a change of the last-applied annotation.
+	ReasonAnnotationMismatch ExplanationReason = "AnnotationMismatch"
+)
+
+const setElementOrderPrefix = "$setElementOrder/"
+
+// Explanation attributes an element of a patch to its source.
+type Explanation struct {
+	// Path of the element in the syntax accepted by IgnoreJSONPath
+	Path   string
+	Reason ExplanationReason
+	// Message describes the reason in a human-readable way
+	Message string
+
+	// Current, Modified and Original are the values of the field in the compared documents, nil when the field is absent.
+	Current  interface{}
+	Modified interface{}
+	Original interface{}
+}
+
+func (e Explanation) String() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Explain attributes each element of a non-empty patch to its source, to find out why an object keeps being updated.
+// Lists are explained as a whole. It returns nil if the patch is empty or is a server-side apply configuration.
+func Explain(result *PatchResult) []Explanation {
+	if result == nil || result.IsEmpty() || result.FieldManager != "" {
+		return nil
+	}
+
+	var patch map[string]interface{}
+	if err := json.Unmarshal(result.Patch, &patch); err != nil {
+		return nil
+	}
+
+	e := &explainer{
+		current:       unmarshalDocument(result.Current),
+		modified:      unmarshalDocument(result.Modified),
+		original:      unmarshalDocument(result.Original),
+		annotationKey: LastAppliedConfig,
+	}
+	if annotator, ok := result.annotator.(*Annotator); ok {
+		e.annotationKey = annotator.key
+	}
+	e.explain(nil, patch)
+
+	return e.explanations
+}
+
+type explainer struct {
+	current       interface{}
+	modified      interface{}
+	original      interface{}
+	annotationKey string
+
+	explanations []Explanation
+}
+
+func (e *explainer) explain(path []interface{}, patch map[string]interface{}) {
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := patch[key]
+
+		if strings.HasPrefix(key, setElementOrderPrefix) {
+			e.add(appendPath(path, strings.TrimPrefix(key, setElementOrderPrefix)), ReasonSetElementOrder,
+				"$setElementOrder directive keeping the order of the list items, it is not a change on its own")
+			continue
+		}
+		if strings.HasPrefix(key, "$") {
+			e.add(appendPath(path, key), ReasonPatchDirective, fmt.Sprintf("%s directive of the strategic merge patch", key))
+			continue
+		}
+
+		fieldPath := appendPath(path, key)
+		if isAnnotationPath(fieldPath, e.annotationKey) {
+			e.add(fieldPath, ReasonAnnotationMismatch, "the last-applied annotation differs from the modified object")
+			continue
+		}
+
+		current, inCurrent := lookupPath(e.current, fieldPath)
+		_, inOriginal := lookupPath(e.original, fieldPath)
+
+		if value == nil {
+			if inOriginal {
+				e.add(fieldPath, ReasonRemovedFromModified, "the field was applied before but is not set by the modified object anymore")
+			} else {
+				e.add(fieldPath, ReasonRemovedDefault, "the field is not set by the modified object and was not applied before, it was probably defaulted by the API server")
+			}
+			continue
+		}
+
+		if object, ok := value.(map[string]interface{}); ok && inCurrent {
+			if _, ok := current.(map[string]interface{}); ok {
+				e.explain(fieldPath, object)
+				continue
+			}
+		}
+
+		switch {
+		case !inCurrent:
+			e.add(fieldPath, ReasonAddedInModified, "the field is set by the modified object but not present in the current one")
+		case inOriginal:
+			e.add(fieldPath, ReasonChangedInModified, "the modified object sets another value than the one applied before")
+		default:
+			e.add(fieldPath, ReasonOverriddenCurrent, "the modified object sets another value than the current one, which was not applied before, e.g. a value defaulted by the API server or set by another controller")
+		}
+	}
+}
+
+func (e *explainer) add(path []interface{}, reason ExplanationReason, message string) {
+	current, _ := lookupPath(e.current, path)
+	modified, _ := lookupPath(e.modified, path)
+	original, _ := lookupPath(e.original, path)
+
+	e.explanations = append(e.explanations, Explanation{
+		Path:     jsonChange{path: path}.jsonPath(),
+		Reason:   reason,
+		Message:  message,
+		Current:  current,
+		Modified: modified,
+		Original: original,
+	})
+}
+
+func isAnnotationPath(path []interface{}, key string) bool {
+	return len(path) == 3 && path[0] == "metadata" && path[1] == "annotations" && path[2] == key
+}
+
+// lookupPath returns the value at the path of object keys in the document.
+func lookupPath(document interface{}, path []interface{}) (interface{}, bool) {
+	node := document
+	for _, segment := range path {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		key, _ := segment.(string)
+		node, ok = object[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+func unmarshalDocument(document []byte) interface{} {
+	if document == nil {
+		return nil
+	}
+	var parsed interface{}
+	if err := json.Unmarshal(document, &parsed); err != nil {
+		return nil
+	}
+	return parsed
+}
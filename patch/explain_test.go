@@ -0,0 +1,105 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExplain(t *testing.T) {
+	int32Ptr := func(i int32) *int32 {
+		return &i
+	}
+
+	newDeployment := func() *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{
+				Name:        "deployment",
+				Namespace:   "default",
+				Annotations: map[string]string{"example.com/owner": "team-a"},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: int32Ptr(1),
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "test", Image: "nginx:1.22"}},
+					},
+				},
+			},
+		}
+	}
+
+	current := newDeployment()
+	assert.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+	// Defaulted by the API server
+	current.Spec.ProgressDeadlineSeconds = int32Ptr(600)
+
+	modified := newDeployment()
+	modified.Labels = map[string]string{"app": "nginx"}
+	modified.Annotations = nil
+	modified.Spec.Replicas = int32Ptr(3)
+	modified.Spec.ProgressDeadlineSeconds = int32Ptr(300)
+	modified.Spec.Template.Spec.Containers[0].Image = "nginx:1.23"
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+
+	var explanations []string
+	for _, explanation := range Explain(result) {
+		explanations = append(explanations, explanation.Path+" "+string(explanation.Reason))
+	}
+	assert.Equal(t, []string{
+		".metadata.annotations RemovedFromModified",
+		".metadata.labels AddedInModified",
+		".spec.progressDeadlineSeconds OverriddenCurrent",
+		".spec.replicas ChangedInModified",
+		".spec.template.spec.containers SetElementOrder",
+		".spec.template.spec.containers ChangedInModified",
+	}, explanations)
+
+	replicas := Explain(result)[3]
+	assert.EqualValues(t, 1, replicas.Current)
+	assert.EqualValues(t, 3, replicas.Modified)
+	assert.EqualValues(t, 1, replicas.Original)
+}
+
+func TestExplainEmptyPatch(t *testing.T) {
+	current := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config"}, Data: map[string]string{"key": "value"}}
+	assert.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	result, err := DefaultPatchMaker.Calculate(current, current.DeepCopy())
+	assert.NoError(t, err)
+	assert.Nil(t, Explain(result))
+}
+
+func TestExplainAnnotationMismatch(t *testing.T) {
+	result := &PatchResult{
+		Patch:    []byte(`{"metadata":{"annotations":{"banzaicloud.com/last-applied":"new"}}}`),
+		Current:  []byte(`{"metadata":{"annotations":{"banzaicloud.com/last-applied":"old"}}}`),
+		Modified: []byte(`{"metadata":{"annotations":{"banzaicloud.com/last-applied":"new"}}}`),
+	}
+
+	explanations := Explain(result)
+	if assert.Len(t, explanations, 1) {
+		assert.Equal(t, ReasonAnnotationMismatch, explanations[0].Reason)
+		assert.Equal(t, ".metadata.annotations['banzaicloud.com/last-applied']", explanations[0].Path)
+		assert.Equal(t, "old", explanations[0].Current)
+	}
+}
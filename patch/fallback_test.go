@@ -0,0 +1,78 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// testApp is a typed custom resource with an untyped field, its Go type doesn't describe the content of the config.
+type testApp struct {
+	v1.TypeMeta   `json:",inline"`
+	v1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec testAppSpec `json:"spec,omitempty"`
+}
+
+type testAppSpec struct {
+	Replicas int32                `json:"replicas,omitempty"`
+	Config   runtime.RawExtension `json:"config,omitempty"`
+}
+
+func (a *testApp) DeepCopyObject() runtime.Object {
+	c := *a
+	a.ObjectMeta.DeepCopyInto(&c.ObjectMeta)
+	a.Spec.Config.DeepCopyInto(&c.Spec.Config)
+	return &c
+}
+
+func TestJSONMergeFallback(t *testing.T) {
+	newApp := func(replicas int32, config string) *testApp {
+		return &testApp{
+			TypeMeta: v1.TypeMeta{
+				APIVersion: "example.com/v1",
+				Kind:       "App",
+			},
+			ObjectMeta: v1.ObjectMeta{
+				Name: "app",
+			},
+			Spec: testAppSpec{
+				Replicas: replicas,
+				Config:   runtime.RawExtension{Raw: []byte(config)},
+			},
+		}
+	}
+
+	current := newApp(1, `{"level":"info","outputs":["stdout"]}`)
+	mustAnnotate(current)
+
+	patch, err := DefaultPatchMaker.Calculate(current, newApp(1, `{"level":"info","outputs":["stdout"]}`))
+	require.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, newApp(2, `{"level":"debug","outputs":["stdout","file"]}`))
+	require.NoError(t, err)
+	assert.True(t, patch.JSONMergeFallback)
+	assert.Equal(t, types.MergePatchType, patch.PatchType())
+	assert.JSONEq(t, `{"spec":{"replicas":2,"config":{"level":"debug","outputs":["stdout","file"]}}}`, string(patch.Patch))
+	assert.Equal(t, int32(2), patch.Patched.(*testApp).Spec.Replicas)
+	assert.JSONEq(t, `{"level":"debug","outputs":["stdout","file"]}`, string(patch.Patched.(*testApp).Spec.Config.Raw))
+}
@@ -0,0 +1,78 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const configurationHashPrefix = "sha256:"
+
+// NewHashAnnotator creates an Annotator storing only a SHA256 hash of the configuration instead of the configuration
+// itself, like the checksum annotations of Flux or kustomize. Calculate returns an empty patch without comparing the
+// objects when the hash of the modified object matches the annotation of the current object, so changes made to the
+// current object by others are not detected until the modified object changes. As the original
+// configuration can't be read back from a hash, the objects are compared like objects without original configuration
+// otherwise, see OnMissingOriginal.
+func NewHashAnnotator(key string) *Annotator {
+	return &Annotator{
+		key:              key,
+		metadataAccessor: meta.NewAccessor(),
+		encode:           hashAnnotation,
+		hashOnly:         true,
+	}
+}
+
+func hashAnnotation(original []byte) (string, error) {
+	sum := sha256.Sum256(original)
+	return configurationHashPrefix + hex.EncodeToString(sum[:]), nil
+}
+
+func isHashAnnotation(annotation string) bool {
+	return strings.HasPrefix(annotation, configurationHashPrefix)
+}
+
+// matchesLastApplied tells whether the hash stored on the current object matches the configuration
+// of the modified object, it is always false for annotators storing the whole configuration.
+func (a *Annotator) matchesLastApplied(currentObject, modifiedObject runtime.Object) (bool, error) {
+	if !a.hashOnly {
+		return false, nil
+	}
+
+	annots, err := a.metadataAccessor.Annotations(currentObject)
+	if err != nil {
+		return false, err
+	}
+	stored, ok := annots[a.key]
+	if !ok {
+		return false, nil
+	}
+
+	modified, err := a.lastAppliedConfiguration(modifiedObject)
+	if err != nil {
+		return false, err
+	}
+	hash, err := a.encode(modified)
+	if err != nil {
+		return false, err
+	}
+
+	return hash == stored, nil
+}
@@ -0,0 +1,61 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHashAnnotator(t *testing.T) {
+	annotator := NewHashAnnotator(LastAppliedConfig)
+	patchMaker := NewPatchMaker(annotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{})
+
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "config"},
+			Data:       map[string]string{"key": value},
+		}
+	}
+
+	current := newConfigMap("value1")
+	assert.NoError(t, annotator.SetLastAppliedAnnotation(current))
+	assert.True(t, strings.HasPrefix(current.Annotations[LastAppliedConfig], configurationHashPrefix))
+
+	original, err := annotator.GetOriginalConfiguration(current)
+	assert.NoError(t, err)
+	assert.Nil(t, original)
+
+	// Changes made by others are not detected while the hash matches
+	current.Data["other"] = "value"
+	result, err := patchMaker.Calculate(current, newConfigMap("value1"))
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+	assert.Equal(t, current, result.Patched)
+
+	result, err = patchMaker.Calculate(current, newConfigMap("value2"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"key":"value2"}}`, string(result.Patch))
+
+	patched := result.Patched.(*corev1.ConfigMap)
+	assert.NotEqual(t, current.Annotations[LastAppliedConfig], patched.Annotations[LastAppliedConfig])
+	matches, err := annotator.matchesLastApplied(patched, newConfigMap("value2"))
+	assert.NoError(t, err)
+	assert.True(t, matches)
+}
@@ -0,0 +1,188 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const dataHashPrefix = "sha256:"
+
+// dataFields are the fields of Secrets and ConfigMaps holding the data.
+var dataFields = []string{"data", "binaryData"}
+
+// WithDataHashing makes Calculate compare the data of Secrets and ConfigMaps through SHA256 hashes, so the
+// Current, Modified and Original fields of the result don't carry the values. The patch still holds the actual
+// values of the changed keys. The stringData of Secrets is compared as data. Use it with an annotator created
+// with Annotator.WithDataHashing so the last-applied annotation holds hashes as well.
+func WithDataHashing() PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.hashData = true
+	}
+}
+
+// WithDataHashing returns a copy of the annotator storing the data of Secrets and ConfigMaps as SHA256 hashes
+// in the last-applied annotation, which keeps secret values out of the annotation and makes it smaller.
+func (a *Annotator) WithDataHashing() *Annotator {
+	hashing := *a
+	hashing.hashData = true
+	return &hashing
+}
+
+func isDataObject(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == "" && (gvk.Kind == "Secret" || gvk.Kind == "ConfigMap")
+}
+
+// dataHasher hashes the data of an object and restores the actual values in patches.
+type dataHasher struct {
+	isSecret bool
+	// values holds the actual modified values by data field and key.
+	values map[string]map[string]interface{}
+}
+
+func newDataHasher(gvk schema.GroupVersionKind) *dataHasher {
+	if !isDataObject(gvk) {
+		return nil
+	}
+	return &dataHasher{
+		isSecret: gvk.Kind == "Secret",
+		values:   map[string]map[string]interface{}{},
+	}
+}
+
+// hash replaces the data values of the JSON document by their hashes.
+func (h *dataHasher) hash(obj []byte, record bool) ([]byte, error) {
+	if obj == nil {
+		return nil, nil
+	}
+
+	resource := map[string]interface{}{}
+	if err := json.Unmarshal(obj, &resource); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal byte sequence")
+	}
+
+	if h.isSecret {
+		foldStringData(resource)
+	}
+	for _, field := range dataFields {
+		data, ok := resource[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if record {
+			values := make(map[string]interface{}, len(data))
+			for key, value := range data {
+				values[key] = value
+			}
+			h.values[field] = values
+		}
+		hashDataValues(data)
+	}
+
+	return json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+}
+
+// restore replaces the hashes of the patch by the actual modified values, a nil hasher returns the patch as is.
+func (h *dataHasher) restore(patch []byte) ([]byte, error) {
+	if h == nil {
+		return patch, nil
+	}
+
+	patchMap := map[string]interface{}{}
+	if err := json.Unmarshal(patch, &patchMap); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal patch")
+	}
+
+	for _, field := range dataFields {
+		data, ok := patchMap[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key, value := range data {
+			if hash, ok := value.(string); ok && strings.HasPrefix(hash, dataHashPrefix) {
+				actual, ok := h.values[field][key]
+				if !ok {
+					return nil, errors.Errorf("could not restore the value of %s.%s", field, key)
+				}
+				data[key] = actual
+			}
+		}
+	}
+
+	return json.ConfigCompatibleWithStandardLibrary.Marshal(patchMap)
+}
+
+// foldStringData moves the stringData of a Secret to its data, as the API server does.
+func foldStringData(resource map[string]interface{}) {
+	stringData, ok := resource["stringData"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	data, ok := resource["data"].(map[string]interface{})
+	if !ok {
+		data = map[string]interface{}{}
+		resource["data"] = data
+	}
+	for key, value := range stringData {
+		if str, ok := value.(string); ok {
+			data[key] = base64.StdEncoding.EncodeToString([]byte(str))
+		}
+	}
+	delete(resource, "stringData")
+}
+
+func hashDataValues(data map[string]interface{}) {
+	for key, value := range data {
+		str, ok := value.(string)
+		if !ok || strings.HasPrefix(str, dataHashPrefix) {
+			continue
+		}
+		sum := sha256.Sum256([]byte(str))
+		data[key] = dataHashPrefix + hex.EncodeToString(sum[:])
+	}
+}
+
+// hashObjectData hashes the data of the JSON document of a Secret or ConfigMap.
+func hashObjectData(obj runtime.Object, data []byte) ([]byte, error) {
+	hasher := newDataHasher(objectGroupVersionKind(obj))
+	if hasher == nil {
+		return data, nil
+	}
+	return hasher.hash(data, false)
+}
+
+// restoreHashedData replaces the hashes of the JSON merge patch by the actual values, then applies it again on the current object.
+func (p *PatchMaker) restoreHashedData(hasher *dataHasher, patch, currentOrg []byte) ([]byte, []byte, error) {
+	patch, err := hasher.restore(patch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	patchedCurrent, err := p.jsonMergePatcher.MergePatch(currentOrg, patch)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to apply patch")
+	}
+
+	return patch, patchedCurrent, nil
+}
@@ -0,0 +1,107 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDataHashing(t *testing.T) {
+	annotator := NewAnnotator(LastAppliedConfig).WithDataHashing()
+	patchMaker := NewPatchMaker(annotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithDataHashing())
+
+	newSecret := func(password string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{
+				"username": []byte("admin"),
+				"password": []byte(password),
+			},
+		}
+	}
+
+	current := newSecret("secret1")
+	assert.NoError(t, annotator.SetLastAppliedAnnotation(current))
+	original, err := annotator.GetOriginalConfiguration(current)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(original), "c2VjcmV0MQ==")
+	assert.Contains(t, string(original), dataHashPrefix)
+
+	result, err := patchMaker.Calculate(current, newSecret("secret1"))
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty(), "unexpected patch: %s", result.Patch)
+
+	result, err = patchMaker.Calculate(current, newSecret("secret2"))
+	assert.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+	assert.JSONEq(t, `{"data":{"password":"c2VjcmV0Mg=="}}`, string(result.Patch))
+	for _, doc := range [][]byte{result.Current, result.Modified, result.Original} {
+		assert.NotContains(t, string(doc), "c2VjcmV0")
+		assert.Contains(t, string(doc), dataHashPrefix)
+	}
+
+	patched := result.Patched.(*corev1.Secret)
+	assert.Equal(t, []byte("secret2"), patched.Data["password"])
+	original, err = annotator.GetOriginalConfiguration(patched)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(original), "c2VjcmV0Mg==")
+}
+
+func TestDataHashingStringData(t *testing.T) {
+	annotator := NewAnnotator(LastAppliedConfig).WithDataHashing()
+	patchMaker := NewPatchMaker(annotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithDataHashing())
+
+	modified := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		StringData: map[string]string{"password": "secret1"},
+	}
+
+	// The API server folds stringData into data
+	current := modified.DeepCopy()
+	assert.NoError(t, annotator.SetLastAppliedAnnotation(current))
+	original, err := annotator.GetOriginalConfiguration(current)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(original), "secret1")
+	current.StringData = nil
+	current.Data = map[string][]byte{"password": []byte("secret1")}
+
+	result, err := patchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty(), "unexpected patch: %s", result.Patch)
+}
+
+func TestDataHashingIgnoresOtherKinds(t *testing.T) {
+	annotator := NewAnnotator(LastAppliedConfig).WithDataHashing()
+
+	pod := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{Name: "pod"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "test", Image: "nginx"}}},
+	}
+	assert.NoError(t, annotator.SetLastAppliedAnnotation(pod))
+	original, err := annotator.GetOriginalConfiguration(pod)
+	assert.NoError(t, err)
+	assert.Contains(t, string(original), `"image":"nginx"`)
+	assert.NotContains(t, string(original), dataHashPrefix)
+}
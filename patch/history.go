@@ -0,0 +1,161 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// PatchRecord describes a non-empty patch calculated for an object.
+type PatchRecord struct {
+	GVK       schema.GroupVersionKind
+	Namespace string
+	Name      string
+	// Patch is redacted, see PatchResult.Redacted.
+	Patch     []byte
+	Timestamp time.Time
+}
+
+// serializedPatchRecord is the JSON form of PatchRecord.
+type serializedPatchRecord struct {
+	Timestamp  time.Time       `json:"timestamp"`
+	APIVersion string          `json:"apiVersion,omitempty"`
+	Kind       string          `json:"kind,omitempty"`
+	Namespace  string          `json:"namespace,omitempty"`
+	Name       string          `json:"name"`
+	Patch      json.RawMessage `json:"patch"`
+}
+
+// MarshalJSON encodes the record as a flat object with the apiVersion and kind of the object.
+func (r PatchRecord) MarshalJSON() ([]byte, error) {
+	apiVersion, kind := r.GVK.ToAPIVersionAndKind()
+	return json.ConfigCompatibleWithStandardLibrary.Marshal(serializedPatchRecord{
+		Timestamp:  r.Timestamp,
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  r.Namespace,
+		Name:       r.Name,
+		Patch:      r.Patch,
+	})
+}
+
+// UnmarshalJSON decodes a record encoded by MarshalJSON.
+func (r *PatchRecord) UnmarshalJSON(data []byte) error {
+	var serialized serializedPatchRecord
+	if err := json.Unmarshal(data, &serialized); err != nil {
+		return errors.Wrap(err, "could not unmarshal patch record")
+	}
+
+	*r = PatchRecord{
+		GVK:       schema.FromAPIVersionAndKind(serialized.APIVersion, serialized.Kind),
+		Namespace: serialized.Namespace,
+		Name:      serialized.Name,
+		Patch:     serialized.Patch,
+		Timestamp: serialized.Timestamp,
+	}
+	return nil
+}
+
+// PatchRecorder keeps an audit trail of the patches calculated by a PatchMaker, see WithPatchRecorder. The context is
+// the one given to CalculateWithContext, the recorder is called synchronously.
+type PatchRecorder interface {
+	RecordPatch(ctx context.Context, record PatchRecord) error
+}
+
+// PatchRecorderFunc adapts a function to a PatchRecorder.
+type PatchRecorderFunc func(ctx context.Context, record PatchRecord) error
+
+func (f PatchRecorderFunc) RecordPatch(ctx context.Context, record PatchRecord) error {
+	return f(ctx, record)
+}
+
+// WithPatchRecorder calls the recorder each time Calculate returns a non-empty patch, cached results included.
+// Secret values are redacted from the recorded patches. The errors of the recorder are logged to the logger set
+// with WithLogger, unless FailOnPatchRecorderError is set.
+func WithPatchRecorder(recorder PatchRecorder) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.recorder = recorder
+	}
+}
+
+// FailOnPatchRecorderError makes Calculate fail when the recorder set with WithPatchRecorder does, for audit trails
+// which must not miss a patch.
+func FailOnPatchRecorderError() PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.failOnRecorderError = true
+	}
+}
+
+// recordPatch passes the patch of the result to the recorder when it's not empty. The error of the recorder is
+// only returned with FailOnPatchRecorderError, it's logged otherwise.
+func (p *PatchMaker) recordPatch(ctx CalculateContext, result *PatchResult) error {
+	if p.recorder == nil || result.IsEmpty() {
+		return nil
+	}
+
+	err := p.recordPatchOf(ctx, result)
+	if err != nil && !p.failOnRecorderError {
+		p.logger.Error(err, "failed to record the patch", "gvk", ctx.GVK.String())
+		return nil
+	}
+	return err
+}
+
+func (p *PatchMaker) recordPatchOf(ctx CalculateContext, result *PatchResult) error {
+	accessor, err := meta.Accessor(ctx.CurrentObject)
+	if err != nil {
+		return errors.Wrap(err, "could not access object metadata")
+	}
+
+	return p.recorder.RecordPatch(ctx.callerContext(), PatchRecord{
+		GVK:       ctx.GVK,
+		Namespace: accessor.GetNamespace(),
+		Name:      accessor.GetName(),
+		Patch:     result.Redacted().Patch,
+		Timestamp: time.Now(),
+	})
+}
+
+// JSONLinesPatchRecorder writes the records as JSON lines, e.g. to a file opened in append mode.
+type JSONLinesPatchRecorder struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONLinesPatchRecorder(w io.Writer) *JSONLinesPatchRecorder {
+	return &JSONLinesPatchRecorder{
+		w: w,
+	}
+}
+
+func (r *JSONLinesPatchRecorder) RecordPatch(_ context.Context, record PatchRecord) error {
+	line, err := json.ConfigCompatibleWithStandardLibrary.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal patch record")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(append(line, '\n'))
+	return errors.Wrap(err, "could not write patch record")
+}
@@ -0,0 +1,128 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+	"strings"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
+)
+
+// PatchHistoryDataKey is the key holding the JSON lines of the records in the ConfigMap of ConfigMapPatchRecorder.
+const PatchHistoryDataKey = "history"
+
+// PatchCalculatedEventReason is the reason of the events emitted by EventPatchRecorder.
+const PatchCalculatedEventReason = "PatchCalculated"
+
+// ConfigMapPatchRecorder appends the records as JSON lines to a ConfigMap, keeping the last records up to the limit.
+// A limit lower than 1 keeps all the records, mind the size limit of ConfigMaps.
+type ConfigMapPatchRecorder struct {
+	client    corev1client.ConfigMapsGetter
+	namespace string
+	name      string
+	limit     int
+}
+
+func NewConfigMapPatchRecorder(client corev1client.ConfigMapsGetter, namespace, name string, limit int) *ConfigMapPatchRecorder {
+	return &ConfigMapPatchRecorder{
+		client:    client,
+		namespace: namespace,
+		name:      name,
+		limit:     limit,
+	}
+}
+
+func (r *ConfigMapPatchRecorder) RecordPatch(ctx context.Context, record PatchRecord) error {
+	line, err := json.ConfigCompatibleWithStandardLibrary.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal patch record")
+	}
+
+	configMaps := r.client.ConfigMaps(r.namespace)
+	err = retry.OnError(retry.DefaultRetry, isConflictOrAlreadyExists, func() error {
+		configMap, err := configMaps.Get(ctx, r.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      r.name,
+					Namespace: r.namespace,
+				},
+				Data: map[string]string{
+					PatchHistoryDataKey: string(line) + "\n",
+				},
+			}
+			_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[PatchHistoryDataKey] = r.appended(configMap.Data[PatchHistoryDataKey], string(line))
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+	return errors.WrapIfWithDetails(err, "could not record patch in ConfigMap", "namespace", r.namespace, "name", r.name)
+}
+
+// appended adds the line to the history, dropping the oldest lines over the limit.
+func (r *ConfigMapPatchRecorder) appended(history, line string) string {
+	lines := append(strings.Split(strings.TrimSuffix(history, "\n"), "\n"), line)
+	if lines[0] == "" {
+		lines = lines[1:]
+	}
+	if r.limit > 0 && len(lines) > r.limit {
+		lines = lines[len(lines)-r.limit:]
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// EventPatchRecorder emits a Normal event with the PatchCalculated reason on the patched object for each record.
+// The event messages are truncated. See EventRecorderHook for events summarizing the changes.
+type EventPatchRecorder struct {
+	recorder record.EventRecorder
+}
+
+func NewEventPatchRecorder(recorder record.EventRecorder) *EventPatchRecorder {
+	return &EventPatchRecorder{
+		recorder: recorder,
+	}
+}
+
+func (r *EventPatchRecorder) RecordPatch(_ context.Context, record PatchRecord) error {
+	apiVersion, kind := record.GVK.ToAPIVersionAndKind()
+	reference := &corev1.ObjectReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  record.Namespace,
+		Name:       record.Name,
+	}
+
+	r.recorder.Event(reference, corev1.EventTypeNormal, PatchCalculatedEventReason, truncateEventMessage("Calculated patch "+string(record.Patch)))
+
+	return nil
+}
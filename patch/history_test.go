@@ -0,0 +1,174 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/go-logr/logr/funcr"
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newHistorySecret(password string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{"password": []byte(password)},
+	}
+}
+
+func TestWithPatchRecorder(t *testing.T) {
+	var records []PatchRecord
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		WithPatchRecorder(PatchRecorderFunc(func(ctx context.Context, record PatchRecord) error {
+			records = append(records, record)
+			return nil
+		})))
+
+	current := newHistorySecret("secret1")
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	result, err := patchMaker.Calculate(current, newHistorySecret("secret1"))
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+	assert.Empty(t, records)
+
+	before := time.Now()
+	result, err = patchMaker.Calculate(current, newHistorySecret("secret2"))
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+	require.Len(t, records, 1)
+	assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, records[0].GVK)
+	assert.Equal(t, "default", records[0].Namespace)
+	assert.Equal(t, "secret", records[0].Name)
+	assert.JSONEq(t, `{"data":{"password":"[redacted]"}}`, string(records[0].Patch))
+	assert.False(t, records[0].Timestamp.Before(before))
+
+}
+
+func TestWithPatchRecorderErrors(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "caller")
+	failingRecorder := PatchRecorderFunc(func(recordCtx context.Context, record PatchRecord) error {
+		assert.Equal(t, "caller", recordCtx.Value(key{}))
+		return errors.New("unavailable")
+	})
+
+	current := newHistorySecret("secret1")
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	// The errors of the recorder are logged
+	var logged []string
+	logger := funcr.New(func(prefix, args string) {
+		logged = append(logged, args)
+	}, funcr.Options{})
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		WithLogger(logger), WithPatchRecorder(failingRecorder))
+	result, err := patchMaker.(CtxMaker).CalculateWithContext(ctx, current, newHistorySecret("secret2"))
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+	require.Len(t, logged, 1)
+	assert.Contains(t, logged[0], `"msg"="failed to record the patch"`)
+	assert.Contains(t, logged[0], `"error"="unavailable"`)
+
+	// Calculate fails with the recorder when it's opted in
+	patchMaker = NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		WithPatchRecorder(failingRecorder), FailOnPatchRecorderError())
+	_, err = patchMaker.(CtxMaker).CalculateWithContext(ctx, current, newHistorySecret("secret2"))
+	assert.EqualError(t, err, "Failed to record the patch: unavailable")
+}
+
+func TestJSONLinesPatchRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := NewJSONLinesPatchRecorder(&buf)
+	record := PatchRecord{
+		GVK:       schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		Namespace: "default",
+		Name:      "app",
+		Patch:     []byte(`{"spec":{"replicas":2}}`),
+		Timestamp: time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, recorder.RecordPatch(context.Background(), record))
+	require.NoError(t, recorder.RecordPatch(context.Background(), record))
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"timestamp":"2022-10-01T12:00:00Z","apiVersion":"apps/v1","kind":"Deployment","namespace":"default","name":"app","patch":{"spec":{"replicas":2}}}`, lines[0])
+
+	var decoded PatchRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &decoded))
+	assert.Equal(t, record, decoded)
+}
+
+func TestConfigMapPatchRecorder(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	recorder := NewConfigMapPatchRecorder(client.CoreV1(), "operator", "history", 2)
+
+	for _, name := range []string{"first", "second", "third"} {
+		require.NoError(t, recorder.RecordPatch(context.Background(), PatchRecord{
+			GVK:       schema.GroupVersionKind{Version: "v1", Kind: "Service"},
+			Namespace: "default",
+			Name:      name,
+			Patch:     []byte(`{"spec":{"type":"NodePort"}}`),
+		}))
+	}
+
+	configMap, err := client.CoreV1().ConfigMaps("operator").Get(context.TODO(), "history", metav1.GetOptions{})
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(configMap.Data[PatchHistoryDataKey], "\n"), "\n")
+	require.Len(t, lines, 2)
+	var record PatchRecord
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "second", record.Name)
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "third", record.Name)
+}
+
+func TestEventPatchRecorder(t *testing.T) {
+	eventRecorder := record.NewFakeRecorder(10)
+	recorder := NewEventPatchRecorder(eventRecorder)
+
+	require.NoError(t, recorder.RecordPatch(context.Background(), PatchRecord{
+		GVK:       schema.GroupVersionKind{Version: "v1", Kind: "Service"},
+		Namespace: "default",
+		Name:      "app",
+		Patch:     []byte(`{"spec":{"type":"NodePort"}}`),
+	}))
+	require.NoError(t, recorder.RecordPatch(context.Background(), PatchRecord{
+		GVK:   schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+		Name:  "config",
+		Patch: []byte(`{"data":{"key":"` + strings.Repeat("a", 1000) + `"}}`),
+	}))
+
+	require.Len(t, eventRecorder.Events, 2)
+	assert.Equal(t, `Normal PatchCalculated Calculated patch {"spec":{"type":"NodePort"}}`, <-eventRecorder.Events)
+	event := <-eventRecorder.Events
+	assert.True(t, strings.HasSuffix(event, "..."), event)
+	assert.Less(t, len(event), 600)
+}
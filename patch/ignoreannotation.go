@@ -0,0 +1,91 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"strings"
+
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// IgnorePathsAnnotation is the annotation read by WithIgnorePathsAnnotation when no other key is given.
+const IgnorePathsAnnotation = "banzaicloud.com/ignore-paths"
+
+// WithIgnorePathsAnnotation reads a comma separated list of paths, in the syntax of IgnoreJSONPath, from the annotation
+// of the current object with the given key (IgnorePathsAnnotation if empty), and ignores them as if IgnoreJSONPath was
+// passed to Calculate, e.g. `banzaicloud.com/ignore-paths: .spec.replicas,.metadata.labels.foo`. It lets cluster users
+// exclude fields from the management of an operator. Invalid paths make Calculate fail.
+func WithIgnorePathsAnnotation(key string) PatchMakerOption {
+	if key == "" {
+		key = IgnorePathsAnnotation
+	}
+	return func(p *PatchMaker) {
+		p.ignorePathsAnnotation = key
+	}
+}
+
+// annotatedIgnoreOption returns the option ignoring the paths listed in the annotation of the object,
+// nil if there is none.
+func (p *PatchMaker) annotatedIgnoreOption(obj runtime.Object) (CalculateOptionCtx, error) {
+	if p.ignorePathsAnnotation == "" {
+		return nil, nil
+	}
+
+	annotations, err := meta.NewAccessor().Annotations(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read annotations")
+	}
+	value := strings.TrimSpace(annotations[p.ignorePathsAnnotation])
+	if value == "" {
+		return nil, nil
+	}
+
+	paths := splitPaths(value)
+	for _, path := range paths {
+		if _, err := parseJSONPath(path); err != nil {
+			return nil, errors.WrapIfWithDetails(err, "invalid path in annotation", "annotation", p.ignorePathsAnnotation)
+		}
+	}
+
+	return WithoutContext(IgnoreJSONPath(paths...)), nil
+}
+
+// splitPaths splits a comma separated list of paths, ignoring the commas in quoted keys.
+func splitPaths(value string) []string {
+	var paths []string
+	start := 0
+	quoted := false
+	for i, char := range value {
+		switch char {
+		case '\'':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				paths = appendTrimmedPath(paths, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return appendTrimmedPath(paths, value[start:])
+}
+
+func appendTrimmedPath(paths []string, path string) []string {
+	if path = strings.TrimSpace(path); path != "" {
+		paths = append(paths, path)
+	}
+	return paths
+}
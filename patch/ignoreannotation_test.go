@@ -0,0 +1,56 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWithIgnorePathsAnnotation(t *testing.T) {
+	newConfigMap := func(labels map[string]string, data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default", Labels: labels},
+			Data:       data,
+		}
+	}
+
+	current := newConfigMap(map[string]string{"app.kubernetes.io/name": "app"}, map[string]string{"a": "1", "b": "1"})
+	mustAnnotate(current)
+	current.Annotations[IgnorePathsAnnotation] = " .data.a , .metadata.labels['app.kubernetes.io/name'] "
+	modified := newConfigMap(map[string]string{"app.kubernetes.io/name": "other"}, map[string]string{"a": "2", "b": "2"})
+
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithIgnorePathsAnnotation(""))
+	patch, err := patchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"b":"2"}}`, string(patch.Patch))
+
+	// The annotation is only read when enabled
+	patch, err = DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.Contains(t, string(patch.Patch), `"a":"2"`)
+
+	// Invalid paths make Calculate fail
+	current.Annotations[IgnorePathsAnnotation] = ".data["
+	_, err = patchMaker.Calculate(current, modified)
+	assert.Error(t, err)
+}
+
+func TestSplitPaths(t *testing.T) {
+	assert.Equal(t, []string{".a", ".metadata.annotations['a,b']", ".c"}, splitPaths(".a, .metadata.annotations['a,b'],,.c,"))
+}
@@ -0,0 +1,134 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import "k8s.io/apimachinery/pkg/runtime/schema"
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// crdCABundlePaths are the CA bundles of CustomResourceDefinition conversion webhooks (v1 and v1beta1).
+var crdCABundlePaths = []string{
+	".spec.conversion.webhook.clientConfig.caBundle",
+	".spec.conversion.webhookClientConfig.caBundle",
+}
+
+// IgnoreCRDConversionWebhookAndStatus removes the status and the conversion webhook CA bundle of CustomResourceDefinitions
+// from both objects before comparing them. The schema fields of the versions (matched by name) and the conversion settings
+// only present in the current object are removed as well: they are populated by the API server, and as the versions list
+// is replaced as a whole they would otherwise produce a patch. Objects of other kinds are left untouched.
+func IgnoreCRDConversionWebhookAndStatus() CalculateOption {
+	return MapOptions(IgnoreCRDConversionWebhookAndStatusMap())
+}
+
+// IgnoreCRDConversionWebhookAndStatusMap is the map option of IgnoreCRDConversionWebhookAndStatus.
+func IgnoreCRDConversionWebhookAndStatusMap() CalculateMapOption {
+	caBundlePaths := make([][]pathSegment, 0, len(crdCABundlePaths))
+	for _, path := range crdCABundlePaths {
+		parsed, err := parseJSONPath(path)
+		if err != nil {
+			panic(err)
+		}
+		caBundlePaths = append(caBundlePaths, parsed)
+	}
+
+	return func(current, modified map[string]interface{}) error {
+		if !hasGroupKind(current, crdGroupKind) || !hasGroupKind(modified, crdGroupKind) {
+			return nil
+		}
+
+		for _, resource := range []map[string]interface{}{current, modified} {
+			delete(resource, "status")
+			for _, path := range caBundlePaths {
+				deleteAtPath(resource, path)
+			}
+		}
+
+		currentSpec, _ := current["spec"].(map[string]interface{})
+		modifiedSpec, _ := modified["spec"].(map[string]interface{})
+		if currentSpec == nil {
+			return nil
+		}
+
+		if currentConversion, ok := currentSpec["conversion"]; ok {
+			if modifiedConversion, ok := modifiedSpec["conversion"]; ok {
+				currentSpec["conversion"] = deleteFieldsMissingFrom(currentConversion, modifiedConversion)
+			} else {
+				delete(currentSpec, "conversion")
+			}
+		}
+
+		modifiedVersions, _ := modifiedSpec["versions"].([]interface{})
+		currentVersions, _ := currentSpec["versions"].([]interface{})
+		for _, currentVersion := range currentVersions {
+			currentVersion, ok := currentVersion.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			modifiedVersion := findNamedItem(modifiedVersions, currentVersion["name"])
+			if modifiedVersion == nil {
+				continue
+			}
+			if currentSchema, ok := currentVersion["schema"]; ok {
+				if modifiedSchema, ok := modifiedVersion["schema"]; ok {
+					currentVersion["schema"] = deleteFieldsMissingFrom(currentSchema, modifiedSchema)
+				}
+			}
+		}
+
+		return nil
+	}
+}
+
+// deleteFieldsMissingFrom removes the object fields of current missing from modified, recursively. Lists are
+// compared item by item.
+func deleteFieldsMissingFrom(current, modified interface{}) interface{} {
+	switch typedCurrent := current.(type) {
+	case map[string]interface{}:
+		typedModified, ok := modified.(map[string]interface{})
+		if !ok {
+			return current
+		}
+		for key, value := range typedCurrent {
+			modifiedValue, ok := typedModified[key]
+			if !ok {
+				delete(typedCurrent, key)
+				continue
+			}
+			typedCurrent[key] = deleteFieldsMissingFrom(value, modifiedValue)
+		}
+	case []interface{}:
+		typedModified, ok := modified.([]interface{})
+		if !ok {
+			return current
+		}
+		for i := range typedCurrent {
+			if i < len(typedModified) {
+				typedCurrent[i] = deleteFieldsMissingFrom(typedCurrent[i], typedModified[i])
+			}
+		}
+	}
+	return current
+}
+
+// findNamedItem returns the list item with the given name.
+func findNamedItem(items []interface{}, name interface{}) map[string]interface{} {
+	for _, item := range items {
+		item, ok := item.(map[string]interface{})
+		if ok && item["name"] == name {
+			return item
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestIgnoreCRDConversionWebhookAndStatus(t *testing.T) {
+	newCRD := func(caBundle string, description string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata": map[string]interface{}{
+				"name": "foos.example.com",
+			},
+			"spec": map[string]interface{}{
+				"group": "example.com",
+				"names": map[string]interface{}{"kind": "Foo", "plural": "foos"},
+				"scope": "Namespaced",
+				"conversion": map[string]interface{}{
+					"strategy": "Webhook",
+					"webhook": map[string]interface{}{
+						"clientConfig": map[string]interface{}{
+							"caBundle": caBundle,
+							"service":  map[string]interface{}{"name": "webhook", "namespace": "default"},
+						},
+						"conversionReviewVersions": []interface{}{"v1"},
+					},
+				},
+				"versions": []interface{}{
+					map[string]interface{}{
+						"name":    "v1",
+						"served":  true,
+						"storage": true,
+						"schema": map[string]interface{}{
+							"openAPIV3Schema": map[string]interface{}{
+								"type":        "object",
+								"description": description,
+							},
+						},
+					},
+				},
+			},
+		}}
+	}
+
+	current := newCRD("injected", "Foo")
+	mustAnnotate(current)
+	// Fields populated by the API server
+	current.Object["status"] = map[string]interface{}{"acceptedNames": map[string]interface{}{"kind": "Foo"}}
+	assert.NoError(t, unstructured.SetNestedField(current.Object, int64(443), "spec", "conversion", "webhook", "clientConfig", "service", "port"))
+	version := current.Object["spec"].(map[string]interface{})["versions"].([]interface{})[0].(map[string]interface{})
+	version["schema"].(map[string]interface{})["openAPIV3Schema"].(map[string]interface{})["x-kubernetes-preserve-unknown-fields"] = false
+
+	patch, err := DefaultPatchMaker.Calculate(current, newCRD("rotated", "Foo"))
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, newCRD("rotated", "Foo"), IgnoreCRDConversionWebhookAndStatus())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// Changes of the schema are still detected
+	patch, err = DefaultPatchMaker.Calculate(current, newCRD("rotated", "Foo resource"), IgnoreCRDConversionWebhookAndStatus())
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	// Other objects are left untouched
+	pod := []byte(`{"apiVersion":"v1","kind":"Pod","spec":{},"status":{"phase":"Running"}}`)
+	currentPod, _, err := IgnoreCRDConversionWebhookAndStatus()(pod, pod)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(pod), string(currentPod))
+}
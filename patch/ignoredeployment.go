@@ -0,0 +1,102 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import "k8s.io/apimachinery/pkg/runtime/schema"
+
+const deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+// deploymentGroupKinds are the group kinds of the Deployments and ReplicaSets, apps/v1 and the deprecated extensions/v1beta1.
+var deploymentGroupKinds = []schema.GroupKind{
+	{Group: "apps", Kind: "Deployment"},
+	{Group: "apps", Kind: "ReplicaSet"},
+	{Group: "extensions", Kind: "Deployment"},
+	{Group: "extensions", Kind: "ReplicaSet"},
+}
+
+// deploymentSpecDefaults are the Deployment spec fields defaulted by the API server with their default values.
+var deploymentSpecDefaults = map[string]interface{}{
+	"progressDeadlineSeconds": float64(600),
+	"revisionHistoryLimit":    float64(10),
+}
+
+// IgnoreDeploymentGeneratedFields removes the fields populated by the API server and the deployment controller from
+// Deployments and ReplicaSets: metadata.generation and the deployment.kubernetes.io/revision annotation are removed from
+// both objects, the defaulted progressDeadlineSeconds, revisionHistoryLimit and rollingUpdate strategy are removed from the
+// current object when the modified object doesn't set them. Objects of other kinds are left untouched.
+func IgnoreDeploymentGeneratedFields() CalculateOption {
+	return MapOptions(IgnoreDeploymentGeneratedFieldsMap())
+}
+
+// IgnoreDeploymentGeneratedFieldsMap is the map option of IgnoreDeploymentGeneratedFields.
+func IgnoreDeploymentGeneratedFieldsMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		if !hasGroupKind(current, deploymentGroupKinds...) || !hasGroupKind(modified, deploymentGroupKinds...) {
+			return nil
+		}
+
+		for _, resource := range []map[string]interface{}{current, modified} {
+			if metadata, ok := resource["metadata"].(map[string]interface{}); ok {
+				delete(metadata, "generation")
+				if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+					delete(annotations, deploymentRevisionAnnotation)
+				}
+			}
+		}
+
+		currentSpec, _ := current["spec"].(map[string]interface{})
+		modifiedSpec, _ := modified["spec"].(map[string]interface{})
+		if currentSpec == nil {
+			return nil
+		}
+
+		deleteDefaultedFields(currentSpec, modifiedSpec, deploymentSpecDefaults)
+
+		if strategy, ok := currentSpec["strategy"].(map[string]interface{}); ok {
+			modifiedStrategy, _ := modifiedSpec["strategy"].(map[string]interface{})
+			deleteDefaultRollingUpdate(strategy, modifiedStrategy)
+			if _, ok := modifiedSpec["strategy"]; !ok && len(strategy) == 0 {
+				delete(currentSpec, "strategy")
+			}
+		}
+
+		return nil
+	}
+}
+
+// deleteDefaultRollingUpdate removes the default RollingUpdate strategy type and the default 25% maxSurge and
+// maxUnavailable from the current strategy when the modified strategy doesn't set them.
+func deleteDefaultRollingUpdate(current, modified map[string]interface{}) {
+	if current["type"] != "RollingUpdate" {
+		return
+	}
+	if _, ok := modified["type"]; !ok {
+		delete(current, "type")
+	}
+
+	rollingUpdate, ok := current["rollingUpdate"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	modifiedRollingUpdate, _ := modified["rollingUpdate"].(map[string]interface{})
+	for _, field := range []string{"maxSurge", "maxUnavailable"} {
+		if _, ok := modifiedRollingUpdate[field]; !ok && rollingUpdate[field] == "25%" {
+			delete(rollingUpdate, field)
+		}
+	}
+	if len(rollingUpdate) == 0 {
+		delete(current, "rollingUpdate")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestIgnoreDeploymentGeneratedFields(t *testing.T) {
+	int32Ptr := func(i int32) *int32 {
+		return &i
+	}
+	newDeployment := func() *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "app"}},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"app": "app"}},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx"}}},
+				},
+			},
+		}
+	}
+
+	current := newDeployment()
+	mustAnnotate(current)
+	// Fields populated by the API server and the deployment controller
+	percent := intstr.FromString("25%")
+	current.Generation = 3
+	current.Annotations[deploymentRevisionAnnotation] = "3"
+	current.Spec.ProgressDeadlineSeconds = int32Ptr(600)
+	current.Spec.RevisionHistoryLimit = int32Ptr(10)
+	current.Spec.Strategy = appsv1.DeploymentStrategy{
+		Type:          appsv1.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: &percent, MaxUnavailable: &percent},
+	}
+
+	modified := newDeployment()
+	modified.Annotations = map[string]string{deploymentRevisionAnnotation: "1"}
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, modified, IgnoreDeploymentGeneratedFields())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// Values differing from the defaults are still compared
+	modified.Spec.RevisionHistoryLimit = int32Ptr(2)
+	patch, err = DefaultPatchMaker.Calculate(current, modified, IgnoreDeploymentGeneratedFields())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"revisionHistoryLimit":2}}`, string(patch.Patch))
+
+	// Objects of other kinds are left untouched
+	statefulSet := []byte(`{"apiVersion":"apps/v1","kind":"StatefulSet","metadata":{"generation":3},"spec":{"revisionHistoryLimit":10}}`)
+	currentStatefulSet, _, err := IgnoreDeploymentGeneratedFields()(statefulSet, statefulSet)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(statefulSet), string(currentStatefulSet))
+}
@@ -0,0 +1,127 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import "strings"
+
+// IgnoreInjectedContainers removes the containers, init containers and volumes injected by service meshes or policy agents
+// from the pod spec of the current object (a Pod, a workload template, a Job or a CronJob job template) unless the modified
+// object has them too, e.g. IgnoreInjectedContainers("istio-proxy", "istio-init", "istio-*"). Names ending with * are
+// prefixes. The volume mounts of the other containers using the removed volumes are removed as well.
+func IgnoreInjectedContainers(names ...string) CalculateOption {
+	return MapOptions(IgnoreInjectedContainersMap(names...))
+}
+
+// IgnoreInjectedContainersMap is the map option of IgnoreInjectedContainers.
+func IgnoreInjectedContainersMap(names ...string) CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		for _, path := range podSpecPaths {
+			currentSpec, ok := fieldValue(current, path).(map[string]interface{})
+			if !ok || !isPodSpec(currentSpec) {
+				continue
+			}
+			modifiedSpec, _ := fieldValue(modified, path).(map[string]interface{})
+
+			for _, field := range []string{"containers", "initContainers"} {
+				removeInjectedItems(currentSpec, modifiedSpec, field, names)
+			}
+			removedVolumes := removeInjectedItems(currentSpec, modifiedSpec, "volumes", names)
+			if len(removedVolumes) > 0 {
+				for _, field := range []string{"containers", "initContainers"} {
+					removeInjectedVolumeMounts(currentSpec, modifiedSpec, field, removedVolumes)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// removeInjectedItems removes the named items of the list matching the names from the current pod spec, unless the modified
+// pod spec has them, and returns the names of the removed items. The list is removed when no item is left.
+func removeInjectedItems(currentSpec, modifiedSpec map[string]interface{}, field string, names []string) map[string]bool {
+	items, ok := currentSpec[field].([]interface{})
+	if !ok {
+		return nil
+	}
+	modifiedItems, _ := modifiedSpec[field].([]interface{})
+
+	removed := map[string]bool{}
+	kept := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		typedItem, _ := item.(map[string]interface{})
+		name, _ := typedItem["name"].(string)
+		if matchesInjectedName(name, names) && findNamedItem(modifiedItems, name) == nil {
+			removed[name] = true
+			continue
+		}
+		kept = append(kept, item)
+	}
+
+	if len(kept) == 0 && modifiedSpec[field] == nil {
+		delete(currentSpec, field)
+	} else {
+		currentSpec[field] = kept
+	}
+	return removed
+}
+
+// removeInjectedVolumeMounts removes the mounts of the removed volumes from the current containers, unless the modified
+// container mounts them too.
+func removeInjectedVolumeMounts(currentSpec, modifiedSpec map[string]interface{}, field string, removedVolumes map[string]bool) {
+	containers, _ := currentSpec[field].([]interface{})
+	modifiedContainers, _ := modifiedSpec[field].([]interface{})
+	for _, container := range containers {
+		typedContainer, ok := container.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		volumeMounts, ok := typedContainer["volumeMounts"].([]interface{})
+		if !ok {
+			continue
+		}
+		modifiedContainer := findNamedItem(modifiedContainers, typedContainer["name"])
+		modifiedVolumeMounts, _ := modifiedContainer["volumeMounts"].([]interface{})
+
+		kept := make([]interface{}, 0, len(volumeMounts))
+		for _, volumeMount := range volumeMounts {
+			typedVolumeMount, _ := volumeMount.(map[string]interface{})
+			name, _ := typedVolumeMount["name"].(string)
+			if removedVolumes[name] && findNamedItem(modifiedVolumeMounts, name) == nil {
+				continue
+			}
+			kept = append(kept, volumeMount)
+		}
+
+		if len(kept) == 0 && modifiedContainer["volumeMounts"] == nil {
+			delete(typedContainer, "volumeMounts")
+		} else {
+			typedContainer["volumeMounts"] = kept
+		}
+	}
+}
+
+// matchesInjectedName tells whether the name is one of the names, or starts with one of the names ending with *.
+func matchesInjectedName(name string, names []string) bool {
+	for _, injected := range names {
+		if prefix := strings.TrimSuffix(injected, "*"); prefix != injected {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == injected {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newInjectedApp(podSpec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "App",
+		"metadata": map[string]interface{}{
+			"name":      "test",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": podSpec,
+			},
+		},
+	}}
+}
+
+func TestIgnoreInjectedContainers(t *testing.T) {
+	modified := newInjectedApp(map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{"name": "app", "image": "app:1"},
+		},
+	})
+	current := newInjectedApp(map[string]interface{}{
+		"containers": []interface{}{
+			map[string]interface{}{
+				"name":  "app",
+				"image": "app:1",
+				"volumeMounts": []interface{}{
+					map[string]interface{}{"name": "istio-envoy", "mountPath": "/etc/istio/proxy"},
+				},
+			},
+			map[string]interface{}{"name": "istio-proxy", "image": "proxyv2:1"},
+		},
+		"initContainers": []interface{}{
+			map[string]interface{}{"name": "istio-init", "image": "proxyv2:1"},
+		},
+		"volumes": []interface{}{
+			map[string]interface{}{"name": "istio-envoy", "emptyDir": map[string]interface{}{}},
+		},
+	})
+	mustAnnotate(current)
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.Calculate(current, modified, IgnoreInjectedContainers("istio-proxy", "istio-init", "istio-*"))
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+
+	// Changes of the other containers are still detected
+	modified.Object["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"] = []interface{}{
+		map[string]interface{}{"name": "app", "image": "app:2"},
+	}
+	result, err = DefaultPatchMaker.Calculate(current, modified, IgnoreInjectedContainers("istio-proxy", "istio-init", "istio-*"))
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+}
+
+func TestIgnoreInjectedContainersKeepsDesired(t *testing.T) {
+	current := []byte(`{"spec":{"containers":[{"name":"app"},{"name":"istio-proxy","image":"proxyv2:1"}],"volumes":[{"name":"istio-envoy"}]}}`)
+	modified := []byte(`{"spec":{"containers":[{"name":"app"},{"name":"istio-proxy","image":"proxyv2:2"}]}}`)
+
+	current, _, err := IgnoreInjectedContainers("istio-*")(current, modified)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"containers":[{"name":"app"},{"name":"istio-proxy","image":"proxyv2:1"}]}}`, string(current))
+}
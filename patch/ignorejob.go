@@ -0,0 +1,111 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import "k8s.io/apimachinery/pkg/runtime/schema"
+
+var (
+	jobGroupKind     = schema.GroupKind{Group: "batch", Kind: "Job"}
+	cronJobGroupKind = schema.GroupKind{Group: "batch", Kind: "CronJob"}
+)
+
+// jobControllerLabels are the labels set by the job controller on the pod template and the selector.
+var jobControllerLabels = []string{
+	"controller-uid",
+	"job-name",
+	"batch.kubernetes.io/controller-uid",
+	"batch.kubernetes.io/job-name",
+}
+
+// jobSpecDefaults are the Job spec fields defaulted by the API server with their default values.
+var jobSpecDefaults = map[string]interface{}{
+	"backoffLimit": float64(6),
+	"completions":  float64(1),
+	"parallelism":  float64(1),
+}
+
+// cronJobSpecDefaults are the CronJob spec fields defaulted by the API server with their default values.
+var cronJobSpecDefaults = map[string]interface{}{
+	"concurrencyPolicy":          "Allow",
+	"suspend":                    false,
+	"successfulJobsHistoryLimit": float64(3),
+	"failedJobsHistoryLimit":     float64(1),
+}
+
+// IgnoreJobGeneratedFields removes the fields populated by the API server and the job controller from Jobs and CronJobs:
+// the status is removed from both objects, the generated selector and the controller-uid and job-name labels of the pod
+// template are removed from both objects unless the modified Job sets manualSelector, and the defaulted backoffLimit,
+// completions, parallelism and CronJob settings are removed from the current object when the modified object doesn't set
+// them. Other objects are left untouched.
+func IgnoreJobGeneratedFields() CalculateOption {
+	return MapOptions(IgnoreJobGeneratedFieldsMap())
+}
+
+// IgnoreJobGeneratedFieldsMap is the map option of IgnoreJobGeneratedFields.
+func IgnoreJobGeneratedFieldsMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		switch {
+		case hasGroupKind(current, cronJobGroupKind) && hasGroupKind(modified, cronJobGroupKind):
+			delete(current, "status")
+			delete(modified, "status")
+
+			currentSpec, _ := current["spec"].(map[string]interface{})
+			modifiedSpec, _ := modified["spec"].(map[string]interface{})
+			deleteDefaultedFields(currentSpec, modifiedSpec, cronJobSpecDefaults)
+
+			deleteJobGeneratedFields(
+				fieldValue(current, []string{"spec", "jobTemplate", "spec"}),
+				fieldValue(modified, []string{"spec", "jobTemplate", "spec"}),
+			)
+		case hasGroupKind(current, jobGroupKind) && hasGroupKind(modified, jobGroupKind):
+			delete(current, "status")
+			delete(modified, "status")
+
+			deleteJobGeneratedFields(current["spec"], modified["spec"])
+		}
+		return nil
+	}
+}
+
+func deleteJobGeneratedFields(current, modified interface{}) {
+	currentSpec, _ := current.(map[string]interface{})
+	modifiedSpec, _ := modified.(map[string]interface{})
+	if currentSpec == nil {
+		return
+	}
+
+	deleteDefaultedFields(currentSpec, modifiedSpec, jobSpecDefaults)
+
+	if modifiedSpec["manualSelector"] == true {
+		return
+	}
+	for _, spec := range []map[string]interface{}{currentSpec, modifiedSpec} {
+		delete(spec, "selector")
+		if labels, ok := fieldValue(spec, []string{"template", "metadata", "labels"}).(map[string]interface{}); ok {
+			for _, label := range jobControllerLabels {
+				delete(labels, label)
+			}
+		}
+	}
+}
+
+// deleteDefaultedFields removes the fields of current set to their default value when modified doesn't set them.
+func deleteDefaultedFields(current, modified map[string]interface{}, defaults map[string]interface{}) {
+	for field, value := range defaults {
+		if modified[field] == nil && current[field] == value {
+			delete(current, field)
+		}
+	}
+}
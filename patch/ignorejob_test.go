@@ -0,0 +1,95 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIgnoreJobGeneratedFields(t *testing.T) {
+	int32Ptr := func(i int32) *int32 {
+		return &i
+	}
+	newJobSpec := func() batchv1.JobSpec {
+		return batchv1.JobSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{{Name: "job", Image: "busybox"}},
+				},
+			},
+		}
+	}
+	generate := func(spec *batchv1.JobSpec) {
+		labels := map[string]string{"controller-uid": "1234", "job-name": "job"}
+		spec.Selector = &v1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "1234"}}
+		spec.Template.Labels = labels
+		spec.BackoffLimit = int32Ptr(6)
+		spec.Completions = int32Ptr(1)
+		spec.Parallelism = int32Ptr(1)
+	}
+
+	current := &batchv1.Job{ObjectMeta: v1.ObjectMeta{Name: "job", Namespace: "default"}, Spec: newJobSpec()}
+	mustAnnotate(current)
+	generate(&current.Spec)
+	current.Status = batchv1.JobStatus{Succeeded: 1}
+	modified := &batchv1.Job{ObjectMeta: v1.ObjectMeta{Name: "job", Namespace: "default"}, Spec: newJobSpec()}
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified, IgnoreJobGeneratedFields())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+	assert.NotContains(t, string(patch.Current), "controller-uid")
+	assert.NotContains(t, string(patch.Current), "status")
+
+	// Values differing from the defaults are still compared
+	modified.Spec.BackoffLimit = int32Ptr(1)
+	patch, err = DefaultPatchMaker.Calculate(current, modified, IgnoreJobGeneratedFields())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"backoffLimit":1}}`, string(patch.Patch))
+
+	// CronJobs
+	newCronJob := func() *batchv1.CronJob {
+		return &batchv1.CronJob{
+			ObjectMeta: v1.ObjectMeta{Name: "cron", Namespace: "default"},
+			Spec: batchv1.CronJobSpec{
+				Schedule:    "* * * * *",
+				JobTemplate: batchv1.JobTemplateSpec{Spec: newJobSpec()},
+			},
+		}
+	}
+	currentCronJob := newCronJob()
+	mustAnnotate(currentCronJob)
+	suspend := false
+	currentCronJob.Spec.ConcurrencyPolicy = batchv1.AllowConcurrent
+	currentCronJob.Spec.Suspend = &suspend
+	currentCronJob.Spec.SuccessfulJobsHistoryLimit = int32Ptr(3)
+	currentCronJob.Spec.FailedJobsHistoryLimit = int32Ptr(1)
+	currentCronJob.Spec.JobTemplate.Spec.BackoffLimit = int32Ptr(6)
+
+	patch, err = DefaultPatchMaker.Calculate(currentCronJob, newCronJob(), IgnoreJobGeneratedFields())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// Custom resources shaped like Jobs are left untouched
+	task := []byte(`{"apiVersion":"example.com/v1","kind":"Task","spec":{"backoffLimit":6,"template":{"spec":{"restartPolicy":"Never"}}},"status":{}}`)
+	currentTask, _, err := IgnoreJobGeneratedFields()(task, []byte(`{"apiVersion":"example.com/v1","kind":"Task","spec":{"template":{"spec":{"restartPolicy":"Never"}}}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(task), string(currentTask))
+}
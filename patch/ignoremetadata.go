@@ -0,0 +1,107 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import "strings"
+
+// templateMetadataPaths are the paths of the metadata of the templates embedded in workloads and CronJobs, where injectors
+// like Istio or linkerd add their annotations.
+var templateMetadataPaths = [][]string{
+	{"spec", "template", "metadata"},
+	{"spec", "jobTemplate", "metadata"},
+	{"spec", "jobTemplate", "spec", "template", "metadata"},
+}
+
+// IgnoreAnnotations removes the annotations whose key starts with one of the prefixes, e.g. kubectl.kubernetes.io/ or
+// sidecar.istio.io/, from both objects before comparing them. The annotations of the embedded templates are removed too,
+// see objectMetadata.
+func IgnoreAnnotations(prefixes ...string) CalculateOption {
+	return MapOptions(IgnoreAnnotationsMap(prefixes...))
+}
+
+// IgnoreAnnotationsMap is the map option of IgnoreAnnotations.
+func IgnoreAnnotationsMap(prefixes ...string) CalculateMapOption {
+	return ignoreMetadataKeys("annotations", prefixes)
+}
+
+// IgnoreLabels removes the labels whose key starts with one of the prefixes from both objects before comparing them.
+// The labels of the embedded templates are removed too, see objectMetadata.
+func IgnoreLabels(prefixes ...string) CalculateOption {
+	return MapOptions(IgnoreLabelsMap(prefixes...))
+}
+
+// IgnoreLabelsMap is the map option of IgnoreLabels.
+func IgnoreLabelsMap(prefixes ...string) CalculateMapOption {
+	return ignoreMetadataKeys("labels", prefixes)
+}
+
+func ignoreMetadataKeys(field string, prefixes []string) CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		for _, resource := range []map[string]interface{}{current, modified} {
+			for _, metadata := range objectMetadata(resource) {
+				deleteKeysWithPrefix(metadata, field, prefixes)
+			}
+		}
+		return nil
+	}
+}
+
+// objectMetadata returns the metadata of the object followed by the metadata of its embedded templates.
+func objectMetadata(resource map[string]interface{}) []map[string]interface{} {
+	var metadata []map[string]interface{}
+	if objectMetadata, ok := resource["metadata"].(map[string]interface{}); ok {
+		metadata = append(metadata, objectMetadata)
+	}
+	return append(metadata, templateMetadata(resource)...)
+}
+
+// templateMetadata returns the metadata of the pod templates, job templates and volumeClaimTemplates embedded in the object.
+func templateMetadata(resource map[string]interface{}) []map[string]interface{} {
+	var metadata []map[string]interface{}
+	for _, path := range templateMetadataPaths {
+		if templateMetadata, ok := fieldValue(resource, path).(map[string]interface{}); ok {
+			metadata = append(metadata, templateMetadata)
+		}
+	}
+	volumeClaimTemplates, _ := fieldValue(resource, []string{"spec", "volumeClaimTemplates"}).([]interface{})
+	for _, volumeClaimTemplate := range volumeClaimTemplates {
+		typedVolumeClaimTemplate, _ := volumeClaimTemplate.(map[string]interface{})
+		if templateMetadata, ok := typedVolumeClaimTemplate["metadata"].(map[string]interface{}); ok {
+			metadata = append(metadata, templateMetadata)
+		}
+	}
+	return metadata
+}
+
+// deleteKeysWithPrefix removes the keys starting with one of the prefixes from the labels or annotations of the metadata,
+// the field is removed when no key is left.
+func deleteKeysWithPrefix(metadata map[string]interface{}, field string, prefixes []string) {
+	values, ok := metadata[field].(map[string]interface{})
+	if !ok || len(values) == 0 {
+		return
+	}
+
+	for key := range values {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				delete(values, key)
+				break
+			}
+		}
+	}
+	if len(values) == 0 {
+		delete(metadata, field)
+	}
+}
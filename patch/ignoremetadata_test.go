@@ -0,0 +1,123 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newAnnotatedDeployment(annotations, labels map[string]string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "test",
+			Namespace:   "default",
+			Annotations: annotations,
+			Labels:      labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: annotations,
+					Labels:      map[string]string{"app": "test"},
+				},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{Name: "test", Image: "test:1"}},
+				},
+			},
+		},
+	}
+}
+
+func TestIgnoreAnnotations(t *testing.T) {
+	current := mustAnnotate(newAnnotatedDeployment(map[string]string{"example.com/config": "a"}, nil)).(*appsv1.Deployment)
+	current.Annotations["kubectl.kubernetes.io/restartedAt"] = "now"
+	current.Spec.Template.Annotations = map[string]string{
+		"example.com/config":      "a",
+		"sidecar.istio.io/status": "injected",
+	}
+	modified := newAnnotatedDeployment(map[string]string{"example.com/config": "a"}, nil)
+	modified.Annotations["kubectl.kubernetes.io/restartedAt"] = "earlier"
+	modified.Spec.Template.Annotations = map[string]string{
+		"example.com/config":      "a",
+		"sidecar.istio.io/status": "pending",
+	}
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.Calculate(current, modified, IgnoreAnnotations("kubectl.kubernetes.io/", "sidecar.istio.io/"))
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+
+	// Other annotations are still compared
+	modified.Spec.Template.Annotations = map[string]string{"example.com/config": "b"}
+	result, err = DefaultPatchMaker.Calculate(current, modified, IgnoreAnnotations("kubectl.kubernetes.io/", "sidecar.istio.io/"))
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+}
+
+func TestIgnoreLabels(t *testing.T) {
+	current := mustAnnotate(newAnnotatedDeployment(nil, map[string]string{"app": "test"})).(*appsv1.Deployment)
+	current.Labels["cattle.io/creator"] = "norman"
+	current.Spec.Template.Labels["security.istio.io/tlsMode"] = "istio"
+	modified := newAnnotatedDeployment(nil, map[string]string{"app": "test", "cattle.io/creator": "helm"})
+	modified.Spec.Template.Labels["security.istio.io/tlsMode"] = "disabled"
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.Calculate(current, modified, IgnoreLabels("cattle.io/", "security.istio.io/"))
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+}
+
+func TestIgnoreAnnotationsCronJob(t *testing.T) {
+	current := []byte(`{"metadata":{"name":"test"},"spec":{"jobTemplate":{"spec":{"template":{"metadata":{"annotations":{"sidecar.istio.io/status":"injected"}}}}}}}`)
+	modified := []byte(`{"metadata":{"name":"test"},"spec":{"jobTemplate":{"spec":{"template":{"metadata":{}}}}}}`)
+
+	current, modified, err := IgnoreAnnotations("sidecar.istio.io/")(current, modified)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(modified), string(current))
+}
+
+func TestIgnoreAnnotationsEmbeddedTemplates(t *testing.T) {
+	current := []byte(`{
+		"metadata": {"name": "test"},
+		"spec": {
+			"jobTemplate": {"metadata": {"annotations": {"linkerd.io/inject": "enabled", "example.com/config": "a"}}},
+			"volumeClaimTemplates": [{"metadata": {"name": "data", "annotations": {"volume.kubernetes.io/selected-node": "node"}}}]
+		}
+	}`)
+	modified := []byte(`{
+		"metadata": {"name": "test"},
+		"spec": {
+			"jobTemplate": {"metadata": {"annotations": {"example.com/config": "a"}}},
+			"volumeClaimTemplates": [{"metadata": {"name": "data"}}]
+		}
+	}`)
+
+	current, modified, err := IgnoreAnnotations("linkerd.io/", "volume.kubernetes.io/")(current, modified)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(modified), string(current))
+}
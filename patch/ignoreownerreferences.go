@@ -0,0 +1,82 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+// ownerReferenceClusterFields are the fields of owner references specific to the cluster the owner lives in.
+var ownerReferenceClusterFields = []string{"uid", "blockOwnerDeletion"}
+
+// IgnoreOwnerReferences removes the owner references from both objects before comparing them.
+func IgnoreOwnerReferences() CalculateOption {
+	return MapOptions(IgnoreOwnerReferencesMap())
+}
+
+// IgnoreOwnerReferencesMap is the map option of IgnoreOwnerReferences.
+func IgnoreOwnerReferencesMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		for _, resource := range []map[string]interface{}{current, modified} {
+			if metadata, ok := resource["metadata"].(map[string]interface{}); ok {
+				delete(metadata, "ownerReferences")
+			}
+		}
+		return nil
+	}
+}
+
+// CleanOwnerReferencesUID compares the owner references without their uid and blockOwnerDeletion fields, which differ
+// between clusters, e.g. after a backup is restored. The modified owner references pointing to the same owner as a current
+// one, by apiVersion, kind and name, take the uid and blockOwnerDeletion of the current owner reference.
+func CleanOwnerReferencesUID() CalculateOption {
+	return MapOptions(CleanOwnerReferencesUIDMap())
+}
+
+// CleanOwnerReferencesUIDMap is the map option of CleanOwnerReferencesUID.
+func CleanOwnerReferencesUIDMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		currentReferences, _ := fieldValue(current, []string{"metadata", "ownerReferences"}).([]interface{})
+		modifiedReferences, _ := fieldValue(modified, []string{"metadata", "ownerReferences"}).([]interface{})
+
+		for _, modifiedReference := range modifiedReferences {
+			typedModifiedReference, ok := modifiedReference.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, currentReference := range currentReferences {
+				typedCurrentReference, ok := currentReference.(map[string]interface{})
+				if !ok || !isSameOwner(typedCurrentReference, typedModifiedReference) {
+					continue
+				}
+				for _, field := range ownerReferenceClusterFields {
+					if value, ok := typedCurrentReference[field]; ok {
+						typedModifiedReference[field] = value
+					} else {
+						delete(typedModifiedReference, field)
+					}
+				}
+				break
+			}
+		}
+		return nil
+	}
+}
+
+// isSameOwner tells whether the owner references point to the same owner, regardless of its uid.
+func isSameOwner(reference, other map[string]interface{}) bool {
+	for _, field := range []string{"apiVersion", "kind", "name"} {
+		if reference[field] != other[field] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newOwnedConfigMap(uid types.UID, blockOwnerDeletion bool) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "owned",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion:         "example.com/v1",
+				Kind:               "App",
+				Name:               "app",
+				UID:                uid,
+				BlockOwnerDeletion: &blockOwnerDeletion,
+			}},
+		},
+		Data: map[string]string{"key": "value"},
+	}
+}
+
+func TestIgnoreOwnerReferences(t *testing.T) {
+	current := mustAnnotate(newOwnedConfigMap("restored-uid", true))
+
+	modified := newOwnedConfigMap("original-uid", false)
+	modified.OwnerReferences[0].Name = "other"
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.Calculate(current, modified, IgnoreOwnerReferences())
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+}
+
+func TestCleanOwnerReferencesUID(t *testing.T) {
+	current := mustAnnotate(newOwnedConfigMap("restored-uid", true))
+
+	result, err := DefaultPatchMaker.Calculate(current, newOwnedConfigMap("original-uid", false))
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.Calculate(current, newOwnedConfigMap("original-uid", false), CleanOwnerReferencesUID())
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+
+	// Another owner is still a change
+	modified := newOwnedConfigMap("original-uid", false)
+	modified.OwnerReferences[0].Name = "other"
+	result, err = DefaultPatchMaker.Calculate(current, modified, CleanOwnerReferencesUID())
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+}
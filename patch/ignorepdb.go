@@ -15,46 +15,31 @@
 package patch
 
 import (
-	"strings"
-
-	"emperror.dev/errors"
 	"github.com/google/go-cmp/cmp"
-	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// IgnorePDBSelector removes the selector of PodDisruptionBudgets from both objects before comparing them when the selectors
+// are equal, as the selector is replaced as a whole and would always produce a patch. It works for the policy/v1 and
+// policy/v1beta1 PodDisruptionBudgets, typed or unstructured.
 func IgnorePDBSelector() CalculateOption {
-	return func(current, modified []byte) ([]byte, []byte, error) {
-		currentResource := map[string]interface{}{}
-		if err := json.Unmarshal(current, &currentResource); err != nil {
-			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for current")
-		}
-
-		modifiedResource := map[string]interface{}{}
-		if err := json.Unmarshal(modified, &modifiedResource); err != nil {
-			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for modified")
-		}
+	return MapOptions(IgnorePDBSelectorMap())
+}
 
-		if isPDB(currentResource) && isPDB(modifiedResource) && cmp.Diff(getPDBSelector(currentResource), getPDBSelector(modifiedResource)) == "" {
-			var err error
-			current, err = deletePDBSelector(currentResource)
-			if err != nil {
-				return nil, nil, errors.Wrap(err, "delete pdb selector from current")
-			}
-			modified, err = deletePDBSelector(modifiedResource)
-			if err != nil {
-				return nil, nil, errors.Wrap(err, "delete pdb selector from modified")
-			}
+// IgnorePDBSelectorMap is the map option of IgnorePDBSelector.
+func IgnorePDBSelectorMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		if isPDB(current) && isPDB(modified) && cmp.Diff(getPDBSelector(current), getPDBSelector(modified)) == "" {
+			deletePDBSelector(current)
+			deletePDBSelector(modified)
 		}
-
-		return current, modified, nil
+		return nil
 	}
 }
 
+// isPDB tells whether the resource is a PodDisruptionBudget of any policy version.
 func isPDB(resource map[string]interface{}) bool {
-	if av, ok := resource["apiVersion"].(string); ok {
-		return strings.HasPrefix(av, "policy/") && resource["kind"] == "PodDisruptionBudget"
-	}
-	return false
+	return hasGroupKind(resource, schema.GroupKind{Group: "policy", Kind: "PodDisruptionBudget"})
 }
 
 func getPDBSelector(resource map[string]interface{}) interface{} {
@@ -68,17 +53,8 @@ func getPDBSelector(resource map[string]interface{}) interface{} {
 	return nil
 }
 
-func deletePDBSelector(resource map[string]interface{}) ([]byte, error) {
-	if spec, ok := resource["spec"]; ok {
-		if spec, ok := spec.(map[string]interface{}); ok {
-			delete(spec, "selector")
-		}
+func deletePDBSelector(resource map[string]interface{}) {
+	if spec, ok := resource["spec"].(map[string]interface{}); ok {
+		delete(spec, "selector")
 	}
-
-	obj, err := json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
-	if err != nil {
-		return []byte{}, errors.Wrap(err, "could not marshal byte sequence")
-	}
-
-	return obj, nil
 }
@@ -3,9 +3,12 @@ package patch
 import (
 	"testing"
 
+	json "github.com/json-iterator/go"
 	"github.com/stretchr/testify/assert"
 	policyv1 "k8s.io/api/policy/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
@@ -93,3 +96,72 @@ func TestIgnorePDBSelector(t *testing.T) {
 	assert.False(t, patch.IsEmpty())
 
 }
+
+func TestIgnorePDBSelectorV1beta1(t *testing.T) {
+	newPDB := func(app string) *policyv1beta1.PodDisruptionBudget {
+		return &policyv1beta1.PodDisruptionBudget{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "pdb",
+				Namespace: "default",
+			},
+			Spec: policyv1beta1.PodDisruptionBudgetSpec{
+				MinAvailable: &intstr.IntOrString{IntVal: 1},
+				Selector: &v1.LabelSelector{
+					MatchLabels: map[string]string{"app": app},
+				},
+			},
+		}
+	}
+
+	// Typed objects without TypeMeta
+	patch, err := DefaultPatchMaker.Calculate(newPDB("test"), newPDB("test"), CleanMetadata(), IgnorePDBSelector())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(newPDB("test"), newPDB("other"), CleanMetadata(), IgnorePDBSelector())
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+}
+
+func TestIgnorePDBSelectorUnstructured(t *testing.T) {
+	newPDB := func(app string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "policy/v1beta1",
+			"kind":       "PodDisruptionBudget",
+			"metadata": map[string]interface{}{
+				"name":      "pdb",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"maxUnavailable": int64(1),
+				"selector": map[string]interface{}{
+					"matchLabels": map[string]interface{}{"app": app},
+				},
+			},
+		}}
+	}
+
+	current, modified, err := IgnorePDBSelector()(mustMarshal(t, newPDB("test")), mustMarshal(t, newPDB("test")))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(current), "selector")
+	assert.NotContains(t, string(modified), "selector")
+
+	current, _, err = IgnorePDBSelector()(mustMarshal(t, newPDB("test")), mustMarshal(t, newPDB("other")))
+	assert.NoError(t, err)
+	assert.Contains(t, string(current), "selector")
+
+	// Objects of other kinds with a similar spec are left untouched
+	other := newPDB("test")
+	other.SetAPIVersion("example.com/v1")
+	current, _, err = IgnorePDBSelector()(mustMarshal(t, other), mustMarshal(t, other))
+	assert.NoError(t, err)
+	assert.Contains(t, string(current), "selector")
+}
+
+func mustMarshal(t *testing.T, obj interface{}) []byte {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,130 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// HPAFieldManager is the field manager of the HorizontalPodAutoscaler controller, which sets the replicas of the
+// workloads through their scale subresource.
+const HPAFieldManager = "kube-controller-manager"
+
+// AutoscalingPredicate tells whether the replicas of a workload are managed by an autoscaler.
+type AutoscalingPredicate func(current, modified *unstructured.Unstructured) bool
+
+// ReplicasManagedByHPA is the default AutoscalingPredicate, it tells whether the metadata.managedFields of the current
+// object show spec.replicas owned by the HorizontalPodAutoscaler controller through the scale subresource.
+func ReplicasManagedByHPA(current, _ *unstructured.Unstructured) bool {
+	for _, entry := range current.GetManagedFields() {
+		if entry.Manager == HPAFieldManager && entry.Subresource == "scale" && ownsReplicas(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReplicasManagedBy returns an AutoscalingPredicate telling whether one of the given field managers owns spec.replicas
+// according to the metadata.managedFields of the current object, e.g. for autoscalers other than the
+// HorizontalPodAutoscaler.
+func ReplicasManagedBy(managers ...string) AutoscalingPredicate {
+	return func(current, _ *unstructured.Unstructured) bool {
+		for _, entry := range current.GetManagedFields() {
+			for _, manager := range managers {
+				if entry.Manager == manager && ownsReplicas(entry) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// ownsReplicas tells whether the managed fields entry owns spec.replicas.
+func ownsReplicas(entry metav1.ManagedFieldsEntry) bool {
+	if entry.FieldsV1 == nil {
+		return false
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+		return false
+	}
+	spec, _ := fields["f:spec"].(map[string]interface{})
+	_, ok := spec["f:replicas"]
+	return ok
+}
+
+// scalableWorkloadGroupKinds are the group kinds of the workloads scaled by HorizontalPodAutoscalers.
+var scalableWorkloadGroupKinds = append([]schema.GroupKind{{Group: "apps", Kind: "StatefulSet"}}, deploymentGroupKinds...)
+
+// IgnoreReplicasWhenHPAManaged removes spec.replicas of Deployments, StatefulSets and ReplicaSets from both objects
+// before comparing them when one of the predicates indicates autoscaling, ReplicasManagedByHPA is used when no
+// predicate is given. Objects of other kinds are left untouched.
+func IgnoreReplicasWhenHPAManaged(predicates ...AutoscalingPredicate) CalculateOption {
+	if len(predicates) == 0 {
+		predicates = []AutoscalingPredicate{ReplicasManagedByHPA}
+	}
+
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		currentResource := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		if err := json.Unmarshal(current, &currentResource.Object); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for current")
+		}
+
+		modifiedResource := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		if err := json.Unmarshal(modified, &modifiedResource.Object); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for modified")
+		}
+
+		if !isScalableWorkload(currentResource) || !isScalableWorkload(modifiedResource) {
+			return current, modified, nil
+		}
+
+		autoscaled := false
+		for _, predicate := range predicates {
+			if predicate(currentResource, modifiedResource) {
+				autoscaled = true
+				break
+			}
+		}
+		if !autoscaled {
+			return current, modified, nil
+		}
+
+		unstructured.RemoveNestedField(currentResource.Object, "spec", "replicas")
+		unstructured.RemoveNestedField(modifiedResource.Object, "spec", "replicas")
+
+		current, err := json.ConfigCompatibleWithStandardLibrary.Marshal(currentResource.Object)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not marshal byte sequence for current")
+		}
+
+		modified, err = json.ConfigCompatibleWithStandardLibrary.Marshal(modifiedResource.Object)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not marshal byte sequence for modified")
+		}
+
+		return current, modified, nil
+	}
+}
+
+func isScalableWorkload(resource *unstructured.Unstructured) bool {
+	return hasGroupKind(resource.Object, scalableWorkloadGroupKinds...)
+}
@@ -0,0 +1,124 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestIgnoreReplicasWhenHPAManaged(t *testing.T) {
+	newStatefulSet := func(replicas int32) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "statefulset",
+				Namespace: "default",
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+					},
+				},
+			},
+		}
+	}
+	replicasFields := &v1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)}
+
+	tests := []struct {
+		name          string
+		managedFields []v1.ManagedFieldsEntry
+		predicates    []AutoscalingPredicate
+		wantEmpty     bool
+	}{
+		{
+			name: "scaled by the HPA",
+			managedFields: []v1.ManagedFieldsEntry{
+				{Manager: "my-operator", Operation: v1.ManagedFieldsOperationUpdate, FieldsType: "FieldsV1", FieldsV1: &v1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{}}}`)}},
+				{Manager: HPAFieldManager, Operation: v1.ManagedFieldsOperationUpdate, Subresource: "scale", FieldsType: "FieldsV1", FieldsV1: replicasFields},
+			},
+			wantEmpty: true,
+		},
+		{
+			name:      "not autoscaled",
+			wantEmpty: false,
+		},
+		{
+			name: "replicas updated by the controller manager without the scale subresource",
+			managedFields: []v1.ManagedFieldsEntry{
+				{Manager: HPAFieldManager, Operation: v1.ManagedFieldsOperationUpdate, FieldsType: "FieldsV1", FieldsV1: replicasFields},
+			},
+			wantEmpty: false,
+		},
+		{
+			name: "scaled by a given manager",
+			managedFields: []v1.ManagedFieldsEntry{
+				{Manager: "keda", Operation: v1.ManagedFieldsOperationUpdate, Subresource: "scale", FieldsType: "FieldsV1", FieldsV1: replicasFields},
+			},
+			predicates: []AutoscalingPredicate{ReplicasManagedBy("keda")},
+			wantEmpty:  true,
+		},
+		{
+			name: "custom predicate",
+			predicates: []AutoscalingPredicate{func(current, modified *unstructured.Unstructured) bool {
+				return modified.GetName() == "statefulset"
+			}},
+			wantEmpty: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := newStatefulSet(1)
+			mustAnnotate(current)
+			current.Spec.Replicas = func(i int32) *int32 { return &i }(4)
+			current.ManagedFields = tt.managedFields
+
+			patch, err := DefaultPatchMaker.Calculate(current, newStatefulSet(1), IgnoreReplicasWhenHPAManaged(tt.predicates...))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantEmpty, patch.IsEmpty())
+		})
+	}
+
+	// Other kinds are left untouched
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "config",
+			ManagedFields: []v1.ManagedFieldsEntry{
+				{Manager: HPAFieldManager, Operation: v1.ManagedFieldsOperationUpdate, Subresource: "scale", FieldsType: "FieldsV1", FieldsV1: replicasFields},
+			},
+		},
+	}
+	current, err := json.Marshal(configMap)
+	assert.NoError(t, err)
+	gotCurrent, _, err := IgnoreReplicasWhenHPAManaged()(current, current)
+	assert.NoError(t, err)
+	assert.Equal(t, current, gotCurrent)
+
+	// Custom resources with a pod template are left untouched
+	custom := []byte(`{"apiVersion":"example.com/v1","kind":"App","spec":{"replicas":4,"template":{}}}`)
+	gotCurrent, _, err = IgnoreReplicasWhenHPAManaged(func(current, modified *unstructured.Unstructured) bool {
+		return true
+	})(custom, custom)
+	assert.NoError(t, err)
+	assert.Equal(t, custom, gotCurrent)
+}
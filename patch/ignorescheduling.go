@@ -0,0 +1,95 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import "reflect"
+
+// schedulingPriorityFields are the pod spec fields set by the Priority admission plugin from the priority class.
+var schedulingPriorityFields = []string{"priorityClassName", "priority", "preemptionPolicy"}
+
+// IgnoreSchedulingMutations removes the scheduling settings added by admission controllers from the pod spec of the current
+// object (a Pod, a workload template, a Job or a CronJob job template) when the modified object doesn't have them: the
+// tolerations, e.g. the default not-ready and unreachable tolerations, the nodeSelector entries, and the priority class
+// with the priority and preemption policy resolved from it.
+func IgnoreSchedulingMutations() CalculateOption {
+	return MapOptions(IgnoreSchedulingMutationsMap())
+}
+
+// IgnoreSchedulingMutationsMap is the map option of IgnoreSchedulingMutations.
+func IgnoreSchedulingMutationsMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		for _, path := range podSpecPaths {
+			currentSpec, ok := fieldValue(current, path).(map[string]interface{})
+			if !ok || !isPodSpec(currentSpec) {
+				continue
+			}
+			modifiedSpec, _ := fieldValue(modified, path).(map[string]interface{})
+
+			removeAddedTolerations(currentSpec, modifiedSpec)
+			removeAddedNodeSelectors(currentSpec, modifiedSpec)
+			if _, ok := modifiedSpec["priorityClassName"]; !ok {
+				for _, field := range schedulingPriorityFields {
+					if _, ok := modifiedSpec[field]; !ok {
+						delete(currentSpec, field)
+					}
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// removeAddedTolerations removes the current tolerations missing from the modified pod spec.
+func removeAddedTolerations(currentSpec, modifiedSpec map[string]interface{}) {
+	tolerations, ok := currentSpec["tolerations"].([]interface{})
+	if !ok {
+		return
+	}
+	modifiedTolerations, _ := modifiedSpec["tolerations"].([]interface{})
+
+	kept := make([]interface{}, 0, len(tolerations))
+	for _, toleration := range tolerations {
+		for _, modifiedToleration := range modifiedTolerations {
+			if reflect.DeepEqual(toleration, modifiedToleration) {
+				kept = append(kept, toleration)
+				break
+			}
+		}
+	}
+
+	if len(kept) == 0 && modifiedSpec["tolerations"] == nil {
+		delete(currentSpec, "tolerations")
+	} else {
+		currentSpec["tolerations"] = kept
+	}
+}
+
+// removeAddedNodeSelectors removes the current nodeSelector entries whose key is missing from the modified pod spec.
+func removeAddedNodeSelectors(currentSpec, modifiedSpec map[string]interface{}) {
+	nodeSelector, ok := currentSpec["nodeSelector"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	modifiedNodeSelector, _ := modifiedSpec["nodeSelector"].(map[string]interface{})
+
+	for key := range nodeSelector {
+		if _, ok := modifiedNodeSelector[key]; !ok {
+			delete(nodeSelector, key)
+		}
+	}
+	if len(nodeSelector) == 0 && modifiedSpec["nodeSelector"] == nil {
+		delete(currentSpec, "nodeSelector")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestIgnoreSchedulingMutations(t *testing.T) {
+	tolerationSeconds := int64(300)
+	priority := int32(1000)
+	newDeployment := func() *appsv1.Deployment {
+		deployment := newAnnotatedDeployment(nil, nil)
+		deployment.Spec.Template.Spec.Tolerations = []v1.Toleration{
+			{Key: "dedicated", Operator: v1.TolerationOpEqual, Value: "app", Effect: v1.TaintEffectNoSchedule},
+		}
+		deployment.Spec.Template.Spec.NodeSelector = map[string]string{"kubernetes.io/os": "linux"}
+		return deployment
+	}
+
+	current := mustAnnotate(newDeployment()).(*appsv1.Deployment)
+	current.Spec.Template.Spec.Tolerations = append(current.Spec.Template.Spec.Tolerations, v1.Toleration{
+		Key: "node.kubernetes.io/not-ready", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoExecute, TolerationSeconds: &tolerationSeconds,
+	})
+	current.Spec.Template.Spec.NodeSelector["node-pool"] = "apps"
+	current.Spec.Template.Spec.PriorityClassName = "default-priority"
+	current.Spec.Template.Spec.Priority = &priority
+
+	result, err := DefaultPatchMaker.Calculate(current, newDeployment())
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.Calculate(current, newDeployment(), IgnoreSchedulingMutations())
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+
+	// The desired scheduling settings are still compared
+	modified := newDeployment()
+	modified.Spec.Template.Spec.NodeSelector["kubernetes.io/os"] = "windows"
+	modified.Spec.Template.Spec.PriorityClassName = "high-priority"
+	result, err = DefaultPatchMaker.Calculate(current, modified, IgnoreSchedulingMutations())
+	require.NoError(t, err)
+	require.False(t, result.IsEmpty())
+	patched := result.Patched.(*appsv1.Deployment)
+	assert.Equal(t, "windows", patched.Spec.Template.Spec.NodeSelector["kubernetes.io/os"])
+	assert.Equal(t, "high-priority", patched.Spec.Template.Spec.PriorityClassName)
+}
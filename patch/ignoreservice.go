@@ -0,0 +1,115 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var serviceGroupKind = schema.GroupKind{Kind: "Service"}
+
+// serviceServerSideFields are the Service spec fields assigned or defaulted by the API server.
+var serviceServerSideFields = []string{
+	"clusterIP",
+	"clusterIPs",
+	"ipFamilies",
+	"ipFamilyPolicy",
+	"internalTrafficPolicy",
+	"sessionAffinityConfig",
+}
+
+// IgnoreServiceServerSideFields removes the Service fields assigned or defaulted by the API server (clusterIP, clusterIPs,
+// ipFamilies, ipFamilyPolicy, internalTrafficPolicy, sessionAffinityConfig, the nodePort and the default TCP protocol of the ports)
+// from the current object when the modified object doesn't set them, so Services created without these fields don't appear drifted.
+// Objects of other kinds are left untouched.
+func IgnoreServiceServerSideFields() CalculateOption {
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		currentResource := map[string]interface{}{}
+		if err := json.Unmarshal(current, &currentResource); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for current")
+		}
+
+		modifiedResource := map[string]interface{}{}
+		if err := json.Unmarshal(modified, &modifiedResource); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for modified")
+		}
+
+		if !hasGroupKind(currentResource, serviceGroupKind) || !hasGroupKind(modifiedResource, serviceGroupKind) {
+			return current, modified, nil
+		}
+
+		deleteServiceServerSideFields(currentResource, modifiedResource)
+
+		current, err := json.ConfigCompatibleWithStandardLibrary.Marshal(currentResource)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not marshal byte sequence for current")
+		}
+
+		return current, modified, nil
+	}
+}
+
+func deleteServiceServerSideFields(current, modified map[string]interface{}) {
+	currentSpec, _ := current["spec"].(map[string]interface{})
+	modifiedSpec, _ := modified["spec"].(map[string]interface{})
+	if currentSpec == nil {
+		return
+	}
+
+	for _, field := range serviceServerSideFields {
+		if _, ok := modifiedSpec[field]; !ok {
+			delete(currentSpec, field)
+		}
+	}
+
+	currentPorts, _ := currentSpec["ports"].([]interface{})
+	modifiedPorts, _ := modifiedSpec["ports"].([]interface{})
+	for _, currentPort := range currentPorts {
+		currentPort, ok := currentPort.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		modifiedPort := findServicePort(modifiedPorts, currentPort)
+		if _, ok := modifiedPort["nodePort"]; !ok {
+			delete(currentPort, "nodePort")
+		}
+		if _, ok := modifiedPort["protocol"]; !ok && currentPort["protocol"] == "TCP" {
+			delete(currentPort, "protocol")
+		}
+	}
+}
+
+// findServicePort returns the port matching the port number and protocol of the given port.
+func findServicePort(ports []interface{}, port map[string]interface{}) map[string]interface{} {
+	for _, candidate := range ports {
+		candidate, ok := candidate.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if candidate["port"] == port["port"] && servicePortProtocol(candidate) == servicePortProtocol(port) {
+			return candidate
+		}
+	}
+	return nil
+}
+
+func servicePortProtocol(port map[string]interface{}) interface{} {
+	if protocol, ok := port["protocol"]; ok {
+		return protocol
+	}
+	return "TCP"
+}
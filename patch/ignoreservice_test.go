@@ -0,0 +1,75 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIgnoreServiceServerSideFields(t *testing.T) {
+	newService := func() *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "service",
+				Namespace: "default",
+			},
+			Spec: corev1.ServiceSpec{
+				Type:     corev1.ServiceTypeNodePort,
+				Selector: map[string]string{"app": "nginx"},
+				Ports: []corev1.ServicePort{
+					{Name: "http", Port: 80},
+				},
+			},
+		}
+	}
+
+	singleStack := corev1.IPFamilyPolicySingleStack
+	cluster := corev1.ServiceInternalTrafficPolicyCluster
+	current := newService()
+	current.Spec.ClusterIP = "10.0.0.1"
+	current.Spec.ClusterIPs = []string{"10.0.0.1"}
+	current.Spec.IPFamilies = []corev1.IPFamily{corev1.IPv4Protocol}
+	current.Spec.IPFamilyPolicy = &singleStack
+	current.Spec.InternalTrafficPolicy = &cluster
+	current.Spec.Ports[0].Protocol = corev1.ProtocolTCP
+	current.Spec.Ports[0].NodePort = 30080
+	// The original configuration was recorded from the object read back from the API server
+	mustAnnotate(current)
+
+	patch, err := DefaultPatchMaker.Calculate(current, newService())
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, newService(), IgnoreServiceServerSideFields())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// Fields set in the modified object are still compared
+	modified := newService()
+	modified.Spec.Ports[0].NodePort = 30081
+	patch, err = DefaultPatchMaker.Calculate(current, modified, IgnoreServiceServerSideFields())
+	assert.NoError(t, err)
+	assert.Contains(t, string(patch.Patch), "30081")
+
+	// Other kinds with a similar spec are left untouched
+	custom := []byte(`{"apiVersion":"example.com/v1","kind":"Gateway","spec":{"clusterIP":"10.0.0.1","ports":[{"port":80}]}}`)
+	currentCustom, _, err := IgnoreServiceServerSideFields()(custom, []byte(`{"apiVersion":"example.com/v1","kind":"Gateway","spec":{}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(custom), string(currentCustom))
+}
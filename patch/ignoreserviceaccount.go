@@ -0,0 +1,78 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var serviceAccountGroupKind = schema.GroupKind{Kind: "ServiceAccount"}
+
+// serviceAccountGeneratedSecretInfixes are the infixes of the names of the secrets generated for ServiceAccounts
+// by the token controller (token) and the OpenShift registry (dockercfg), e.g. default-token-x8kzp.
+var serviceAccountGeneratedSecretInfixes = []string{"-token-", "-dockercfg-"}
+
+// IgnoreServiceAccountTokenSecrets removes the secrets and imagePullSecrets entries of the current ServiceAccount generated
+// for it by the token controller (<name>-token-*, or <name>-dockercfg-* on OpenShift), unless the modified object references
+// them too. Other objects are left untouched.
+func IgnoreServiceAccountTokenSecrets() CalculateOption {
+	return MapOptions(IgnoreServiceAccountTokenSecretsMap())
+}
+
+// IgnoreServiceAccountTokenSecretsMap is the map option of IgnoreServiceAccountTokenSecrets.
+func IgnoreServiceAccountTokenSecretsMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		if !hasGroupKind(current, serviceAccountGroupKind) || !hasGroupKind(modified, serviceAccountGroupKind) {
+			return nil
+		}
+
+		name, _ := fieldValue(current, []string{"metadata", "name"}).(string)
+		for _, field := range []string{"secrets", "imagePullSecrets"} {
+			references, ok := current[field].([]interface{})
+			if !ok {
+				continue
+			}
+			modifiedReferences, _ := modified[field].([]interface{})
+
+			kept := make([]interface{}, 0, len(references))
+			for _, reference := range references {
+				typedReference, _ := reference.(map[string]interface{})
+				referenceName, _ := typedReference["name"].(string)
+				if isServiceAccountGeneratedSecret(name, referenceName) && findNamedItem(modifiedReferences, referenceName) == nil {
+					continue
+				}
+				kept = append(kept, reference)
+			}
+
+			if len(kept) == 0 && modified[field] == nil {
+				delete(current, field)
+			} else {
+				current[field] = kept
+			}
+		}
+		return nil
+	}
+}
+
+func isServiceAccountGeneratedSecret(serviceAccountName, secretName string) bool {
+	for _, infix := range serviceAccountGeneratedSecretInfixes {
+		if strings.HasPrefix(secretName, serviceAccountName+infix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIgnoreServiceAccountTokenSecrets(t *testing.T) {
+	newServiceAccount := func(pullSecrets ...corev1.LocalObjectReference) *corev1.ServiceAccount {
+		return &corev1.ServiceAccount{
+			ObjectMeta:       v1.ObjectMeta{Name: "app", Namespace: "default"},
+			ImagePullSecrets: pullSecrets,
+		}
+	}
+
+	registry := corev1.LocalObjectReference{Name: "registry"}
+	current := newServiceAccount(registry)
+	mustAnnotate(current)
+	current.Secrets = []corev1.ObjectReference{{Name: "app-token-x8kzp"}}
+	current.ImagePullSecrets = append(current.ImagePullSecrets, corev1.LocalObjectReference{Name: "app-dockercfg-7f9qz"})
+
+	// The generated entries are removed from the current object only
+	patch, err := DefaultPatchMaker.Calculate(current, newServiceAccount(registry), IgnoreServiceAccountTokenSecrets())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+	assert.JSONEq(t, `{"imagePullSecrets":[{"name":"registry"}],"metadata":{"name":"app","namespace":"default"}}`, stripAnnotations(t, patch.Current))
+
+	// Other secrets are still compared
+	patch, err = DefaultPatchMaker.Calculate(current, newServiceAccount(registry, corev1.LocalObjectReference{Name: "mirror"}), IgnoreServiceAccountTokenSecrets())
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	// Objects of other kinds referencing secrets are left untouched
+	credentials := []byte(`{"apiVersion":"example.com/v1","kind":"Credentials","metadata":{"name":"app"},"secrets":[{"name":"app-token-x8kzp"}]}`)
+	currentCredentials, _, err := IgnoreServiceAccountTokenSecrets()(credentials, []byte(`{"apiVersion":"example.com/v1","kind":"Credentials","metadata":{"name":"app"}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(credentials), string(currentCredentials))
+}
+
+func stripAnnotations(t *testing.T, document []byte) string {
+	stripped, _, err := IgnoreJSONPath(".metadata.annotations")(document, document)
+	assert.NoError(t, err)
+	return string(stripped)
+}
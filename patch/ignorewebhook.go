@@ -0,0 +1,30 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+// webhookCABundlePaths are the CA bundles of ValidatingWebhookConfigurations, MutatingWebhookConfigurations
+// and CustomResourceDefinition conversion webhooks (v1 and v1beta1).
+var webhookCABundlePaths = []string{
+	".webhooks[*].clientConfig.caBundle",
+	".spec.conversion.webhook.clientConfig.caBundle",
+	".spec.conversion.webhookClientConfig.caBundle",
+}
+
+// IgnoreWebhookCABundle removes the clientConfig.caBundle of admission webhooks and CRD conversion webhooks
+// from both objects before comparing them, as it is usually injected by a CA injector (e.g. cert-manager)
+// and rotating the certificate shouldn't produce a patch.
+func IgnoreWebhookCABundle() CalculateOption {
+	return IgnoreJSONPath(webhookCABundlePaths...)
+}
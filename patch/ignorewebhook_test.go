@@ -0,0 +1,83 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestIgnoreWebhookCABundle(t *testing.T) {
+	newWebhookConfiguration := func(caBundle string) *admissionregistrationv1.ValidatingWebhookConfiguration {
+		url := "https://webhook.example.com/validate"
+		return &admissionregistrationv1.ValidatingWebhookConfiguration{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "webhook",
+			},
+			Webhooks: []admissionregistrationv1.ValidatingWebhook{
+				{
+					Name: "validate.example.com",
+					ClientConfig: admissionregistrationv1.WebhookClientConfig{
+						URL:      &url,
+						CABundle: []byte(caBundle),
+					},
+				},
+			},
+		}
+	}
+
+	current := newWebhookConfiguration("")
+	mustAnnotate(current)
+	current.Webhooks[0].ClientConfig.CABundle = []byte("injected")
+
+	patch, err := DefaultPatchMaker.Calculate(current, newWebhookConfiguration("rotated"))
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, newWebhookConfiguration("rotated"), IgnoreWebhookCABundle())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	newCRD := func(caBundle string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata": map[string]interface{}{
+				"name": "foos.example.com",
+			},
+			"spec": map[string]interface{}{
+				"conversion": map[string]interface{}{
+					"strategy": "Webhook",
+					"webhook": map[string]interface{}{
+						"clientConfig": map[string]interface{}{
+							"caBundle": caBundle,
+						},
+					},
+				},
+			},
+		}}
+	}
+
+	currentCRD := newCRD("injected")
+	mustAnnotate(currentCRD)
+
+	patch, err = DefaultPatchMaker.Calculate(currentCRD, newCRD("rotated"), IgnoreWebhookCABundle())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+}
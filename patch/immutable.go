@@ -0,0 +1,133 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// immutableField is a field which can't be changed once the object is created. When changed is set,
+// only the changes it reports are considered immutable.
+type immutableField struct {
+	path    string
+	changed func(change FieldChange) bool
+}
+
+var (
+	immutableFieldsMu sync.RWMutex
+	immutableFields   = map[schema.GroupKind][]immutableField{
+		{Kind: "Service"}: {
+			{path: ".spec.clusterIP"},
+			{path: ".spec.clusterIPs[0]"},
+		},
+		{Kind: "PersistentVolumeClaim"}: {
+			{path: ".spec.accessModes"},
+			{path: ".spec.selector"},
+			{path: ".spec.storageClassName"},
+			{path: ".spec.volumeMode"},
+			{path: ".spec.volumeName"},
+			{path: ".spec.dataSource"},
+			{path: ".spec.dataSourceRef"},
+			{path: ".spec.resources.limits"},
+			// Volumes can be expanded but not shrunk
+			{path: ".spec.resources.requests.storage", changed: isQuantityDecrease},
+		},
+		{Group: "apps", Kind: "Deployment"}: {
+			{path: ".spec.selector"},
+		},
+		{Group: "apps", Kind: "ReplicaSet"}: {
+			{path: ".spec.selector"},
+		},
+		{Group: "apps", Kind: "DaemonSet"}: {
+			{path: ".spec.selector"},
+		},
+		{Group: "apps", Kind: "StatefulSet"}: {
+			{path: ".spec.selector"},
+			{path: ".spec.serviceName"},
+			{path: ".spec.podManagementPolicy"},
+			{path: ".spec.volumeClaimTemplates"},
+		},
+		{Group: "batch", Kind: "Job"}: {
+			{path: ".spec.selector"},
+			{path: ".spec.template"},
+		},
+		{Group: "storage.k8s.io", Kind: "StorageClass"}: {
+			{path: ".provisioner"},
+			{path: ".parameters"},
+			{path: ".reclaimPolicy"},
+			{path: ".volumeBindingMode"},
+		},
+	}
+)
+
+// RegisterImmutableFields declares fields of a kind which can't be changed once the object is created,
+// e.g. for custom resources validated with x-kubernetes-validations. Paths use the syntax accepted by IgnoreJSONPath.
+func RegisterImmutableFields(groupKind schema.GroupKind, paths ...string) {
+	immutableFieldsMu.Lock()
+	defer immutableFieldsMu.Unlock()
+
+	for _, path := range paths {
+		immutableFields[groupKind] = append(immutableFields[groupKind], immutableField{path: path})
+	}
+}
+
+// setImmutableChanges flags the result when the patch changes immutable fields of the kind.
+func setImmutableChanges(result *PatchResult, groupKind schema.GroupKind) error {
+	immutableFieldsMu.RLock()
+	fields := immutableFields[groupKind]
+	immutableFieldsMu.RUnlock()
+
+	if len(fields) == 0 || result.IsEmpty() {
+		return nil
+	}
+
+	changes, err := result.fieldChanges()
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		for _, field := range fields {
+			if change.IsUnder(field.path) && (field.changed == nil || field.changed(change)) {
+				result.ImmutableChanges = append(result.ImmutableChanges, change)
+				break
+			}
+		}
+	}
+	result.RequiresRecreate = len(result.ImmutableChanges) > 0
+
+	return nil
+}
+
+func isQuantityDecrease(change FieldChange) bool {
+	if change.Op != JSONPatchOpReplace {
+		return true
+	}
+
+	oldQuantity, err := resource.ParseQuantity(fmt.Sprint(change.Old))
+	if err != nil {
+		return true
+	}
+	newQuantity, err := resource.ParseQuantity(fmt.Sprint(change.New))
+	if err != nil {
+		return true
+	}
+
+	return newQuantity.Cmp(oldQuantity) < 0
+}
@@ -0,0 +1,115 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestImmutableFields(t *testing.T) {
+	newPVC := func(storageClass, size string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "data",
+				Namespace: "default",
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				StorageClassName: &storageClass,
+				AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceStorage: resource.MustParse(size),
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name             string
+		modified         *corev1.PersistentVolumeClaim
+		requiresRecreate bool
+		immutablePaths   []string
+	}{
+		{
+			name:     "no change",
+			modified: newPVC("standard", "10Gi"),
+		},
+		{
+			name:     "expand",
+			modified: newPVC("standard", "20Gi"),
+		},
+		{
+			name:             "shrink",
+			modified:         newPVC("standard", "5Gi"),
+			requiresRecreate: true,
+			immutablePaths:   []string{".spec.resources.requests.storage"},
+		},
+		{
+			name:             "storage class",
+			modified:         newPVC("fast", "10Gi"),
+			requiresRecreate: true,
+			immutablePaths:   []string{".spec.storageClassName"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current := newPVC("standard", "10Gi")
+			mustAnnotate(current)
+
+			result, err := DefaultPatchMaker.Calculate(current, tt.modified)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.requiresRecreate, result.RequiresRecreate)
+
+			var paths []string
+			for _, change := range result.ImmutableChanges {
+				paths = append(paths, change.Path)
+			}
+			assert.Equal(t, tt.immutablePaths, paths)
+		})
+	}
+}
+
+func TestRegisterImmutableFields(t *testing.T) {
+	RegisterImmutableFields(schema.GroupKind{Group: "example.com", Kind: "Immutable"}, ".spec.type")
+
+	newObject := func(objectType string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Immutable",
+			"metadata": map[string]interface{}{
+				"name": "object",
+			},
+			"spec": map[string]interface{}{
+				"type": objectType,
+			},
+		}}
+	}
+
+	current := newObject("a")
+	mustAnnotate(current)
+
+	result, err := DefaultPatchMaker.Calculate(current, newObject("b"))
+	assert.NoError(t, err)
+	assert.True(t, result.RequiresRecreate)
+}
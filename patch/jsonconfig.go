@@ -0,0 +1,68 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	stdjson "encoding/json"
+
+	json "github.com/json-iterator/go"
+	kjson "sigs.k8s.io/json"
+)
+
+// JSONConfig marshals the compared objects to JSON and decodes the patched objects.
+type JSONConfig interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSONIterConfig uses json-iterator, compatible with the standard library but marshaling the zero IntOrString values
+	// as null. It is the default.
+	JSONIterConfig JSONConfig = json.ConfigCompatibleWithStandardLibrary
+	// StandardJSONConfig uses encoding/json.
+	StandardJSONConfig JSONConfig = standardJSONConfig{}
+	// KubernetesJSONConfig uses sigs.k8s.io/json like the API machinery: field names are case-sensitive when decoding
+	// and integers are kept as int64 in unstructured content.
+	KubernetesJSONConfig JSONConfig = kubernetesJSONConfig{}
+)
+
+// WithJSONConfig makes the patch maker marshal and decode the objects with the given config instead of JSONIterConfig,
+// e.g. when json-iterator doesn't handle some API types like the API machinery does. The documents derived from the
+// marshaled objects are handled by json-iterator in any case.
+func WithJSONConfig(config JSONConfig) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.jsonConfig = config
+	}
+}
+
+type standardJSONConfig struct{}
+
+func (standardJSONConfig) Marshal(v interface{}) ([]byte, error) {
+	return stdjson.Marshal(v)
+}
+
+func (standardJSONConfig) Unmarshal(data []byte, v interface{}) error {
+	return stdjson.Unmarshal(data, v)
+}
+
+type kubernetesJSONConfig struct{}
+
+func (kubernetesJSONConfig) Marshal(v interface{}) ([]byte, error) {
+	return stdjson.Marshal(v)
+}
+
+func (kubernetesJSONConfig) Unmarshal(data []byte, v interface{}) error {
+	return kjson.UnmarshalCaseSensitivePreserveInts(data, v)
+}
@@ -0,0 +1,61 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWithJSONConfig(t *testing.T) {
+	for name, config := range map[string]JSONConfig{
+		"jsoniter":   JSONIterConfig,
+		"standard":   StandardJSONConfig,
+		"kubernetes": KubernetesJSONConfig,
+	} {
+		t.Run(name, func(t *testing.T) {
+			patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithJSONConfig(config))
+
+			current := newBenchmarkDeployment()
+			require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+			modified := newBenchmarkDeployment()
+			modified.Spec.Template.Spec.Containers[0].Image = "nginx:1.24"
+
+			result, err := patchMaker.Calculate(current, modified)
+			require.NoError(t, err)
+			assert.Contains(t, string(result.Patch), `"image":"nginx:1.24"`)
+			patched := result.Patched.(*appsv1.Deployment)
+			assert.Equal(t, "nginx:1.24", patched.Spec.Template.Spec.Containers[0].Image)
+
+			result, err = patchMaker.Calculate(patched, modified)
+			require.NoError(t, err)
+			assert.True(t, result.IsEmpty(), string(result.Patch))
+		})
+	}
+}
+
+func TestKubernetesJSONConfigIsCaseSensitive(t *testing.T) {
+	var meta v1.ObjectMeta
+	require.NoError(t, StandardJSONConfig.Unmarshal([]byte(`{"Name":"test"}`), &meta))
+	assert.Equal(t, "test", meta.Name)
+
+	meta = v1.ObjectMeta{}
+	require.NoError(t, KubernetesJSONConfig.Unmarshal([]byte(`{"Name":"test"}`), &meta))
+	assert.Empty(t, meta.Name)
+}
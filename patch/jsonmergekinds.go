@@ -0,0 +1,53 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// WithJSONMergeKinds compares the typed objects of the given kinds with JSON merge patch semantics, see RegisterJSONMergeKinds.
+func WithJSONMergeKinds(gvks ...schema.GroupVersionKind) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.RegisterJSONMergeKinds(gvks...)
+	}
+}
+
+// RegisterJSONMergeKinds makes the typed objects of the given kinds compared with JSON merge patch semantics like
+// unstructured objects, lists are replaced as a whole. This is the way the API server patches custom resources, whose Go types
+// can't be used for strategic merge patches even when they compile to typed objects. The kind of typed objects without TypeMeta
+// is resolved from the defaulting scheme or the client-go scheme, the kinds must be registered before the maker is used.
+func (p *PatchMaker) RegisterJSONMergeKinds(gvks ...schema.GroupVersionKind) {
+	if p.jsonMergeKinds == nil {
+		p.jsonMergeKinds = map[schema.GroupVersionKind]bool{}
+	}
+	for _, gvk := range gvks {
+		p.jsonMergeKinds[gvk] = true
+	}
+}
+
+// patchTypeFor returns the type of the patches calculated for the object, before any fallback.
+func (p *PatchMaker) patchTypeFor(gvk schema.GroupVersionKind, obj runtime.Object) types.PatchType {
+	if p.applyPatcher != nil {
+		return types.ApplyPatchType
+	}
+	if _, ok := obj.(*unstructured.Unstructured); ok || p.jsonMergeKinds[gvk] {
+		return types.MergePatchType
+	}
+	return types.StrategicMergePatchType
+}
@@ -0,0 +1,70 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRegisterJSONMergeKinds(t *testing.T) {
+	newPod := func(containers ...corev1.Container) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "pod",
+			},
+			Spec: corev1.PodSpec{
+				Containers: containers,
+			},
+		}
+	}
+	sidecar := corev1.Container{Name: "sidecar", Image: "proxy:1"}
+
+	current := newPod(corev1.Container{Name: "app", Image: "app:1"})
+	mustAnnotate(current)
+	current.Spec.Containers = append(current.Spec.Containers, sidecar)
+	modified := newPod(corev1.Container{Name: "app", Image: "app:2"})
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.Len(t, patch.Patched.(*corev1.Pod).Spec.Containers, 2)
+
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		WithJSONMergeKinds(corev1.SchemeGroupVersion.WithKind("Pod")),
+	)
+	patch, err = patchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.False(t, patch.JSONMergeFallback)
+	assert.Equal(t, []corev1.Container{{Name: "app", Image: "app:2"}}, patch.Patched.(*corev1.Pod).Spec.Containers)
+
+	// The kind is read from the TypeMeta of custom resources
+	app := &testApp{
+		TypeMeta:   v1.TypeMeta{APIVersion: "example.com/v1", Kind: "App"},
+		ObjectMeta: v1.ObjectMeta{Name: "app"},
+	}
+	mustAnnotate(app)
+	scaled := app.DeepCopyObject().(*testApp)
+	scaled.Spec.Replicas = 2
+
+	patchMaker.(*PatchMaker).RegisterJSONMergeKinds(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "App"})
+	patch, err = patchMaker.Calculate(app, scaled)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"replicas":2}}`, string(patch.Patch))
+}
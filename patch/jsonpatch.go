@@ -0,0 +1,234 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+const (
+	JSONPatchOpAdd     = "add"
+	JSONPatchOpRemove  = "remove"
+	JSONPatchOpReplace = "replace"
+	JSONPatchOpTest    = "test"
+)
+
+// JSONPatchOperation is a single RFC 6902 JSON Patch operation.
+type JSONPatchOperation struct {
+	Operation string      `json:"op"`
+	Path      string      `json:"path"`
+	Value     interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON marshals the value of the add, replace and test operations even when it's null, as RFC 6902 requires
+// it for these operations.
+func (o JSONPatchOperation) MarshalJSON() ([]byte, error) {
+	switch o.Operation {
+	case JSONPatchOpAdd, JSONPatchOpReplace, JSONPatchOpTest:
+		return json.ConfigCompatibleWithStandardLibrary.Marshal(struct {
+			Operation string      `json:"op"`
+			Path      string      `json:"path"`
+			Value     interface{} `json:"value"`
+		}{o.Operation, o.Path, o.Value})
+	}
+
+	type operation JSONPatchOperation
+	return json.ConfigCompatibleWithStandardLibrary.Marshal(operation(o))
+}
+
+// JSONPatch returns the difference between the current object and the patched object
+// as an RFC 6902 JSON Patch document. The values are masked for redacted results.
+func (p *PatchResult) JSONPatch() ([]byte, error) {
+	operations := []JSONPatchOperation{}
+	if !p.IsEmpty() {
+		if p.patchedCurrent == nil {
+			return nil, errors.New("patch result does not contain the patched object")
+		}
+
+		changes, err := diffJSON(p.currentOrg, p.patchedCurrent)
+		if err != nil {
+			return nil, err
+		}
+		if p.redacted {
+			changes = p.redactJSONChanges(changes)
+		}
+		operations = jsonPatchOperations(changes)
+	}
+
+	return json.ConfigCompatibleWithStandardLibrary.Marshal(operations)
+}
+
+// CreateJSONPatch creates an RFC 6902 JSON Patch document transforming original into modified.
+// Fields holding a null value are considered absent.
+func CreateJSONPatch(original, modified []byte) ([]byte, error) {
+	operations, err := createJSONPatchOperations(original, modified)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.ConfigCompatibleWithStandardLibrary.Marshal(operations)
+}
+
+func createJSONPatchOperations(original, modified []byte) ([]JSONPatchOperation, error) {
+	changes, err := diffJSON(original, modified)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonPatchOperations(changes), nil
+}
+
+// jsonPatchOperations returns the JSON Patch operations of the changes.
+func jsonPatchOperations(changes []jsonChange) []JSONPatchOperation {
+	operations := make([]JSONPatchOperation, 0, len(changes))
+	for _, change := range changes {
+		operation := JSONPatchOperation{
+			Operation: change.op,
+			Path:      change.pointer(),
+		}
+		if change.op != JSONPatchOpRemove {
+			operation.Value = change.new
+		}
+		operations = append(operations, operation)
+	}
+
+	return operations
+}
+
+// jsonChange is a single difference between two JSON documents.
+type jsonChange struct {
+	op string
+	// path holds the object keys (string) and list indexes (int) leading to the changed value.
+	path []interface{}
+	old  interface{}
+	new  interface{}
+}
+
+// pointer returns the path of the change as a JSON pointer.
+func (c jsonChange) pointer() string {
+	var b strings.Builder
+	for _, segment := range c.path {
+		b.WriteByte('/')
+		switch typedSegment := segment.(type) {
+		case int:
+			b.WriteString(strconv.Itoa(typedSegment))
+		case string:
+			b.WriteString(escapeJSONPointer(typedSegment))
+		}
+	}
+	return b.String()
+}
+
+// diffJSON returns the changes transforming original into modified, fields holding a null value are considered absent.
+func diffJSON(original, modified []byte) ([]jsonChange, error) {
+	var originalDoc, modifiedDoc interface{}
+	if err := json.Unmarshal(original, &originalDoc); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal original byte sequence")
+	}
+	if err := json.Unmarshal(modified, &modifiedDoc); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal modified byte sequence")
+	}
+
+	return diffJSONValues(nil, originalDoc, modifiedDoc, []jsonChange{}), nil
+}
+
+func appendPath(path []interface{}, segment interface{}) []interface{} {
+	newPath := make([]interface{}, len(path), len(path)+1)
+	copy(newPath, path)
+	return append(newPath, segment)
+}
+
+func diffJSONValues(path []interface{}, original, modified interface{}, changes []jsonChange) []jsonChange {
+	switch originalValue := original.(type) {
+	case map[string]interface{}:
+		if modifiedValue, ok := modified.(map[string]interface{}); ok {
+			return diffJSONObjects(path, originalValue, modifiedValue, changes)
+		}
+	case []interface{}:
+		if modifiedValue, ok := modified.([]interface{}); ok {
+			return diffJSONArrays(path, originalValue, modifiedValue, changes)
+		}
+	}
+
+	if !reflect.DeepEqual(original, modified) {
+		changes = append(changes, jsonChange{op: JSONPatchOpReplace, path: path, old: original, new: modified})
+	}
+
+	return changes
+}
+
+func diffJSONObjects(path []interface{}, original, modified map[string]interface{}, changes []jsonChange) []jsonChange {
+	keys := make([]string, 0, len(original)+len(modified))
+	for key := range original {
+		keys = append(keys, key)
+	}
+	for key := range modified {
+		if _, ok := original[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := appendPath(path, key)
+		originalValue := original[key]
+		modifiedValue := modified[key]
+
+		switch {
+		case originalValue == nil && modifiedValue == nil:
+			continue
+		case originalValue == nil:
+			changes = append(changes, jsonChange{op: JSONPatchOpAdd, path: keyPath, new: modifiedValue})
+		case modifiedValue == nil:
+			changes = append(changes, jsonChange{op: JSONPatchOpRemove, path: keyPath, old: originalValue})
+		default:
+			changes = diffJSONValues(keyPath, originalValue, modifiedValue, changes)
+		}
+	}
+
+	return changes
+}
+
+func diffJSONArrays(path []interface{}, original, modified []interface{}, changes []jsonChange) []jsonChange {
+	common := len(original)
+	if len(modified) < common {
+		common = len(modified)
+	}
+
+	for i := 0; i < common; i++ {
+		changes = diffJSONValues(appendPath(path, i), original[i], modified[i], changes)
+	}
+
+	// Remove from the end so the indexes of the remaining elements don't shift.
+	for i := len(original) - 1; i >= common; i-- {
+		changes = append(changes, jsonChange{op: JSONPatchOpRemove, path: appendPath(path, i), old: original[i]})
+	}
+
+	for i := common; i < len(modified); i++ {
+		changes = append(changes, jsonChange{op: JSONPatchOpAdd, path: appendPath(path, i), new: modified[i]})
+	}
+
+	return changes
+}
+
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
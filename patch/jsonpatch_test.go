@@ -0,0 +1,123 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCreateJSONPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		modified string
+		want     string
+	}{
+		{
+			name:     "no changes",
+			original: `{"a":"b","c":[1,2]}`,
+			modified: `{"a":"b","c":[1,2]}`,
+			want:     `[]`,
+		},
+		{
+			name:     "add, replace and remove fields",
+			original: `{"a":"b","c":"d"}`,
+			modified: `{"a":"changed","e":{"f":true}}`,
+			want:     `[{"op":"replace","path":"/a","value":"changed"},{"op":"remove","path":"/c"},{"op":"add","path":"/e","value":{"f":true}}]`,
+		},
+		{
+			name:     "null is absent",
+			original: `{"a":null,"b":"c"}`,
+			modified: `{"b":"c","d":null}`,
+			want:     `[]`,
+		},
+		{
+			name:     "shrink and grow lists",
+			original: `{"a":[1,2,3],"b":[{"c":"d"}]}`,
+			modified: `{"a":[1],"b":[{"c":"e"},{"f":"g"}]}`,
+			want:     `[{"op":"remove","path":"/a/2"},{"op":"remove","path":"/a/1"},{"op":"replace","path":"/b/0/c","value":"e"},{"op":"add","path":"/b/1","value":{"f":"g"}}]`,
+		},
+		{
+			name:     "null list items",
+			original: `{"a":[1,2]}`,
+			modified: `{"a":[1,null]}`,
+			want:     `[{"op":"replace","path":"/a/1","value":null}]`,
+		},
+		{
+			name:     "escape keys",
+			original: `{"metadata":{"labels":{}}}`,
+			modified: `{"metadata":{"labels":{"app.kubernetes.io/name":"a~b"}}}`,
+			want:     `[{"op":"add","path":"/metadata/labels/app.kubernetes.io~1name","value":"a~b"}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CreateJSONPatch([]byte(tt.original), []byte(tt.modified))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
+
+func TestPatchResultJSONPatch(t *testing.T) {
+	current := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "my-service",
+			Namespace: "default",
+			Labels: map[string]string{
+				"foo": "bar",
+			},
+		},
+	}
+	mustAnnotate(current)
+	current.Spec.ClusterIP = "10.0.0.1"
+
+	modified := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "my-service",
+			Namespace: "default",
+			Labels: map[string]string{
+				"foo": "baz",
+			},
+		},
+	}
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	jsonPatch, err := result.JSONPatch()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"op":"replace","path":"/metadata/labels/foo","value":"baz"}]`, string(jsonPatch))
+
+	decoded, err := jsonpatch.DecodePatch(jsonPatch)
+	assert.NoError(t, err)
+	applied, err := decoded.Apply(result.currentOrg)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(result.patchedCurrent), string(applied))
+
+	// An empty patch is an empty operation list
+	result, err = DefaultPatchMaker.Calculate(mustAnnotate(modified), modified)
+	assert.NoError(t, err)
+	jsonPatch, err = result.JSONPatch()
+	assert.NoError(t, err)
+	assert.Equal(t, `[]`, string(jsonPatch))
+}
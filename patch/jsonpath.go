@@ -0,0 +1,266 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+type pathSegmentKind int
+
+const (
+	fieldSegment pathSegmentKind = iota
+	indexSegment
+	wildcardSegment
+)
+
+type pathSegment struct {
+	kind  pathSegmentKind
+	name  string
+	index int
+}
+
+// IgnoreJSONPath removes the fields matching the given paths from both objects before comparing them.
+// Paths use a simple JSONPath like syntax, e.g. `.status`, `.spec.template.spec.containers[*].image`,
+// `.spec.ports[0].nodePort` or `.metadata.annotations['example.com/key']`.
+func IgnoreJSONPath(paths ...string) CalculateOption {
+	parsedPaths := make([][]pathSegment, 0, len(paths))
+	var parseErr error
+	for _, path := range paths {
+		segments, err := parseJSONPath(path)
+		if err != nil {
+			parseErr = errors.Append(parseErr, err)
+			continue
+		}
+		parsedPaths = append(parsedPaths, segments)
+	}
+
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		if parseErr != nil {
+			return []byte{}, []byte{}, parseErr
+		}
+
+		current, err := deleteJSONPaths(current, parsedPaths)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not delete paths from current byte sequence")
+		}
+
+		modified, err = deleteJSONPaths(modified, parsedPaths)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not delete paths from modified byte sequence")
+		}
+
+		return current, modified, nil
+	}
+}
+
+func deleteJSONPaths(obj []byte, paths [][]pathSegment) ([]byte, error) {
+	var resource interface{}
+	if err := json.Unmarshal(obj, &resource); err != nil {
+		return []byte{}, errors.Wrap(err, "could not unmarshal byte sequence")
+	}
+
+	for _, path := range paths {
+		resource = deleteAtPath(resource, path)
+	}
+
+	obj, err := json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+	if err != nil {
+		return []byte{}, errors.Wrap(err, "could not marshal byte sequence")
+	}
+
+	return obj, nil
+}
+
+// deleteAtPath removes the values matching path from node and returns the updated node.
+func deleteAtPath(node interface{}, path []pathSegment) interface{} {
+	if len(path) == 0 {
+		return node
+	}
+	segment, rest := path[0], path[1:]
+
+	switch typedNode := node.(type) {
+	case map[string]interface{}:
+		switch segment.kind {
+		case fieldSegment:
+			if len(rest) == 0 {
+				delete(typedNode, segment.name)
+			} else if child, ok := typedNode[segment.name]; ok {
+				typedNode[segment.name] = deleteAtPath(child, rest)
+			}
+		case wildcardSegment:
+			for key, child := range typedNode {
+				if len(rest) == 0 {
+					delete(typedNode, key)
+				} else {
+					typedNode[key] = deleteAtPath(child, rest)
+				}
+			}
+		}
+	case []interface{}:
+		switch segment.kind {
+		case indexSegment:
+			if segment.index < 0 || segment.index >= len(typedNode) {
+				return node
+			}
+			if len(rest) == 0 {
+				return append(typedNode[:segment.index:segment.index], typedNode[segment.index+1:]...)
+			}
+			typedNode[segment.index] = deleteAtPath(typedNode[segment.index], rest)
+		case wildcardSegment:
+			if len(rest) == 0 {
+				return []interface{}{}
+			}
+			for i, child := range typedNode {
+				typedNode[i] = deleteAtPath(child, rest)
+			}
+		}
+	}
+
+	return node
+}
+
+// parseJSONPath parses paths like `.spec.containers[*].image` or `.metadata.labels['app.kubernetes.io/name']`, quotes and
+// backslashes being escaped with a backslash in quoted field names.
+func parseJSONPath(path string) ([]pathSegment, error) {
+	original := path
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	if path == "" || path == "." {
+		return nil, errors.Errorf("invalid path %q: empty path", original)
+	}
+
+	var segments []pathSegment
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end == -1 {
+				end = len(path)
+			}
+			name := path[:end]
+			if name == "" {
+				return nil, errors.Errorf("invalid path %q: empty field name", original)
+			}
+			if name == "*" {
+				segments = append(segments, pathSegment{kind: wildcardSegment})
+			} else {
+				segments = append(segments, pathSegment{kind: fieldSegment, name: name})
+			}
+			path = path[end:]
+		case '[':
+			if len(path) > 1 && (path[1] == '\'' || path[1] == '"') {
+				name, rest, ok := parseQuotedName(path[2:], path[1])
+				if !ok || !strings.HasPrefix(rest, "]") {
+					return nil, errors.Errorf("invalid path %q: unterminated quoted field name", original)
+				}
+				segments = append(segments, pathSegment{kind: fieldSegment, name: name})
+				path = rest[1:]
+				continue
+			}
+
+			end := strings.IndexByte(path, ']')
+			if end == -1 {
+				return nil, errors.Errorf("invalid path %q: missing ]", original)
+			}
+			selector := path[1:end]
+			if selector == "*" {
+				segments = append(segments, pathSegment{kind: wildcardSegment})
+			} else {
+				index, err := strconv.Atoi(selector)
+				if err != nil {
+					return nil, errors.Errorf("invalid path %q: invalid index %q", original, selector)
+				}
+				segments = append(segments, pathSegment{kind: indexSegment, index: index})
+			}
+			path = path[end+1:]
+		default:
+			// Allow the leading dot to be omitted
+			if len(segments) != 0 {
+				return nil, errors.Errorf("invalid path %q: unexpected character %q", original, path[0])
+			}
+			path = "." + path
+		}
+	}
+
+	return segments, nil
+}
+
+// parseQuotedName parses a field name ending with the quote, a backslash escapes the following character. It returns
+// the name and the rest of the path after the quote.
+func parseQuotedName(path string, quote byte) (string, string, bool) {
+	var name strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case quote:
+			return name.String(), path[i+1:], true
+		case '\\':
+			i++
+			if i == len(path) {
+				return "", "", false
+			}
+		}
+		name.WriteByte(path[i])
+	}
+	return "", "", false
+}
+
+// quoteName quotes the field name for parseJSONPath, escaping the quotes and backslashes.
+func quoteName(name string) string {
+	return "['" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(name) + "']"
+}
+
+// pathSegments returns the segments of a path made of object keys (string) and list indexes (int).
+func pathSegments(path []interface{}) []pathSegment {
+	segments := make([]pathSegment, 0, len(path))
+	for _, segment := range path {
+		switch typedSegment := segment.(type) {
+		case int:
+			segments = append(segments, pathSegment{kind: indexSegment, index: typedSegment})
+		case string:
+			segments = append(segments, pathSegment{kind: fieldSegment, name: typedSegment})
+		}
+	}
+	return segments
+}
+
+// matchesJSONPath tells whether the segments of a concrete path match the parsed path.
+func matchesJSONPath(path, parsedPath []pathSegment) bool {
+	return len(path) == len(parsedPath) && matchesPathPrefix(path, parsedPath)
+}
+
+// matchesPathPrefix tells whether the segments the concrete path and the parsed path have in common match.
+func matchesPathPrefix(path, parsedPath []pathSegment) bool {
+	for i := 0; i < len(path) && i < len(parsedPath); i++ {
+		switch parsedPath[i].kind {
+		case wildcardSegment:
+			continue
+		case fieldSegment:
+			if path[i].kind != fieldSegment || path[i].name != parsedPath[i].name {
+				return false
+			}
+		case indexSegment:
+			if path[i].kind != indexSegment || path[i].index != parsedPath[i].index {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,125 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIgnoreJSONPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		obj   string
+		want  string
+	}{
+		{
+			name:  "top level field",
+			paths: []string{".status"},
+			obj:   `{"spec":{"a":"b"},"status":{"c":"d"}}`,
+			want:  `{"spec":{"a":"b"}}`,
+		},
+		{
+			name:  "leading dot and dollar sign are optional",
+			paths: []string{"spec.a", "$.spec.b"},
+			obj:   `{"spec":{"a":"b","b":"c","c":"d"}}`,
+			want:  `{"spec":{"c":"d"}}`,
+		},
+		{
+			name:  "wildcard in list",
+			paths: []string{".spec.containers[*].image"},
+			obj:   `{"spec":{"containers":[{"name":"a","image":"a"},{"name":"b","image":"b"}]}}`,
+			want:  `{"spec":{"containers":[{"name":"a"},{"name":"b"}]}}`,
+		},
+		{
+			name:  "list index",
+			paths: []string{".spec.ports[1]", ".spec.ports[0].nodePort", ".spec.ports[5]"},
+			obj:   `{"spec":{"ports":[{"port":80,"nodePort":30000},{"port":443}]}}`,
+			want:  `{"spec":{"ports":[{"port":80}]}}`,
+		},
+		{
+			name:  "quoted field name",
+			paths: []string{`.metadata.annotations['example.com/key']`, `.metadata.labels["app.kubernetes.io/name"]`},
+			obj:   `{"metadata":{"annotations":{"example.com/key":"a","other":"b"},"labels":{"app.kubernetes.io/name":"c"}}}`,
+			want:  `{"metadata":{"annotations":{"other":"b"},"labels":{}}}`,
+		},
+		{
+			name:  "escaped quotes",
+			paths: []string{`.metadata.annotations['it\'s']`, `.metadata.labels["a\"b\\"]`},
+			obj:   `{"metadata":{"annotations":{"it's":"a","other":"b"},"labels":{"a\"b\\":"c"}}}`,
+			want:  `{"metadata":{"annotations":{"other":"b"},"labels":{}}}`,
+		},
+		{
+			name:  "map wildcard",
+			paths: []string{".metadata.labels.*"},
+			obj:   `{"metadata":{"labels":{"a":"b","c":"d"}}}`,
+			want:  `{"metadata":{"labels":{}}}`,
+		},
+		{
+			name:  "missing path",
+			paths: []string{".spec.template.spec"},
+			obj:   `{"spec":{"replicas":1}}`,
+			want:  `{"spec":{"replicas":1}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, modified, err := IgnoreJSONPath(tt.paths...)([]byte(tt.obj), []byte(tt.obj))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(current))
+			assert.JSONEq(t, tt.want, string(modified))
+		})
+	}
+}
+
+func TestIgnoreJSONPathInvalid(t *testing.T) {
+	for _, path := range []string{"", ".spec..a", ".spec.ports[a]", ".spec.ports[0", ".metadata.labels['a", `.metadata.labels['a\']`} {
+		_, _, err := IgnoreJSONPath(path)([]byte(`{}`), []byte(`{}`))
+		assert.Error(t, err, path)
+	}
+}
+
+func TestIgnoreJSONPathCalculate(t *testing.T) {
+	current := &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "deployment",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{Name: "app", Image: "app:1.0.0"},
+					},
+				},
+			},
+		},
+	}
+	modified := current.DeepCopy()
+	modified.Spec.Template.Spec.Containers[0].Image = "app:2.0.0"
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, modified, IgnoreJSONPath(".spec.template.spec.containers[*].image"))
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+}
@@ -0,0 +1,199 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"bytes"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+// keptNull replaces the nulls kept by KeepNullFields while the nulls of the documents are removed.
+const keptNull = "\x00objectmatcher:null"
+
+// keptNullJSON is keptNull as encoded in JSON documents.
+var keptNullJSON = []byte(`"\u0000objectmatcher:null"`)
+
+// WithNullFieldsKept applies KeepNullFields in every comparison.
+func WithNullFieldsKept() PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.keepNullFields = true
+	}
+}
+
+// KeepNullFields keeps the explicit nulls of the modified object, which are otherwise removed before comparing the objects,
+// so they remove the fields from the current object. It must come after the options adding or removing fields. Typed objects
+// marshal some unset fields as null, like metadata.creationTimestamp, which would then be removed too: PreserveNullAt is
+// usually a better fit for them.
+func KeepNullFields() CalculateOption {
+	return MapOptions(KeepNullFieldsMap())
+}
+
+// KeepNullFieldsMap is the map option of KeepNullFields.
+func KeepNullFieldsMap() CalculateMapOption {
+	return func(_, modified map[string]interface{}) error {
+		markNulls(modified)
+		return nil
+	}
+}
+
+// PreserveNullAt keeps the explicit nulls of the modified object at the given paths, like KeepNullFields, while the other
+// nulls are still removed. Paths use the syntax of IgnoreJSONPath, e.g. `.spec.suspend` or
+// `.metadata.annotations['example.com/key']`.
+func PreserveNullAt(paths ...string) CalculateOption {
+	return MapOptions(PreserveNullAtMap(paths...))
+}
+
+// PreserveNullAtMap is the map option of PreserveNullAt.
+func PreserveNullAtMap(paths ...string) CalculateMapOption {
+	parsedPaths := make([][]pathSegment, 0, len(paths))
+	var parseErr error
+	for _, path := range paths {
+		segments, err := parseJSONPath(path)
+		if err != nil {
+			parseErr = errors.Append(parseErr, err)
+			continue
+		}
+		parsedPaths = append(parsedPaths, segments)
+	}
+
+	return func(_, modified map[string]interface{}) error {
+		if parseErr != nil {
+			return parseErr
+		}
+		for _, path := range parsedPaths {
+			markNullsAtPath(modified, path)
+		}
+		return nil
+	}
+}
+
+// markNullsAtPath replaces the nulls matching path in node by keptNull.
+func markNullsAtPath(node interface{}, path []pathSegment) {
+	if len(path) == 0 {
+		return
+	}
+	segment, rest := path[0], path[1:]
+
+	switch typedNode := node.(type) {
+	case map[string]interface{}:
+		switch segment.kind {
+		case fieldSegment:
+			if child, ok := typedNode[segment.name]; ok {
+				if len(rest) == 0 {
+					if child == nil {
+						typedNode[segment.name] = keptNull
+					}
+				} else {
+					markNullsAtPath(child, rest)
+				}
+			}
+		case wildcardSegment:
+			for key, child := range typedNode {
+				if len(rest) == 0 {
+					if child == nil {
+						typedNode[key] = keptNull
+					}
+				} else {
+					markNullsAtPath(child, rest)
+				}
+			}
+		}
+	case []interface{}:
+		switch segment.kind {
+		case indexSegment:
+			if segment.index < 0 || segment.index >= len(typedNode) {
+				return
+			}
+			if len(rest) == 0 {
+				if typedNode[segment.index] == nil {
+					typedNode[segment.index] = keptNull
+				}
+			} else {
+				markNullsAtPath(typedNode[segment.index], rest)
+			}
+		case wildcardSegment:
+			for i, child := range typedNode {
+				if len(rest) == 0 {
+					if child == nil {
+						typedNode[i] = keptNull
+					}
+				} else {
+					markNullsAtPath(child, rest)
+				}
+			}
+		}
+	}
+}
+
+// markNulls replaces the nulls of the node by keptNull.
+func markNulls(node interface{}) {
+	switch typedNode := node.(type) {
+	case map[string]interface{}:
+		for key, value := range typedNode {
+			if value == nil {
+				typedNode[key] = keptNull
+				continue
+			}
+			markNulls(value)
+		}
+	case []interface{}:
+		for i, item := range typedNode {
+			if item == nil {
+				typedNode[i] = keptNull
+				continue
+			}
+			markNulls(item)
+		}
+	}
+}
+
+// restoreKeptNulls turns the kept nulls of the document back into nulls.
+func restoreKeptNulls(document []byte) ([]byte, error) {
+	if !bytes.Contains(document, keptNullJSON) {
+		return document, nil
+	}
+
+	resource := map[string]interface{}{}
+	if err := json.Unmarshal(document, &resource); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal byte sequence")
+	}
+	restoreNulls(resource)
+
+	return json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+}
+
+// restoreNulls replaces the keptNull values of the node by nulls.
+func restoreNulls(node interface{}) {
+	switch typedNode := node.(type) {
+	case map[string]interface{}:
+		for key, value := range typedNode {
+			if value == keptNull {
+				typedNode[key] = nil
+				continue
+			}
+			restoreNulls(value)
+		}
+	case []interface{}:
+		for i, item := range typedNode {
+			if item == keptNull {
+				typedNode[i] = nil
+				continue
+			}
+			restoreNulls(item)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newNullableApp(spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "App",
+		"metadata": map[string]interface{}{
+			"name":      "test",
+			"namespace": "default",
+		},
+		"spec": spec,
+	}}
+}
+
+func TestKeepNullFields(t *testing.T) {
+	current := newNullableApp(map[string]interface{}{"replicas": int64(1)})
+	mustAnnotate(current)
+	// Set by another controller
+	current.Object["spec"].(map[string]interface{})["suspend"] = true
+
+	modified := newNullableApp(map[string]interface{}{"replicas": int64(1), "suspend": nil})
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.Calculate(current, modified, KeepNullFields())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"suspend":null}}`, string(result.Patch))
+	_, found, err := unstructured.NestedFieldNoCopy(result.Patched.(*unstructured.Unstructured).Object, "spec", "suspend")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithNullFieldsKept())
+	result, err = patchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"suspend":null}}`, string(result.Patch))
+
+	// Nulls of fields absent from the current object don't produce a patch
+	modified = newNullableApp(map[string]interface{}{"replicas": int64(1), "paused": nil})
+	result, err = DefaultPatchMaker.Calculate(current, modified, KeepNullFields())
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+}
+
+func TestPreserveNullAt(t *testing.T) {
+	current := newNullableApp(map[string]interface{}{"replicas": int64(1)})
+	current.SetAnnotations(map[string]string{"example.com/key": "value"})
+	mustAnnotate(current)
+	// Set by another controller
+	current.Object["spec"].(map[string]interface{})["suspend"] = true
+	current.Object["spec"].(map[string]interface{})["paused"] = true
+
+	modified := newNullableApp(map[string]interface{}{"replicas": int64(1), "suspend": nil, "paused": nil})
+	modified.Object["metadata"].(map[string]interface{})["annotations"] = map[string]interface{}{"example.com/key": nil}
+
+	result, err := DefaultPatchMaker.Calculate(current, modified, PreserveNullAt(".spec.suspend", ".metadata.annotations['example.com/key']"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"annotations":{"example.com/key":null}},"spec":{"suspend":null}}`, string(result.Patch))
+
+	_, err = DefaultPatchMaker.Calculate(current, modified, PreserveNullAt(".spec["))
+	require.Error(t, err)
+}
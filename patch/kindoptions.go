@@ -0,0 +1,49 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import "k8s.io/apimachinery/pkg/runtime/schema"
+
+// WithKindOptions applies the options in every comparison of objects of the given kind, see RegisterOptions.
+func WithKindOptions(gvk schema.GroupVersionKind, opts ...CalculateOption) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.RegisterOptions(gvk, opts...)
+	}
+}
+
+// RegisterOptions registers options applied in every comparison of objects of the given kind, before the options
+// given to Calculate, so call sites don't need to know which normalizations each kind requires. The kind of typed
+// objects without TypeMeta is resolved from the defaulting scheme or the client-go scheme. The options must be
+// registered before the maker is used.
+func (p *PatchMaker) RegisterOptions(gvk schema.GroupVersionKind, opts ...CalculateOption) {
+	if p.kindOptions == nil {
+		p.kindOptions = map[schema.GroupVersionKind][]CalculateOption{}
+	}
+	p.kindOptions[gvk] = append(p.kindOptions[gvk], opts...)
+}
+
+// withKindOptions prepends the options registered for the kind to the options.
+func (p *PatchMaker) withKindOptions(gvk schema.GroupVersionKind, opts []CalculateOptionCtx) []CalculateOptionCtx {
+	kindOpts := p.kindOptions[gvk]
+	if len(kindOpts) == 0 {
+		return opts
+	}
+
+	withKindOpts := make([]CalculateOptionCtx, 0, len(kindOpts)+len(opts))
+	for _, opt := range kindOpts {
+		withKindOpts = append(withKindOpts, WithoutContext(opt))
+	}
+	return append(withKindOpts, opts...)
+}
@@ -0,0 +1,57 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestRegisterOptions(t *testing.T) {
+	newConfigMap := func(value string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Data:       map[string]string{"key": value},
+		}
+	}
+	newSecret := func(value string) *v1.Secret {
+		return &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			StringData: map[string]string{"key": value},
+		}
+	}
+
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		WithKindOptions(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, IgnoreField("data")),
+	)
+
+	result, err := patchMaker.Calculate(mustAnnotate(newConfigMap("a")), newConfigMap("b"))
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+
+	result, err = patchMaker.Calculate(mustAnnotate(newSecret("a")), newSecret("b"))
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	patchMaker.(*PatchMaker).RegisterOptions(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, IgnoreField("stringData"))
+	result, err = patchMaker.Calculate(mustAnnotate(newSecret("a")), newSecret("b"))
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+}
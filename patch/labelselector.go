@@ -0,0 +1,229 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// IgnoreLabelSelectorOrdering makes the label selectors of the modified object equivalent to the ones of the current
+// object take their form, wherever they are in the objects: the selectors of workloads and PodDisruptionBudgets, the pod
+// and namespace selectors of NetworkPolicies, the selectors of custom resources like PodMonitors... The selectors are
+// equivalent when they have the same requirements, whatever the order of the matchExpressions and of their values, and
+// whether a requirement is written as a matchLabels entry or an In expression with a single value.
+func IgnoreLabelSelectorOrdering() CalculateOption {
+	return MapOptions(IgnoreLabelSelectorOrderingMap())
+}
+
+// IgnoreLabelSelectorOrderingMap is the map option of IgnoreLabelSelectorOrdering.
+func IgnoreLabelSelectorOrderingMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		alignLabelSelectors(current, modified)
+		return nil
+	}
+}
+
+// NormalizeLabelSelectors rewrites the label selectors of both objects in a canonical form before comparing them, so
+// equivalent selectors compare equal even without a current object, e.g. for fingerprints: the In expressions with a
+// single value are turned into matchLabels entries, the values of the other expressions are sorted and deduplicated,
+// the expressions are sorted by key and operator, and empty matchLabels and matchExpressions are removed. The patches
+// use the canonical form, see IgnoreLabelSelectorOrdering to keep the form of the current object instead.
+func NormalizeLabelSelectors() CalculateOption {
+	return MapOptions(NormalizeLabelSelectorsMap())
+}
+
+// NormalizeLabelSelectorsMap is the map option of NormalizeLabelSelectors.
+func NormalizeLabelSelectorsMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		canonicalizeLabelSelectors(current)
+		canonicalizeLabelSelectors(modified)
+		return nil
+	}
+}
+
+// canonicalizeLabelSelectors rewrites the label selectors found in the node in their canonical form.
+func canonicalizeLabelSelectors(node interface{}) {
+	switch typedNode := node.(type) {
+	case map[string]interface{}:
+		if isLabelSelector(typedNode) {
+			canonicalizeLabelSelector(typedNode)
+			return
+		}
+		for _, value := range typedNode {
+			canonicalizeLabelSelectors(value)
+		}
+	case []interface{}:
+		for _, item := range typedNode {
+			canonicalizeLabelSelectors(item)
+		}
+	}
+}
+
+func canonicalizeLabelSelector(selector map[string]interface{}) {
+	matchLabels, _ := selector["matchLabels"].(map[string]interface{})
+	if matchLabels == nil {
+		matchLabels = map[string]interface{}{}
+	}
+	matchExpressions, _ := selector["matchExpressions"].([]interface{})
+
+	seen := map[string]bool{}
+	expressions := make([]interface{}, 0, len(matchExpressions))
+	for _, expression := range matchExpressions {
+		typedExpression, ok := expression.(map[string]interface{})
+		if !ok {
+			expressions = append(expressions, expression)
+			continue
+		}
+		values, _ := typedExpression["values"].([]interface{})
+		uniqueValues := map[string]bool{}
+		for _, value := range values {
+			uniqueValues[fmt.Sprint(value)] = true
+		}
+		sortedValues := sortedKeys(uniqueValues)
+
+		key, _ := typedExpression["key"].(string)
+		if typedExpression["operator"] == "In" && len(sortedValues) == 1 {
+			if value, ok := matchLabels[key]; !ok || value == sortedValues[0] {
+				matchLabels[key] = sortedValues[0]
+				continue
+			}
+		}
+
+		if values != nil {
+			canonicalValues := make([]interface{}, 0, len(sortedValues))
+			for _, value := range sortedValues {
+				canonicalValues = append(canonicalValues, value)
+			}
+			typedExpression["values"] = canonicalValues
+		}
+		requirement := fmt.Sprintf("%v %v %v", typedExpression["key"], typedExpression["operator"], sortedValues)
+		if !seen[requirement] {
+			seen[requirement] = true
+			expressions = append(expressions, typedExpression)
+		}
+	}
+	sort.SliceStable(expressions, func(i, j int) bool {
+		return expressionSortKey(expressions[i]) < expressionSortKey(expressions[j])
+	})
+
+	delete(selector, "matchLabels")
+	delete(selector, "matchExpressions")
+	if len(matchLabels) > 0 {
+		selector["matchLabels"] = matchLabels
+	}
+	if len(expressions) > 0 {
+		selector["matchExpressions"] = expressions
+	}
+}
+
+func expressionSortKey(expression interface{}) string {
+	typedExpression, _ := expression.(map[string]interface{})
+	return fmt.Sprintf("%v\x00%v", typedExpression["key"], typedExpression["operator"])
+}
+
+// alignLabelSelectors walks the current and modified nodes in parallel and replaces the modified label selectors
+// equivalent to the current ones by a copy of them. List items are matched by name when they have one, by index otherwise.
+func alignLabelSelectors(current, modified interface{}) {
+	switch typedModified := modified.(type) {
+	case map[string]interface{}:
+		typedCurrent, ok := current.(map[string]interface{})
+		if !ok {
+			return
+		}
+		if isLabelSelector(typedCurrent) && isLabelSelector(typedModified) {
+			if !reflect.DeepEqual(typedCurrent, typedModified) && labelSelectorRequirements(typedCurrent) == labelSelectorRequirements(typedModified) {
+				for key := range typedModified {
+					delete(typedModified, key)
+				}
+				for key, value := range runtime.DeepCopyJSON(typedCurrent) {
+					typedModified[key] = value
+				}
+			}
+			return
+		}
+		for key, value := range typedModified {
+			if currentValue, ok := typedCurrent[key]; ok {
+				alignLabelSelectors(currentValue, value)
+			}
+		}
+	case []interface{}:
+		typedCurrent, ok := current.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range typedModified {
+			if typedItem, ok := item.(map[string]interface{}); ok && typedItem["name"] != nil {
+				if currentItem := findNamedItem(typedCurrent, typedItem["name"]); currentItem != nil {
+					alignLabelSelectors(currentItem, item)
+				}
+				continue
+			}
+			if i < len(typedCurrent) {
+				alignLabelSelectors(typedCurrent[i], item)
+			}
+		}
+	}
+}
+
+// isLabelSelector tells whether the node is a label selector, a map holding only matchLabels and matchExpressions.
+func isLabelSelector(node map[string]interface{}) bool {
+	if len(node) == 0 {
+		return false
+	}
+	for key := range node {
+		if key != "matchLabels" && key != "matchExpressions" {
+			return false
+		}
+	}
+	return true
+}
+
+// labelSelectorRequirements returns the sorted requirements of the label selector as a string, matchLabels entries being
+// written as In expressions.
+func labelSelectorRequirements(selector map[string]interface{}) string {
+	requirements := map[string]bool{}
+
+	matchLabels, _ := selector["matchLabels"].(map[string]interface{})
+	for key, value := range matchLabels {
+		requirements[fmt.Sprintf("%s In [%v]", key, value)] = true
+	}
+
+	matchExpressions, _ := selector["matchExpressions"].([]interface{})
+	for _, expression := range matchExpressions {
+		typedExpression, _ := expression.(map[string]interface{})
+		values, _ := typedExpression["values"].([]interface{})
+		uniqueValues := map[string]bool{}
+		for _, value := range values {
+			uniqueValues[fmt.Sprint(value)] = true
+		}
+		requirements[fmt.Sprintf("%v %v [%s]", typedExpression["key"], typedExpression["operator"], strings.Join(sortedKeys(uniqueValues), " "))] = true
+	}
+
+	return strings.Join(sortedKeys(requirements), ", ")
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,96 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIgnoreLabelSelectorOrdering(t *testing.T) {
+	newNetworkPolicy := func(podSelector metav1.LabelSelector) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: podSelector,
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+					}},
+				}},
+			},
+		}
+	}
+
+	current := mustAnnotate(newNetworkPolicy(metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "app", Operator: metav1.LabelSelectorOpIn, Values: []string{"a", "b"}},
+			{Key: "tier", Operator: metav1.LabelSelectorOpExists},
+		},
+	})).(*networkingv1.NetworkPolicy)
+	current.Spec.Ingress[0].From[0].NamespaceSelector = &metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "team", Operator: metav1.LabelSelectorOpIn, Values: []string{"a"}}},
+	}
+	modified := newNetworkPolicy(metav1.LabelSelector{
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{Key: "tier", Operator: metav1.LabelSelectorOpExists},
+			{Key: "app", Operator: metav1.LabelSelectorOpIn, Values: []string{"b", "a"}},
+		},
+	})
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.Calculate(current, modified, IgnoreLabelSelectorOrdering())
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+
+	// Other requirements are still a change
+	modified.Spec.PodSelector.MatchExpressions[1].Values = []string{"a", "c"}
+	result, err = DefaultPatchMaker.Calculate(current, modified, IgnoreLabelSelectorOrdering())
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+}
+
+func TestIgnoreLabelSelectorOrderingUnstructured(t *testing.T) {
+	current := []byte(`{"kind":"PodMonitor","spec":{"selector":{"matchExpressions":[{"key":"a","operator":"In","values":["1"]},{"key":"b","operator":"NotIn","values":["2","3"]}]},"namespaceSelector":{"matchNames":["x"]}}}`)
+	modified := []byte(`{"kind":"PodMonitor","spec":{"selector":{"matchLabels":{"a":"1"},"matchExpressions":[{"key":"b","operator":"NotIn","values":["3","2"]}]},"namespaceSelector":{"matchNames":["x"]}}}`)
+
+	alignedCurrent, alignedModified, err := IgnoreLabelSelectorOrdering()(current, modified)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(alignedCurrent), string(alignedModified))
+}
+
+func TestNormalizeLabelSelectors(t *testing.T) {
+	current := []byte(`{"spec":{"selector":{"matchLabels":{"app":"test"},"matchExpressions":[{"key":"tier","operator":"NotIn","values":["b","a","b"]},{"key":"env","operator":"Exists"}]}}}`)
+	modified := []byte(`{"spec":{"selector":{"matchExpressions":[{"key":"env","operator":"Exists"},{"key":"app","operator":"In","values":["test"]},{"key":"tier","operator":"NotIn","values":["a","b"]}]}}}`)
+
+	normalizedCurrent, normalizedModified, err := NormalizeLabelSelectors()(current, modified)
+	require.NoError(t, err)
+	expected := `{"spec":{"selector":{"matchLabels":{"app":"test"},"matchExpressions":[{"key":"env","operator":"Exists"},{"key":"tier","operator":"NotIn","values":["a","b"]}]}}}`
+	assert.JSONEq(t, expected, string(normalizedCurrent))
+	assert.JSONEq(t, expected, string(normalizedModified))
+
+	// Conflicting requirements on the same key are kept
+	conflicting := []byte(`{"selector":{"matchLabels":{"app":"a"},"matchExpressions":[{"key":"app","operator":"In","values":["b"]}]}}`)
+	normalized, _, err := NormalizeLabelSelectors()(conflicting, conflicting)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(conflicting), string(normalized))
+}
@@ -0,0 +1,67 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"github.com/go-logr/logr"
+)
+
+// debugLevel is the verbosity of the debug dumps.
+const debugLevel = 1
+
+// NewPatchMakerWithLogger creates a PatchMaker logging debug dumps of the compared documents to the logger, see WithLogger.
+func NewPatchMakerWithLogger(logger logr.Logger, annotator *Annotator, strategicMergePatcher StrategicMergePatcher, jsonMergePatcher JSONMergePatcher, opts ...PatchMakerOption) Maker {
+	return NewPatchMaker(annotator, strategicMergePatcher, jsonMergePatcher, append([]PatchMakerOption{WithLogger(logger)}, opts...)...)
+}
+
+// WithLogger logs the original, current and modified documents along with the intermediate patches at the debug
+// level (V(1)) when a patch is not empty, or when the three-way patch was discarded as it made no actual change.
+// Secret values are redacted, see PatchResult.Redacted.
+func WithLogger(logger logr.Logger) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.logger = logger
+	}
+}
+
+// logResult dumps the documents of a result when the patch is not empty.
+func (p *PatchMaker) logResult(ctx CalculateContext, result *PatchResult) {
+	log := p.logger.V(debugLevel)
+	if !log.Enabled() {
+		return
+	}
+
+	threeWayPatchEmpty := result.threeWayPatch == nil || string(result.threeWayPatch) == "{}"
+	if result.IsEmpty() && threeWayPatchEmpty {
+		return
+	}
+
+	redacted := result.Redacted()
+	keysAndValues := []interface{}{
+		"gvk", ctx.GVK.String(),
+		"patch", string(redacted.Patch),
+		"original", string(redacted.Original),
+		"current", string(redacted.Current),
+		"modified", string(redacted.Modified),
+	}
+	if !threeWayPatchEmpty {
+		keysAndValues = append(keysAndValues, "threeWayPatch", string(redacted.threeWayPatch))
+	}
+
+	if result.IsEmpty() {
+		log.Info("three-way patch discarded as it makes no actual change", keysAndValues...)
+		return
+	}
+	log.Info("patch is not empty", keysAndValues...)
+}
@@ -0,0 +1,79 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewPatchMakerWithLogger(t *testing.T) {
+	var logs []string
+	logger := funcr.New(func(prefix, args string) {
+		logs = append(logs, args)
+	}, funcr.Options{Verbosity: 1})
+
+	patchMaker := NewPatchMakerWithLogger(logger, DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{})
+
+	newSecret := func(password string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{"password": []byte(password)},
+		}
+	}
+
+	current := newSecret("secret1")
+	assert.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	result, err := patchMaker.Calculate(current, newSecret("secret1"))
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+	assert.Empty(t, logs)
+
+	result, err = patchMaker.Calculate(current, newSecret("secret2"))
+	assert.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+	if assert.Len(t, logs, 1) {
+		assert.Contains(t, logs[0], "patch is not empty")
+		assert.Contains(t, logs[0], `"threeWayPatch"`)
+		assert.Contains(t, logs[0], RedactedValue)
+		assert.NotContains(t, logs[0], "c2VjcmV0")
+	}
+}
+
+func TestWithLoggerVerbosity(t *testing.T) {
+	var logs []string
+	logger := funcr.New(func(prefix, args string) {
+		logs = append(logs, args)
+	}, funcr.Options{})
+
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithLogger(logger))
+
+	current := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config"}, Data: map[string]string{"key": "value1"}}
+	assert.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+	modified := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config"}, Data: map[string]string{"key": "value2"}}
+
+	result, err := patchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+	assert.Empty(t, logs, "debug dumps are not logged at the default verbosity")
+}
@@ -0,0 +1,88 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"strings"
+
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ManagedAnnotation is the annotation read by WithManagedAnnotation when no other key is given.
+const ManagedAnnotation = "banzaicloud.com/managed"
+
+// WithManagedAnnotation makes Calculate skip the current objects having the annotation with the given key
+// (ManagedAnnotation if empty) set to "false": the result has an empty patch, the current object as patched
+// object and Skipped set. It gives cluster users a way to pause the management of an object by an operator.
+func WithManagedAnnotation(key string) PatchMakerOption {
+	if key == "" {
+		key = ManagedAnnotation
+	}
+	return func(p *PatchMaker) {
+		p.managedAnnotation = key
+	}
+}
+
+// unmanaged tells whether the management of the object is paused by the managed annotation.
+func (p *PatchMaker) unmanaged(obj runtime.Object) (bool, error) {
+	if p.managedAnnotation == "" {
+		return false, nil
+	}
+
+	annotations, err := meta.NewAccessor().Annotations(obj)
+	if err != nil {
+		return false, errors.Wrap(err, "could not read annotations")
+	}
+	return strings.EqualFold(strings.TrimSpace(annotations[p.managedAnnotation]), "false"), nil
+}
+
+// skippedResult returns the result of an unmanaged object, leaving it as it is.
+func (p *PatchMaker) skippedResult(ctx CalculateContext, currentObject runtime.Object) (*PatchResult, error) {
+	current, err := p.jsonConfig.Marshal(currentObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert current object to byte sequence")
+	}
+
+	patched, err := newObjectFromJSON(p.jsonConfig, currentObject, current)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create patched object")
+	}
+
+	redactionPaths, err := p.redactionPathsFor(ctx.GVK)
+	if err != nil {
+		return nil, err
+	}
+
+	p.logger.V(debugLevel).Info("object is not managed, skipping", "gvk", ctx.GVK.String(), "annotation", p.managedAnnotation)
+
+	result := &PatchResult{
+		Patch:   []byte("{}"),
+		Current: current,
+		Patched: patched,
+		Skipped: true,
+
+		currentOrg:     current,
+		patchedCurrent: current,
+		patchType:      p.patchTypeFor(ctx.GVK, currentObject),
+		redactionPaths: redactionPaths,
+	}
+	if err := canonicalResult(result); err != nil {
+		return nil, errors.Wrap(err, "Failed to sort the keys of the result documents")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,67 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWithManagedAnnotation(t *testing.T) {
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default"},
+			Data:       map[string]string{"key": value},
+		}
+	}
+
+	current := newConfigMap("value1")
+	mustAnnotate(current)
+	current.Annotations[ManagedAnnotation] = "False"
+	modified := newConfigMap("value2")
+
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithManagedAnnotation(""))
+	for _, calculate := range []func() (*PatchResult, error){
+		func() (*PatchResult, error) { return patchMaker.Calculate(current, modified) },
+		func() (*PatchResult, error) {
+			return patchMaker.(MetadataMaker).CalculateMetadataOnly(current, modified)
+		},
+	} {
+		result, err := calculate()
+		assert.NoError(t, err)
+		assert.True(t, result.Skipped)
+		assert.True(t, result.IsEmpty())
+		assert.Empty(t, result.Changes())
+		assert.Equal(t, current, result.Patched)
+		assert.NotSame(t, current, result.Patched)
+	}
+
+	// Managed objects
+	current.Annotations[ManagedAnnotation] = "true"
+	result, err := patchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, result.Skipped)
+	assert.False(t, result.IsEmpty())
+
+	// The annotation is only read when enabled
+	current.Annotations[ManagedAnnotation] = "false"
+	result, err = DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, result.Skipped)
+	assert.False(t, result.IsEmpty())
+}
@@ -0,0 +1,234 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+// IgnoreFieldsOwnedByManagers removes the fields owned by other field managers than fieldManager,
+// according to the metadata.managedFields of the current object, from both objects before comparing them.
+// Fields shared with fieldManager are kept. This avoids fighting over fields other controllers manage,
+// like spec.replicas set by the HorizontalPodAutoscaler.
+func IgnoreFieldsOwnedByManagers(fieldManager string) CalculateOption {
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		currentResource := map[string]interface{}{}
+		if err := json.Unmarshal(current, &currentResource); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for current")
+		}
+
+		modifiedResource := map[string]interface{}{}
+		if err := json.Unmarshal(modified, &modifiedResource); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for modified")
+		}
+
+		ownedFields, err := fieldsOwnedByOtherManagers(currentResource, fieldManager)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not parse managed fields of current")
+		}
+		if len(ownedFields) == 0 {
+			return current, modified, nil
+		}
+
+		deleteManagedFields(currentResource, ownedFields)
+		deleteManagedFields(modifiedResource, ownedFields)
+
+		current, err = json.ConfigCompatibleWithStandardLibrary.Marshal(currentResource)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not marshal byte sequence for current")
+		}
+
+		modified, err = json.ConfigCompatibleWithStandardLibrary.Marshal(modifiedResource)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not marshal byte sequence for modified")
+		}
+
+		return current, modified, nil
+	}
+}
+
+// fieldsOwnedByOtherManagers returns the fieldsV1 set of the fields owned by other managers than fieldManager
+// and not by fieldManager itself.
+func fieldsOwnedByOtherManagers(obj map[string]interface{}, fieldManager string) (map[string]interface{}, error) {
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	managedFields, _ := metadata["managedFields"].([]interface{})
+
+	others := map[string]interface{}{}
+	own := map[string]interface{}{}
+	for _, entry := range managedFields {
+		entry, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if fieldsType, ok := entry["fieldsType"].(string); ok && fieldsType != "FieldsV1" {
+			return nil, errors.Errorf("unsupported managed fields type %q", fieldsType)
+		}
+		fields, ok := entry["fieldsV1"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if entry["manager"] == fieldManager {
+			mergeFieldSets(own, fields)
+		} else {
+			mergeFieldSets(others, fields)
+		}
+	}
+
+	subtractFieldSet(others, own)
+
+	return others, nil
+}
+
+func mergeFieldSets(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcChild, _ := value.(map[string]interface{})
+		dstChild, ok := dst[key].(map[string]interface{})
+		if !ok {
+			dstChild = map[string]interface{}{}
+			dst[key] = dstChild
+		}
+		mergeFieldSets(dstChild, srcChild)
+	}
+}
+
+// subtractFieldSet removes the leaf fields of set owned by other as well, the parents left without fields are removed.
+func subtractFieldSet(set, other map[string]interface{}) {
+	for key, value := range set {
+		otherChild, ok := other[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		child, _ := value.(map[string]interface{})
+		if isLeafFieldSet(child) {
+			delete(set, key)
+			continue
+		}
+
+		subtractFieldSet(child, otherChild)
+		if len(child) == 0 {
+			delete(set, key)
+		}
+	}
+}
+
+// isLeafFieldSet tells whether the set describes a field owned as a whole, without owned children.
+func isLeafFieldSet(set map[string]interface{}) bool {
+	for key := range set {
+		if key != "." {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteManagedFields deletes the fields described by a fieldsV1 set from the data.
+func deleteManagedFields(data interface{}, fields map[string]interface{}) {
+	switch typedData := data.(type) {
+	case map[string]interface{}:
+		for key, value := range fields {
+			if !strings.HasPrefix(key, "f:") {
+				continue
+			}
+			name := strings.TrimPrefix(key, "f:")
+			child, _ := value.(map[string]interface{})
+			if isLeafFieldSet(child) {
+				delete(typedData, name)
+				continue
+			}
+			deleteManagedFields(typedData[name], child)
+		}
+	case []interface{}:
+		for key, value := range fields {
+			child, _ := value.(map[string]interface{})
+			for _, item := range listItemsForField(typedData, key) {
+				if isLeafFieldSet(child) {
+					// Removing list items would change the meaning of the list, keep them as they are
+					continue
+				}
+				deleteManagedFields(item, withoutKeyFields(key, child))
+			}
+		}
+	}
+}
+
+// listItemsForField returns the list items a fieldsV1 key (k:, v: or i:) refers to.
+func listItemsForField(list []interface{}, key string) []interface{} {
+	var items []interface{}
+
+	switch {
+	case strings.HasPrefix(key, "k:"):
+		keyFields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(key, "k:")), &keyFields); err != nil {
+			return nil
+		}
+		for _, item := range list {
+			object, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			matches := true
+			for field, value := range keyFields {
+				if !reflect.DeepEqual(object[field], value) {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				items = append(items, item)
+			}
+		}
+	case strings.HasPrefix(key, "v:"):
+		var value interface{}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(key, "v:")), &value); err != nil {
+			return nil
+		}
+		for _, item := range list {
+			if reflect.DeepEqual(item, value) {
+				items = append(items, item)
+			}
+		}
+	case strings.HasPrefix(key, "i:"):
+		index, err := strconv.Atoi(strings.TrimPrefix(key, "i:"))
+		if err == nil && index >= 0 && index < len(list) {
+			items = append(items, list[index])
+		}
+	}
+
+	return items
+}
+
+// withoutKeyFields drops the fields identifying a list item from its field set, so items stay identifiable.
+func withoutKeyFields(key string, fields map[string]interface{}) map[string]interface{} {
+	keyFields := map[string]interface{}{}
+	if !strings.HasPrefix(key, "k:") || json.Unmarshal([]byte(strings.TrimPrefix(key, "k:")), &keyFields) != nil {
+		return fields
+	}
+
+	filtered := make(map[string]interface{}, len(fields))
+	for field, value := range fields {
+		if _, ok := keyFields[strings.TrimPrefix(field, "f:")]; ok {
+			continue
+		}
+		filtered[field] = value
+	}
+	return filtered
+}
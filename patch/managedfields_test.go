@@ -0,0 +1,102 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIgnoreFieldsOwnedByManagers(t *testing.T) {
+	newDeployment := func(replicas int32, image string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "deployment",
+				Namespace: "default",
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{Name: "app", Image: image},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	current := newDeployment(1, "nginx:1.22")
+	mustAnnotate(current)
+	current.Spec.Replicas = func(i int32) *int32 { return &i }(5)
+	current.Spec.Template.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "INJECTED", Value: "true"}}
+	current.ManagedFields = []v1.ManagedFieldsEntry{
+		{
+			Manager:    "operator",
+			Operation:  v1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &v1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"app\"}":{".":{},"f:image":{},"f:name":{}}}}}}}`)},
+		},
+		{
+			Manager:    "kube-controller-manager",
+			Operation:  v1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &v1.FieldsV1{Raw: []byte(`{"f:spec":{"f:replicas":{}}}`)},
+		},
+		{
+			Manager:    "injector",
+			Operation:  v1.ManagedFieldsOperationUpdate,
+			FieldsType: "FieldsV1",
+			FieldsV1:   &v1.FieldsV1{Raw: []byte(`{"f:spec":{"f:template":{"f:spec":{"f:containers":{"k:{\"name\":\"app\"}":{"f:name":{},"f:env":{}}}}}}}`)},
+		},
+	}
+
+	opts := []CalculateOption{IgnoreFieldsOwnedByManagers("operator")}
+
+	// Fields owned by other managers are ignored
+	patch, err := DefaultPatchMaker.Calculate(current, newDeployment(1, "nginx:1.22"), opts...)
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	// Without the option the replicas are set back
+	patch, err = DefaultPatchMaker.Calculate(current, newDeployment(1, "nginx:1.22"))
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	// Fields owned by the field manager are still compared
+	patch, err = DefaultPatchMaker.Calculate(current, newDeployment(1, "nginx:1.23"), opts...)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+	assert.NotContains(t, string(patch.Patch), "replicas")
+	assert.Contains(t, string(patch.Patch), "nginx:1.23")
+}
+
+func TestFieldsOwnedByOtherManagers(t *testing.T) {
+	obj := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"metadata":{"managedFields":[
+		{"manager":"a","fieldsType":"FieldsV1","fieldsV1":{"f:metadata":{"f:labels":{".":{},"f:x":{},"f:y":{}}}}},
+		{"manager":"b","fieldsType":"FieldsV1","fieldsV1":{"f:metadata":{"f:labels":{".":{},"f:y":{}}}}}
+	]}}`), &obj))
+
+	owned, err := fieldsOwnedByOtherManagers(obj, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"f:metadata": map[string]interface{}{"f:labels": map[string]interface{}{"f:x": map[string]interface{}{}}}}, owned)
+}
@@ -0,0 +1,122 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+// CalculateMapOption modifies the parsed objects in place before comparing them. Unlike CalculateOption, a chain of
+// map options passed to MapOptions parses and marshals the objects only once.
+type CalculateMapOption func(current, modified map[string]interface{}) error
+
+// MapOptions returns a CalculateOption applying the map options in order on the objects parsed once.
+func MapOptions(opts ...CalculateMapOption) CalculateOption {
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		currentResource := map[string]interface{}{}
+		if err := json.Unmarshal(current, &currentResource); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for current")
+		}
+		modifiedResource := map[string]interface{}{}
+		if err := json.Unmarshal(modified, &modifiedResource); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for modified")
+		}
+
+		for _, opt := range opts {
+			if err := opt(currentResource, modifiedResource); err != nil {
+				return []byte{}, []byte{}, err
+			}
+		}
+
+		current, err := json.ConfigCompatibleWithStandardLibrary.Marshal(currentResource)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not marshal byte sequence for current")
+		}
+		modified, err = json.ConfigCompatibleWithStandardLibrary.Marshal(modifiedResource)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not marshal byte sequence for modified")
+		}
+
+		return current, modified, nil
+	}
+}
+
+// FromCalculateOption adapts a CalculateOption to be used among map options. The objects are marshaled for
+// the option and replaced by its results, so it costs the round-trip MapOptions saves for the other options.
+func FromCalculateOption(opt CalculateOption) CalculateMapOption {
+	return func(currentResource, modifiedResource map[string]interface{}) error {
+		current, err := json.ConfigCompatibleWithStandardLibrary.Marshal(currentResource)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal byte sequence for current")
+		}
+		modified, err := json.ConfigCompatibleWithStandardLibrary.Marshal(modifiedResource)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal byte sequence for modified")
+		}
+
+		current, modified, err = opt(current, modified)
+		if err != nil {
+			return err
+		}
+
+		if err := replaceResource(currentResource, current); err != nil {
+			return errors.Wrap(err, "could not unmarshal byte sequence for current")
+		}
+		if err := replaceResource(modifiedResource, modified); err != nil {
+			return errors.Wrap(err, "could not unmarshal byte sequence for modified")
+		}
+		return nil
+	}
+}
+
+// replaceResource replaces the content of the resource with the JSON document.
+func replaceResource(resource map[string]interface{}, document []byte) error {
+	replacement := map[string]interface{}{}
+	if err := json.Unmarshal(document, &replacement); err != nil {
+		return err
+	}
+	for key := range resource {
+		delete(resource, key)
+	}
+	for key, value := range replacement {
+		resource[key] = value
+	}
+	return nil
+}
+
+// IgnoreStatusFieldsMap is the map option of IgnoreStatusFields.
+func IgnoreStatusFieldsMap() CalculateMapOption {
+	return IgnoreFieldMap("status")
+}
+
+// IgnoreFieldMap is the map option of IgnoreField.
+func IgnoreFieldMap(field string) CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		delete(current, field)
+		delete(modified, field)
+		return nil
+	}
+}
+
+// IgnoreVolumeClaimTemplateTypeMetaAndStatusMap is the map option of IgnoreVolumeClaimTemplateTypeMetaAndStatus.
+func IgnoreVolumeClaimTemplateTypeMetaAndStatusMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		deleteVolumeClaimTemplateFields(current)
+		deleteVolumeClaimTemplateFields(modified)
+		deleteDefaultedStorageClassNames(current, modified)
+		return nil
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMapOptions(t *testing.T) {
+	current := []byte(`{"metadata":{"name":"app"},"spec":{"replicas":1},"status":{"ready":true}}`)
+	modified := []byte(`{"metadata":{"name":"app"},"spec":{"replicas":2}}`)
+
+	opt := MapOptions(
+		IgnoreStatusFieldsMap(),
+		FromCalculateOption(IgnoreField("spec")),
+		func(current, modified map[string]interface{}) error {
+			modified["extra"] = true
+			return nil
+		},
+	)
+	current, modified, err := opt(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"name":"app"}}`, string(current))
+	assert.JSONEq(t, `{"metadata":{"name":"app"},"extra":true}`, string(modified))
+}
+
+func TestMapOptionsCalculate(t *testing.T) {
+	newPod := func(image string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Name: "pod", Namespace: "default"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: image}}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+
+	current := newPod("nginx:1")
+	mustAnnotate(current)
+	modified := newPod("nginx:2")
+	modified.Status = corev1.PodStatus{}
+
+	result, err := DefaultPatchMaker.Calculate(current, modified, MapOptions(IgnoreStatusFieldsMap()))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"$setElementOrder/containers":[{"name":"app"}],"containers":[{"image":"nginx:2","name":"app"}]}}`, string(result.Patch))
+
+	result, err = DefaultPatchMaker.Calculate(current, modified, MapOptions(IgnoreStatusFieldsMap(), IgnoreFieldMap("spec")))
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+}
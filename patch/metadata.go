@@ -0,0 +1,220 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/api/meta"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// CalculateMetadataOnly compares only the labels, annotations, owner references and finalizers of the objects and
+// returns a JSON merge patch of the metadata, e.g. for controllers managing the metadata of objects owned elsewhere.
+// The objects can be typed, unstructured or metav1.PartialObjectMetadata. The original configuration is used,
+// when there is one, to remove the labels and annotations no longer present in the modified object, but the
+// last-applied annotation itself is neither compared nor set on the patched object.
+func (p *PatchMaker) CalculateMetadataOnly(currentObject, modifiedObject runtime.Object) (*PatchResult, error) {
+	unmanaged, err := p.unmanaged(currentObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to check whether the object is managed")
+	}
+	if unmanaged {
+		return p.skippedResult(p.newCalculateContext(context.Background(), currentObject, modifiedObject), currentObject)
+	}
+
+	currentOrg, err := p.jsonConfig.Marshal(currentObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert current object to byte sequence")
+	}
+
+	currentMetadata, err := p.comparedMetadata(currentObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get metadata of current object")
+	}
+
+	modifiedMetadata, err := p.comparedMetadata(modifiedObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get metadata of modified object")
+	}
+
+	var originalMetadata *comparedMetadata
+	original, err := p.originalConfiguration(context.Background(), currentObject, currentOrg)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get original configuration")
+	}
+	if original != nil {
+		originalObject := &v1.PartialObjectMetadata{}
+		if err := json.Unmarshal(original, originalObject); err != nil {
+			return nil, errors.Wrap(err, "Failed to convert original configuration to object")
+		}
+		originalMetadata, err = p.comparedMetadata(originalObject)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get metadata of original configuration")
+		}
+	}
+
+	// Lists are replaced as a whole by JSON merge patches, keep the items set by others in the modified lists.
+	modifiedMetadata.Finalizers = mergeMetadataList(currentMetadata.Finalizers, originalMetadata.finalizers(), modifiedMetadata.Finalizers,
+		func(finalizer string) string { return finalizer })
+	modifiedMetadata.OwnerReferences = mergeMetadataList(currentMetadata.OwnerReferences, originalMetadata.ownerReferences(), modifiedMetadata.OwnerReferences,
+		func(ownerReference v1.OwnerReference) string { return string(ownerReference.UID) })
+
+	current, err := currentMetadata.document()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert current metadata to byte sequence")
+	}
+	modified, err := modifiedMetadata.document()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert modified metadata to byte sequence")
+	}
+	if originalMetadata != nil {
+		original, err = originalMetadata.document()
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to convert original metadata to byte sequence")
+		}
+	}
+
+	patch, patchedCurrent, err := p.unstructuredJsonMergePatch(original, modified, current, currentOrg)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to generate metadata merge patch")
+	}
+
+	patched, err := newObjectFromJSON(p.jsonConfig, currentObject, patchedCurrent)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create patched object")
+	}
+
+	redactionPaths, err := p.redactionPathsFor(objectGroupVersionKind(currentObject, p.defaultingScheme))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PatchResult{
+		Patch:    patch,
+		Current:  current,
+		Modified: modified,
+		Original: original,
+		Patched:  patched,
+
+		currentOrg:     currentOrg,
+		patchedCurrent: patchedCurrent,
+		redactionPaths: redactionPaths,
+		patchType:      types.MergePatchType,
+	}
+	if err := canonicalResult(result); err != nil {
+		return nil, errors.Wrap(err, "Failed to sort the keys of the result documents")
+	}
+
+	return result, nil
+}
+
+// comparedMetadata holds the metadata fields compared by CalculateMetadataOnly. Labels and annotations are always
+// present, so the keys set by others are left untouched when the modified object has none.
+type comparedMetadata struct {
+	Labels          map[string]string   `json:"labels"`
+	Annotations     map[string]string   `json:"annotations"`
+	OwnerReferences []v1.OwnerReference `json:"ownerReferences,omitempty"`
+	Finalizers      []string            `json:"finalizers,omitempty"`
+}
+
+func (p *PatchMaker) comparedMetadata(obj runtime.Object) (*comparedMetadata, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &comparedMetadata{
+		Labels:          map[string]string{},
+		Annotations:     map[string]string{},
+		OwnerReferences: accessor.GetOwnerReferences(),
+		Finalizers:      accessor.GetFinalizers(),
+	}
+	for key, value := range accessor.GetLabels() {
+		metadata.Labels[key] = value
+	}
+	for key, value := range accessor.GetAnnotations() {
+		if p.annotator != nil && key == p.annotator.key {
+			continue
+		}
+		metadata.Annotations[key] = value
+	}
+
+	return metadata, nil
+}
+
+func (m *comparedMetadata) finalizers() []string {
+	if m == nil {
+		return nil
+	}
+	return m.Finalizers
+}
+
+func (m *comparedMetadata) ownerReferences() []v1.OwnerReference {
+	if m == nil {
+		return nil
+	}
+	return m.OwnerReferences
+}
+
+// document returns the metadata as a JSON document of an object.
+func (m *comparedMetadata) document() ([]byte, error) {
+	return json.ConfigCompatibleWithStandardLibrary.Marshal(map[string]interface{}{"metadata": m})
+}
+
+// mergeMetadataList keeps the current items, except the ones removed from the original configuration, followed by
+// the modified items missing from the current list.
+func mergeMetadataList[T any](current, original, modified []T, key func(T) string) []T {
+	inModified := map[string]bool{}
+	for _, item := range modified {
+		inModified[key(item)] = true
+	}
+	removed := map[string]bool{}
+	for _, item := range original {
+		if !inModified[key(item)] {
+			removed[key(item)] = true
+		}
+	}
+
+	var merged []T
+	inCurrent := map[string]bool{}
+	for _, item := range current {
+		inCurrent[key(item)] = true
+		if removed[key(item)] {
+			continue
+		}
+		if inModified[key(item)] {
+			// Take the modified version of the item
+			for _, modifiedItem := range modified {
+				if key(modifiedItem) == key(item) {
+					item = modifiedItem
+					break
+				}
+			}
+		}
+		merged = append(merged, item)
+	}
+	for _, item := range modified {
+		if !inCurrent[key(item)] {
+			merged = append(merged, item)
+		}
+	}
+
+	return merged
+}
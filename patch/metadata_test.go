@@ -0,0 +1,76 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCalculateMetadataOnly(t *testing.T) {
+	replicas := int32(3)
+	current := &appsv1.Deployment{
+		ObjectMeta: v1.ObjectMeta{
+			Name:       "deployment",
+			Namespace:  "default",
+			Labels:     map[string]string{"owner": "someone-else"},
+			Finalizers: []string{"example.com/other"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+		},
+	}
+
+	modified := &v1.PartialObjectMetadata{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        "deployment",
+			Namespace:   "default",
+			Labels:      map[string]string{"team": "a"},
+			Annotations: map[string]string{"example.com/managed": "true"},
+			Finalizers:  []string{"example.com/cleanup"},
+		},
+	}
+
+	// Labels and finalizers set by others are kept, the spec is not compared
+	result, err := DefaultPatchMaker.(MetadataMaker).CalculateMetadataOnly(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"labels":{"team":"a"},"annotations":{"example.com/managed":"true"},"finalizers":["example.com/other","example.com/cleanup"]}}`, string(result.Patch))
+
+	patched := result.Patched.(*appsv1.Deployment)
+	assert.Equal(t, map[string]string{"owner": "someone-else", "team": "a"}, patched.Labels)
+	assert.Equal(t, int32(3), *patched.Spec.Replicas)
+	_, hasAnnotation := patched.Annotations[LastAppliedConfig]
+	assert.False(t, hasAnnotation)
+
+	result, err = DefaultPatchMaker.(MetadataMaker).CalculateMetadataOnly(patched, modified)
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+
+	// With an original configuration the labels no longer present are removed
+	original := patched.DeepCopy()
+	original.Labels = map[string]string{"team": "a"}
+	original.Finalizers = []string{"example.com/cleanup"}
+	mustAnnotate(original)
+	patched.Annotations[LastAppliedConfig] = original.Annotations[LastAppliedConfig]
+	modified.Labels = nil
+	modified.Finalizers = nil
+
+	result, err = DefaultPatchMaker.(MetadataMaker).CalculateMetadataOnly(patched, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"metadata":{"labels":{"team":null},"finalizers":["example.com/other"]}}`, string(result.Patch))
+}
@@ -0,0 +1,78 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ErrMissingOriginal is returned by Calculate when the original configuration is missing
+// and the MissingOriginalError policy is set.
+var ErrMissingOriginal = errors.NewPlain("original configuration is missing")
+
+// MissingOriginalPolicy tells how to compare objects without original configuration, e.g. objects created by Helm or kubectl.
+type MissingOriginalPolicy int
+
+const (
+	// MissingOriginalTwoWay compares the current and modified objects only: the fields set by the modified object
+	// are added or changed, the other fields of the current object are left untouched. This is the default.
+	MissingOriginalTwoWay MissingOriginalPolicy = iota
+	// MissingOriginalAssumeCurrent takes the current object as the original configuration, without the metadata set
+	// by the API server and the status. The fields of the current object not set by the modified object are removed.
+	MissingOriginalAssumeCurrent
+	// MissingOriginalError makes Calculate fail with ErrMissingOriginal.
+	MissingOriginalError
+)
+
+// OnMissingOriginal sets the policy applied when the original configuration of the current object is missing.
+func OnMissingOriginal(policy MissingOriginalPolicy) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.missingOriginalPolicy = policy
+	}
+}
+
+// originalConfiguration returns the original configuration of the current object, or the one derived from
+// the current document according to the missing original policy.
+func (p *PatchMaker) originalConfiguration(ctx context.Context, currentObject runtime.Object, current []byte) ([]byte, error) {
+	if p.store != nil {
+		original, err := getOriginalConfiguration(ctx, p.store, currentObject)
+		if err != nil || original != nil {
+			return original, err
+		}
+	}
+
+	switch p.missingOriginalPolicy {
+	case MissingOriginalAssumeCurrent:
+		resource := map[string]interface{}{}
+		if err := json.Unmarshal(current, &resource); err != nil {
+			return nil, errors.Wrap(err, "could not unmarshal byte sequence for current")
+		}
+		deleteServerManagedFields(resource)
+		if metadata, ok := resource["metadata"].(map[string]interface{}); ok {
+			if annotations, ok := metadata["annotations"].(map[string]interface{}); ok && p.annotator != nil {
+				delete(annotations, p.annotator.key)
+			}
+		}
+		return json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+	case MissingOriginalError:
+		return nil, ErrMissingOriginal
+	default:
+		return nil, nil
+	}
+}
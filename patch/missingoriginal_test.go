@@ -0,0 +1,104 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOnMissingOriginal(t *testing.T) {
+	// Created by another tool, without last-applied annotation
+	current := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:            "config",
+			Namespace:       "default",
+			ResourceVersion: "12",
+			UID:             "1234",
+		},
+		Data: map[string]string{"key": "value1", "other": "value"},
+	}
+	modified := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+		Data: map[string]string{"key": "value2"},
+	}
+
+	tests := []struct {
+		name   string
+		policy *MissingOriginalPolicy
+		patch  string
+		err    error
+	}{
+		{
+			name:  "default",
+			patch: `{"data":{"key":"value2"}}`,
+		},
+		{
+			name:   "two way",
+			policy: missingOriginalPolicy(MissingOriginalTwoWay),
+			patch:  `{"data":{"key":"value2"}}`,
+		},
+		{
+			name:   "assume current is original",
+			policy: missingOriginalPolicy(MissingOriginalAssumeCurrent),
+			patch:  `{"data":{"key":"value2","other":null}}`,
+		},
+		{
+			name:   "error",
+			policy: missingOriginalPolicy(MissingOriginalError),
+			err:    ErrMissingOriginal,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var opts []PatchMakerOption
+			if test.policy != nil {
+				opts = append(opts, OnMissingOriginal(*test.policy))
+			}
+			patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, opts...)
+
+			result, err := patchMaker.Calculate(current, modified)
+			if test.err != nil {
+				assert.True(t, errors.Is(err, test.err), "unexpected error: %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.JSONEq(t, test.patch, string(result.Patch))
+		})
+	}
+}
+
+func TestOnMissingOriginalWithOriginal(t *testing.T) {
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, OnMissingOriginal(MissingOriginalError))
+
+	current := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config"}, Data: map[string]string{"key": "value1"}}
+	assert.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	result, err := patchMaker.Calculate(current, current.DeepCopy())
+	assert.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+}
+
+func missingOriginalPolicy(policy MissingOriginalPolicy) *MissingOriginalPolicy {
+	return &policy
+}
@@ -0,0 +1,262 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// equivalenceFunc tells whether two different values found at the same path are semantically equal.
+// The path holds the object keys (string) and the indexes of the modified lists (int) leading to the values.
+type equivalenceFunc func(path []interface{}, current, modified interface{}) bool
+
+// NormalizeQuantities replaces the resource quantities of the modified object by the ones of the current object
+// when they are equal but written differently (1000m and 1, 1024Mi and 1Gi), so they don't produce a patch.
+// It applies to the requests and limits of every resources field: containers, init containers, ephemeral containers,
+// PersistentVolumeClaims and volumeClaimTemplates, in typed and unstructured objects alike.
+func NormalizeQuantities() CalculateOption {
+	return alignEquivalentValuesOption(equivalentQuantities)
+}
+
+// intOrStringFields are the last keys of the paths of the IntOrString fields of the built-in types: the ports of probes and
+// lifecycle handlers, Service target ports, NetworkPolicy ports, rollout strategies, PodDisruptionBudgets and Ingress backends.
+var intOrStringFields = [][]string{
+	{"httpGet", "port"},
+	{"tcpSocket", "port"},
+	{"ports", "targetPort"},
+	{"ports", "port"},
+	{"rollingUpdate", "maxSurge"},
+	{"rollingUpdate", "maxUnavailable"},
+	{"spec", "minAvailable"},
+	{"spec", "maxUnavailable"},
+	{"backend", "servicePort"},
+}
+
+// NormalizeIntOrStringAndDurations replaces the values of the modified object by the ones of the current object when they
+// are equal but written differently: integers written as strings ("1" and 1) in the IntOrString fields of probes, Services,
+// NetworkPolicies, PodDisruptionBudgets, rollout strategies and Ingresses, and integers or durations ("1h" and "60m") at the
+// given paths, e.g. the duration fields of custom resources. Paths use the syntax of IgnoreJSONPath. Percentages aren't
+// considered integers, and the other fields, e.g. ConfigMap data, container args or env values, are left untouched.
+func NormalizeIntOrStringAndDurations(paths ...string) CalculateOption {
+	parsedPaths := make([][]pathSegment, 0, len(paths))
+	var parseErr error
+	for _, path := range paths {
+		segments, err := parseJSONPath(path)
+		if err != nil {
+			parseErr = errors.Append(parseErr, err)
+			continue
+		}
+		parsedPaths = append(parsedPaths, segments)
+	}
+
+	align := alignEquivalentValuesOption(func(path []interface{}, current, modified interface{}) bool {
+		segments := pathSegments(path)
+		for _, parsedPath := range parsedPaths {
+			if matchesJSONPath(segments, parsedPath) {
+				return equivalentIntOrString(current, modified) || equivalentDurations(current, modified)
+			}
+		}
+		return isIntOrStringField(path) && equivalentIntOrString(current, modified)
+	})
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		if parseErr != nil {
+			return []byte{}, []byte{}, parseErr
+		}
+		return align(current, modified)
+	}
+}
+
+// isIntOrStringField tells whether the path ends like the path of a known IntOrString field.
+func isIntOrStringField(path []interface{}) bool {
+	if len(path) > 0 && path[0] == "metadata" {
+		return false
+	}
+
+	keys := make([]string, 0, len(path))
+	for _, segment := range path {
+		if key, ok := segment.(string); ok {
+			keys = append(keys, key)
+		}
+	}
+	for _, field := range intOrStringFields {
+		if len(keys) >= len(field) && reflect.DeepEqual(keys[len(keys)-len(field):], field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func alignEquivalentValuesOption(equivalent equivalenceFunc) CalculateOption {
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		var currentResource, modifiedResource interface{}
+		if err := json.Unmarshal(current, &currentResource); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for current")
+		}
+		if err := json.Unmarshal(modified, &modifiedResource); err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not unmarshal byte sequence for modified")
+		}
+
+		modifiedResource = alignEquivalentValues(nil, currentResource, modifiedResource, equivalent)
+
+		modified, err := json.ConfigCompatibleWithStandardLibrary.Marshal(modifiedResource)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not marshal byte sequence for modified")
+		}
+
+		return current, modified, nil
+	}
+}
+
+// alignEquivalentValues walks both documents together and returns modified with the values equivalent
+// to the current ones replaced by the current values. List items are paired by name when they have one.
+func alignEquivalentValues(path []interface{}, current, modified interface{}, equivalent equivalenceFunc) interface{} {
+	switch typedModified := modified.(type) {
+	case map[string]interface{}:
+		typedCurrent, ok := current.(map[string]interface{})
+		if !ok {
+			return modified
+		}
+		for key, value := range typedModified {
+			if currentValue, ok := typedCurrent[key]; ok {
+				typedModified[key] = alignEquivalentValues(appendPath(path, key), currentValue, value, equivalent)
+			}
+		}
+		return typedModified
+	case []interface{}:
+		typedCurrent, ok := current.([]interface{})
+		if !ok {
+			return modified
+		}
+		for i, value := range typedModified {
+			if currentValue, ok := pairListItem(typedCurrent, i, value); ok {
+				typedModified[i] = alignEquivalentValues(appendPath(path, i), currentValue, value, equivalent)
+			}
+		}
+		return typedModified
+	}
+
+	if !reflect.DeepEqual(current, modified) && equivalent(path, current, modified) {
+		return current
+	}
+	return modified
+}
+
+// pairListItem returns the item of the current list matching the modified item: the item with the same name,
+// or the item at the same index when the items have no name.
+func pairListItem(current []interface{}, index int, modified interface{}) (interface{}, bool) {
+	if item, ok := modified.(map[string]interface{}); ok {
+		if name, ok := item["name"]; ok {
+			for _, currentItem := range current {
+				if currentItem, ok := currentItem.(map[string]interface{}); ok && currentItem["name"] == name {
+					return currentItem, true
+				}
+			}
+			return nil, false
+		}
+	}
+
+	if index < len(current) {
+		return current[index], true
+	}
+	return nil, false
+}
+
+// equivalentQuantities compares the values of resources.requests and resources.limits as quantities.
+func equivalentQuantities(path []interface{}, current, modified interface{}) bool {
+	if len(path) < 3 || path[len(path)-3] != "resources" || (path[len(path)-2] != "requests" && path[len(path)-2] != "limits") {
+		return false
+	}
+
+	currentQuantity, ok := parseQuantity(current)
+	if !ok {
+		return false
+	}
+	modifiedQuantity, ok := parseQuantity(modified)
+	if !ok {
+		return false
+	}
+
+	return currentQuantity.Cmp(modifiedQuantity) == 0
+}
+
+func parseQuantity(value interface{}) (resource.Quantity, bool) {
+	var str string
+	switch typedValue := value.(type) {
+	case string:
+		str = typedValue
+	case float64:
+		str = strconv.FormatFloat(typedValue, 'f', -1, 64)
+	default:
+		return resource.Quantity{}, false
+	}
+
+	quantity, err := resource.ParseQuantity(str)
+	if err != nil {
+		return resource.Quantity{}, false
+	}
+	return quantity, true
+}
+
+// equivalentIntOrString tells whether one value is a number and the other the same integer written as a string.
+func equivalentIntOrString(current, modified interface{}) bool {
+	number, str, ok := numberAndString(current, modified)
+	if !ok {
+		number, str, ok = numberAndString(modified, current)
+	}
+	if !ok {
+		return false
+	}
+
+	integer, err := strconv.ParseInt(str, 10, 64)
+	return err == nil && float64(integer) == number
+}
+
+func numberAndString(a, b interface{}) (float64, string, bool) {
+	number, ok := a.(float64)
+	if !ok {
+		return 0, "", false
+	}
+	str, ok := b.(string)
+	return number, str, ok
+}
+
+// equivalentDurations tells whether both values are the same duration written differently.
+func equivalentDurations(current, modified interface{}) bool {
+	currentString, ok := current.(string)
+	if !ok {
+		return false
+	}
+	modifiedString, ok := modified.(string)
+	if !ok {
+		return false
+	}
+
+	currentDuration, err := time.ParseDuration(currentString)
+	if err != nil {
+		return false
+	}
+	modifiedDuration, err := time.ParseDuration(modifiedString)
+	if err != nil {
+		return false
+	}
+	return currentDuration == modifiedDuration
+}
@@ -0,0 +1,211 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNormalizeQuantities(t *testing.T) {
+	newPod := func(cpu, memory interface{}, storage string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "StatefulSet",
+			"metadata": map[string]interface{}{
+				"name": "statefulset",
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{
+								"name": "app",
+								"resources": map[string]interface{}{
+									"requests": map[string]interface{}{"cpu": cpu},
+									"limits":   map[string]interface{}{"memory": memory},
+								},
+							},
+						},
+						"initContainers": []interface{}{
+							map[string]interface{}{
+								"name": "init",
+								"resources": map[string]interface{}{
+									"requests": map[string]interface{}{"cpu": cpu},
+								},
+							},
+						},
+					},
+				},
+				"volumeClaimTemplates": []interface{}{
+					map[string]interface{}{
+						"spec": map[string]interface{}{
+							"resources": map[string]interface{}{
+								"requests": map[string]interface{}{"storage": storage},
+							},
+						},
+					},
+				},
+			},
+		}}
+	}
+
+	current := newPod("1", "1Gi", "1Gi")
+	mustAnnotate(current)
+
+	tests := []struct {
+		name      string
+		modified  *unstructured.Unstructured
+		wantEmpty bool
+	}{
+		{
+			name:      "equal quantities written differently",
+			modified:  newPod("1000m", "1024Mi", "1024Mi"),
+			wantEmpty: true,
+		},
+		{
+			name:      "numeric quantities",
+			modified:  newPod(int64(1), "1073741824", "1Gi"),
+			wantEmpty: true,
+		},
+		{
+			name:      "different quantities",
+			modified:  newPod("500m", "1Gi", "1Gi"),
+			wantEmpty: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := DefaultPatchMaker.Calculate(current, tt.modified, NormalizeQuantities())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantEmpty, patch.IsEmpty(), string(patch.Patch))
+		})
+	}
+
+	// Without the option the representations differ
+	patch, err := DefaultPatchMaker.Calculate(current, newPod("1000m", "1Gi", "1Gi"))
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+}
+
+func TestNormalizeIntOrStringAndDurations(t *testing.T) {
+	newObject := func(maxUnavailable, port, timeout interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Foo",
+			"metadata": map[string]interface{}{
+				"name":   "foo",
+				"labels": map[string]interface{}{"window": timeout},
+			},
+			"spec": map[string]interface{}{
+				"strategy": map[string]interface{}{
+					"rollingUpdate": map[string]interface{}{"maxUnavailable": maxUnavailable},
+				},
+				"probe": map[string]interface{}{
+					"httpGet": map[string]interface{}{"port": port},
+				},
+				"timeout": timeout,
+			},
+		}}
+	}
+
+	current := newObject(int64(1), "8080", "1h")
+	mustAnnotate(current)
+
+	tests := []struct {
+		name      string
+		modified  *unstructured.Unstructured
+		paths     []string
+		wantPatch string
+	}{
+		{
+			name:      "equivalent values",
+			modified:  newObject("1", int64(8080), "60m"),
+			paths:     []string{".spec.timeout"},
+			wantPatch: `{"metadata":{"labels":{"window":"60m"}}}`,
+		},
+		{
+			name:      "durations without paths",
+			modified:  newObject("1", int64(8080), "60m"),
+			wantPatch: `{"metadata":{"labels":{"window":"60m"}},"spec":{"timeout":"60m"}}`,
+		},
+		{
+			name:      "percentage",
+			modified:  newObject("1%", "8080", "1h"),
+			paths:     []string{".spec.timeout"},
+			wantPatch: `{"spec":{"strategy":{"rollingUpdate":{"maxUnavailable":"1%"}}}}`,
+		},
+		{
+			name:      "different durations",
+			modified:  newObject(int64(1), "8080", "61m"),
+			paths:     []string{".spec.timeout"},
+			wantPatch: `{"metadata":{"labels":{"window":"61m"}},"spec":{"timeout":"61m"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := DefaultPatchMaker.Calculate(current, tt.modified, NormalizeIntOrStringAndDurations(tt.paths...))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.wantPatch, string(patch.Patch))
+		})
+	}
+
+	_, err := DefaultPatchMaker.Calculate(current, current, NormalizeIntOrStringAndDurations(".spec["))
+	assert.Error(t, err)
+}
+
+func TestNormalizeIntOrStringAndDurationsLeavesOtherFields(t *testing.T) {
+	newConfigMap := func(timeout, port string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "default"},
+			Data:       map[string]string{"timeout": timeout, "port": port},
+		}
+	}
+	current := newConfigMap("1h", "8080")
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	for _, timeout := range []string{"60m", "3600s"} {
+		patch, err := DefaultPatchMaker.Calculate(current, newConfigMap(timeout, "8080"), NormalizeIntOrStringAndDurations())
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data":{"timeout":"`+timeout+`"}}`, string(patch.Patch))
+	}
+
+	newPod := func(value string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "default"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  "app",
+					Image: "nginx",
+					Args:  []string{"--timeout", value},
+					Env:   []corev1.EnvVar{{Name: "TIMEOUT", Value: value}},
+				}},
+			},
+		}
+	}
+	pod := newPod("1h")
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(pod))
+	patch, err := DefaultPatchMaker.Calculate(pod, newPod("60m"), NormalizeIntOrStringAndDurations())
+	require.NoError(t, err)
+	assert.Contains(t, patch.Report(), `.spec.containers[0].args[1]: "1h" -> "60m"`)
+	assert.Contains(t, patch.Report(), `.spec.containers[0].env[0].value: "1h" -> "60m"`)
+}
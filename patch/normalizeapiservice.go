@@ -0,0 +1,91 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"bytes"
+	"encoding/base64"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var apiServiceGroupKind = schema.GroupKind{Group: "apiregistration.k8s.io", Kind: "APIService"}
+
+// NormalizeAPIService normalizes apiregistration.k8s.io APIServices of aggregated APIs: the status is removed from
+// both objects, the caBundle injected in the current object is removed when the modified object doesn't set it,
+// CA bundles encoding the same certificates with different surrounding whitespace compare equal, and the default
+// 443 service port is removed from the current object when the modified object doesn't set it.
+// Other objects are left untouched.
+func NormalizeAPIService() CalculateOption {
+	return MapOptions(NormalizeAPIServiceMap())
+}
+
+// NormalizeAPIServiceMap is the map option of NormalizeAPIService.
+func NormalizeAPIServiceMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		if !hasGroupKind(current, apiServiceGroupKind) || !hasGroupKind(modified, apiServiceGroupKind) {
+			return nil
+		}
+
+		delete(current, "status")
+		delete(modified, "status")
+
+		currentSpec, _ := current["spec"].(map[string]interface{})
+		modifiedSpec, _ := modified["spec"].(map[string]interface{})
+		if currentSpec == nil {
+			return nil
+		}
+
+		switch {
+		case modifiedSpec["caBundle"] == nil:
+			delete(currentSpec, "caBundle")
+		case equivalentCABundles(currentSpec["caBundle"], modifiedSpec["caBundle"]):
+			modifiedSpec["caBundle"] = currentSpec["caBundle"]
+		}
+
+		if service, ok := currentSpec["service"].(map[string]interface{}); ok {
+			modifiedService, _ := modifiedSpec["service"].(map[string]interface{})
+			deleteDefaultedFields(service, modifiedService, map[string]interface{}{"port": float64(443)})
+		}
+
+		return nil
+	}
+}
+
+// equivalentCABundles tells whether the base64 encoded CA bundles are the same once the whitespace around
+// the PEM blocks is trimmed.
+func equivalentCABundles(current, modified interface{}) bool {
+	currentBundle, ok := decodeCABundle(current)
+	if !ok {
+		return false
+	}
+	modifiedBundle, ok := decodeCABundle(modified)
+	if !ok {
+		return false
+	}
+	return bytes.Equal(currentBundle, modifiedBundle)
+}
+
+func decodeCABundle(value interface{}) ([]byte, bool) {
+	encoded, ok := value.(string)
+	if !ok {
+		return nil, false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	return bytes.TrimSpace(decoded), true
+}
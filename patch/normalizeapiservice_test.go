@@ -0,0 +1,76 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNormalizeAPIService(t *testing.T) {
+	newAPIService := func(spec map[string]interface{}) *unstructured.Unstructured {
+		spec["group"] = "metrics.k8s.io"
+		spec["version"] = "v1beta1"
+		spec["groupPriorityMinimum"] = int64(100)
+		spec["versionPriority"] = int64(100)
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiregistration.k8s.io/v1",
+			"kind":       "APIService",
+			"metadata":   map[string]interface{}{"name": "v1beta1.metrics.k8s.io"},
+			"spec":       spec,
+		}}
+	}
+	certificate := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"
+
+	current := newAPIService(map[string]interface{}{
+		"service": map[string]interface{}{"name": "metrics-server", "namespace": "kube-system"},
+	})
+	mustAnnotate(current)
+	current.Object["spec"].(map[string]interface{})["service"].(map[string]interface{})["port"] = int64(443)
+	current.Object["spec"].(map[string]interface{})["caBundle"] = base64.StdEncoding.EncodeToString([]byte(certificate))
+	current.Object["status"] = map[string]interface{}{
+		"conditions": []interface{}{map[string]interface{}{"type": "Available", "status": "True"}},
+	}
+
+	modified := newAPIService(map[string]interface{}{
+		"service": map[string]interface{}{"name": "metrics-server", "namespace": "kube-system"},
+	})
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified, NormalizeAPIService())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+	assert.NotContains(t, string(patch.Current), "caBundle")
+
+	// The same CA bundle with a trailing newline
+	modified.Object["spec"].(map[string]interface{})["caBundle"] = base64.StdEncoding.EncodeToString([]byte(certificate + "\n"))
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizeAPIService())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// Another CA bundle
+	modified.Object["spec"].(map[string]interface{})["caBundle"] = base64.StdEncoding.EncodeToString([]byte("other"))
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizeAPIService())
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	// Objects of other kinds are left untouched
+	other := []byte(`{"apiVersion":"example.com/v1","kind":"Backend","spec":{"groupPriorityMinimum":100,"caBundle":"Zm9v"},"status":{}}`)
+	currentOther, _, err := NormalizeAPIService()(other, []byte(`{"apiVersion":"example.com/v1","kind":"Backend","spec":{"groupPriorityMinimum":100}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(other), string(currentOther))
+}
@@ -0,0 +1,132 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var hpaGroupKind = schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}
+
+// hpaScalingRulesDefaults are the scaling rules defaulted by the API server for autoscaling/v2 HorizontalPodAutoscalers
+// setting a behavior.
+var hpaScalingRulesDefaults = map[string]func() map[string]interface{}{
+	"scaleUp": func() map[string]interface{} {
+		return map[string]interface{}{
+			"stabilizationWindowSeconds": float64(0),
+			"selectPolicy":               "Max",
+			"policies": []interface{}{
+				map[string]interface{}{"type": "Pods", "value": float64(4), "periodSeconds": float64(15)},
+				map[string]interface{}{"type": "Percent", "value": float64(100), "periodSeconds": float64(15)},
+			},
+		}
+	},
+	"scaleDown": func() map[string]interface{} {
+		return map[string]interface{}{
+			"stabilizationWindowSeconds": float64(300),
+			"selectPolicy":               "Max",
+			"policies": []interface{}{
+				map[string]interface{}{"type": "Percent", "value": float64(100), "periodSeconds": float64(15)},
+			},
+		}
+	},
+}
+
+// NormalizeHPA sets the defaults of the API server missing from the modified autoscaling/v2 HorizontalPodAutoscaler:
+// minReplicas, the 80% CPU utilization metric when there is no metric, and the scaleUp and scaleDown rules (stabilization
+// window, selectPolicy and policies) of the behavior when it's set. Metric target values and average values equal as
+// quantities, and average utilizations written as strings, are replaced by the current values. Other objects are
+// left untouched.
+func NormalizeHPA() CalculateOption {
+	return MapOptions(NormalizeHPAMap())
+}
+
+// NormalizeHPAMap is the map option of NormalizeHPA.
+func NormalizeHPAMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		if !hasGroupKind(current, hpaGroupKind) || !hasGroupKind(modified, hpaGroupKind) {
+			return nil
+		}
+
+		spec, _ := modified["spec"].(map[string]interface{})
+		if spec == nil {
+			return nil
+		}
+
+		setDefault(spec, "minReplicas", float64(1))
+		if metrics, _ := spec["metrics"].([]interface{}); len(metrics) == 0 {
+			spec["metrics"] = []interface{}{
+				map[string]interface{}{
+					"type": "Resource",
+					"resource": map[string]interface{}{
+						"name":   "cpu",
+						"target": map[string]interface{}{"type": "Utilization", "averageUtilization": float64(80)},
+					},
+				},
+			}
+		}
+
+		if behavior, ok := spec["behavior"].(map[string]interface{}); ok {
+			for direction, defaults := range hpaScalingRulesDefaults {
+				rules, ok := behavior[direction].(map[string]interface{})
+				if !ok {
+					behavior[direction] = defaults()
+					continue
+				}
+				for field, value := range defaults() {
+					setDefault(rules, field, value)
+				}
+			}
+		}
+
+		modified["spec"] = alignEquivalentValues([]interface{}{"spec"}, current["spec"], spec, equivalentMetricTargets)
+		return nil
+	}
+}
+
+// equivalentMetricTargets compares the value and averageValue of metric targets as quantities, and the
+// averageUtilization as integers.
+func equivalentMetricTargets(path []interface{}, current, modified interface{}) bool {
+	if len(path) < 2 || path[len(path)-2] != "target" {
+		return false
+	}
+
+	switch path[len(path)-1] {
+	case "value", "averageValue":
+		currentQuantity, ok := parseQuantity(current)
+		if !ok {
+			return false
+		}
+		modifiedQuantity, ok := parseQuantity(modified)
+		if !ok {
+			return false
+		}
+		return currentQuantity.Cmp(modifiedQuantity) == 0
+	case "averageUtilization":
+		currentUtilization, ok := current.(float64)
+		if !ok {
+			return false
+		}
+		modifiedUtilization, ok := modified.(string)
+		if !ok {
+			return false
+		}
+		utilization, err := strconv.ParseInt(modifiedUtilization, 10, 32)
+		return err == nil && float64(utilization) == currentUtilization
+	}
+	return false
+}
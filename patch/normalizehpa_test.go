@@ -0,0 +1,109 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNormalizeHPA(t *testing.T) {
+	newHPA := func(spec map[string]interface{}) *unstructured.Unstructured {
+		spec["scaleTargetRef"] = map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "app"}
+		spec["maxReplicas"] = int64(5)
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "autoscaling/v2",
+			"kind":       "HorizontalPodAutoscaler",
+			"metadata":   map[string]interface{}{"name": "app", "namespace": "default"},
+			"spec":       spec,
+		}}
+	}
+
+	// Server defaulted object
+	current := newHPA(map[string]interface{}{
+		"minReplicas": int64(1),
+		"metrics": []interface{}{
+			map[string]interface{}{
+				"type": "Resource",
+				"resource": map[string]interface{}{
+					"name":   "memory",
+					"target": map[string]interface{}{"type": "AverageValue", "averageValue": "1Gi"},
+				},
+			},
+		},
+		"behavior": map[string]interface{}{
+			"scaleUp": map[string]interface{}{
+				"stabilizationWindowSeconds": int64(0),
+				"selectPolicy":               "Max",
+				"policies": []interface{}{
+					map[string]interface{}{"type": "Pods", "value": int64(4), "periodSeconds": int64(15)},
+					map[string]interface{}{"type": "Percent", "value": int64(100), "periodSeconds": int64(15)},
+				},
+			},
+			"scaleDown": map[string]interface{}{
+				"stabilizationWindowSeconds": int64(60),
+				"selectPolicy":               "Max",
+				"policies": []interface{}{
+					map[string]interface{}{"type": "Percent", "value": int64(100), "periodSeconds": int64(15)},
+				},
+			},
+		},
+	})
+	mustAnnotate(current)
+
+	modified := newHPA(map[string]interface{}{
+		"metrics": []interface{}{
+			map[string]interface{}{
+				"type": "Resource",
+				"resource": map[string]interface{}{
+					"name":   "memory",
+					"target": map[string]interface{}{"type": "AverageValue", "averageValue": "1024Mi"},
+				},
+			},
+		},
+		"behavior": map[string]interface{}{
+			"scaleDown": map[string]interface{}{"stabilizationWindowSeconds": int64(60)},
+		},
+	})
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizeHPA())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+}
+
+func TestNormalizeHPADefaultMetric(t *testing.T) {
+	current := []byte(`{"apiVersion":"autoscaling/v2","kind":"HorizontalPodAutoscaler","spec":{"scaleTargetRef":{"name":"app"},"minReplicas":1,"maxReplicas":3,` +
+		`"metrics":[{"type":"Resource","resource":{"name":"cpu","target":{"type":"Utilization","averageUtilization":80}}}]}}`)
+	modified := []byte(`{"apiVersion":"autoscaling/v2","kind":"HorizontalPodAutoscaler","spec":{"scaleTargetRef":{"name":"app"},"maxReplicas":3}}`)
+
+	normalizedCurrent, normalizedModified, err := NormalizeHPA()(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(normalizedCurrent), string(normalizedModified))
+
+	// Objects of other kinds with a scaleTargetRef are left untouched
+	current = bytes.ReplaceAll(current, []byte(`"apiVersion":"autoscaling/v2"`), []byte(`"apiVersion":"keda.sh/v1alpha1"`))
+	modified = bytes.ReplaceAll(modified, []byte(`"apiVersion":"autoscaling/v2"`), []byte(`"apiVersion":"keda.sh/v1alpha1"`))
+	normalizedCurrent, normalizedModified, err = NormalizeHPA()(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(current), string(normalizedCurrent))
+	assert.JSONEq(t, string(modified), string(normalizedModified))
+}
@@ -0,0 +1,128 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"strconv"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const ingressClassAnnotation = "kubernetes.io/ingress.class"
+
+// ingressGroupKinds are the group kinds of networking/v1 and the deprecated extensions/v1beta1 Ingresses.
+var ingressGroupKinds = []schema.GroupKind{
+	{Group: "networking.k8s.io", Kind: "Ingress"},
+	{Group: "extensions", Kind: "Ingress"},
+}
+
+// NormalizeIngress makes Ingresses written for different cluster versions compare equal: the missing pathType of the
+// modified paths is defaulted to ImplementationSpecific, the ingressClassName of the current object is set on the modified
+// object when it uses the legacy kubernetes.io/ingress.class annotation with the same class, and numeric backend port names
+// (networking/v1 service.port.name and v1beta1 servicePort) are turned into port numbers in both objects.
+// Objects of other kinds are left untouched.
+func NormalizeIngress() CalculateOption {
+	return MapOptions(NormalizeIngressMap())
+}
+
+// NormalizeIngressMap is the map option of NormalizeIngress.
+func NormalizeIngressMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		if !hasGroupKind(current, ingressGroupKinds...) || !hasGroupKind(modified, ingressGroupKinds...) {
+			return nil
+		}
+
+		for _, resource := range []map[string]interface{}{current, modified} {
+			for _, backend := range ingressBackends(resource) {
+				normalizeIngressBackendPort(backend)
+			}
+		}
+
+		modifiedSpec, _ := modified["spec"].(map[string]interface{})
+		if modifiedSpec == nil {
+			return nil
+		}
+		for _, path := range ingressPaths(modified) {
+			setDefault(path, "pathType", "ImplementationSpecific")
+		}
+
+		if modifiedSpec["ingressClassName"] == nil {
+			annotations, _ := fieldValue(modified, []string{"metadata", "annotations"}).(map[string]interface{})
+			currentClassName := fieldValue(current, []string{"spec", "ingressClassName"})
+			if class, ok := annotations[ingressClassAnnotation]; ok && class == currentClassName {
+				modifiedSpec["ingressClassName"] = class
+			}
+		}
+
+		return nil
+	}
+}
+
+// ingressPaths returns the HTTP paths of the rules.
+func ingressPaths(resource map[string]interface{}) []map[string]interface{} {
+	var paths []map[string]interface{}
+	rules, _ := fieldValue(resource, []string{"spec", "rules"}).([]interface{})
+	for _, rule := range rules {
+		rule, _ := rule.(map[string]interface{})
+		rulePaths, _ := fieldValue(rule, []string{"http", "paths"}).([]interface{})
+		for _, path := range rulePaths {
+			if path, ok := path.(map[string]interface{}); ok {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// ingressBackends returns the default backend (networking/v1 and v1beta1) and the backends of the paths.
+func ingressBackends(resource map[string]interface{}) []map[string]interface{} {
+	var backends []map[string]interface{}
+	for _, field := range []string{"defaultBackend", "backend"} {
+		if backend, ok := fieldValue(resource, []string{"spec", field}).(map[string]interface{}); ok {
+			backends = append(backends, backend)
+		}
+	}
+	for _, path := range ingressPaths(resource) {
+		if backend, ok := path["backend"].(map[string]interface{}); ok {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
+
+func normalizeIngressBackendPort(backend map[string]interface{}) {
+	if port, ok := fieldValue(backend, []string{"service", "port"}).(map[string]interface{}); ok {
+		if number, ok := numericPort(port["name"]); ok {
+			delete(port, "name")
+			port["number"] = number
+		}
+	}
+	if number, ok := numericPort(backend["servicePort"]); ok {
+		backend["servicePort"] = number
+	}
+}
+
+// numericPort returns the port number of port names made of digits.
+func numericPort(value interface{}) (float64, bool) {
+	name, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+	number, err := strconv.ParseUint(name, 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return float64(number), true
+}
@@ -0,0 +1,89 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networkingv1 "k8s.io/api/networking/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNormalizeIngress(t *testing.T) {
+	newIngress := func(pathType *networkingv1.PathType, port networkingv1.ServiceBackendPort) *networkingv1.Ingress {
+		return &networkingv1.Ingress{
+			ObjectMeta: v1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{{
+					Host: "app.example.com",
+					IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+						Paths: []networkingv1.HTTPIngressPath{{
+							Path:     "/",
+							PathType: pathType,
+							Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+								Name: "app",
+								Port: port,
+							}},
+						}},
+					}},
+				}},
+			},
+		}
+	}
+
+	implementationSpecific := networkingv1.PathTypeImplementationSpecific
+	className := "nginx"
+	current := newIngress(&implementationSpecific, networkingv1.ServiceBackendPort{Number: 80})
+	mustAnnotate(current)
+	current.Spec.IngressClassName = &className
+
+	modified := newIngress(nil, networkingv1.ServiceBackendPort{Name: "80"})
+	modified.Annotations = map[string]string{ingressClassAnnotation: className}
+	current.Annotations[ingressClassAnnotation] = className
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizeIngress())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// Named ports and other classes are still compared
+	modified = newIngress(nil, networkingv1.ServiceBackendPort{Name: "http"})
+	modified.Annotations = map[string]string{ingressClassAnnotation: "traefik"}
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizeIngress())
+	assert.NoError(t, err)
+	assert.Contains(t, string(patch.Patch), `"name":"http"`)
+	assert.Contains(t, string(patch.Patch), `"traefik"`)
+}
+
+func TestNormalizeIngressV1beta1(t *testing.T) {
+	current := []byte(`{"apiVersion":"extensions/v1beta1","kind":"Ingress","spec":{"backend":{"serviceName":"app","servicePort":80}}}`)
+	modified := []byte(`{"apiVersion":"extensions/v1beta1","kind":"Ingress","spec":{"backend":{"serviceName":"app","servicePort":"80"}}}`)
+
+	normalizedCurrent, normalizedModified, err := NormalizeIngress()(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(normalizedCurrent), string(normalizedModified))
+
+	// Objects of other kinds are left untouched, whatever the shape of their spec
+	current = []byte(`{"apiVersion":"example.com/v1","kind":"Gateway","spec":{"backend":{"serviceName":"app","servicePort":80}}}`)
+	modified = []byte(`{"apiVersion":"example.com/v1","kind":"Gateway","spec":{"backend":{"serviceName":"app","servicePort":"80"}}}`)
+	normalizedCurrent, normalizedModified, err = NormalizeIngress()(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(current), string(normalizedCurrent))
+	assert.JSONEq(t, string(modified), string(normalizedModified))
+}
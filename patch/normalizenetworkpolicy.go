@@ -0,0 +1,78 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var networkPolicyGroupKind = schema.GroupKind{Group: "networking.k8s.io", Kind: "NetworkPolicy"}
+
+// NormalizeNetworkPolicy makes equal NetworkPolicies compare equal: a missing podSelector of the modified object is set
+// to the empty selector, the protocol of the ingress and egress ports defaults to TCP, and the policyTypes are defaulted
+// like the API server does (Ingress, plus Egress when there are egress rules) and sorted in both objects.
+// Other objects are left untouched.
+func NormalizeNetworkPolicy() CalculateOption {
+	return MapOptions(NormalizeNetworkPolicyMap())
+}
+
+// NormalizeNetworkPolicyMap is the map option of NormalizeNetworkPolicy.
+func NormalizeNetworkPolicyMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		if !hasGroupKind(current, networkPolicyGroupKind) || !hasGroupKind(modified, networkPolicyGroupKind) {
+			return nil
+		}
+
+		if modifiedSpec, ok := modified["spec"].(map[string]interface{}); ok {
+			setDefault(modifiedSpec, "podSelector", map[string]interface{}{})
+			if policyTypes, _ := modifiedSpec["policyTypes"].([]interface{}); len(policyTypes) == 0 {
+				policyTypes = []interface{}{"Ingress"}
+				if egress, _ := modifiedSpec["egress"].([]interface{}); len(egress) > 0 {
+					policyTypes = append(policyTypes, "Egress")
+				}
+				modifiedSpec["policyTypes"] = policyTypes
+			}
+		}
+
+		for _, resource := range []map[string]interface{}{current, modified} {
+			spec, _ := resource["spec"].(map[string]interface{})
+			if spec == nil {
+				continue
+			}
+			for _, direction := range []string{"ingress", "egress"} {
+				rules, _ := spec[direction].([]interface{})
+				for _, rule := range rules {
+					rule, _ := rule.(map[string]interface{})
+					ports, _ := rule["ports"].([]interface{})
+					for _, port := range ports {
+						if port, ok := port.(map[string]interface{}); ok {
+							setDefault(port, "protocol", "TCP")
+						}
+					}
+				}
+			}
+			if policyTypes, ok := spec["policyTypes"].([]interface{}); ok {
+				sort.SliceStable(policyTypes, func(i, j int) bool {
+					return fmt.Sprint(policyTypes[i]) < fmt.Sprint(policyTypes[j])
+				})
+			}
+		}
+
+		return nil
+	}
+}
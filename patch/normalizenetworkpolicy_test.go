@@ -0,0 +1,70 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestNormalizeNetworkPolicy(t *testing.T) {
+	newNetworkPolicy := func(spec map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "networking.k8s.io/v1",
+			"kind":       "NetworkPolicy",
+			"metadata":   map[string]interface{}{"name": "allow", "namespace": "default"},
+			"spec":       spec,
+		}}
+	}
+	rules := func(port map[string]interface{}) []interface{} {
+		return []interface{}{map[string]interface{}{"ports": []interface{}{port}}}
+	}
+
+	// Server defaulted object
+	current := newNetworkPolicy(map[string]interface{}{
+		"podSelector": map[string]interface{}{},
+		"policyTypes": []interface{}{"Ingress", "Egress"},
+		"ingress":     rules(map[string]interface{}{"port": int64(80), "protocol": "TCP"}),
+		"egress":      rules(map[string]interface{}{"port": int64(53), "protocol": "UDP"}),
+	})
+	mustAnnotate(current)
+
+	modified := newNetworkPolicy(map[string]interface{}{
+		"ingress": rules(map[string]interface{}{"port": int64(80)}),
+		"egress":  rules(map[string]interface{}{"port": int64(53), "protocol": "UDP"}),
+	})
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizeNetworkPolicy())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// The order of the policy types doesn't matter
+	modified.Object["spec"].(map[string]interface{})["policyTypes"] = []interface{}{"Egress", "Ingress"}
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizeNetworkPolicy())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// NetworkPolicies of other groups are left untouched
+	calico := []byte(`{"apiVersion":"projectcalico.org/v3","kind":"NetworkPolicy","spec":{"policyTypes":["Egress","Ingress"]}}`)
+	currentCalico, _, err := NormalizeNetworkPolicy()(calico, calico)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(calico), string(currentCalico))
+}
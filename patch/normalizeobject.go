@@ -0,0 +1,50 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Normalize returns the object as Calculate compares it: marshaled, with the options applied and the null fields removed.
+// The options receive the object as both the current and the modified object, with the apiVersion and kind of typed
+// objects (see ApplyOptions), the modified one is returned. The keys of the returned JSON document are sorted, so equal
+// objects give the same bytes, e.g. for hashing, caching or testing.
+func Normalize(obj runtime.Object, opts ...CalculateOption) ([]byte, error) {
+	_, document, err := ApplyOptions(obj, obj, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	document, err = DeleteNullInJsonBytes(document)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to delete null from object")
+	}
+
+	resource := map[string]interface{}{}
+	if err := json.Unmarshal(document, &resource); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal byte sequence")
+	}
+	restoreNulls(resource)
+
+	document, err = json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal byte sequence")
+	}
+
+	return document, nil
+}
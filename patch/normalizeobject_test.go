@@ -0,0 +1,62 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNormalize(t *testing.T) {
+	typed := newNullableApp(map[string]interface{}{"suspend": nil, "replicas": int64(1)})
+	typed.Object["status"] = map[string]interface{}{"ready": true}
+
+	normalized, err := Normalize(typed, IgnoreStatusFields())
+	require.NoError(t, err)
+	assert.Equal(t, `{"apiVersion":"example.com/v1","kind":"App","metadata":{"name":"test","namespace":"default"},"spec":{"replicas":1}}`, string(normalized))
+
+	normalized, err = Normalize(typed, PreserveNullAt(".spec.suspend"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"apiVersion":"example.com/v1","kind":"App","metadata":{"name":"test","namespace":"default"},"spec":{"replicas":1,"suspend":null},"status":{"ready":true}}`, string(normalized))
+
+	// Typed and unstructured objects give the same document
+	deployment := newBenchmarkDeployment()
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(deployment)
+	require.NoError(t, err)
+	typedNormalized, err := Normalize(deployment)
+	require.NoError(t, err)
+	unstructuredNormalized, err := Normalize(&unstructured.Unstructured{Object: content})
+	require.NoError(t, err)
+	assert.Equal(t, string(typedNormalized), string(unstructuredNormalized))
+
+	// The options receive the kind of typed objects, which isn't kept in the document
+	var kind string
+	recordKind := func(current, modified []byte) ([]byte, []byte, error) {
+		kind = json.Get(modified, "kind").ToString()
+		return current, modified, nil
+	}
+	recordedNormalized, err := Normalize(deployment, recordKind)
+	require.NoError(t, err)
+	assert.Equal(t, "Deployment", kind)
+	assert.Equal(t, string(typedNormalized), string(recordedNormalized))
+
+	_, err = Normalize(typed, IgnoreJSONPath(".spec["))
+	require.Error(t, err)
+}
@@ -0,0 +1,164 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import "strings"
+
+// podSpecPaths are the paths of the pod specs of Pods, workloads, Jobs and CronJobs.
+var podSpecPaths = [][]string{
+	{"spec"},
+	{"spec", "template", "spec"},
+	{"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// podSpecDefaults are the pod spec fields defaulted by the API server with their default values.
+var podSpecDefaults = map[string]interface{}{
+	"dnsPolicy":                     "ClusterFirst",
+	"restartPolicy":                 "Always",
+	"schedulerName":                 "default-scheduler",
+	"terminationGracePeriodSeconds": float64(30),
+}
+
+// containerDefaults are the container fields defaulted by the API server with their default values.
+var containerDefaults = map[string]interface{}{
+	"terminationMessagePath":   "/dev/termination-log",
+	"terminationMessagePolicy": "File",
+}
+
+// probeDefaults are the probe fields defaulted by the API server with their default values.
+var probeDefaults = map[string]interface{}{
+	"timeoutSeconds":   float64(1),
+	"periodSeconds":    float64(10),
+	"successThreshold": float64(1),
+	"failureThreshold": float64(3),
+}
+
+// NormalizePodTemplate sets the well-known defaults of the API server missing from the pod spec of the modified
+// object (a Pod, a workload template, a Job or a CronJob job template), so it matches the defaulted current object:
+// dnsPolicy, restartPolicy, schedulerName, an empty securityContext, terminationGracePeriodSeconds, the termination
+// message, image pull policy and port protocol of the containers and the thresholds of their probes.
+// Jobs must set their restartPolicy, so it is never defaulted for them.
+func NormalizePodTemplate() CalculateOption {
+	return MapOptions(NormalizePodTemplateMap())
+}
+
+// NormalizePodTemplateMap is the map option of NormalizePodTemplate.
+func NormalizePodTemplateMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		for _, path := range podSpecPaths {
+			podSpec, ok := fieldValue(modified, path).(map[string]interface{})
+			if !ok || !isPodSpec(podSpec) {
+				continue
+			}
+			setPodSpecDefaults(podSpec, !isJobTemplate(modified, path))
+		}
+		return nil
+	}
+}
+
+// isPodSpec tells whether the object is a pod spec by looking for its containers.
+func isPodSpec(spec map[string]interface{}) bool {
+	_, ok := spec["containers"].([]interface{})
+	return ok
+}
+
+// isJobTemplate tells whether the pod spec at the path belongs to a Job or a CronJob.
+func isJobTemplate(resource map[string]interface{}, path []string) bool {
+	switch {
+	case len(path) == 1:
+		return false
+	case len(path) > 3:
+		return true
+	}
+	switch resource["kind"] {
+	case "Job":
+		return true
+	case nil, "":
+		spec, _ := resource["spec"].(map[string]interface{})
+		for _, field := range []string{"completions", "parallelism", "backoffLimit", "activeDeadlineSeconds", "completionMode"} {
+			if _, ok := spec[field]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func setPodSpecDefaults(spec map[string]interface{}, defaultRestartPolicy bool) {
+	for field, value := range podSpecDefaults {
+		if field == "restartPolicy" && !defaultRestartPolicy {
+			continue
+		}
+		setDefault(spec, field, value)
+	}
+	if _, ok := spec["securityContext"]; !ok {
+		spec["securityContext"] = map[string]interface{}{}
+	}
+
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _ := spec[field].([]interface{})
+		for _, container := range containers {
+			if container, ok := container.(map[string]interface{}); ok {
+				setContainerDefaults(container)
+			}
+		}
+	}
+}
+
+func setContainerDefaults(container map[string]interface{}) {
+	for field, value := range containerDefaults {
+		setDefault(container, field, value)
+	}
+	if image, ok := container["image"].(string); ok {
+		setDefault(container, "imagePullPolicy", defaultImagePullPolicy(image))
+	}
+
+	ports, _ := container["ports"].([]interface{})
+	for _, port := range ports {
+		if port, ok := port.(map[string]interface{}); ok {
+			setDefault(port, "protocol", "TCP")
+		}
+	}
+
+	for _, field := range []string{"livenessProbe", "readinessProbe", "startupProbe"} {
+		if probe, ok := container[field].(map[string]interface{}); ok {
+			for probeField, value := range probeDefaults {
+				setDefault(probe, probeField, value)
+			}
+		}
+	}
+}
+
+// defaultImagePullPolicy returns Always for the latest or untagged images, IfNotPresent otherwise.
+func defaultImagePullPolicy(image string) string {
+	if strings.Contains(image, "@") {
+		return "IfNotPresent"
+	}
+	tag := ""
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		tag = image[i+1:]
+	}
+	if tag == "" || tag == "latest" {
+		return "Always"
+	}
+	return "IfNotPresent"
+}
+
+// setDefault sets the field to the value when it's missing or null.
+func setDefault(object map[string]interface{}, field string, value interface{}) {
+	if object[field] == nil {
+		object[field] = value
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestNormalizePodTemplate(t *testing.T) {
+	newDeployment := func() *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{
+				Selector: &v1.LabelSelector{MatchLabels: map[string]string{"app": "app"}},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: v1.ObjectMeta{Labels: map[string]string{"app": "app"}},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{
+						Name:  "app",
+						Image: "nginx:1.23",
+						Ports: []corev1.ContainerPort{{ContainerPort: 80}},
+						ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+							HTTPGet: &corev1.HTTPGetAction{Path: "/", Port: intstr.FromInt(80)},
+						}},
+					}}},
+				},
+			},
+		}
+	}
+
+	current := newDeployment()
+	mustAnnotate(current)
+	// Defaults set by the API server
+	gracePeriod := int64(30)
+	podSpec := &current.Spec.Template.Spec
+	podSpec.DNSPolicy = corev1.DNSClusterFirst
+	podSpec.RestartPolicy = corev1.RestartPolicyAlways
+	podSpec.SchedulerName = corev1.DefaultSchedulerName
+	podSpec.SecurityContext = &corev1.PodSecurityContext{}
+	podSpec.TerminationGracePeriodSeconds = &gracePeriod
+	container := &podSpec.Containers[0]
+	container.TerminationMessagePath = corev1.TerminationMessagePathDefault
+	container.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+	container.ImagePullPolicy = corev1.PullIfNotPresent
+	container.Ports[0].Protocol = corev1.ProtocolTCP
+	container.ReadinessProbe.TimeoutSeconds = 1
+	container.ReadinessProbe.PeriodSeconds = 10
+	container.ReadinessProbe.SuccessThreshold = 1
+	container.ReadinessProbe.FailureThreshold = 3
+
+	patch, err := DefaultPatchMaker.Calculate(current, newDeployment(), NormalizePodTemplate())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// Custom resources embedding pod templates get JSON merge patches replacing the containers
+	newCustomResource := func(deployment *appsv1.Deployment) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(deployment)
+		assert.NoError(t, err)
+		u.SetUnstructuredContent(content)
+		u.SetAPIVersion("example.com/v1")
+		u.SetKind("App")
+		return u
+	}
+	patch, err = DefaultPatchMaker.Calculate(newCustomResource(current), newCustomResource(newDeployment()))
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(newCustomResource(current), newCustomResource(newDeployment()), NormalizePodTemplate())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// The defaults are the ones of the API server, the current object isn't taken into account
+	current.Spec.Template.Spec.SchedulerName = "custom"
+	patch, err = DefaultPatchMaker.Calculate(current, newDeployment(), NormalizePodTemplate())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"template":{"spec":{"schedulerName":"default-scheduler"}}}}`, string(patch.Patch))
+}
+
+func TestNormalizePodTemplateJob(t *testing.T) {
+	job := []byte(`{"kind":"Job","spec":{"template":{"spec":{"restartPolicy":"Never","containers":[{"name":"job","image":"busybox"}]}}}}`)
+	cronJob := []byte(`{"kind":"CronJob","spec":{"jobTemplate":{"spec":{"template":{"spec":{"containers":[{"name":"job","image":"busybox"}]}}}}}}`)
+
+	_, modified, err := NormalizePodTemplate()(job, job)
+	assert.NoError(t, err)
+	normalizedJob := &batchv1.Job{}
+	assert.NoError(t, json.Unmarshal(modified, normalizedJob))
+	assert.Equal(t, corev1.RestartPolicyNever, normalizedJob.Spec.Template.Spec.RestartPolicy)
+	assert.Equal(t, corev1.PullAlways, normalizedJob.Spec.Template.Spec.Containers[0].ImagePullPolicy)
+
+	_, modified, err = NormalizePodTemplate()(cronJob, cronJob)
+	assert.NoError(t, err)
+	normalizedCronJob := &batchv1.CronJob{}
+	assert.NoError(t, json.Unmarshal(modified, normalizedCronJob))
+	assert.Empty(t, normalizedCronJob.Spec.JobTemplate.Spec.Template.Spec.RestartPolicy)
+	assert.Equal(t, corev1.DNSClusterFirst, normalizedCronJob.Spec.JobTemplate.Spec.Template.Spec.DNSPolicy)
+}
+
+func TestDefaultImagePullPolicy(t *testing.T) {
+	assert.Equal(t, "Always", defaultImagePullPolicy("nginx"))
+	assert.Equal(t, "Always", defaultImagePullPolicy("nginx:latest"))
+	assert.Equal(t, "Always", defaultImagePullPolicy("registry:5000/nginx"))
+	assert.Equal(t, "IfNotPresent", defaultImagePullPolicy("registry:5000/nginx:1.23"))
+	assert.Equal(t, "IfNotPresent", defaultImagePullPolicy("nginx@sha256:abcd"))
+}
@@ -0,0 +1,87 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var pvcGroupKind = schema.GroupKind{Kind: "PersistentVolumeClaim"}
+
+// NormalizePVC removes the fields of PersistentVolumeClaims set by the API server and the volume controllers from
+// the current object when the modified object doesn't set them: the bound volumeName, the default storageClassName,
+// the default Filesystem volumeMode and the dataSource or dataSourceRef mirrored from the other one. The status is
+// removed from both objects. Other objects are left untouched.
+func NormalizePVC() CalculateOption {
+	return MapOptions(NormalizePVCMap())
+}
+
+// NormalizePVCMap is the map option of NormalizePVC.
+func NormalizePVCMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		if !hasGroupKind(current, pvcGroupKind) || !hasGroupKind(modified, pvcGroupKind) {
+			return nil
+		}
+
+		delete(current, "status")
+		delete(modified, "status")
+
+		currentSpec, _ := current["spec"].(map[string]interface{})
+		modifiedSpec, _ := modified["spec"].(map[string]interface{})
+		if currentSpec == nil {
+			return nil
+		}
+
+		for _, field := range []string{"volumeName", "storageClassName"} {
+			if modifiedSpec[field] == nil {
+				delete(currentSpec, field)
+			}
+		}
+		deleteDefaultedFields(currentSpec, modifiedSpec, map[string]interface{}{"volumeMode": "Filesystem"})
+
+		// The API server mirrors dataSource and dataSourceRef when only one of them is set
+		for _, fields := range [][2]string{{"dataSource", "dataSourceRef"}, {"dataSourceRef", "dataSource"}} {
+			field, mirror := fields[0], fields[1]
+			if modifiedSpec[field] != nil || modifiedSpec[mirror] == nil {
+				continue
+			}
+			if sameDataSource(currentSpec[field], currentSpec[mirror]) {
+				delete(currentSpec, field)
+			}
+		}
+
+		return nil
+	}
+}
+
+// sameDataSource tells whether the data sources reference the same object, dataSourceRef can have a namespace.
+func sameDataSource(a, b interface{}) bool {
+	typedA, ok := a.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	typedB, ok := b.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, field := range []string{"apiGroup", "kind", "name"} {
+		if !reflect.DeepEqual(typedA[field], typedB[field]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNormalizePVC(t *testing.T) {
+	newPVC := func() *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: v1.ObjectMeta{Name: "data", Namespace: "default"},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+				},
+				DataSource: &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: "source"},
+			},
+		}
+	}
+
+	current := newPVC()
+	mustAnnotate(current)
+	// Fields set by the API server and the volume controllers
+	storageClassName := "standard"
+	volumeMode := corev1.PersistentVolumeFilesystem
+	current.Spec.VolumeName = "pvc-1234"
+	current.Spec.StorageClassName = &storageClassName
+	current.Spec.VolumeMode = &volumeMode
+	current.Spec.DataSourceRef = &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: "source"}
+	current.Status = corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound}
+
+	patch, err := DefaultPatchMaker.Calculate(current, newPVC(), NormalizePVC())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+	assert.JSONEq(t, `{"accessModes":["ReadWriteOnce"],"dataSource":{"kind":"PersistentVolumeClaim","name":"source"},"resources":{"requests":{"storage":"1Gi"}}}`,
+		string(mustMarshalField(t, patch.Current, "spec")))
+
+	// Explicit values are still compared
+	modified := newPVC()
+	otherStorageClassName := "fast"
+	modified.Spec.StorageClassName = &otherStorageClassName
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizePVC())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"storageClassName":"fast"}}`, string(patch.Patch))
+
+	// Objects of other kinds with a similar spec are left untouched
+	volume := []byte(`{"apiVersion":"example.com/v1","kind":"Volume","spec":{"accessModes":["ReadWriteOnce"],"resources":{},"volumeName":"pv-1"},"status":{}}`)
+	currentVolume, _, err := NormalizePVC()(volume, []byte(`{"apiVersion":"example.com/v1","kind":"Volume","spec":{"accessModes":["ReadWriteOnce"],"resources":{}}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(volume), string(currentVolume))
+}
+
+func mustMarshalField(t *testing.T, document []byte, field string) []byte {
+	resource := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(document, &resource))
+	value, err := json.Marshal(resource[field])
+	assert.NoError(t, err)
+	return value
+}
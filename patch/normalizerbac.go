@@ -0,0 +1,112 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const rbacAPIGroup = "rbac.authorization.k8s.io"
+
+var (
+	rbacRoleGroupKinds = []schema.GroupKind{
+		{Group: rbacAPIGroup, Kind: "Role"},
+		{Group: rbacAPIGroup, Kind: "ClusterRole"},
+	}
+	rbacBindingGroupKinds = []schema.GroupKind{
+		{Group: rbacAPIGroup, Kind: "RoleBinding"},
+		{Group: rbacAPIGroup, Kind: "ClusterRoleBinding"},
+	}
+)
+
+// policyRuleLists are the lists of the policy rules whose order doesn't matter.
+var policyRuleLists = []string{"apiGroups", "resources", "resourceNames", "verbs", "nonResourceURLs"}
+
+// NormalizeRBAC makes semantically equal RBAC objects compare equal: the verbs, resources, apiGroups, resourceNames and
+// nonResourceURLs of the rules of Roles and ClusterRoles are sorted, and the subjects of RoleBindings and ClusterRoleBindings
+// are deduplicated, with the apiGroup of ServiceAccount subjects removed when empty and the apiGroup of User and Group subjects
+// defaulted to rbac.authorization.k8s.io. Other objects are left untouched.
+func NormalizeRBAC() CalculateOption {
+	return MapOptions(NormalizeRBACMap())
+}
+
+// NormalizeRBACMap is the map option of NormalizeRBAC.
+func NormalizeRBACMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		for _, resource := range []map[string]interface{}{current, modified} {
+			switch {
+			case hasGroupKind(resource, rbacRoleGroupKinds...):
+				rules, _ := resource["rules"].([]interface{})
+				for _, rule := range rules {
+					if rule, ok := rule.(map[string]interface{}); ok {
+						sortPolicyRule(rule)
+					}
+				}
+			case hasGroupKind(resource, rbacBindingGroupKinds...):
+				if subjects, ok := resource["subjects"].([]interface{}); ok {
+					resource["subjects"] = normalizeSubjects(subjects)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+func sortPolicyRule(rule map[string]interface{}) {
+	for _, field := range policyRuleLists {
+		values, ok := rule[field].([]interface{})
+		if !ok {
+			continue
+		}
+		sort.SliceStable(values, func(i, j int) bool {
+			return fmt.Sprint(values[i]) < fmt.Sprint(values[j])
+		})
+	}
+}
+
+// normalizeSubjects defaults the apiGroup of the subjects and removes the duplicates, keeping the first occurrence.
+func normalizeSubjects(subjects []interface{}) []interface{} {
+	normalized := make([]interface{}, 0, len(subjects))
+	seen := map[string]bool{}
+	for _, subject := range subjects {
+		typedSubject, ok := subject.(map[string]interface{})
+		if !ok {
+			normalized = append(normalized, subject)
+			continue
+		}
+
+		switch typedSubject["kind"] {
+		case "ServiceAccount":
+			if typedSubject["apiGroup"] == "" {
+				delete(typedSubject, "apiGroup")
+			}
+		case "User", "Group":
+			if apiGroup, _ := typedSubject["apiGroup"].(string); apiGroup == "" {
+				typedSubject["apiGroup"] = rbacAPIGroup
+			}
+		}
+
+		key := fmt.Sprint(typedSubject["kind"], "/", typedSubject["apiGroup"], "/", typedSubject["namespace"], "/", typedSubject["name"])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, typedSubject)
+	}
+	return normalized
+}
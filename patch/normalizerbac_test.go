@@ -0,0 +1,88 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNormalizeRBACRole(t *testing.T) {
+	newRole := func(verbs, resources []string) *rbacv1.ClusterRole {
+		return &rbacv1.ClusterRole{
+			ObjectMeta: v1.ObjectMeta{Name: "reader"},
+			Rules: []rbacv1.PolicyRule{{
+				APIGroups: []string{"apps", ""},
+				Resources: resources,
+				Verbs:     verbs,
+			}},
+		}
+	}
+
+	current := newRole([]string{"get", "list", "watch"}, []string{"pods", "deployments"})
+	mustAnnotate(current)
+	modified := newRole([]string{"watch", "get", "list"}, []string{"deployments", "pods"})
+	modified.Rules[0].APIGroups = []string{"", "apps"}
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizeRBAC())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// Other verbs are still compared
+	patch, err = DefaultPatchMaker.Calculate(current, newRole([]string{"get"}, []string{"pods", "deployments"}), NormalizeRBAC())
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+}
+
+func TestNormalizeRBACBinding(t *testing.T) {
+	newBinding := func(subjects ...rbacv1.Subject) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: v1.ObjectMeta{Name: "reader", Namespace: "default"},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacAPIGroup, Kind: "ClusterRole", Name: "reader"},
+			Subjects:   subjects,
+		}
+	}
+
+	serviceAccount := rbacv1.Subject{Kind: "ServiceAccount", Name: "app", Namespace: "default"}
+	user := rbacv1.Subject{Kind: "User", Name: "jane", APIGroup: rbacAPIGroup}
+	userWithoutGroup := rbacv1.Subject{Kind: "User", Name: "jane"}
+
+	current := newBinding(serviceAccount, user)
+	mustAnnotate(current)
+	modified := newBinding(serviceAccount, userWithoutGroup, serviceAccount)
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizeRBAC())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+}
+
+func TestNormalizeRBACOtherKinds(t *testing.T) {
+	// Custom resources with rules or a roleRef are left untouched
+	current := []byte(`{"apiVersion":"example.com/v1","kind":"Policy","rules":[{"verbs":["list","get"]}],"roleRef":{"name":"reader"},"subjects":[{"kind":"User","name":"jane"}]}`)
+	normalizedCurrent, _, err := NormalizeRBAC()(current, current)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(current), string(normalizedCurrent))
+}
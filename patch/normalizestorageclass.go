@@ -0,0 +1,84 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	storageClassGroupKind        = schema.GroupKind{Group: "storage.k8s.io", Kind: "StorageClass"}
+	volumeSnapshotClassGroupKind = schema.GroupKind{Group: "snapshot.storage.k8s.io", Kind: "VolumeSnapshotClass"}
+)
+
+// defaultClassAnnotations maps the legacy default class annotations to the current ones.
+var defaultClassAnnotations = map[string]string{
+	"storageclass.beta.kubernetes.io/is-default-class": "storageclass.kubernetes.io/is-default-class",
+}
+
+// storageClassDefaults are the StorageClass fields defaulted by the API server with their default values.
+var storageClassDefaults = map[string]interface{}{
+	"reclaimPolicy":     "Delete",
+	"volumeBindingMode": "Immediate",
+}
+
+// NormalizeStorageClasses normalizes cluster-scoped storage classes: the defaulted reclaimPolicy and volumeBindingMode
+// of StorageClasses are removed from the current object when the modified object doesn't set them, allowVolumeExpansion
+// set to false is removed from both objects as it's the same as unset, and the default class markers of StorageClasses
+// and VolumeSnapshotClasses are compared as booleans, the legacy beta annotation being treated as the current one.
+// Other objects are left untouched.
+func NormalizeStorageClasses() CalculateOption {
+	return MapOptions(NormalizeStorageClassesMap())
+}
+
+// NormalizeStorageClassesMap is the map option of NormalizeStorageClasses.
+func NormalizeStorageClassesMap() CalculateMapOption {
+	return func(current, modified map[string]interface{}) error {
+		switch {
+		case hasGroupKind(current, storageClassGroupKind) && hasGroupKind(modified, storageClassGroupKind):
+			deleteDefaultedFields(current, modified, storageClassDefaults)
+			for _, resource := range []map[string]interface{}{current, modified} {
+				if resource["allowVolumeExpansion"] == false {
+					delete(resource, "allowVolumeExpansion")
+				}
+			}
+		case hasGroupKind(current, volumeSnapshotClassGroupKind) && hasGroupKind(modified, volumeSnapshotClassGroupKind):
+		default:
+			return nil
+		}
+
+		for _, resource := range []map[string]interface{}{current, modified} {
+			annotations, ok := fieldValue(resource, []string{"metadata", "annotations"}).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for legacy, annotation := range defaultClassAnnotations {
+				if value, ok := annotations[legacy]; ok {
+					delete(annotations, legacy)
+					setDefault(annotations, annotation, value)
+				}
+			}
+			for _, annotation := range []string{"storageclass.kubernetes.io/is-default-class", "snapshot.storage.kubernetes.io/is-default-class"} {
+				if value, ok := annotations[annotation].(string); ok {
+					annotations[annotation] = strings.ToLower(value)
+				}
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNormalizeStorageClasses(t *testing.T) {
+	newStorageClass := func(annotations map[string]string) *storagev1.StorageClass {
+		return &storagev1.StorageClass{
+			ObjectMeta:  v1.ObjectMeta{Name: "standard", Annotations: annotations},
+			Provisioner: "ebs.csi.aws.com",
+			Parameters:  map[string]string{"type": "gp3"},
+		}
+	}
+
+	current := newStorageClass(map[string]string{"storageclass.kubernetes.io/is-default-class": "true"})
+	mustAnnotate(current)
+	// Defaults set by the API server
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	bindingMode := storagev1.VolumeBindingImmediate
+	allowVolumeExpansion := false
+	current.ReclaimPolicy = &reclaimPolicy
+	current.VolumeBindingMode = &bindingMode
+	current.AllowVolumeExpansion = &allowVolumeExpansion
+
+	modified := newStorageClass(map[string]string{"storageclass.beta.kubernetes.io/is-default-class": "True"})
+	modified.AllowVolumeExpansion = &allowVolumeExpansion
+
+	patch, err := DefaultPatchMaker.Calculate(current, modified, NormalizeStorageClasses())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty(), string(patch.Patch))
+
+	// Other values are still compared
+	retain := corev1.PersistentVolumeReclaimRetain
+	modified.ReclaimPolicy = &retain
+	patch, err = DefaultPatchMaker.Calculate(current, modified, NormalizeStorageClasses())
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"reclaimPolicy":"Retain"}`, string(patch.Patch))
+}
+
+func TestNormalizeVolumeSnapshotClasses(t *testing.T) {
+	current := []byte(`{"apiVersion":"snapshot.storage.k8s.io/v1","kind":"VolumeSnapshotClass","metadata":{"annotations":{"snapshot.storage.kubernetes.io/is-default-class":"TRUE"}},"driver":"ebs.csi.aws.com","deletionPolicy":"Delete"}`)
+	modified := []byte(`{"apiVersion":"snapshot.storage.k8s.io/v1","kind":"VolumeSnapshotClass","metadata":{"annotations":{"snapshot.storage.kubernetes.io/is-default-class":"true"}},"driver":"ebs.csi.aws.com","deletionPolicy":"Delete"}`)
+
+	normalizedCurrent, normalizedModified, err := NormalizeStorageClasses()(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(normalizedCurrent), string(normalizedModified))
+
+	// Objects of other kinds with a driver and a deletionPolicy are left untouched
+	current = bytes.ReplaceAll(current, []byte(`"kind":"VolumeSnapshotClass"`), []byte(`"kind":"VolumeGroupSnapshotClass"`))
+	modified = bytes.ReplaceAll(modified, []byte(`"kind":"VolumeSnapshotClass"`), []byte(`"kind":"VolumeGroupSnapshotClass"`))
+	normalizedCurrent, _, err = NormalizeStorageClasses()(current, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(current), string(normalizedCurrent))
+}
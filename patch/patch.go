@@ -15,13 +15,17 @@
 package patch
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 
 	"emperror.dev/errors"
-	json "github.com/json-iterator/go"
+	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 )
 
 var DefaultPatchMaker = NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{})
@@ -30,121 +34,427 @@ type Maker interface {
 	Calculate(currentObject, modifiedObject runtime.Object, opts ...CalculateOption) (*PatchResult, error)
 }
 
+// The PatchMaker returned by NewPatchMaker implements the following interfaces as well, assert the Maker to use them,
+// e.g. patch.DefaultPatchMaker.(patch.CtxMaker).
+
+// CtxMaker calculates patches with options receiving the objects being compared. CalculateWithContext passes the
+// context of the caller to the calls to the API server, e.g. to read the original configuration from a ConfigMapStore.
+type CtxMaker interface {
+	CalculateCtx(currentObject, modifiedObject runtime.Object, opts ...CalculateOptionCtx) (*PatchResult, error)
+	CalculateWithContext(ctx context.Context, currentObject, modifiedObject runtime.Object, opts ...CalculateOptionCtx) (*PatchResult, error)
+}
+
+// BatchMaker calculates the patches of several pairs of objects concurrently, see CalculateAll.
+type BatchMaker interface {
+	CalculateAll(pairs []ObjectPair, opts ...CalculateOption) ([]*PatchResult, error)
+}
+
+// MetadataMaker compares only the labels, annotations, owner references and finalizers.
+type MetadataMaker interface {
+	CalculateMetadataOnly(currentObject, modifiedObject runtime.Object) (*PatchResult, error)
+}
+
+// SubresourceMaker compares only the status or the replicas, for the status and scale subresources.
+type SubresourceMaker interface {
+	CalculateStatus(currentObject, modifiedObject runtime.Object) (*PatchResult, error)
+	CalculateScale(currentObject, modifiedObject runtime.Object) (*PatchResult, error)
+}
+
+// YAMLMaker calculates patches of objects given as manifests.
+type YAMLMaker interface {
+	CalculateFromYAML(currentYAML, modifiedYAML []byte, gvk schema.GroupVersionKind, opts ...CalculateOption) (*PatchResult, error)
+}
+
+var (
+	_ CtxMaker         = &PatchMaker{}
+	_ BatchMaker       = &PatchMaker{}
+	_ MetadataMaker    = &PatchMaker{}
+	_ SubresourceMaker = &PatchMaker{}
+	_ YAMLMaker        = &PatchMaker{}
+)
+
 type PatchMaker struct {
-	annotator *Annotator
+	annotator   *Annotator
+	lastApplied LastAppliedAnnotator
+	store       OriginalConfigurationStore
 
 	strategicMergePatcher StrategicMergePatcher
 	jsonMergePatcher      JSONMergePatcher
+	applyPatcher          *ServerSideApplyPatcher
+	defaultingScheme      *runtime.Scheme
+	conversionScheme      *runtime.Scheme
+	unstructuredConverter UnstructuredConverter
+	schemaSource          SchemaSource
+	concurrency           int
+	hashData              bool
+	redactionPaths        []string
+	logger                logr.Logger
+	recorder              PatchRecorder
+	failOnRecorderError   bool
+	eventRecorder         record.EventRecorder
+	missingOriginalPolicy MissingOriginalPolicy
+	skipAnnotatePatched   bool
+	cache                 *resultCache
+	ignorePathsAnnotation string
+	managedAnnotation     string
+	statusInMainResource  bool
+	jsonMergeKinds        map[schema.GroupVersionKind]bool
+	kindOptions           map[schema.GroupVersionKind][]CalculateOption
+	keepNullFields        bool
+	jsonConfig            JSONConfig
+	dryRunClient          PatchClient
 }
 
-func NewPatchMaker(annotator *Annotator, strategicMergePatcher StrategicMergePatcher, jsonMergePatcher JSONMergePatcher) Maker {
-	return &PatchMaker{
+// PatchMakerOption customizes the behaviour of a PatchMaker created with NewPatchMaker.
+type PatchMakerOption func(*PatchMaker)
+
+// WithoutAnnotatingPatched leaves the last-applied annotation of the Patched object as it is on the current object,
+// for callers managing the annotation separately or using server-side apply. The annotator is still used to read
+// the original configuration.
+func WithoutAnnotatingPatched() PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.skipAnnotatePatched = true
+	}
+}
+
+// NewPatchMaker creates a PatchMaker reading the original configuration from the annotator and setting it on the
+// patched objects. With a nil annotator the patched objects are not annotated, and the original configuration is only
+// read from the store set by WithOriginalConfigurationStore.
+func NewPatchMaker(annotator *Annotator, strategicMergePatcher StrategicMergePatcher, jsonMergePatcher JSONMergePatcher, opts ...PatchMakerOption) Maker {
+	p := &PatchMaker{
 		annotator: annotator,
 
 		strategicMergePatcher: strategicMergePatcher,
 		jsonMergePatcher:      jsonMergePatcher,
+		logger:                logr.Discard(),
+		jsonConfig:            JSONIterConfig,
+	}
+	if annotator != nil {
+		p.lastApplied = annotator
+		p.store = annotator
 	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
 }
 
 func (p *PatchMaker) Calculate(currentObject, modifiedObject runtime.Object, opts ...CalculateOption) (*PatchResult, error) {
+	ctxOpts := make([]CalculateOptionCtx, 0, len(opts))
+	for _, opt := range opts {
+		ctxOpts = append(ctxOpts, WithoutContext(opt))
+	}
+
+	return p.CalculateCtx(currentObject, modifiedObject, ctxOpts...)
+}
+
+func (p *PatchMaker) CalculateCtx(currentObject, modifiedObject runtime.Object, opts ...CalculateOptionCtx) (*PatchResult, error) {
+	return p.CalculateWithContext(context.Background(), currentObject, modifiedObject, opts...)
+}
 
-	current, err := json.ConfigCompatibleWithStandardLibrary.Marshal(currentObject)
+func (p *PatchMaker) CalculateWithContext(ctx context.Context, currentObject, modifiedObject runtime.Object, opts ...CalculateOptionCtx) (*PatchResult, error) {
+	modifiedObject, err := p.converted(currentObject, modifiedObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert modified object to the version of current object")
+	}
+	calculateContext := p.newCalculateContext(ctx, currentObject, modifiedObject)
+
+	unmanaged, err := p.unmanaged(currentObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to check whether the object is managed")
+	}
+	if unmanaged {
+		return p.skippedResult(calculateContext, currentObject)
+	}
+
+	// The options given by the caller are not part of the key, the kind options and the ignored paths annotation are
+	// covered by the kind and the resourceVersion of the current object.
+	var cacheKey resultCacheKey
+	cacheable := false
+	if p.cache != nil && len(opts) == 0 {
+		var err error
+		cacheKey, cacheable, err = p.resultCacheKey(calculateContext)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to compute result cache key")
+		}
+		if cacheable {
+			if result, ok := p.cache.get(cacheKey); ok {
+				if err := p.recordPatch(calculateContext, result); err != nil {
+					return nil, errors.Wrap(err, "Failed to record the patch")
+				}
+				p.emitDriftEvent(calculateContext, result)
+				return result, nil
+			}
+		}
+	}
+
+	opts = p.withKindOptions(calculateContext.GVK, opts)
+	ignoreOpt, err := p.annotatedIgnoreOption(currentObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read ignored paths")
+	}
+	if ignoreOpt != nil {
+		opts = append(opts[:len(opts):len(opts)], ignoreOpt)
+	}
+	if p.keepNullFields {
+		opts = append(opts[:len(opts):len(opts)], WithoutContext(KeepNullFields()))
+	}
+
+	result, err := p.calculate(calculateContext, currentObject, modifiedObject, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonicalResult(result); err != nil {
+		return nil, errors.Wrap(err, "Failed to sort the keys of the result documents")
+	}
+
+	if err := setImmutableChanges(result, calculateContext.GVK.GroupKind()); err != nil {
+		return nil, errors.Wrap(err, "Failed to detect immutable field changes")
+	}
+	result.modifiedObject = modifiedObject
+	result.annotator = p.lastApplied
+	result.redactionPaths, err = p.redactionPathsFor(calculateContext.GVK)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.validateWithDryRun(calculateContext, result); err != nil {
+		return nil, err
+	}
+	p.logResult(calculateContext, result)
+	if cacheable {
+		p.cache.add(cacheKey, result)
+	}
+	if err := p.recordPatch(calculateContext, result); err != nil {
+		return nil, errors.Wrap(err, "Failed to record the patch")
+	}
+	p.emitDriftEvent(calculateContext, result)
+
+	return result, nil
+}
+
+func (p *PatchMaker) calculate(calculateContext CalculateContext, currentObject, modifiedObject runtime.Object, opts []CalculateOptionCtx) (*PatchResult, error) {
+	current, err := p.jsonConfig.Marshal(currentObject)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to convert current object to byte sequence")
 	}
-	currentOrg := make([]byte, len(current))
-	copy(currentOrg, current)
+	// The options may modify the document in place, keep the current object as submitted. The other steps always
+	// produce new documents.
+	currentOrg := current
+	if len(opts) > 0 {
+		currentOrg = make([]byte, len(current))
+		copy(currentOrg, current)
+	}
 
-	modified, err := json.ConfigCompatibleWithStandardLibrary.Marshal(modifiedObject)
+	if p.annotator != nil {
+		matches, err := p.annotator.matchesLastApplied(currentObject, modifiedObject)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to compare the last-applied hash")
+		}
+		if matches {
+			patched, err := newObjectFromJSON(p.jsonConfig, currentObject, currentOrg)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to create patched object")
+			}
+			return &PatchResult{
+				Patch:   []byte("{}"),
+				Current: current,
+				Patched: patched,
+
+				currentOrg:     currentOrg,
+				patchedCurrent: currentOrg,
+				patchType:      p.patchTypeFor(calculateContext.GVK, currentObject),
+			}, nil
+		}
+	}
+
+	modified, err := p.jsonConfig.Marshal(p.defaulted(modifiedObject))
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to convert current object to byte sequence")
 	}
 
-	for _, opt := range opts {
-		current, modified, err = opt(current, modified)
+	// The status is updated through the status subresource
+	current, err = p.withoutSubresources(current)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to delete subresources from current object")
+	}
+	modified, err = p.withoutSubresources(modified)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to delete subresources from modified object")
+	}
+
+	// The options receive the kind of typed objects, so the kind specific ones recognize them
+	if len(opts) > 0 {
+		current, modified, err = applyWithTypeMeta(calculateContext.GVK, calculateContext.GVK, current, modified, func(current, modified []byte) ([]byte, []byte, error) {
+			var err error
+			for _, opt := range opts {
+				current, modified, err = opt(calculateContext, current, modified)
+				if err != nil {
+					return nil, nil, errors.Wrap(err, "Failed to apply option function")
+				}
+			}
+			return current, modified, nil
+		})
 		if err != nil {
-			return nil, errors.Wrap(err, "Failed to apply option function")
+			return nil, err
 		}
 	}
 
-	current, _, err = DeleteNullInJson(current)
+	current, err = DeleteNullInJsonBytes(current)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to delete null from current object")
 	}
 
-	modified, _, err = DeleteNullInJson(modified)
+	modified, err = DeleteNullInJsonBytes(modified)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to delete null from modified object")
 	}
+	modified, err = restoreKeptNulls(modified)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to restore kept null in modified object")
+	}
+
+	var hasher *dataHasher
+	if p.hashData {
+		hasher = newDataHasher(calculateContext.GVK)
+	}
+	if hasher != nil {
+		current, err = hasher.hash(current, false)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to hash data of current object")
+		}
+		modified, err = hasher.hash(modified, true)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to hash data of modified object")
+		}
+	}
 
-	original, err := p.annotator.GetOriginalConfiguration(currentObject)
+	if p.applyPatcher != nil {
+		return p.calculateApply(currentObject, modifiedObject, current, modified, currentOrg)
+	}
+
+	original, err := p.originalConfiguration(calculateContext.callerContext(), currentObject, current)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to get original configuration")
 	}
+	original, err = p.withoutSubresources(original)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to delete subresources from original configuration")
+	}
+	if hasher != nil {
+		original, err = hasher.hash(original, false)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to hash data of original configuration")
+		}
+	}
 
 	var patch []byte
+	var threeWayPatch []byte
 	var patched any
 	var patchedCurrent []byte
+	var jsonMergeFallback bool
+	patchType := types.MergePatchType
 
 	switch currentObject.(type) {
 	default:
-		patch, err = p.strategicMergePatcher.CreateThreeWayMergePatch(original, modified, current, currentObject)
-		if err != nil {
-			return nil, errors.Wrap(err, "Failed to generate strategic merge patch")
-		}
-
-		// $setElementOrder can make it hard to decide whether there is an actual diff or not.
-		// In cases like that trying to apply the patch locally on current will make it clear.
-		if string(patch) != "{}" {
-			patchCurrent, err := p.strategicMergePatcher.StrategicMergePatch(current, patch, currentObject)
+		useJSONMerge := p.jsonMergeKinds[calculateContext.GVK]
+		if !useJSONMerge {
+			patch, err = p.strategicMergePatcher.CreateThreeWayMergePatch(original, modified, current, currentObject)
 			if err != nil {
-				return nil, errors.Wrap(err, "Failed to apply patch")
+				if !isUnsupportedByStrategicMerge(err) {
+					return nil, errors.Wrap(err, "Failed to generate strategic merge patch")
+				}
+				p.logger.V(debugLevel).Info("falling back to JSON merge patch", "gvk", calculateContext.GVK.String(), "reason", err.Error())
+				jsonMergeFallback = true
+				useJSONMerge = true
 			}
+		}
 
-			patch, err = p.strategicMergePatcher.CreateTwoWayMergePatch(current, patchCurrent, currentObject)
+		if useJSONMerge {
+			patch, patchedCurrent, err = p.unstructuredJsonMergePatch(original, modified, current, currentOrg)
 			if err != nil {
-				return nil, errors.Wrap(err, "Failed to create patch again to check for an actual diff")
+				return nil, errors.Wrap(err, "Failed to generate merge patch")
 			}
-
-			patchedCurrent, err = p.strategicMergePatcher.StrategicMergePatch(currentOrg, patch, currentObject)
-			if err != nil {
-				return nil, errors.Wrap(err, "Failed to apply patch")
+			if hasher != nil && string(patch) != "{}" {
+				patch, patchedCurrent, err = p.restoreHashedData(hasher, patch, currentOrg)
+				if err != nil {
+					return nil, errors.Wrap(err, "Failed to restore hashed data in patch")
+				}
 			}
 		} else {
-			patchedCurrent = currentOrg
+			patchType = types.StrategicMergePatchType
+			threeWayPatch = patch
+
+			// $setElementOrder can make it hard to decide whether there is an actual diff or not.
+			// In cases like that trying to apply the patch locally on current will make it clear.
+			if string(patch) != "{}" {
+				patchCurrent, err := p.strategicMergePatcher.StrategicMergePatch(current, patch, currentObject)
+				if err != nil {
+					return nil, errors.Wrap(err, "Failed to apply patch")
+				}
+
+				patch, err = p.strategicMergePatcher.CreateTwoWayMergePatch(current, patchCurrent, currentObject)
+				if err != nil {
+					return nil, errors.Wrap(err, "Failed to create patch again to check for an actual diff")
+				}
+
+				patch, err = hasher.restore(patch)
+				if err != nil {
+					return nil, errors.Wrap(err, "Failed to restore hashed data in patch")
+				}
+
+				patchedCurrent, err = p.strategicMergePatcher.StrategicMergePatch(currentOrg, patch, currentObject)
+				if err != nil {
+					return nil, errors.Wrap(err, "Failed to apply patch")
+				}
+			} else {
+				patchedCurrent = currentOrg
+			}
 		}
 
-		switch reflect.ValueOf(currentObject).Kind() {
-		case reflect.Ptr:
-			patched = reflect.New(reflect.ValueOf(currentObject).Elem().Type()).Interface()
-			if err = json.Unmarshal(patchedCurrent, patched); err != nil {
-				return nil, errors.Wrap(err, "Failed to create patched object")
-			}
-		case reflect.Struct:
-			patched = reflect.New(reflect.ValueOf(currentObject).Type()).Interface()
-			if err = json.Unmarshal(patchedCurrent, patched); err != nil {
-				return nil, errors.Wrap(err, "Failed to create patched object")
-			}
-		default:
-			panic(fmt.Sprintf("Unknow type: %s", reflect.ValueOf(currentObject).Kind()))
+		patched, err = newObjectFromJSON(p.jsonConfig, currentObject, patchedCurrent)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create patched object")
 		}
-		if err := DefaultAnnotator.SetLastAppliedAnnotationToObject(patched.(runtime.Object), modifiedObject); err != nil {
-			return nil, errors.Wrap(err, "Failed to annotate patched object")
+		if p.lastApplied != nil && !p.skipAnnotatePatched {
+			if err := p.lastApplied.SetLastAppliedAnnotationToObject(patched.(runtime.Object), modifiedObject); err != nil {
+				return nil, errors.Wrap(err, "Failed to annotate patched object")
+			}
 		}
 	case *unstructured.Unstructured:
-		var patchCurrent []byte
-		patch, patchCurrent, err = p.unstructuredJsonMergePatch(original, modified, current, currentOrg)
+		objectSchema, err := p.lookupSchema(currentObject)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to lookup object schema")
+		}
+
+		if lookupPatchMeta, ok := p.registeredPatchMeta(calculateContext.GVK); ok {
+			patch, patchedCurrent, err = p.unstructuredPatchMetaMergePatch(lookupPatchMeta, original, modified, current, currentOrg)
+		} else if objectSchema != nil {
+			patch, patchedCurrent, err = p.unstructuredSchemaMergePatch(objectSchema, original, modified, current, currentOrg)
+		} else {
+			patch, patchedCurrent, err = p.unstructuredJsonMergePatch(original, modified, current, currentOrg)
+		}
 		if err != nil {
 			return nil, errors.Wrap(err, "Failed to generate merge patch")
 		}
 
-		patched = reflect.New(reflect.ValueOf(currentObject).Elem().Type()).Interface()
-		if err = json.Unmarshal(patchCurrent, patched); err != nil {
+		if hasher != nil && string(patch) != "{}" {
+			patch, patchedCurrent, err = p.restoreHashedData(hasher, patch, currentOrg)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to restore hashed data in patch")
+			}
+		}
+
+		patched, err = newObjectFromJSON(p.jsonConfig, currentObject, patchedCurrent)
+		if err != nil {
 			return nil, errors.Wrap(err, "Failed to create patched object")
 		}
 
-		if err := DefaultAnnotator.SetLastAppliedAnnotationToObject(patched.(runtime.Object), modifiedObject); err != nil {
-			return nil, errors.Wrap(err, "Failed to annotate patched object")
+		if p.lastApplied != nil && !p.skipAnnotatePatched {
+			if err := p.lastApplied.SetLastAppliedAnnotationToObject(patched.(runtime.Object), modifiedObject); err != nil {
+				return nil, errors.Wrap(err, "Failed to annotate patched object")
+			}
 		}
 	}
 
@@ -154,11 +464,38 @@ func (p *PatchMaker) Calculate(currentObject, modifiedObject runtime.Object, opt
 		Modified: modified,
 		Original: original,
 		Patched:  patched,
+
+		currentOrg:     currentOrg,
+		patchedCurrent: patchedCurrent,
+		threeWayPatch:  threeWayPatch,
+		patchType:      patchType,
+
+		JSONMergeFallback: jsonMergeFallback,
 	}, nil
 }
 
+// newObjectFromJSON decodes data with the config into a new object of the same type as obj.
+func newObjectFromJSON(config JSONConfig, obj runtime.Object, data []byte) (any, error) {
+	var newObject any
+
+	switch reflect.ValueOf(obj).Kind() {
+	case reflect.Ptr:
+		newObject = reflect.New(reflect.ValueOf(obj).Elem().Type()).Interface()
+	case reflect.Struct:
+		newObject = reflect.New(reflect.ValueOf(obj).Type()).Interface()
+	default:
+		panic(fmt.Sprintf("Unknow type: %s", reflect.ValueOf(obj).Kind()))
+	}
+
+	if err := config.Unmarshal(data, newObject); err != nil {
+		return nil, err
+	}
+
+	return newObject, nil
+}
+
 func (p *PatchMaker) unstructuredJsonMergePatch(original, modified, current, currentOrg []byte) ([]byte, []byte, error) {
-	
+
 	patch, err := p.jsonMergePatcher.CreateThreeWayJSONMergePatch(original, modified, current)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "Failed to generate merge patch")
@@ -195,12 +532,67 @@ type PatchResult struct {
 	Modified []byte
 	Original []byte
 	Patched  any
+
+	// FieldManager and Force are set when the patch is a server-side apply configuration
+	// and must be sent with the matching apply options.
+	FieldManager string
+	Force        bool
+
+	// RequiresRecreate is set when the patch changes immutable fields, the object must be deleted and created again
+	// instead of being patched. ImmutableChanges lists these changes.
+	RequiresRecreate bool
+	ImmutableChanges []FieldChange
+
+	// JSONMergeFallback is set when the typed object couldn't be compared with strategic merge semantics, e.g. because
+	// its Go type doesn't describe every field of the documents, the patch is then a JSON merge patch.
+	JSONMergeFallback bool
+
+	// ValidationErrors holds the rejections of the patch by the API server, see WithDryRunValidation.
+	ValidationErrors []error
+
+	// Skipped is set when the current object is not managed, see WithManagedAnnotation. The patch is empty.
+	Skipped bool
+
+	// modifiedObject and annotator are used to build the recreate plan.
+	modifiedObject runtime.Object
+	annotator      LastAppliedAnnotator
+
+	// currentOrg and patchedCurrent hold the current object as submitted and after applying the patch on it.
+	currentOrg     []byte
+	patchedCurrent []byte
+
+	// threeWayPatch is the strategic merge patch before checking it for an actual diff, it is logged for debugging.
+	threeWayPatch []byte
+
+	// patchType is the type of Patch.
+	patchType types.PatchType
+
+	// redactionPaths are masked by Redacted, redacted is set on the redacted copies.
+	redactionPaths []string
+	redacted       bool
 }
 
 func (p *PatchResult) IsEmpty() bool {
 	return string(p.Patch) == "{}"
 }
 
+// PatchType returns the type of Patch: a strategic merge patch for typed objects, a JSON merge patch for unstructured objects,
+// the kinds registered with RegisterJSONMergeKinds and the JSONMergeFallback results, or an apply patch for server-side apply.
+// The patch sent by Apply may be of another type, see PatchRequest.
+func (p *PatchResult) PatchType() types.PatchType {
+	if p.patchType != "" {
+		return p.patchType
+	}
+	if _, ok := p.Patched.(*unstructured.Unstructured); ok {
+		return types.MergePatchType
+	}
+	return types.StrategicMergePatchType
+}
+
+// String renders the documents of the result with the secret values masked, see Redacted.
 func (p *PatchResult) String() string {
+	if !p.redacted {
+		p = p.Redacted()
+	}
 	return fmt.Sprintf("\nPatch: %s \nCurrent: %s\nModified: %s\nOriginal: %s\n", p.Patch, p.Current, p.Modified, p.Original)
 }
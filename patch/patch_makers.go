@@ -15,8 +15,12 @@
 package patch
 
 import (
+	"strings"
+
 	"emperror.dev/errors"
 	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/jsonmergepatch"
 	"k8s.io/apimachinery/pkg/util/mergepatch"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
@@ -36,25 +40,65 @@ type JSONMergePatcher interface {
 
 type K8sStrategicMergePatcher struct {
 	PreconditionFuncs []mergepatch.PreconditionFunc
+
+	// PatchMeta holds the patch metadata of the kinds registered with RegisterPatchMeta.
+	PatchMeta map[schema.GroupVersionKind]strategicpatch.LookupPatchMeta
+}
+
+// RegisterPatchMeta sets the patch metadata used for the objects of the given kind, instead of the struct tags of their
+// Go type. It supplies the merge keys of kinds without Go type, which are then compared with strategic merge semantics
+// when they are unstructured, or overrides the merge keys of typed objects. The patch metadata must be registered before
+// the patcher is used, e.g.
+//
+//	lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(&v1alpha1.App{})
+//	patcher.RegisterPatchMeta(v1alpha1.GroupVersion.WithKind("App"), lookupPatchMeta)
+func (p *K8sStrategicMergePatcher) RegisterPatchMeta(gvk schema.GroupVersionKind, lookupPatchMeta strategicpatch.LookupPatchMeta) {
+	if p.PatchMeta == nil {
+		p.PatchMeta = map[schema.GroupVersionKind]strategicpatch.LookupPatchMeta{}
+	}
+	p.PatchMeta[gvk] = lookupPatchMeta
 }
 
 func (p *K8sStrategicMergePatcher) StrategicMergePatch(original, patch []byte, dataStruct interface{}) ([]byte, error) {
+	if lookupPatchMeta, ok := p.registeredPatchMeta(dataStruct); ok {
+		return strategicpatch.StrategicMergePatchUsingLookupPatchMeta(original, patch, lookupPatchMeta)
+	}
 	return strategicpatch.StrategicMergePatch(original, patch, dataStruct)
 }
 
 func (p *K8sStrategicMergePatcher) CreateTwoWayMergePatch(original, modified []byte, dataStruct interface{}) ([]byte, error) {
+	if lookupPatchMeta, ok := p.registeredPatchMeta(dataStruct); ok {
+		return strategicpatch.CreateTwoWayMergePatchUsingLookupPatchMeta(original, modified, lookupPatchMeta, p.PreconditionFuncs...)
+	}
 	return strategicpatch.CreateTwoWayMergePatch(original, modified, dataStruct, p.PreconditionFuncs...)
 }
 
 func (p *K8sStrategicMergePatcher) CreateThreeWayMergePatch(original, modified, current []byte, dataStruct interface{}) ([]byte, error) {
-	lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(dataStruct)
-	if err != nil {
-		return nil, errors.WrapWithDetails(err, "Failed to lookup patch meta", "current object", dataStruct)
+	lookupPatchMeta, ok := p.registeredPatchMeta(dataStruct)
+	if !ok {
+		var err error
+		lookupPatchMeta, err = strategicpatch.NewPatchMetaFromStruct(dataStruct)
+		if err != nil {
+			return nil, errors.WrapWithDetails(err, "Failed to lookup patch meta", "current object", dataStruct)
+		}
 	}
 
 	return strategicpatch.CreateThreeWayMergePatch(original, modified, current, lookupPatchMeta, true, p.PreconditionFuncs...)
 }
 
+// registeredPatchMeta returns the patch metadata registered for the kind of the object.
+func (p *K8sStrategicMergePatcher) registeredPatchMeta(dataStruct interface{}) (strategicpatch.LookupPatchMeta, bool) {
+	if len(p.PatchMeta) == 0 {
+		return nil, false
+	}
+	obj, ok := dataStruct.(runtime.Object)
+	if !ok {
+		return nil, false
+	}
+	lookupPatchMeta, ok := p.PatchMeta[objectGroupVersionKind(obj)]
+	return lookupPatchMeta, ok
+}
+
 type BaseJSONMergePatcher struct{}
 
 func (p *BaseJSONMergePatcher) MergePatch(docData, patchData []byte) ([]byte, error) {
@@ -68,3 +112,33 @@ func (p *BaseJSONMergePatcher) CreateMergePatch(originalJSON, modifiedJSON []byt
 func (p *BaseJSONMergePatcher) CreateThreeWayJSONMergePatch(original, modified, current []byte) ([]byte, error) {
 	return jsonmergepatch.CreateThreeWayJSONMergePatch(original, modified, current)
 }
+
+// registeredPatchMeta returns the patch metadata registered for the kind in the strategic merge patcher, which lets
+// unstructured objects of this kind be compared with strategic merge semantics.
+func (p *PatchMaker) registeredPatchMeta(gvk schema.GroupVersionKind) (strategicpatch.LookupPatchMeta, bool) {
+	patcher, ok := p.strategicMergePatcher.(*K8sStrategicMergePatcher)
+	if !ok || gvk.Empty() {
+		return nil, false
+	}
+	lookupPatchMeta, ok := patcher.PatchMeta[gvk]
+	return lookupPatchMeta, ok
+}
+
+// unsupportedByStrategicMergeMessages are the messages of the strategicpatch errors raised when the Go type of an object
+// doesn't describe its documents, e.g. for typed custom resources with inlined or untyped fields.
+var unsupportedByStrategicMergeMessages = []string{
+	"unable to find api field",
+	"expected slice or array type",
+	"unexpected slice of slice",
+}
+
+// isUnsupportedByStrategicMerge tells whether the strategic merge patch failed because of the Go type of the object,
+// in which case the object can still be compared with a JSON merge patch.
+func isUnsupportedByStrategicMerge(err error) bool {
+	for _, message := range unsupportedByStrategicMergeMessages {
+		if strings.Contains(err.Error(), message) {
+			return true
+		}
+	}
+	return false
+}
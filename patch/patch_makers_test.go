@@ -0,0 +1,110 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+func TestRegisterPatchMetaUnstructured(t *testing.T) {
+	newApp := func(containers ...interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "App",
+			"metadata": map[string]interface{}{
+				"name": "app",
+			},
+			"spec": map[string]interface{}{
+				"containers": containers,
+			},
+		}}
+	}
+	container := func(name, image string) interface{} {
+		return map[string]interface{}{"name": name, "image": image}
+	}
+
+	current := newApp(container("app", "app:1"))
+	mustAnnotate(current)
+	current.Object["spec"].(map[string]interface{})["containers"] = []interface{}{container("app", "app:1"), container("sidecar", "proxy:1")}
+	modified := newApp(container("app", "app:2"))
+
+	// The list is replaced by the JSON merge patch
+	patch, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	containers, _, _ := unstructured.NestedSlice(patch.Patched.(*unstructured.Unstructured).Object, "spec", "containers")
+	assert.Len(t, containers, 1)
+
+	// The containers of a pod spec are merged by name
+	lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(&corev1.Pod{})
+	require.NoError(t, err)
+	patcher := &K8sStrategicMergePatcher{}
+	patcher.RegisterPatchMeta(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "App"}, lookupPatchMeta)
+	patchMaker := NewPatchMaker(DefaultAnnotator, patcher, &BaseJSONMergePatcher{})
+
+	patch, err = patchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+	containers, _, _ = unstructured.NestedSlice(patch.Patched.(*unstructured.Unstructured).Object, "spec", "containers")
+	assert.Equal(t, []interface{}{container("app", "app:2"), container("sidecar", "proxy:1")}, containers)
+}
+
+func TestRegisterPatchMetaTyped(t *testing.T) {
+	newPod := func(containers ...corev1.Container) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "pod",
+			},
+			Spec: corev1.PodSpec{
+				Containers: containers,
+			},
+		}
+	}
+	app := corev1.Container{Name: "app", Image: "app:1"}
+	sidecar := corev1.Container{Name: "sidecar", Image: "proxy:1"}
+
+	current := newPod(app)
+	mustAnnotate(current)
+	current.Spec.Containers = append(current.Spec.Containers, sidecar)
+
+	patch, err := DefaultPatchMaker.Calculate(current, newPod(app))
+	require.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	// Without merge key the containers are replaced as a whole
+	type atomicContainersPod struct {
+		v1.TypeMeta   `json:",inline"`
+		v1.ObjectMeta `json:"metadata"`
+		Spec          struct {
+			Containers []corev1.Container `json:"containers"`
+		} `json:"spec"`
+	}
+	lookupPatchMeta, err := strategicpatch.NewPatchMetaFromStruct(&atomicContainersPod{})
+	require.NoError(t, err)
+	patcher := &K8sStrategicMergePatcher{}
+	patcher.RegisterPatchMeta(corev1.SchemeGroupVersion.WithKind("Pod"), lookupPatchMeta)
+	patchMaker := NewPatchMaker(DefaultAnnotator, patcher, &BaseJSONMergePatcher{})
+
+	patch, err = patchMaker.Calculate(current, newPod(corev1.Container{Name: "app", Image: "app:2"}))
+	require.NoError(t, err)
+	assert.Len(t, patch.Patched.(*corev1.Pod).Spec.Containers, 1)
+}
@@ -0,0 +1,37 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	json "github.com/json-iterator/go"
+)
+
+// hashJSON returns the SHA256 hash of the JSON representation of the value. The value is serialized into a pooled
+// stream buffer, which saves the copy made by Marshal when the JSON document itself isn't needed.
+func hashJSON(v interface{}) (string, error) {
+	stream := json.ConfigCompatibleWithStandardLibrary.BorrowStream(nil)
+	defer json.ConfigCompatibleWithStandardLibrary.ReturnStream(stream)
+
+	stream.WriteVal(v)
+	if stream.Error != nil {
+		return "", stream.Error
+	}
+
+	sum := sha256.Sum256(stream.Buffer())
+	return hex.EncodeToString(sum[:]), nil
+}
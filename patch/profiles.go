@@ -0,0 +1,116 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"sort"
+	"sync"
+
+	"emperror.dev/errors"
+)
+
+// Names of the built-in profiles.
+const (
+	ProfileNameWorkloads  = "workloads"
+	ProfileNameNetworking = "networking"
+	ProfileNameAll        = "all"
+)
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = map[string][]CalculateOption{
+		ProfileNameWorkloads:  ProfileWorkloads(),
+		ProfileNameNetworking: ProfileNetworking(),
+		ProfileNameAll:        ProfileAll(),
+	}
+)
+
+// ProfileWorkloads returns the normalization options of Pods, workloads, Jobs, CronJobs and the objects they depend on:
+// their generated and defaulted fields, volume claims, autoscalers, disruption budgets, quantities and unordered lists.
+// NormalizePodTemplate is left out, it is meant for the custom resources embedding pod templates.
+func ProfileWorkloads() []CalculateOption {
+	return []CalculateOption{
+		IgnoreDeploymentGeneratedFields(),
+		IgnoreJobGeneratedFields(),
+		IgnoreVolumeClaimTemplateTypeMetaAndStatus(),
+		NormalizePVC(),
+		NormalizeHPA(),
+		IgnorePDBSelector(),
+		NormalizeQuantities(),
+		NormalizeIntOrStringAndDurations(),
+		SortUnorderedLists(),
+	}
+}
+
+// ProfileNetworking returns the normalization options of Services, Ingresses and NetworkPolicies.
+func ProfileNetworking() []CalculateOption {
+	return []CalculateOption{
+		IgnoreServiceServerSideFields(),
+		NormalizeIngress(),
+		NormalizeNetworkPolicy(),
+	}
+}
+
+// ProfileAll returns the options of ProfileWorkloads and ProfileNetworking, and the normalization options of the RBAC
+// objects, ServiceAccounts, webhook configurations, CustomResourceDefinitions, APIServices and storage classes.
+func ProfileAll() []CalculateOption {
+	opts := append(ProfileWorkloads(), ProfileNetworking()...)
+	return append(opts,
+		NormalizeRBAC(),
+		IgnoreServiceAccountTokenSecrets(),
+		IgnoreWebhookCABundle(),
+		IgnoreCRDConversionWebhookAndStatus(),
+		NormalizeAPIService(),
+		NormalizeStorageClasses(),
+	)
+}
+
+// RegisterProfile registers the options under the profile name, replacing the profile registered with the same name,
+// so controllers can share option lists by name.
+func RegisterProfile(name string, opts ...CalculateOption) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+
+	profiles[name] = append([]CalculateOption(nil), opts...)
+}
+
+// Profile returns the options of the profiles, in order.
+func Profile(names ...string) ([]CalculateOption, error) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+
+	var opts []CalculateOption
+	for _, name := range names {
+		profileOpts, ok := profiles[name]
+		if !ok {
+			return nil, errors.Errorf("unknown profile %q", name)
+		}
+		opts = append(opts, profileOpts...)
+	}
+	return opts, nil
+}
+
+// ProfileNames returns the names of the registered profiles, sorted.
+func ProfileNames() []string {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,67 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProfileWorkloads(t *testing.T) {
+	newDeployment := func() *appsv1.Deployment {
+		return newAnnotatedDeployment(nil, nil)
+	}
+
+	current := mustAnnotate(newDeployment()).(*appsv1.Deployment)
+	revisionHistoryLimit := int32(10)
+	current.Spec.RevisionHistoryLimit = &revisionHistoryLimit
+	current.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "B", Value: "b"}, {Name: "A", Value: "a"}}
+	modified := newDeployment()
+	modified.Spec.Template.Spec.Containers[0].Env = []v1.EnvVar{{Name: "A", Value: "a"}, {Name: "B", Value: "b"}}
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+
+	result, err = DefaultPatchMaker.Calculate(current, modified, ProfileWorkloads()...)
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty(), string(result.Patch))
+}
+
+func TestProfileRegistry(t *testing.T) {
+	assert.Subset(t, ProfileNames(), []string{ProfileNameWorkloads, ProfileNameNetworking, ProfileNameAll})
+
+	all, err := Profile(ProfileNameAll)
+	require.NoError(t, err)
+	assert.Len(t, all, len(ProfileAll()))
+
+	RegisterProfile("test-config", IgnoreField("data"))
+	opts, err := Profile("test-config", ProfileNameNetworking)
+	require.NoError(t, err)
+	assert.Len(t, opts, 1+len(ProfileNetworking()))
+
+	current := mustAnnotate(&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Data: map[string]string{"key": "a"}})
+	result, err := DefaultPatchMaker.Calculate(current, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test"}, Data: map[string]string{"key": "b"}}, opts...)
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+
+	_, err = Profile("unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,85 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// serverManagedMetadataFields are the metadata fields set by the API server, which must not be sent when creating an object.
+var serverManagedMetadataFields = []string{
+	"managedFields",
+	"resourceVersion",
+	"uid",
+	"creationTimestamp",
+	"deletionTimestamp",
+	"deletionGracePeriodSeconds",
+	"generation",
+	"selfLink",
+}
+
+// RecreatePlan returns the modified object ready to be created once the current object is deleted, when the patch
+// can't be applied in place (see RequiresRecreate). The metadata set by the API server and the status are cleared,
+// and the last-applied annotation is set from the modified object itself.
+func (p *PatchResult) RecreatePlan() (runtime.Object, error) {
+	if p.redacted {
+		return nil, ErrRedacted
+	}
+	if p.modifiedObject == nil {
+		return nil, errors.New("patch result does not contain the modified object")
+	}
+
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(p.modifiedObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal modified object")
+	}
+
+	resource := map[string]interface{}{}
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal modified object")
+	}
+	deleteServerManagedFields(resource)
+
+	data, err = json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal recreated object")
+	}
+
+	obj, err := newObjectFromJSON(JSONIterConfig, p.modifiedObject, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create recreated object")
+	}
+	recreated := obj.(runtime.Object)
+
+	if p.annotator != nil {
+		if err := p.annotator.SetLastAppliedAnnotation(recreated); err != nil {
+			return nil, errors.Wrap(err, "could not annotate recreated object")
+		}
+	}
+
+	return recreated, nil
+}
+
+// deleteServerManagedFields removes the metadata set by the API server and the status from the resource.
+func deleteServerManagedFields(resource map[string]interface{}) {
+	if metadata, ok := resource["metadata"].(map[string]interface{}); ok {
+		for _, field := range serverManagedMetadataFields {
+			delete(metadata, field)
+		}
+	}
+	delete(resource, "status")
+}
@@ -0,0 +1,71 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRecreatePlan(t *testing.T) {
+	newService := func(clusterIP string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "service",
+				Namespace: "default",
+				Labels:    map[string]string{"app": "nginx"},
+			},
+			Spec: corev1.ServiceSpec{
+				ClusterIP: clusterIP,
+				Ports:     []corev1.ServicePort{{Name: "http", Port: 80}},
+			},
+		}
+	}
+
+	current := newService("10.0.0.1")
+	mustAnnotate(current)
+	current.ResourceVersion = "12"
+	current.UID = "1234"
+
+	// The modified object was built from the current one
+	modified := current.DeepCopy()
+	modified.Spec.ClusterIP = "None"
+	modified.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}}
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.True(t, result.RequiresRecreate)
+
+	obj, err := result.RecreatePlan()
+	assert.NoError(t, err)
+	recreated := obj.(*corev1.Service)
+
+	assert.Empty(t, recreated.ResourceVersion)
+	assert.Empty(t, recreated.UID)
+	assert.Empty(t, recreated.Status.LoadBalancer.Ingress)
+	assert.Equal(t, "None", recreated.Spec.ClusterIP)
+	assert.Equal(t, map[string]string{"app": "nginx"}, recreated.Labels)
+
+	original, err := DefaultAnnotator.GetOriginalConfiguration(recreated)
+	assert.NoError(t, err)
+	assert.Contains(t, string(original), `"clusterIP":"None"`)
+	assert.NotContains(t, string(original), "resourceVersion")
+
+	// The modified object is left untouched
+	assert.Equal(t, "12", modified.ResourceVersion)
+}
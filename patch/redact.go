@@ -0,0 +1,276 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RedactedValue replaces the masked values of a redacted PatchResult.
+const RedactedValue = "[redacted]"
+
+// secretRedactionPaths are the fields of Secrets holding secret values.
+var secretRedactionPaths = []string{
+	".data.*",
+	".stringData.*",
+}
+
+// WithRedaction masks the values at the given paths in the output of PatchResult.Redacted and PatchResult.String,
+// in addition to the data of Secrets. Paths use the syntax accepted by IgnoreJSONPath, e.g. `.spec.password` or
+// `.spec.users[*].token`.
+func WithRedaction(paths ...string) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.redactionPaths = append(p.redactionPaths, paths...)
+	}
+}
+
+// redactionPathsFor returns the paths to mask in the results for objects of the given kind.
+func (p *PatchMaker) redactionPathsFor(gvk schema.GroupVersionKind) ([]string, error) {
+	paths := append([]string{}, p.redactionPaths...)
+	if gvk.Group == "" && gvk.Kind == "Secret" {
+		paths = append(paths, secretRedactionPaths...)
+		// The last-applied annotations hold the data as well
+		keys := []string{KubectlLastAppliedConfig}
+		if p.annotator != nil {
+			keys = append(keys, p.annotator.key)
+			keys = append(keys, p.annotator.fallbackKeys...)
+		}
+		seen := map[string]bool{}
+		for _, key := range keys {
+			if !seen[key] {
+				seen[key] = true
+				paths = append(paths, ".metadata.annotations['"+key+"']")
+			}
+		}
+	}
+
+	for _, path := range paths {
+		if _, err := parseJSONPath(path); err != nil {
+			return nil, errors.Wrap(err, "invalid redaction path")
+		}
+	}
+
+	return paths, nil
+}
+
+// Redacted returns a copy of the result where the values of Secret data and of the paths configured with
+// WithRedaction are replaced by RedactedValue in Patch, Current, Modified, Original, ImmutableChanges
+// and the output of Changes, Report and JSONPatch. Patched is replaced by an unstructured copy holding the
+// masked values. A redacted result is meant to be displayed, Apply, PatchRequest and RecreatePlan fail with
+// ErrRedacted.
+func (p *PatchResult) Redacted() *PatchResult {
+	redacted := *p
+	redacted.redacted = true
+
+	if len(p.redactionPaths) == 0 {
+		return &redacted
+	}
+
+	paths := p.redactionSegments()
+	redacted.Patch = redactJSONPaths(p.Patch, paths)
+	redacted.Current = redactJSONPaths(p.Current, paths)
+	redacted.Modified = redactJSONPaths(p.Modified, paths)
+	redacted.Original = redactJSONPaths(p.Original, paths)
+	redacted.threeWayPatch = redactJSONPaths(p.threeWayPatch, paths)
+	redacted.ImmutableChanges = p.redactChanges(p.ImmutableChanges)
+	redacted.Patched = redactObject(p.Patched, paths)
+
+	return &redacted
+}
+
+// ErrRedacted is returned by the methods of redacted results building objects or requests for the API server,
+// which would hold the masked values.
+var ErrRedacted = errors.NewPlain("patch result is redacted")
+
+// redactObject returns an unstructured copy of the object with the values matching the paths masked.
+func redactObject(obj interface{}, paths [][]pathSegment) interface{} {
+	runtimeObj, ok := obj.(runtime.Object)
+	if !ok {
+		return obj
+	}
+
+	redacted := &unstructured.Unstructured{}
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(runtimeObj)
+	if err == nil {
+		err = KubernetesJSONConfig.Unmarshal(redactJSONPaths(data, paths), &redacted.Object)
+	}
+	if err != nil || redacted.Object == nil {
+		// Nothing of the object is kept if it can't be masked
+		redacted.Object = map[string]interface{}{}
+	}
+	redacted.SetGroupVersionKind(objectGroupVersionKind(runtimeObj))
+
+	return redacted
+}
+
+// redactionSegments returns the parsed redaction paths of the result.
+func (p *PatchResult) redactionSegments() [][]pathSegment {
+	paths := make([][]pathSegment, 0, len(p.redactionPaths))
+	for _, path := range p.redactionPaths {
+		// The paths are validated when the result is calculated
+		if segments, err := parseJSONPath(path); err == nil {
+			paths = append(paths, segments)
+		}
+	}
+
+	return paths
+}
+
+// redactChanges masks the values of the changes under the redaction paths.
+func (p *PatchResult) redactChanges(changes []FieldChange) []FieldChange {
+	if changes == nil {
+		return nil
+	}
+
+	paths := p.redactionSegments()
+	redacted := make([]FieldChange, 0, len(changes))
+	for _, change := range changes {
+		var path []pathSegment
+		changePaths := paths
+		if change.Path != "." {
+			var err error
+			if path, err = parseJSONPath(change.Path); err != nil {
+				// The whole change is masked if its path can't be matched
+				changePaths = [][]pathSegment{nil}
+			}
+		}
+		change.Old = redactChangeValue(path, change.Old, changePaths)
+		change.New = redactChangeValue(path, change.New, changePaths)
+		redacted = append(redacted, change)
+	}
+
+	return redacted
+}
+
+// redactJSONChanges masks the values of the changes matching the redaction paths.
+func (p *PatchResult) redactJSONChanges(changes []jsonChange) []jsonChange {
+	paths := p.redactionSegments()
+	redacted := make([]jsonChange, 0, len(changes))
+	for _, change := range changes {
+		path := pathSegments(change.path)
+		change.old = redactChangeValue(path, change.old, paths)
+		change.new = redactChangeValue(path, change.new, paths)
+		redacted = append(redacted, change)
+	}
+
+	return redacted
+}
+
+// redactChangeValue masks the value of the field at path: the whole value if the field matches one of the paths or
+// is under it, the matching values inside it if the field is a parent of one of the paths, e.g. the whole data of a Secret.
+func redactChangeValue(path []pathSegment, value interface{}, paths [][]pathSegment) interface{} {
+	copied := false
+	for _, redactionPath := range paths {
+		if !matchesPathPrefix(path, redactionPath) {
+			continue
+		}
+		if len(path) >= len(redactionPath) {
+			return redactValue(value)
+		}
+		if !copied {
+			value = runtime.DeepCopyJSONValue(value)
+			copied = true
+		}
+		redactAtPath(value, redactionPath[len(path):])
+	}
+
+	return value
+}
+
+// redactJSONPaths masks the values matching the paths in the JSON document, the whole document is masked
+// if it can't be parsed.
+func redactJSONPaths(obj []byte, paths [][]pathSegment) []byte {
+	if obj == nil || len(paths) == 0 {
+		return obj
+	}
+
+	var resource interface{}
+	if err := json.Unmarshal(obj, &resource); err != nil {
+		return []byte(`"` + RedactedValue + `"`)
+	}
+
+	for _, path := range paths {
+		redactAtPath(resource, path)
+	}
+
+	redacted, err := json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+	if err != nil {
+		return []byte(`"` + RedactedValue + `"`)
+	}
+
+	return redacted
+}
+
+// redactAtPath replaces the values matching path in node by RedactedValue.
+func redactAtPath(node interface{}, path []pathSegment) {
+	if len(path) == 0 {
+		return
+	}
+	segment, rest := path[0], path[1:]
+
+	switch typedNode := node.(type) {
+	case map[string]interface{}:
+		switch segment.kind {
+		case fieldSegment:
+			if child, ok := typedNode[segment.name]; ok {
+				if len(rest) == 0 {
+					typedNode[segment.name] = redactValue(child)
+				} else {
+					redactAtPath(child, rest)
+				}
+			}
+		case wildcardSegment:
+			for key, child := range typedNode {
+				if len(rest) == 0 {
+					typedNode[key] = redactValue(child)
+				} else {
+					redactAtPath(child, rest)
+				}
+			}
+		}
+	case []interface{}:
+		switch segment.kind {
+		case indexSegment:
+			if segment.index < 0 || segment.index >= len(typedNode) {
+				return
+			}
+			if len(rest) == 0 {
+				typedNode[segment.index] = redactValue(typedNode[segment.index])
+			} else {
+				redactAtPath(typedNode[segment.index], rest)
+			}
+		case wildcardSegment:
+			for i, child := range typedNode {
+				if len(rest) == 0 {
+					typedNode[i] = redactValue(child)
+				} else {
+					redactAtPath(child, rest)
+				}
+			}
+		}
+	}
+}
+
+// redactValue masks a value, nulls are kept as they mark removed fields in patches.
+func redactValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return RedactedValue
+}
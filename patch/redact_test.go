@@ -0,0 +1,183 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRedactedSecret(t *testing.T) {
+	newSecret := func(password string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{"password": []byte(password)},
+		}
+	}
+
+	current := newSecret("secret1")
+	assert.NoError(t, NewAnnotator(LastAppliedConfig).SetLastAppliedAnnotation(current))
+
+	result, err := DefaultPatchMaker.Calculate(current, newSecret("secret2"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(result.Patch), "c2VjcmV0Mg==")
+
+	redacted := result.Redacted()
+	assert.JSONEq(t, `{"data":{"password":"[redacted]"}}`, string(redacted.Patch))
+	for _, doc := range [][]byte{redacted.Current, redacted.Modified, redacted.Original, []byte(redacted.String()), []byte(result.String())} {
+		assert.NotContains(t, string(doc), "c2VjcmV0")
+	}
+	assert.Contains(t, string(redacted.Current), RedactedValue)
+
+	for _, change := range redacted.Changes() {
+		assert.Equal(t, RedactedValue, change.New, change.Path)
+	}
+	assert.NotContains(t, redacted.Report(), "c2VjcmV0")
+
+	// The result itself is left untouched
+	assert.Contains(t, string(result.Patch), "c2VjcmV0Mg==")
+}
+
+func TestWithRedaction(t *testing.T) {
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		WithRedaction(".spec.containers[*].env[*].value"))
+
+	newPod := func(token string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Name: "pod"},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  "test",
+					Image: "nginx",
+					Env:   []corev1.EnvVar{{Name: "TOKEN", Value: token}},
+				}},
+			},
+		}
+	}
+
+	current := newPod("token1")
+	assert.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	result, err := patchMaker.Calculate(current, newPod("token2"))
+	assert.NoError(t, err)
+
+	redacted := result.Redacted()
+	assert.Contains(t, string(redacted.Patch), RedactedValue)
+	assert.NotContains(t, string(redacted.Patch), "token2")
+	assert.NotContains(t, string(redacted.Current), "token1")
+	assert.Contains(t, string(redacted.Current), `"image":"nginx"`)
+
+	_, err = NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		WithRedaction(".spec[")).Calculate(current, newPod("token2"))
+	assert.Error(t, err)
+}
+
+func TestRedactedAccessors(t *testing.T) {
+	newSecret := func(data map[string][]byte) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Data: data,
+		}
+	}
+
+	tests := map[string]struct {
+		current  *corev1.Secret
+		modified *corev1.Secret
+	}{
+		"changed value": {
+			current:  newSecret(map[string][]byte{"pw": []byte("oldsecret")}),
+			modified: newSecret(map[string][]byte{"pw": []byte("supersecret")}),
+		},
+		"added data": {
+			current:  newSecret(nil),
+			modified: newSecret(map[string][]byte{"pw": []byte("supersecret")}),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(test.current))
+			result, err := DefaultPatchMaker.Calculate(test.current, test.modified)
+			require.NoError(t, err)
+			require.False(t, result.IsEmpty())
+
+			redacted := result.Redacted()
+			jsonPatch, err := redacted.JSONPatch()
+			require.NoError(t, err)
+			patchYAML, err := redacted.PatchYAML()
+			require.NoError(t, err)
+			changes, err := json.Marshal(redacted.Changes())
+			require.NoError(t, err)
+			immutableChanges, err := json.Marshal(redacted.ImmutableChanges)
+			require.NoError(t, err)
+			explanations, err := json.Marshal(Explain(redacted))
+			require.NoError(t, err)
+			conflicts, err := json.Marshal(redacted.Conflicts())
+			require.NoError(t, err)
+			patched, err := json.Marshal(redacted.Patched)
+			require.NoError(t, err)
+			serialized, err := json.Marshal(redacted)
+			require.NoError(t, err)
+
+			outputs := map[string][]byte{
+				"Patch":            redacted.Patch,
+				"Current":          redacted.Current,
+				"Modified":         redacted.Modified,
+				"Original":         redacted.Original,
+				"String":           []byte(redacted.String()),
+				"Report":           []byte(redacted.Report()),
+				"JSONPatch":        jsonPatch,
+				"PatchYAML":        patchYAML,
+				"Changes":          changes,
+				"ImmutableChanges": immutableChanges,
+				"Explain":          explanations,
+				"Conflicts":        conflicts,
+				"Patched":          patched,
+				"MarshalJSON":      serialized,
+			}
+			// "supersecret" and "oldsecret" in base64
+			for output, data := range outputs {
+				assert.NotContains(t, string(data), "c3VwZXJzZWNyZXQ", output)
+				assert.NotContains(t, string(data), "b2xkc2VjcmV0", output)
+			}
+			assert.Contains(t, string(jsonPatch), RedactedValue)
+			assert.NotEmpty(t, redacted.Changes())
+
+			_, err = redacted.PatchRequest()
+			assert.ErrorIs(t, err, ErrRedacted)
+			_, err = redacted.Apply(context.Background(), nil)
+			assert.ErrorIs(t, err, ErrRedacted)
+			_, err = redacted.RecreatePlan()
+			assert.ErrorIs(t, err, ErrRedacted)
+
+			// The result itself is left untouched
+			jsonPatch, err = result.JSONPatch()
+			require.NoError(t, err)
+			assert.Contains(t, string(jsonPatch), "c3VwZXJzZWNyZXQ")
+		})
+	}
+}
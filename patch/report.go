@@ -0,0 +1,203 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+var plainPathSegment = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type reportConfig struct {
+	color bool
+}
+
+type ReportOption func(*reportConfig)
+
+// WithColor colorizes the report with ANSI escape sequences.
+func WithColor() ReportOption {
+	return func(c *reportConfig) {
+		c.color = true
+	}
+}
+
+// FieldChange is a change the patch makes to a single field of the current object.
+type FieldChange struct {
+	// Path of the field in the syntax accepted by IgnoreJSONPath, e.g. .spec.template.spec.containers[0].image
+	Path string `json:"path"`
+	// Old value of the field, nil when the field is added
+	Old interface{} `json:"old,omitempty"`
+	// New value of the field, nil when the field is removed
+	New interface{} `json:"new,omitempty"`
+	// Op is one of JSONPatchOpAdd, JSONPatchOpRemove or JSONPatchOpReplace
+	Op string `json:"op"`
+}
+
+// IsUnder tells whether the changed field is the given field or one of its children. The path uses the syntax
+// accepted by IgnoreJSONPath, wildcards match any key or index, e.g. .spec.template or .spec.containers[*].image.
+func (c FieldChange) IsUnder(path string) bool {
+	prefix, err := parseJSONPath(path)
+	if err != nil {
+		return false
+	}
+	segments, err := parseJSONPath(c.Path)
+	if err != nil || len(segments) < len(prefix) {
+		return false
+	}
+
+	for i, segment := range prefix {
+		switch segment.kind {
+		case wildcardSegment:
+			continue
+		case fieldSegment:
+			if segments[i].kind != fieldSegment || segments[i].name != segment.name {
+				return false
+			}
+		case indexSegment:
+			if segments[i].kind != indexSegment || segments[i].index != segment.index {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Changes returns the changes the patch makes to the current object, one entry per changed field.
+// It returns nil if the patch is empty or the result doesn't contain the patched object.
+func (p *PatchResult) Changes() []FieldChange {
+	changes, err := p.fieldChanges()
+	if err != nil {
+		return nil
+	}
+	return changes
+}
+
+func (p *PatchResult) fieldChanges() ([]FieldChange, error) {
+	if p.IsEmpty() {
+		return nil, nil
+	}
+	if p.patchedCurrent == nil {
+		return nil, errors.New("patch result does not contain the patched object")
+	}
+
+	changes, err := diffJSON(p.currentOrg, p.patchedCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if p.redacted {
+		changes = p.redactJSONChanges(changes)
+	}
+
+	fieldChanges := make([]FieldChange, 0, len(changes))
+	for _, change := range changes {
+		fieldChanges = append(fieldChanges, FieldChange{
+			Path: change.jsonPath(),
+			Old:  change.old,
+			New:  change.new,
+			Op:   change.op,
+		})
+	}
+	return fieldChanges, nil
+}
+
+// Report renders the changes the patch makes to the current object as a human-readable diff,
+// one line per changed field prefixed with + (added), - (removed) or ~ (replaced), e.g.
+// `~ .spec.replicas: 1 -> 3`. Paths use the syntax accepted by IgnoreJSONPath.
+func (p *PatchResult) Report(opts ...ReportOption) string {
+	config := &reportConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	changes, err := p.fieldChanges()
+	if err != nil {
+		return fmt.Sprintf("could not compute changes: %s", err)
+	}
+	if len(changes) == 0 {
+		return "no changes"
+	}
+
+	lines := make([]string, 0, len(changes))
+	for _, change := range changes {
+		lines = append(lines, config.renderChange(change))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (c *reportConfig) renderChange(change FieldChange) string {
+	var symbol, color, line string
+
+	switch change.Op {
+	case JSONPatchOpAdd:
+		symbol, color = "+", colorGreen
+		line = fmt.Sprintf("%s: %s", change.Path, renderValue(change.New))
+	case JSONPatchOpRemove:
+		symbol, color = "-", colorRed
+		line = fmt.Sprintf("%s: %s", change.Path, renderValue(change.Old))
+	default:
+		symbol, color = "~", colorYellow
+		line = fmt.Sprintf("%s: %s -> %s", change.Path, renderValue(change.Old), renderValue(change.New))
+	}
+
+	if c.color {
+		return color + symbol + " " + line + colorReset
+	}
+	return symbol + " " + line
+}
+
+// jsonPath returns the path of the change in the syntax accepted by IgnoreJSONPath.
+func (c jsonChange) jsonPath() string {
+	if len(c.path) == 0 {
+		return "."
+	}
+
+	var b strings.Builder
+	for _, segment := range c.path {
+		switch typedSegment := segment.(type) {
+		case int:
+			b.WriteString("[" + strconv.Itoa(typedSegment) + "]")
+		case string:
+			if plainPathSegment.MatchString(typedSegment) {
+				b.WriteString("." + typedSegment)
+			} else {
+				b.WriteString(quoteName(typedSegment))
+			}
+		}
+	}
+	return b.String()
+}
+
+func renderValue(value interface{}) string {
+	rendered, err := json.ConfigCompatibleWithStandardLibrary.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(rendered)
+}
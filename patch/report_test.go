@@ -0,0 +1,121 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPatchResultReport(t *testing.T) {
+	newDeployment := func(replicas int32, labels map[string]string, args ...string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "deployment",
+				Namespace: "default",
+				Labels:    labels,
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{Name: "app", Image: "nginx", Args: args},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	current := newDeployment(1, map[string]string{"team": "a"}, "--port=80", "--debug")
+	mustAnnotate(current)
+
+	patch, err := DefaultPatchMaker.Calculate(current, current.DeepCopy())
+	assert.NoError(t, err)
+	assert.Equal(t, "no changes", patch.Report())
+
+	patch, err = DefaultPatchMaker.Calculate(current, newDeployment(3, map[string]string{"team": "a", "app.kubernetes.io/name": "app"}, "--port=80"))
+	assert.NoError(t, err)
+	report := patch.Report()
+	assert.Contains(t, report, `+ .metadata.labels['app.kubernetes.io/name']: "app"`)
+	assert.Contains(t, report, `~ .spec.replicas: 1 -> 3`)
+	assert.Contains(t, report, `- .spec.template.spec.containers[0].args[1]: "--debug"`)
+	assert.NotContains(t, report, "\x1b[")
+
+	assert.Contains(t, patch.Report(WithColor()), "\x1b[33m~ .spec.replicas: 1 -> 3\x1b[0m")
+}
+
+func TestPatchResultChanges(t *testing.T) {
+	current := &corev1.Pod{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "pod",
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app", Image: "nginx:1.22"},
+			},
+		},
+	}
+	mustAnnotate(current)
+
+	patch, err := DefaultPatchMaker.Calculate(current, current.DeepCopy())
+	assert.NoError(t, err)
+	assert.Empty(t, patch.Changes())
+
+	modified := current.DeepCopy()
+	modified.Annotations = nil
+	modified.Spec.Containers[0].Image = "nginx:1.23"
+	modified.Spec.NodeName = "node"
+
+	patch, err = DefaultPatchMaker.Calculate(current, modified)
+	assert.NoError(t, err)
+
+	var changes []FieldChange
+	for _, change := range patch.Changes() {
+		if change.Path != ".metadata.annotations['"+LastAppliedConfig+"']" {
+			changes = append(changes, change)
+		}
+	}
+	assert.Equal(t, []FieldChange{
+		{Path: ".spec.containers[0].image", Old: "nginx:1.22", New: "nginx:1.23", Op: JSONPatchOpReplace},
+		{Path: ".spec.nodeName", New: "node", Op: JSONPatchOpAdd},
+	}, changes)
+}
+
+func TestFieldChangeIsUnder(t *testing.T) {
+	change := FieldChange{Path: ".spec.template.spec.containers[0].image"}
+
+	assert.True(t, change.IsUnder(".spec"))
+	assert.True(t, change.IsUnder(".spec.template"))
+	assert.True(t, change.IsUnder(".spec.template.spec.containers[*].image"))
+	assert.True(t, change.IsUnder(".spec.template.spec.containers[0]"))
+	assert.False(t, change.IsUnder(".spec.template.spec.containers[1]"))
+	assert.False(t, change.IsUnder(".spec.temp"))
+	assert.False(t, change.IsUnder(".metadata"))
+
+	assert.True(t, FieldChange{Path: ".metadata.labels['app.kubernetes.io/name']"}.IsUnder(".metadata.labels"))
+
+	// Quotes in keys are escaped
+	path := jsonChange{path: []interface{}{"metadata", "annotations", `it's a \ key`}}.jsonPath()
+	assert.Equal(t, `.metadata.annotations['it\'s a \\ key']`, path)
+	assert.True(t, FieldChange{Path: path}.IsUnder(".metadata.annotations"))
+	assert.True(t, FieldChange{Path: path}.IsUnder(path))
+	assert.False(t, FieldChange{Path: path}.IsUnder(".metadata.annotations['it']"))
+}
@@ -0,0 +1,164 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// serializedPatchResult is the JSON form of PatchResult.
+type serializedPatchResult struct {
+	Patch    json.RawMessage `json:"patch,omitempty"`
+	Current  json.RawMessage `json:"current,omitempty"`
+	Modified json.RawMessage `json:"modified,omitempty"`
+	Original json.RawMessage `json:"original,omitempty"`
+	// Patched is the patched object, CurrentObject and PatchedCurrent the current object as submitted and after applying
+	// the patch on it, all used to build the patch requests and the reports.
+	Patched        *serializedObject `json:"patched,omitempty"`
+	CurrentObject  json.RawMessage   `json:"currentObject,omitempty"`
+	PatchedCurrent json.RawMessage   `json:"patchedCurrent,omitempty"`
+	PatchType      types.PatchType   `json:"patchType,omitempty"`
+
+	FieldManager      string        `json:"fieldManager,omitempty"`
+	Force             bool          `json:"force,omitempty"`
+	RequiresRecreate  bool          `json:"requiresRecreate,omitempty"`
+	ImmutableChanges  []FieldChange `json:"immutableChanges,omitempty"`
+	JSONMergeFallback bool          `json:"jsonMergeFallback,omitempty"`
+	ValidationErrors  []string      `json:"validationErrors,omitempty"`
+	Skipped           bool          `json:"skipped,omitempty"`
+	Redacted          bool          `json:"redacted,omitempty"`
+}
+
+// serializedObject is an object with its kind, which typed objects usually don't carry.
+type serializedObject struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Object     json.RawMessage `json:"object"`
+}
+
+// MarshalJSON encodes the result, e.g. to store it in the status of a custom resource or to send it to another component.
+// The patched object is encoded with its kind. The documents are encoded as they are, use Redacted to mask the secret
+// values first, which masks the patched object as well. The validation errors are encoded as messages.
+func (p *PatchResult) MarshalJSON() ([]byte, error) {
+	serialized := serializedPatchResult{
+		Patch:          p.Patch,
+		Current:        p.Current,
+		Modified:       p.Modified,
+		Original:       p.Original,
+		CurrentObject:  p.currentOrg,
+		PatchedCurrent: p.patchedCurrent,
+		PatchType:      p.patchType,
+
+		FieldManager:      p.FieldManager,
+		Force:             p.Force,
+		RequiresRecreate:  p.RequiresRecreate,
+		ImmutableChanges:  p.ImmutableChanges,
+		JSONMergeFallback: p.JSONMergeFallback,
+		Skipped:           p.Skipped,
+		Redacted:          p.redacted,
+	}
+	for _, err := range p.ValidationErrors {
+		serialized.ValidationErrors = append(serialized.ValidationErrors, err.Error())
+	}
+
+	if patched, ok := p.Patched.(runtime.Object); ok {
+		document, err := json.ConfigCompatibleWithStandardLibrary.Marshal(patched)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not marshal patched object")
+		}
+		apiVersion, kind := objectGroupVersionKind(patched).ToAPIVersionAndKind()
+		serialized.Patched = &serializedObject{APIVersion: apiVersion, Kind: kind, Object: document}
+	}
+
+	// The patched object and documents of a redacted result are masked as well
+	if p.redacted {
+		paths := p.redactionSegments()
+		serialized.CurrentObject = redactJSONPaths(serialized.CurrentObject, paths)
+		serialized.PatchedCurrent = redactJSONPaths(serialized.PatchedCurrent, paths)
+		if serialized.Patched != nil {
+			serialized.Patched.Object = redactJSONPaths(serialized.Patched.Object, paths)
+		}
+	}
+
+	return json.ConfigCompatibleWithStandardLibrary.Marshal(serialized)
+}
+
+// UnmarshalJSON decodes a result encoded by MarshalJSON. The patched object is decoded as the type registered for its kind
+// in the client-go scheme, or as an unstructured object if there is none or the result was redacted. The decoded result can build patch requests, but not recreate
+// plans, which need the modified object.
+func (p *PatchResult) UnmarshalJSON(data []byte) error {
+	var serialized serializedPatchResult
+	if err := json.Unmarshal(data, &serialized); err != nil {
+		return errors.Wrap(err, "could not unmarshal patch result")
+	}
+
+	*p = PatchResult{
+		Patch:    serialized.Patch,
+		Current:  serialized.Current,
+		Modified: serialized.Modified,
+		Original: serialized.Original,
+
+		FieldManager:      serialized.FieldManager,
+		Force:             serialized.Force,
+		RequiresRecreate:  serialized.RequiresRecreate,
+		ImmutableChanges:  serialized.ImmutableChanges,
+		JSONMergeFallback: serialized.JSONMergeFallback,
+		Skipped:           serialized.Skipped,
+
+		currentOrg:     serialized.CurrentObject,
+		patchedCurrent: serialized.PatchedCurrent,
+		patchType:      serialized.PatchType,
+		redacted:       serialized.Redacted,
+	}
+	for _, message := range serialized.ValidationErrors {
+		p.ValidationErrors = append(p.ValidationErrors, errors.New(message))
+	}
+
+	if serialized.Patched != nil {
+		patched, err := decodeSerializedObject(serialized.Patched, serialized.Redacted)
+		if err != nil {
+			return errors.Wrap(err, "could not decode patched object")
+		}
+		p.Patched = patched
+	}
+
+	return nil
+}
+
+// decodeSerializedObject decodes the object as the type registered for its kind, or as an unstructured object. Redacted
+// objects are always decoded as unstructured objects, the masked values may not fit the typed fields, e.g. Secret data.
+func decodeSerializedObject(serialized *serializedObject, redacted bool) (runtime.Object, error) {
+	gvk := schema.FromAPIVersionAndKind(serialized.APIVersion, serialized.Kind)
+	if typed, err := clientgoscheme.Scheme.New(gvk); err == nil && !redacted {
+		if err := json.Unmarshal(serialized.Object, typed); err != nil {
+			return nil, err
+		}
+		return typed, nil
+	}
+
+	// The integers are kept as int64 like the unstructured decoder does, the kind may be missing from typed objects
+	obj := &unstructured.Unstructured{}
+	if err := KubernetesJSONConfig.Unmarshal(serialized.Object, &obj.Object); err != nil {
+		return nil, err
+	}
+	obj.SetGroupVersionKind(gvk)
+	return obj, nil
+}
@@ -0,0 +1,116 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func TestPatchResultJSON(t *testing.T) {
+	current := newBenchmarkDeployment()
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+	modified := newBenchmarkDeployment()
+	modified.Spec.Template.Spec.Containers[0].Image = "nginx:1.24"
+
+	result, err := DefaultPatchMaker.Calculate(current, modified)
+	require.NoError(t, err)
+	result.ValidationErrors = []error{errors.New("rejected")}
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	decoded := &PatchResult{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+
+	assert.Equal(t, string(result.Patch), string(decoded.Patch))
+	assert.Equal(t, string(result.Original), string(decoded.Original))
+	assert.Equal(t, result.PatchType(), decoded.PatchType())
+	assert.Equal(t, result.Changes(), decoded.Changes())
+	assert.Equal(t, "rejected", decoded.ValidationErrors[0].Error())
+	patched, ok := decoded.Patched.(*appsv1.Deployment)
+	require.True(t, ok, "%T", decoded.Patched)
+	assert.Equal(t, "nginx:1.24", patched.Spec.Template.Spec.Containers[0].Image)
+
+	request, err := result.PatchRequest()
+	require.NoError(t, err)
+	decodedRequest, err := decoded.PatchRequest()
+	require.NoError(t, err)
+	assert.Equal(t, request, decodedRequest)
+
+	// YAML goes through JSON
+	data, err = yaml.Marshal(result)
+	require.NoError(t, err)
+	decoded = &PatchResult{}
+	require.NoError(t, yaml.Unmarshal(data, decoded))
+	assert.Equal(t, string(result.Patch), string(decoded.Patch))
+}
+
+func TestPatchResultJSONUnstructured(t *testing.T) {
+	current := newNullableApp(map[string]interface{}{"replicas": int64(1)})
+	mustAnnotate(current)
+	result, err := DefaultPatchMaker.Calculate(current, newNullableApp(map[string]interface{}{"replicas": int64(2)}))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	decoded := &PatchResult{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+
+	assert.JSONEq(t, `{"spec":{"replicas":2}}`, string(decoded.Patch))
+	patched, ok := decoded.Patched.(*unstructured.Unstructured)
+	require.True(t, ok, "%T", decoded.Patched)
+	assert.Equal(t, "App", patched.GetKind())
+	replicas, _, _ := unstructured.NestedInt64(patched.Object, "spec", "replicas")
+	assert.Equal(t, int64(2), replicas)
+}
+
+func TestPatchResultJSONRedacted(t *testing.T) {
+	newSecret := func(password string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{"password": []byte(password)},
+		}
+	}
+	current := newSecret("secret1")
+	require.NoError(t, DefaultAnnotator.SetLastAppliedAnnotation(current))
+	result, err := DefaultPatchMaker.Calculate(current, newSecret("secret2"))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(result.Redacted())
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "c2VjcmV0")
+
+	decoded := &PatchResult{}
+	require.NoError(t, json.Unmarshal(data, decoded))
+	patched, ok := decoded.Patched.(*unstructured.Unstructured)
+	require.True(t, ok, "%T", decoded.Patched)
+	password, _, _ := unstructured.NestedString(patched.Object, "data", "password")
+	assert.Equal(t, RedactedValue, password)
+	for _, change := range decoded.Changes() {
+		assert.Equal(t, RedactedValue, change.New, change.Path)
+	}
+}
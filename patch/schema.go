@@ -0,0 +1,244 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+
+	"emperror.dev/errors"
+	openapi_v2 "github.com/google/gnostic/openapiv2"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/kube-openapi/pkg/util/proto"
+)
+
+// SchemaSource looks up the OpenAPI schema of a kind, it returns nil if the kind is unknown.
+type SchemaSource interface {
+	LookupSchema(gvk schema.GroupVersionKind) (proto.Schema, error)
+}
+
+// SchemaSourceFunc adapts a function to a SchemaSource.
+type SchemaSourceFunc func(gvk schema.GroupVersionKind) (proto.Schema, error)
+
+func (f SchemaSourceFunc) LookupSchema(gvk schema.GroupVersionKind) (proto.Schema, error) {
+	return f(gvk)
+}
+
+// WithSchemaSource makes Calculate use strategic merge semantics (list merge keys, atomic lists)
+// for unstructured objects whose schema is known by the source, instead of plain JSON merge semantics.
+// The computed patch is still a JSON merge patch as the API server doesn't accept strategic merge
+// patches for custom resources.
+func WithSchemaSource(source SchemaSource) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.schemaSource = source
+	}
+}
+
+// lookupSchema returns the schema of the object if a schema source is configured and knows its kind.
+func (p *PatchMaker) lookupSchema(obj runtime.Object) (proto.Schema, error) {
+	if p.schemaSource == nil {
+		return nil, nil
+	}
+
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		return nil, nil
+	}
+
+	return p.schemaSource.LookupSchema(gvk)
+}
+
+// OpenAPISchemaSource looks up schemas in an OpenAPI v2 document, like the one returned
+// by the OpenAPISchema method of the discovery client.
+type OpenAPISchemaSource struct {
+	schemas map[schema.GroupVersionKind]proto.Schema
+}
+
+func NewOpenAPISchemaSource(doc *openapi_v2.Document) (*OpenAPISchemaSource, error) {
+	models, err := proto.NewOpenAPIData(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse OpenAPI document")
+	}
+
+	source := &OpenAPISchemaSource{
+		schemas: map[schema.GroupVersionKind]proto.Schema{},
+	}
+	for _, name := range models.ListModels() {
+		model := models.LookupModel(name)
+		if model == nil {
+			continue
+		}
+		for _, gvk := range parseGroupVersionKindExtension(model.GetExtensions()) {
+			source.schemas[gvk] = model
+		}
+	}
+
+	return source, nil
+}
+
+func (s *OpenAPISchemaSource) LookupSchema(gvk schema.GroupVersionKind) (proto.Schema, error) {
+	return s.schemas[gvk], nil
+}
+
+func parseGroupVersionKindExtension(extensions map[string]interface{}) []schema.GroupVersionKind {
+	var gvks []schema.GroupVersionKind
+
+	values, ok := extensions["x-kubernetes-group-version-kind"].([]interface{})
+	if !ok {
+		return gvks
+	}
+	for _, value := range values {
+		fields := stringMap(value)
+		if fields == nil {
+			continue
+		}
+		gvks = append(gvks, schema.GroupVersionKind{
+			Group:   fmt.Sprint(fields["group"]),
+			Version: fmt.Sprint(fields["version"]),
+			Kind:    fmt.Sprint(fields["kind"]),
+		})
+	}
+
+	return gvks
+}
+
+// stringMap converts the maps decoded from OpenAPI extensions to map[string]interface{}.
+func stringMap(value interface{}) map[string]interface{} {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		return typedValue
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(typedValue))
+		for k, v := range typedValue {
+			m[fmt.Sprint(k)] = v
+		}
+		return m
+	}
+	return nil
+}
+
+// lenientPatchMeta looks up patch metadata in an OpenAPI schema. Fields the schema doesn't describe
+// (unknown fields, maps of objects) are merged like in a JSON merge patch instead of failing, and lists declared with
+// x-kubernetes-list-type: map and a single key are merged by that key like in structural schemas.
+type lenientPatchMeta struct {
+	lookup strategicpatch.LookupPatchMeta
+}
+
+func newLenientPatchMeta(s proto.Schema) lenientPatchMeta {
+	return lenientPatchMeta{lookup: strategicpatch.NewPatchMetaFromOpenAPI(s)}
+}
+
+func (l lenientPatchMeta) LookupPatchMetadataForStruct(key string) (strategicpatch.LookupPatchMeta, strategicpatch.PatchMeta, error) {
+	if l.lookup == nil {
+		return l, strategicpatch.PatchMeta{}, nil
+	}
+
+	lookup, patchMeta, err := l.lookup.LookupPatchMetadataForStruct(key)
+	if err != nil || lookup == nil {
+		return lenientPatchMeta{}, strategicpatch.PatchMeta{}, nil
+	}
+
+	return lenientPatchMeta{lookup: lookup}, patchMeta, nil
+}
+
+func (l lenientPatchMeta) LookupPatchMetadataForSlice(key string) (strategicpatch.LookupPatchMeta, strategicpatch.PatchMeta, error) {
+	if l.lookup == nil {
+		return l, strategicpatch.PatchMeta{}, nil
+	}
+
+	lookup, patchMeta, err := l.lookup.LookupPatchMetadataForSlice(key)
+	if err != nil || lookup == nil {
+		return lenientPatchMeta{}, strategicpatch.PatchMeta{}, nil
+	}
+
+	if patchMeta.GetPatchMergeKey() == "" {
+		if mergeKey := l.listMapKey(key); mergeKey != "" {
+			patchMeta.SetPatchStrategies([]string{"merge"})
+			patchMeta.SetPatchMergeKey(mergeKey)
+		}
+	}
+
+	return lenientPatchMeta{lookup: lookup}, patchMeta, nil
+}
+
+func (l lenientPatchMeta) Name() string {
+	if l.lookup == nil {
+		return ""
+	}
+	return l.lookup.Name()
+}
+
+// listMapKey returns the key of a list declared with x-kubernetes-list-type: map and a single map key.
+func (l lenientPatchMeta) listMapKey(key string) string {
+	fieldLookup, _, err := l.lookup.LookupPatchMetadataForStruct(key)
+	if err != nil {
+		return ""
+	}
+	fieldMeta, ok := fieldLookup.(strategicpatch.PatchMetaFromOpenAPI)
+	if !ok || fieldMeta.Schema == nil {
+		return ""
+	}
+
+	extensions := fieldMeta.Schema.GetExtensions()
+	if extensions["x-kubernetes-list-type"] != "map" {
+		return ""
+	}
+	keys, ok := extensions["x-kubernetes-list-map-keys"].([]interface{})
+	if !ok || len(keys) != 1 {
+		return ""
+	}
+	mergeKey, _ := keys[0].(string)
+	return mergeKey
+}
+
+// unstructuredSchemaMergePatch computes the effective changes with strategic merge semantics described
+// by the schema, then returns them as a JSON merge patch along with the patched current object.
+func (p *PatchMaker) unstructuredSchemaMergePatch(s proto.Schema, original, modified, current, currentOrg []byte) ([]byte, []byte, error) {
+	return p.unstructuredPatchMetaMergePatch(newLenientPatchMeta(s), original, modified, current, currentOrg)
+}
+
+// unstructuredPatchMetaMergePatch is unstructuredSchemaMergePatch with the given patch metadata.
+func (p *PatchMaker) unstructuredPatchMetaMergePatch(lookupPatchMeta strategicpatch.LookupPatchMeta, original, modified, current, currentOrg []byte) ([]byte, []byte, error) {
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, lookupPatchMeta, true)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to generate strategic merge patch")
+	}
+
+	if string(patch) == "{}" {
+		return patch, currentOrg, nil
+	}
+
+	patchCurrent, err := strategicpatch.StrategicMergePatchUsingLookupPatchMeta(current, patch, lookupPatchMeta)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to merge generated patch to current object")
+	}
+
+	patch, err = p.jsonMergePatcher.CreateMergePatch(current, patchCurrent)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to create patch between the current and patched current object")
+	}
+
+	if string(patch) == "{}" {
+		return patch, currentOrg, nil
+	}
+
+	patchedCurrent, err := p.jsonMergePatcher.MergePatch(currentOrg, patch)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to apply patch")
+	}
+
+	return patch, patchedCurrent, nil
+}
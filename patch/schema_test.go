@@ -0,0 +1,117 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	openapi_v2 "github.com/google/gnostic/openapiv2"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const testOpenAPIDocument = `{
+  "swagger": "2.0",
+  "info": {"title": "test", "version": "v1"},
+  "paths": {},
+  "definitions": {
+    "com.example.v1.Foo": {
+      "type": "object",
+      "x-kubernetes-group-version-kind": [{"group": "example.com", "kind": "Foo", "version": "v1"}],
+      "properties": {
+        "apiVersion": {"type": "string"},
+        "kind": {"type": "string"},
+        "metadata": {
+          "type": "object",
+          "properties": {
+            "name": {"type": "string"},
+            "annotations": {"type": "object", "additionalProperties": {"type": "string"}}
+          }
+        },
+        "spec": {
+          "type": "object",
+          "properties": {
+            "items": {
+              "type": "array",
+              "x-kubernetes-list-type": "map",
+              "x-kubernetes-list-map-keys": ["name"],
+              "items": {
+                "type": "object",
+                "properties": {
+                  "name": {"type": "string"},
+                  "value": {"type": "string"},
+                  "serverField": {"type": "string"}
+                }
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+func newTestFoo(items ...map[string]interface{}) *unstructured.Unstructured {
+	list := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		list = append(list, item)
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Foo",
+		"metadata": map[string]interface{}{
+			"name": "foo",
+		},
+		"spec": map[string]interface{}{
+			"items": list,
+		},
+	}}
+}
+
+func TestWithSchemaSource(t *testing.T) {
+	doc, err := openapi_v2.ParseDocument([]byte(testOpenAPIDocument))
+	assert.NoError(t, err)
+	source, err := NewOpenAPISchemaSource(doc)
+	assert.NoError(t, err)
+
+	fooSchema, err := source.LookupSchema(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Foo"})
+	assert.NoError(t, err)
+	assert.NotNil(t, fooSchema)
+
+	maker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithSchemaSource(source))
+
+	current := newTestFoo(map[string]interface{}{"name": "a", "value": "1"})
+	mustAnnotate(current)
+	current.Object["spec"].(map[string]interface{})["items"].([]interface{})[0].(map[string]interface{})["serverField"] = "x"
+
+	// The whole list is compared with JSON merge semantics
+	patch, err := DefaultPatchMaker.Calculate(current, newTestFoo(map[string]interface{}{"name": "a", "value": "1"}))
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	// List items are merged by key with the schema
+	patch, err = maker.Calculate(current, newTestFoo(map[string]interface{}{"name": "a", "value": "1"}))
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	// The patch is a JSON merge patch keeping the fields set by the server
+	patch, err = maker.Calculate(current, newTestFoo(
+		map[string]interface{}{"name": "a", "value": "1"},
+		map[string]interface{}{"name": "b", "value": "2"},
+	))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"items":[{"name":"a","serverField":"x","value":"1"},{"name":"b","value":"2"}]}}`, string(patch.Patch))
+}
@@ -0,0 +1,145 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ServerSideApplyPatcher turns the modified object into an apply configuration
+// that can be sent to the API server with a server-side apply request.
+type ServerSideApplyPatcher struct {
+	// FieldManager is the name of the field manager owning the applied fields.
+	FieldManager string
+	// Force makes the apply request take ownership of conflicting fields.
+	Force bool
+}
+
+// WithServerSideApply makes Calculate produce server-side apply configurations
+// instead of strategic or JSON merge patches. The last-applied annotation is neither
+// read nor written in this mode since the API server tracks field ownership itself.
+func WithServerSideApply(patcher *ServerSideApplyPatcher) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.applyPatcher = patcher
+	}
+}
+
+// CreateApplyConfiguration removes the server managed metadata and the status from the modified
+// object, the API server would reject or ignore them in an apply request on the main resource.
+func (p *ServerSideApplyPatcher) CreateApplyConfiguration(modified []byte) ([]byte, error) {
+	resource := map[string]interface{}{}
+	if err := json.Unmarshal(modified, &resource); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal byte sequence")
+	}
+
+	if resource["apiVersion"] == nil || resource["kind"] == nil {
+		return nil, errors.New("apply configuration requires apiVersion and kind to be set")
+	}
+
+	deleteServerManagedFields(resource)
+
+	applyConfiguration, err := json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal byte sequence")
+	}
+
+	return applyConfiguration, nil
+}
+
+// calculateApply decides whether applying the modified object would change the current one
+// and returns the apply configuration as the patch if it does.
+// Fields missing from the modified object are kept, a server-side apply only removes
+// them when they were previously owned by the same field manager.
+func (p *PatchMaker) calculateApply(currentObject, modifiedObject runtime.Object, current, modified, currentOrg []byte) (*PatchResult, error) {
+	var diff []byte
+	var patchedCurrent []byte
+
+	switch currentObject.(type) {
+	default:
+		patchCurrent, err := p.strategicMergePatcher.StrategicMergePatch(current, modified, currentObject)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to merge modified object into current object")
+		}
+
+		diff, err = p.strategicMergePatcher.CreateTwoWayMergePatch(current, patchCurrent, currentObject)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create patch between the current and patched current object")
+		}
+
+		if string(diff) != "{}" {
+			patchedCurrent, err = p.strategicMergePatcher.StrategicMergePatch(currentOrg, diff, currentObject)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to apply patch")
+			}
+		}
+	case *unstructured.Unstructured:
+		patchCurrent, err := p.jsonMergePatcher.MergePatch(current, modified)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to merge modified object into current object")
+		}
+
+		diff, err = p.jsonMergePatcher.CreateMergePatch(current, patchCurrent)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create patch between the current and patched current object")
+		}
+
+		if string(diff) != "{}" {
+			patchedCurrent, err = p.jsonMergePatcher.MergePatch(currentOrg, diff)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to apply patch")
+			}
+		}
+	}
+
+	patch := []byte("{}")
+	if string(diff) == "{}" {
+		patchedCurrent = currentOrg
+	} else {
+		modifiedOrg, err := p.jsonConfig.Marshal(modifiedObject)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to convert modified object to byte sequence")
+		}
+		modifiedOrg, err = DeleteNullInJsonBytes(modifiedOrg)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to delete null from modified object")
+		}
+		patch, err = p.applyPatcher.CreateApplyConfiguration(modifiedOrg)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to create apply configuration")
+		}
+	}
+
+	patched, err := newObjectFromJSON(p.jsonConfig, currentObject, patchedCurrent)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create patched object")
+	}
+
+	return &PatchResult{
+		Patch:        patch,
+		Current:      current,
+		Modified:     modified,
+		Patched:      patched,
+		FieldManager: p.applyPatcher.FieldManager,
+		Force:        p.applyPatcher.Force,
+
+		currentOrg:     currentOrg,
+		patchedCurrent: patchedCurrent,
+		patchType:      types.ApplyPatchType,
+	}, nil
+}
@@ -0,0 +1,134 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestServerSideApply(t *testing.T) {
+	maker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithServerSideApply(&ServerSideApplyPatcher{
+		FieldManager: "test-operator",
+		Force:        true,
+	}))
+
+	current := &corev1.Service{
+		TypeMeta: v1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:            "my-service",
+			Namespace:       "default",
+			ResourceVersion: "42",
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "10.0.0.1",
+			Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP},
+			},
+		},
+	}
+	modified := &corev1.Service{
+		TypeMeta: v1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "my-service",
+			Namespace: "default",
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, Protocol: corev1.ProtocolTCP},
+			},
+		},
+	}
+
+	// Fields set by the server are not part of the diff
+	patch, err := maker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+	assert.Equal(t, "test-operator", patch.FieldManager)
+	assert.True(t, patch.Force)
+	assert.Nil(t, patch.Original)
+
+	// The patch is the apply configuration when there is a diff
+	modified.Labels = map[string]string{"foo": "bar"}
+	patch, err = maker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+	assert.JSONEq(t, `{"apiVersion":"v1","kind":"Service","metadata":{"name":"my-service","namespace":"default","labels":{"foo":"bar"}},"spec":{"ports":[{"name":"http","port":80,"protocol":"TCP"}]}}`, string(patch.Patch))
+	assert.Equal(t, map[string]string{"foo": "bar"}, patch.Patched.(*corev1.Service).Labels)
+	assert.Equal(t, "10.0.0.1", patch.Patched.(*corev1.Service).Spec.ClusterIP)
+	_, hasAnnotation := patch.Patched.(*corev1.Service).Annotations[LastAppliedConfig]
+	assert.False(t, hasAnnotation)
+}
+
+func TestServerSideApplyUnstructured(t *testing.T) {
+	maker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithServerSideApply(&ServerSideApplyPatcher{
+		FieldManager: "test-operator",
+	}))
+
+	current := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Foo",
+		"metadata": map[string]interface{}{
+			"name":            "foo",
+			"resourceVersion": "42",
+		},
+		"spec": map[string]interface{}{
+			"a": "b",
+			"c": "d",
+		},
+	}}
+	modified := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Foo",
+		"metadata": map[string]interface{}{
+			"name": "foo",
+		},
+		"spec": map[string]interface{}{
+			"a": "b",
+		},
+	}}
+
+	patch, err := maker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	modified.Object["spec"] = map[string]interface{}{
+		"a": "changed",
+	}
+	patch, err = maker.Calculate(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+	assert.JSONEq(t, `{"apiVersion":"example.com/v1","kind":"Foo","metadata":{"name":"foo"},"spec":{"a":"changed"}}`, string(patch.Patch))
+	assert.Equal(t, map[string]interface{}{"a": "changed", "c": "d"}, patch.Patched.(*unstructured.Unstructured).Object["spec"])
+}
+
+func TestServerSideApplyRequiresTypeMeta(t *testing.T) {
+	maker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithServerSideApply(&ServerSideApplyPatcher{
+		FieldManager: "test-operator",
+	}))
+
+	_, err := maker.Calculate(&corev1.ConfigMap{}, &corev1.ConfigMap{Data: map[string]string{"a": "b"}})
+	assert.Error(t, err)
+}
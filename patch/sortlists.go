@@ -0,0 +1,161 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"sort"
+	"strings"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// podSpecUnorderedLists are the lists of pod specs whose order doesn't matter.
+var podSpecUnorderedLists = []string{"tolerations", "imagePullSecrets", "topologySpreadConstraints"}
+
+// containerLists are the lists of containers of pod specs, their env lists are sorted.
+var containerLists = []string{"initContainers", "containers", "ephemeralContainers"}
+
+// podSpecGroupKinds are the group kinds of the Pods and of the workloads with a pod template, see podSpecPaths.
+var podSpecGroupKinds = []schema.GroupKind{
+	{Kind: "Pod"},
+	{Kind: "ReplicationController"},
+	{Group: "apps", Kind: "Deployment"},
+	{Group: "apps", Kind: "StatefulSet"},
+	{Group: "apps", Kind: "DaemonSet"},
+	{Group: "apps", Kind: "ReplicaSet"},
+	{Group: "extensions", Kind: "Deployment"},
+	{Group: "extensions", Kind: "DaemonSet"},
+	{Group: "extensions", Kind: "ReplicaSet"},
+	{Group: "batch", Kind: "Job"},
+	{Group: "batch", Kind: "CronJob"},
+}
+
+// SortUnorderedLists sorts the lists Kubernetes treats as unordered or users frequently reorder (tolerations, env,
+// imagePullSecrets and topologySpreadConstraints) in the pod spec of both objects (a Pod, a workload template, a Job
+// or a CronJob job template) before comparing them, so reordering them doesn't produce a patch. Env lists referencing
+// other variables with $(VAR) are left untouched since their order matters. Objects of other kinds are left untouched.
+func SortUnorderedLists() CalculateOption {
+	return func(current, modified []byte) ([]byte, []byte, error) {
+		current, err := sortUnorderedLists(current)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not sort lists of current byte sequence")
+		}
+
+		modified, err = sortUnorderedLists(modified)
+		if err != nil {
+			return []byte{}, []byte{}, errors.Wrap(err, "could not sort lists of modified byte sequence")
+		}
+
+		return current, modified, nil
+	}
+}
+
+func sortUnorderedLists(obj []byte) ([]byte, error) {
+	resource := map[string]interface{}{}
+	if err := json.Unmarshal(obj, &resource); err != nil {
+		return []byte{}, errors.Wrap(err, "could not unmarshal byte sequence")
+	}
+
+	if !hasGroupKind(resource, podSpecGroupKinds...) {
+		return obj, nil
+	}
+
+	for _, path := range podSpecPaths {
+		podSpec, ok := fieldValue(resource, path).(map[string]interface{})
+		if !ok || !isPodSpec(podSpec) {
+			continue
+		}
+		if err := sortPodSpecLists(podSpec); err != nil {
+			return []byte{}, err
+		}
+	}
+
+	obj, err := json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+	if err != nil {
+		return []byte{}, errors.Wrap(err, "could not marshal byte sequence")
+	}
+
+	return obj, nil
+}
+
+func sortPodSpecLists(podSpec map[string]interface{}) error {
+	for _, field := range podSpecUnorderedLists {
+		if list, ok := podSpec[field].([]interface{}); ok && isObjectList(list) {
+			if err := sortObjectList(list); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, field := range containerLists {
+		containers, _ := podSpec[field].([]interface{})
+		for _, container := range containers {
+			container, _ := container.(map[string]interface{})
+			if env, ok := container["env"].([]interface{}); ok && isObjectList(env) && !hasEnvDependencies(env) {
+				if err := sortObjectList(env); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func isObjectList(list []interface{}) bool {
+	for _, item := range list {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hasEnvDependencies tells whether an env var value references another variable.
+func hasEnvDependencies(env []interface{}) bool {
+	for _, item := range env {
+		if value, ok := item.(map[string]interface{})["value"].(string); ok && strings.Contains(value, "$(") {
+			return true
+		}
+	}
+	return false
+}
+
+// sortObjectList sorts the items by their JSON representation, which starts with the keys in alphabetical order.
+func sortObjectList(list []interface{}) error {
+	keys := make(map[int]string, len(list))
+	indexes := make([]int, len(list))
+	for i, item := range list {
+		key, err := json.ConfigCompatibleWithStandardLibrary.Marshal(item)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal list item")
+		}
+		keys[i] = string(key)
+		indexes[i] = i
+	}
+
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return keys[indexes[a]] < keys[indexes[b]]
+	})
+
+	sorted := make([]interface{}, len(list))
+	for i, index := range indexes {
+		sorted[i] = list[index]
+	}
+	copy(list, sorted)
+
+	return nil
+}
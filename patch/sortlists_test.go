@@ -0,0 +1,86 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSortUnorderedLists(t *testing.T) {
+	newPod := func(env []corev1.EnvVar, tolerations []corev1.Toleration, pullSecrets []corev1.LocalObjectReference) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name:      "pod",
+				Namespace: "default",
+			},
+			Spec: corev1.PodSpec{
+				Containers:       []corev1.Container{{Name: "app", Image: "nginx", Env: env}},
+				Tolerations:      tolerations,
+				ImagePullSecrets: pullSecrets,
+			},
+		}
+	}
+
+	a := corev1.EnvVar{Name: "A", Value: "a"}
+	b := corev1.EnvVar{Name: "B", Value: "b"}
+	dependent := corev1.EnvVar{Name: "C", Value: "$(A)"}
+	infra := corev1.Toleration{Key: "infra", Operator: corev1.TolerationOpExists}
+	gpu := corev1.Toleration{Key: "gpu", Operator: corev1.TolerationOpExists}
+	registry := corev1.LocalObjectReference{Name: "registry"}
+	mirror := corev1.LocalObjectReference{Name: "mirror"}
+
+	current := newPod([]corev1.EnvVar{a, b}, []corev1.Toleration{infra, gpu}, []corev1.LocalObjectReference{registry, mirror})
+	mustAnnotate(current)
+	reordered := newPod([]corev1.EnvVar{b, a}, []corev1.Toleration{gpu, infra}, []corev1.LocalObjectReference{mirror, registry})
+
+	patch, err := DefaultPatchMaker.Calculate(current, reordered)
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.Calculate(current, reordered, SortUnorderedLists())
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	// The order of env vars referencing other variables matters
+	current = newPod([]corev1.EnvVar{a, dependent}, nil, nil)
+	mustAnnotate(current)
+
+	patch, err = DefaultPatchMaker.Calculate(current, newPod([]corev1.EnvVar{dependent, a}, nil, nil), SortUnorderedLists())
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	// Workload templates and CronJob job templates are sorted
+	for _, kind := range []string{`"apiVersion":"apps/v1","kind":"Deployment","spec":{"template":{"spec":%s}}`, `"apiVersion":"batch/v1","kind":"CronJob","spec":{"jobTemplate":{"spec":{"template":{"spec":%s}}}}`} {
+		current := []byte("{" + fmt.Sprintf(kind, `{"containers":[{"env":[{"name":"B"},{"name":"A"}]}],"tolerations":[{"key":"b"},{"key":"a"}]}`) + "}")
+		sorted, _, err := SortUnorderedLists()(current, current)
+		assert.NoError(t, err)
+		assert.JSONEq(t, "{"+fmt.Sprintf(kind, `{"containers":[{"env":[{"name":"A"},{"name":"B"}]}],"tolerations":[{"key":"a"},{"key":"b"}]}`)+"}", string(sorted))
+	}
+
+	// Lists outside of the pod specs and objects of other kinds are left untouched
+	for _, obj := range []string{
+		`{"apiVersion":"apps/v1","kind":"Deployment","spec":{"env":[{"name":"B"},{"name":"A"}],"template":{"spec":{"containers":[]}}}}`,
+		`{"apiVersion":"example.com/v1","kind":"App","spec":{"containers":[{"env":[{"name":"B"},{"name":"A"}]}],"tolerations":[{"key":"b"},{"key":"a"}]}}`,
+	} {
+		sorted, _, err := SortUnorderedLists()([]byte(obj), []byte(obj))
+		assert.NoError(t, err)
+		assert.JSONEq(t, obj, string(sorted))
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// OriginalConfigurationStore persists the last applied configuration of objects,
+// which is used as the original in the three way merge.
+type OriginalConfigurationStore interface {
+	// GetOriginalConfiguration returns the stored configuration of the object, or nil if there is none.
+	GetOriginalConfiguration(obj runtime.Object) ([]byte, error)
+	// SetOriginalConfiguration stores original as the configuration of the object.
+	SetOriginalConfiguration(obj runtime.Object, original []byte) error
+}
+
+var _ OriginalConfigurationStore = &Annotator{}
+
+// OriginalConfigurationStoreWithContext is implemented by the stores calling the API server, so the context of the
+// caller given to CalculateWithContext or SetLastAppliedConfigurationWithContext is used for the calls.
+type OriginalConfigurationStoreWithContext interface {
+	OriginalConfigurationStore
+	GetOriginalConfigurationWithContext(ctx context.Context, obj runtime.Object) ([]byte, error)
+	SetOriginalConfigurationWithContext(ctx context.Context, obj runtime.Object, original []byte) error
+}
+
+// getOriginalConfiguration reads the original configuration of the object from the store, with the context if the
+// store supports it.
+func getOriginalConfiguration(ctx context.Context, store OriginalConfigurationStore, obj runtime.Object) ([]byte, error) {
+	if store, ok := store.(OriginalConfigurationStoreWithContext); ok {
+		return store.GetOriginalConfigurationWithContext(ctx, obj)
+	}
+	return store.GetOriginalConfiguration(obj)
+}
+
+// LastAppliedAnnotator stores the last applied configuration of objects on the objects themselves, so it is set on the
+// patched objects too. Annotator implements it, the patchtest package provides fakes for tests.
+type LastAppliedAnnotator interface {
+	OriginalConfigurationStore
+	// SetLastAppliedAnnotation sets the configuration of the object on itself.
+	SetLastAppliedAnnotation(obj runtime.Object) error
+	// SetLastAppliedAnnotationToObject sets the configuration of objExpected on objModified.
+	SetLastAppliedAnnotationToObject(objModified runtime.Object, objExpected runtime.Object) error
+}
+
+var _ LastAppliedAnnotator = &Annotator{}
+
+// WithLastAppliedAnnotator makes the patch maker read the original configuration from the annotator and set it on the
+// patched objects instead of the annotator given to NewPatchMaker. The options relying on the annotation key, like the
+// last-applied hash of NewHashAnnotator, are only supported by an Annotator.
+func WithLastAppliedAnnotator(annotator LastAppliedAnnotator) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.annotator, _ = annotator.(*Annotator)
+		p.lastApplied = annotator
+		p.store = annotator
+	}
+}
+
+// WithOriginalConfigurationStore makes Calculate read the original configuration from
+// the given store instead of the annotation of the current object.
+func WithOriginalConfigurationStore(store OriginalConfigurationStore) PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.store = store
+	}
+}
+
+// SetLastAppliedConfiguration saves the configuration of the object in the store
+// so it can be used as the original when the object is compared next time.
+func SetLastAppliedConfiguration(store OriginalConfigurationStore, obj runtime.Object) error {
+	return SetLastAppliedConfigurationWithContext(context.Background(), store, obj)
+}
+
+// SetLastAppliedConfigurationWithContext is SetLastAppliedConfiguration passing the context to the stores
+// implementing OriginalConfigurationStoreWithContext.
+func SetLastAppliedConfigurationWithContext(ctx context.Context, store OriginalConfigurationStore, obj runtime.Object) error {
+	if annotator, ok := store.(LastAppliedAnnotator); ok {
+		return annotator.SetLastAppliedAnnotation(obj)
+	}
+
+	modified, err := json.ConfigCompatibleWithStandardLibrary.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	// Remove nulls from json
+	modifiedWithoutNulls, err := DeleteNullInJsonBytes(modified)
+	if err != nil {
+		return err
+	}
+	canonical, err := CanonicalJSON(modifiedWithoutNulls)
+	if err != nil {
+		return err
+	}
+	if store, ok := store.(OriginalConfigurationStoreWithContext); ok {
+		return store.SetOriginalConfigurationWithContext(ctx, obj, canonical)
+	}
+	return store.SetOriginalConfiguration(obj, canonical)
+}
+
+// InMemoryStore keeps original configurations in memory, it is lost when the process exits.
+type InMemoryStore struct {
+	mu             sync.RWMutex
+	configurations map[string][]byte
+}
+
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		configurations: map[string][]byte{},
+	}
+}
+
+func (s *InMemoryStore) GetOriginalConfiguration(obj runtime.Object) ([]byte, error) {
+	key, err := objectStoreKey(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.configurations[key], nil
+}
+
+func (s *InMemoryStore) SetOriginalConfiguration(obj runtime.Object, original []byte) error {
+	if len(original) < 1 {
+		return nil
+	}
+
+	key, err := objectStoreKey(obj)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.configurations[key] = append([]byte{}, original...)
+	return nil
+}
+
+// objectStoreKey identifies an object by its group, kind, namespace and name.
+func objectStoreKey(obj runtime.Object) (string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", errors.Wrap(err, "could not access object metadata")
+	}
+	if accessor.GetName() == "" {
+		return "", errors.New("object has no name")
+	}
+
+	return strings.Join([]string{objectGroupKind(obj), accessor.GetNamespace(), accessor.GetName()}, "/"), nil
+}
+
+// objectGroupKind returns the lower case kind and group of the object, e.g. deployment.apps, or the kind alone for
+// the core group. The kind is resolved from the client-go scheme, or is the Go type name when it's unknown.
+func objectGroupKind(obj runtime.Object) string {
+	groupKind := objectGroupVersionKind(obj).GroupKind()
+	if groupKind.Kind == "" {
+		groupKind.Kind = reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+	}
+	return strings.ToLower(groupKind.String())
+}
@@ -0,0 +1,262 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// OriginalConfigurationDataKey is the key holding the original configuration in sidecar ConfigMaps and Secrets.
+const OriginalConfigurationDataKey = "original"
+
+// ConfigMapStore keeps the original configuration of each object in a sidecar ConfigMap,
+// which allows configurations larger than the annotation size limit. The ConfigMap is named after the lower case kind and group of the object, e.g. deployment.apps-app-last-applied.
+// It lives in the namespace of the object, or in the store namespace for cluster scoped objects, which is then required,
+// and is owned by the object when possible so it is garbage collected along with it. Concurrent updates are retried.
+type ConfigMapStore struct {
+	client    corev1client.ConfigMapsGetter
+	namespace string
+}
+
+func NewConfigMapStore(client corev1client.ConfigMapsGetter, namespace string) *ConfigMapStore {
+	return &ConfigMapStore{
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+var _ OriginalConfigurationStoreWithContext = &ConfigMapStore{}
+
+func (s *ConfigMapStore) GetOriginalConfiguration(obj runtime.Object) ([]byte, error) {
+	return s.GetOriginalConfigurationWithContext(context.Background(), obj)
+}
+
+func (s *ConfigMapStore) GetOriginalConfigurationWithContext(ctx context.Context, obj runtime.Object) ([]byte, error) {
+	namespace, name, err := sidecarLocation(obj, s.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	configMap, err := s.client.ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WrapWithDetails(err, "could not get original configuration ConfigMap", "namespace", namespace, "name", name)
+	}
+
+	original, ok := configMap.Data[OriginalConfigurationDataKey]
+	if !ok {
+		return nil, nil
+	}
+
+	return decodeOriginalConfiguration(original)
+}
+
+func (s *ConfigMapStore) SetOriginalConfiguration(obj runtime.Object, original []byte) error {
+	return s.SetOriginalConfigurationWithContext(context.Background(), obj, original)
+}
+
+func (s *ConfigMapStore) SetOriginalConfigurationWithContext(ctx context.Context, obj runtime.Object, original []byte) error {
+	if len(original) < 1 {
+		return nil
+	}
+
+	namespace, name, err := sidecarLocation(obj, s.namespace)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := zipAndBase64EncodeAnnotation(original)
+	if err != nil {
+		return err
+	}
+
+	configMaps := s.client.ConfigMaps(namespace)
+	err = retry.OnError(retry.DefaultRetry, isConflictOrAlreadyExists, func() error {
+		configMap, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: sidecarObjectMeta(obj, namespace, name),
+				Data: map[string]string{
+					OriginalConfigurationDataKey: encoded,
+				},
+			}
+			_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[OriginalConfigurationDataKey] = encoded
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+	return errors.WrapIfWithDetails(err, "could not store original configuration in ConfigMap", "namespace", namespace, "name", name)
+}
+
+// SecretStore works like ConfigMapStore but keeps the original configuration in a Secret,
+// which is preferable when the objects hold sensitive data.
+type SecretStore struct {
+	client    corev1client.SecretsGetter
+	namespace string
+}
+
+func NewSecretStore(client corev1client.SecretsGetter, namespace string) *SecretStore {
+	return &SecretStore{
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+var _ OriginalConfigurationStoreWithContext = &SecretStore{}
+
+func (s *SecretStore) GetOriginalConfiguration(obj runtime.Object) ([]byte, error) {
+	return s.GetOriginalConfigurationWithContext(context.Background(), obj)
+}
+
+func (s *SecretStore) GetOriginalConfigurationWithContext(ctx context.Context, obj runtime.Object) ([]byte, error) {
+	namespace, name, err := sidecarLocation(obj, s.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := s.client.Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WrapWithDetails(err, "could not get original configuration Secret", "namespace", namespace, "name", name)
+	}
+
+	original, ok := secret.Data[OriginalConfigurationDataKey]
+	if !ok {
+		return nil, nil
+	}
+
+	return decodeOriginalConfiguration(string(original))
+}
+
+func (s *SecretStore) SetOriginalConfiguration(obj runtime.Object, original []byte) error {
+	return s.SetOriginalConfigurationWithContext(context.Background(), obj, original)
+}
+
+func (s *SecretStore) SetOriginalConfigurationWithContext(ctx context.Context, obj runtime.Object, original []byte) error {
+	if len(original) < 1 {
+		return nil
+	}
+
+	namespace, name, err := sidecarLocation(obj, s.namespace)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := zipAndBase64EncodeAnnotation(original)
+	if err != nil {
+		return err
+	}
+
+	secrets := s.client.Secrets(namespace)
+	err = retry.OnError(retry.DefaultRetry, isConflictOrAlreadyExists, func() error {
+		secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			secret = &corev1.Secret{
+				ObjectMeta: sidecarObjectMeta(obj, namespace, name),
+				Data: map[string][]byte{
+					OriginalConfigurationDataKey: []byte(encoded),
+				},
+			}
+			_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[OriginalConfigurationDataKey] = []byte(encoded)
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+		return err
+	})
+	return errors.WrapIfWithDetails(err, "could not store original configuration in Secret", "namespace", namespace, "name", name)
+}
+
+// isConflictOrAlreadyExists tells whether the sidecar object was updated or created concurrently, in which case it's
+// read again before retrying.
+func isConflictOrAlreadyExists(err error) bool {
+	return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+}
+
+// sidecarLocation returns the namespace and name of the sidecar object holding the original configuration of obj.
+func sidecarLocation(obj runtime.Object, defaultNamespace string) (string, string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return "", "", errors.Wrap(err, "could not access object metadata")
+	}
+	if accessor.GetName() == "" {
+		return "", "", errors.New("object has no name")
+	}
+
+	namespace := accessor.GetNamespace()
+	if namespace == "" {
+		if defaultNamespace == "" {
+			return "", "", errors.NewWithDetails("the store has no namespace for cluster scoped objects", "name", accessor.GetName())
+		}
+		namespace = defaultNamespace
+	}
+
+	return namespace, objectGroupKind(obj) + "-" + accessor.GetName() + "-last-applied", nil
+}
+
+func sidecarObjectMeta(obj runtime.Object, namespace, name string) metav1.ObjectMeta {
+	objectMeta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return objectMeta
+	}
+	gvk := objectGroupVersionKind(obj)
+	// Namespaced owners must live in the namespace of their dependents
+	if accessor.GetUID() != "" && gvk.Kind != "" && (accessor.GetNamespace() == "" || accessor.GetNamespace() == namespace) {
+		objectMeta.OwnerReferences = []metav1.OwnerReference{
+			{
+				APIVersion: gvk.GroupVersion().String(),
+				Kind:       gvk.Kind,
+				Name:       accessor.GetName(),
+				UID:        accessor.GetUID(),
+			},
+		}
+	}
+
+	return objectMeta
+}
@@ -0,0 +1,203 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestInMemoryStore(t *testing.T) {
+	store := NewInMemoryStore()
+	maker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithOriginalConfigurationStore(store))
+
+	original := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"a": "b",
+			"c": "d",
+		},
+	}
+	assert.NoError(t, SetLastAppliedConfiguration(store, original))
+	_, hasAnnotation := original.Annotations[LastAppliedConfig]
+	assert.False(t, hasAnnotation)
+
+	// The field removed from the modified object is deleted thanks to the stored original
+	modified := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+		Data: map[string]string{
+			"a": "b",
+		},
+	}
+	patch, err := maker.Calculate(original, modified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"c":null}}`, string(patch.Patch))
+
+	// Without the original nothing is deleted
+	patch, err = DefaultPatchMaker.Calculate(original, modified)
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+}
+
+func TestConfigMapStore(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store := NewConfigMapStore(client.CoreV1(), "objectmatcher")
+
+	service := &corev1.Service{
+		TypeMeta: v1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Service",
+		},
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "my-service",
+			Namespace: "default",
+			UID:       "1234",
+		},
+	}
+
+	original, err := store.GetOriginalConfiguration(service)
+	assert.NoError(t, err)
+	assert.Nil(t, original)
+
+	assert.NoError(t, store.SetOriginalConfiguration(service, []byte(`{"a":"b"}`)))
+	assert.NoError(t, store.SetOriginalConfiguration(service, []byte(`{"a":"c"}`)))
+
+	original, err = store.GetOriginalConfiguration(service)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":"c"}`, string(original))
+
+	configMap, err := client.CoreV1().ConfigMaps("default").Get(context.TODO(), "service-my-service-last-applied", v1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Service", configMap.OwnerReferences[0].Kind)
+	assert.Equal(t, "1234", string(configMap.OwnerReferences[0].UID))
+
+	// Cluster scoped objects are stored in the namespace of the store
+	clusterRole := &rbacv1.ClusterRole{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "my-role",
+		},
+	}
+	assert.NoError(t, store.SetOriginalConfiguration(clusterRole, []byte(`{"a":"b"}`)))
+	_, err = client.CoreV1().ConfigMaps("objectmatcher").Get(context.TODO(), "clusterrole.rbac.authorization.k8s.io-my-role-last-applied", v1.GetOptions{})
+	assert.NoError(t, err)
+
+	// Objects of the same kind and name from other groups are stored apart
+	deployment := &unstructured.Unstructured{}
+	deployment.SetAPIVersion("example.com/v1")
+	deployment.SetKind("Deployment")
+	deployment.SetNamespace("default")
+	deployment.SetName("app")
+	assert.NoError(t, store.SetOriginalConfiguration(deployment, []byte(`{"a":"b"}`)))
+	original, err = store.GetOriginalConfiguration(&appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Name: "app", Namespace: "default"}})
+	assert.NoError(t, err)
+	assert.Nil(t, original)
+	_, err = client.CoreV1().ConfigMaps("default").Get(context.TODO(), "deployment.example.com-app-last-applied", v1.GetOptions{})
+	assert.NoError(t, err)
+
+	// Cluster scoped objects require the namespace of the store
+	assert.Error(t, NewConfigMapStore(client.CoreV1(), "").SetOriginalConfiguration(clusterRole, []byte(`{"a":"b"}`)))
+}
+
+func TestConfigMapStoreRetriesOnConflict(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store := NewConfigMapStore(client.CoreV1(), "objectmatcher")
+	service := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "my-service", Namespace: "default"}}
+	assert.NoError(t, store.SetOriginalConfiguration(service, []byte(`{"a":"b"}`)))
+
+	conflicts := 0
+	client.PrependReactor("update", "configmaps", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if conflicts > 0 {
+			return false, nil, nil
+		}
+		conflicts++
+		return true, nil, apierrors.NewConflict(corev1.Resource("configmaps"), "service-my-service-last-applied", errors.New("modified"))
+	})
+	assert.NoError(t, store.SetOriginalConfiguration(service, []byte(`{"a":"c"}`)))
+	assert.Equal(t, 1, conflicts)
+
+	original, err := store.GetOriginalConfiguration(service)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":"c"}`, string(original))
+}
+
+type contextStore struct {
+	*InMemoryStore
+	contexts []context.Context
+}
+
+func (s *contextStore) GetOriginalConfigurationWithContext(ctx context.Context, obj runtime.Object) ([]byte, error) {
+	s.contexts = append(s.contexts, ctx)
+	return s.GetOriginalConfiguration(obj)
+}
+
+func (s *contextStore) SetOriginalConfigurationWithContext(ctx context.Context, obj runtime.Object, original []byte) error {
+	s.contexts = append(s.contexts, ctx)
+	return s.SetOriginalConfiguration(obj, original)
+}
+
+func TestOriginalConfigurationStoreWithContext(t *testing.T) {
+	type key struct{}
+	ctx := context.WithValue(context.Background(), key{}, "caller")
+	store := &contextStore{InMemoryStore: NewInMemoryStore()}
+	maker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{}, WithOriginalConfigurationStore(store))
+
+	configMap := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config", Namespace: "default"}, Data: map[string]string{"a": "b"}}
+	assert.NoError(t, SetLastAppliedConfigurationWithContext(ctx, store, configMap))
+	_, err := maker.(CtxMaker).CalculateWithContext(ctx, configMap, configMap)
+	assert.NoError(t, err)
+
+	assert.Len(t, store.contexts, 2)
+	for _, storeCtx := range store.contexts {
+		assert.Equal(t, "caller", storeCtx.Value(key{}))
+	}
+}
+
+func TestSecretStore(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	store := NewSecretStore(client.CoreV1(), "objectmatcher")
+
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:      "credentials",
+			Namespace: "default",
+		},
+	}
+
+	assert.NoError(t, store.SetOriginalConfiguration(secret, []byte(`{"a":"b"}`)))
+	original, err := store.GetOriginalConfiguration(secret)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":"b"}`, string(original))
+
+	_, err = client.CoreV1().Secrets("default").Get(context.TODO(), "secret-credentials-last-applied", v1.GetOptions{})
+	assert.NoError(t, err)
+}
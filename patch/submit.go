@@ -0,0 +1,189 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	jsonpatch "github.com/evanphx/json-patch"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+// PatchRequest is a patch as sent to the API server.
+type PatchRequest struct {
+	Type types.PatchType
+	Data []byte
+
+	// FieldManager and Force are set for server-side apply patches.
+	FieldManager string
+	Force        bool
+
+	// DryRun is set when the patch must be validated by the API server without being persisted (dryRun=All).
+	DryRun bool
+}
+
+// PatchClient submits patches to the API server. Patch decodes the object returned by the API server into obj,
+// which holds the kind, the name and the namespace of the object to patch.
+type PatchClient interface {
+	Patch(ctx context.Context, obj runtime.Object, request PatchRequest) error
+}
+
+// ApplyOption customizes PatchResult.Apply.
+type ApplyOption func(*applyOptions)
+
+type applyOptions struct {
+	withoutLastApplied bool
+	resourceVersion    bool
+}
+
+// WithoutLastAppliedUpdate leaves the last-applied annotation as it is on the current object.
+func WithoutLastAppliedUpdate() ApplyOption {
+	return func(o *applyOptions) {
+		o.withoutLastApplied = true
+	}
+}
+
+// WithResourceVersionCheck adds the resourceVersion of the current object to the patch, so the API server rejects it
+// with a conflict when the object has been modified since it was read.
+func WithResourceVersionCheck() ApplyOption {
+	return func(o *applyOptions) {
+		o.resourceVersion = true
+	}
+}
+
+// Apply submits the patch and returns the object returned by the API server. Nothing is sent when the patch is empty,
+// the patched object is returned as is.
+//
+// The patch sent turns the current object into the patched object, so it also updates the last-applied annotation,
+// unless WithoutLastAppliedUpdate is set. It is a strategic merge patch for the built-in types, a JSON merge patch for
+// the other objects, since the API server doesn't accept strategic merge patches for custom resources, and an apply patch
+// for server-side apply results.
+func (p *PatchResult) Apply(ctx context.Context, client PatchClient, opts ...ApplyOption) (runtime.Object, error) {
+	if p.redacted {
+		return nil, ErrRedacted
+	}
+	patched, ok := p.Patched.(runtime.Object)
+	if !ok {
+		return nil, errors.New("patch result does not contain the patched object")
+	}
+	obj := patched.DeepCopyObject()
+	if p.IsEmpty() {
+		return obj, nil
+	}
+
+	request, err := p.PatchRequest(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Patch(ctx, obj, request); err != nil {
+		return nil, errors.Wrap(err, "could not patch the object")
+	}
+	return obj, nil
+}
+
+// PatchRequest returns the request sent by Apply.
+func (p *PatchResult) PatchRequest(opts ...ApplyOption) (PatchRequest, error) {
+	if p.redacted {
+		return PatchRequest{}, ErrRedacted
+	}
+	o := applyOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if p.FieldManager != "" {
+		return PatchRequest{
+			Type:         types.ApplyPatchType,
+			Data:         p.Patch,
+			FieldManager: p.FieldManager,
+			Force:        p.Force,
+		}, nil
+	}
+
+	patched := p.patchedCurrent
+	if !o.withoutLastApplied {
+		var err error
+		patched, err = json.ConfigCompatibleWithStandardLibrary.Marshal(p.Patched)
+		if err != nil {
+			return PatchRequest{}, errors.Wrap(err, "could not marshal the patched object")
+		}
+	}
+
+	request := PatchRequest{Type: types.MergePatchType}
+	var err error
+	if obj, ok := p.Patched.(runtime.Object); ok && p.patchType == types.StrategicMergePatchType && isBuiltinType(obj) {
+		request.Type = types.StrategicMergePatchType
+		request.Data, err = strategicpatch.CreateTwoWayMergePatch(p.currentOrg, patched, obj)
+	} else {
+		request.Data, err = jsonpatch.CreateMergePatch(p.currentOrg, patched)
+	}
+	if err != nil {
+		return PatchRequest{}, errors.Wrap(err, "could not create the patch")
+	}
+
+	if o.resourceVersion {
+		request.Data, err = withResourceVersion(request.Data, p.currentOrg)
+		if err != nil {
+			return PatchRequest{}, err
+		}
+	}
+	return request, nil
+}
+
+// isBuiltinType tells whether the object is a typed object of the client-go scheme.
+func isBuiltinType(obj runtime.Object) bool {
+	if _, ok := obj.(runtime.Unstructured); ok {
+		return false
+	}
+	_, _, err := clientgoscheme.Scheme.ObjectKinds(obj)
+	return err == nil
+}
+
+// withResourceVersion adds the resourceVersion of the current document to the patch.
+func withResourceVersion(patch, current []byte) ([]byte, error) {
+	var currentResource struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(current, &currentResource); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal the current object")
+	}
+	if currentResource.Metadata.ResourceVersion == "" {
+		return patch, nil
+	}
+
+	var patchMap map[string]interface{}
+	if err := json.Unmarshal(patch, &patchMap); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal the patch")
+	}
+	metadata, ok := patchMap["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = map[string]interface{}{}
+		patchMap["metadata"] = metadata
+	}
+	metadata["resourceVersion"] = currentResource.Metadata.ResourceVersion
+
+	patch, err := json.ConfigCompatibleWithStandardLibrary.Marshal(patchMap)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal the patch")
+	}
+	return patch, nil
+}
@@ -0,0 +1,186 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"context"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// recordingPatchClient applies the patches on the stored object like the API server would.
+type recordingPatchClient struct {
+	stored   []byte
+	requests []PatchRequest
+}
+
+func (c *recordingPatchClient) Patch(_ context.Context, obj runtime.Object, request PatchRequest) error {
+	c.requests = append(c.requests, request)
+
+	var patched []byte
+	var err error
+	if request.Type == types.StrategicMergePatchType {
+		patched, err = strategicpatch.StrategicMergePatch(c.stored, request.Data, obj)
+	} else {
+		patched, err = jsonpatch.MergePatch(c.stored, request.Data)
+	}
+	if err != nil {
+		return err
+	}
+	c.stored = patched
+	return json.Unmarshal(patched, obj)
+}
+
+func TestPatchResultApply(t *testing.T) {
+	newConfigMap := func(value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: v1.ObjectMeta{
+				Name:            "config",
+				Namespace:       "default",
+				ResourceVersion: "42",
+			},
+			Data: map[string]string{"key": value},
+		}
+	}
+
+	current := newConfigMap("a")
+	mustAnnotate(current)
+	stored, err := json.Marshal(current)
+	require.NoError(t, err)
+
+	result, err := DefaultPatchMaker.Calculate(current, newConfigMap("a"))
+	require.NoError(t, err)
+	client := &recordingPatchClient{stored: stored}
+	obj, err := result.Apply(context.Background(), client)
+	require.NoError(t, err)
+	assert.Empty(t, client.requests)
+	assert.Equal(t, "a", obj.(*corev1.ConfigMap).Data["key"])
+
+	result, err = DefaultPatchMaker.Calculate(current, newConfigMap("b"))
+	require.NoError(t, err)
+	obj, err = result.Apply(context.Background(), client, WithResourceVersionCheck())
+	require.NoError(t, err)
+	require.Len(t, client.requests, 1)
+	assert.Equal(t, types.StrategicMergePatchType, client.requests[0].Type)
+	assert.Contains(t, string(client.requests[0].Data), `"resourceVersion":"42"`)
+	assert.Equal(t, "b", obj.(*corev1.ConfigMap).Data["key"])
+
+	// The last-applied annotation is updated along with the data
+	updated := obj.(*corev1.ConfigMap)
+	result, err = DefaultPatchMaker.Calculate(updated, newConfigMap("b"))
+	require.NoError(t, err)
+	assert.True(t, result.IsEmpty())
+
+	request, err := result.PatchRequest()
+	require.NoError(t, err)
+	assert.Equal(t, types.StrategicMergePatchType, request.Type)
+
+	result, err = DefaultPatchMaker.Calculate(current, newConfigMap("c"))
+	require.NoError(t, err)
+	request, err = result.PatchRequest(WithoutLastAppliedUpdate())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"key":"c"}}`, string(request.Data))
+}
+
+func TestPatchResultApplyUnstructured(t *testing.T) {
+	newApp := func(replicas int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "App",
+			"metadata": map[string]interface{}{
+				"name": "app",
+			},
+			"spec": map[string]interface{}{
+				"replicas": replicas,
+			},
+		}}
+	}
+
+	current := newApp(1)
+	mustAnnotate(current)
+	stored, err := json.Marshal(current)
+	require.NoError(t, err)
+
+	result, err := DefaultPatchMaker.Calculate(current, newApp(2))
+	require.NoError(t, err)
+	client := &recordingPatchClient{stored: stored}
+	obj, err := result.Apply(context.Background(), client)
+	require.NoError(t, err)
+	require.Len(t, client.requests, 1)
+	assert.Equal(t, types.MergePatchType, client.requests[0].Type)
+
+	replicas, _, _ := unstructured.NestedInt64(obj.(*unstructured.Unstructured).Object, "spec", "replicas")
+	assert.Equal(t, int64(2), replicas)
+
+	// Typed custom resources are patched with JSON merge patches as well
+	app := &testApp{
+		TypeMeta:   v1.TypeMeta{APIVersion: "example.com/v1", Kind: "App"},
+		ObjectMeta: v1.ObjectMeta{Name: "app"},
+	}
+	mustAnnotate(app)
+	scaled := app.DeepCopyObject().(*testApp)
+	scaled.Spec.Replicas = 2
+
+	result, err = DefaultPatchMaker.Calculate(app, scaled)
+	require.NoError(t, err)
+	request, err := result.PatchRequest()
+	require.NoError(t, err)
+	assert.Equal(t, types.MergePatchType, request.Type)
+}
+
+func TestPatchResultPatchType(t *testing.T) {
+	newPod := func(image string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{Name: "pod"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: image}}},
+		}
+	}
+	current := newPod("app:1")
+	mustAnnotate(current)
+
+	result, err := DefaultPatchMaker.Calculate(current, newPod("app:2"))
+	require.NoError(t, err)
+	assert.Equal(t, types.StrategicMergePatchType, result.PatchType())
+
+	patchMaker := NewPatchMaker(DefaultAnnotator, &K8sStrategicMergePatcher{}, &BaseJSONMergePatcher{},
+		WithJSONMergeKinds(corev1.SchemeGroupVersion.WithKind("Pod")),
+	)
+	result, err = patchMaker.Calculate(current, newPod("app:2"))
+	require.NoError(t, err)
+	assert.Equal(t, types.MergePatchType, result.PatchType())
+
+	result, err = DefaultPatchMaker.(MetadataMaker).CalculateMetadataOnly(current, newPod("app:2"))
+	require.NoError(t, err)
+	assert.Equal(t, types.MergePatchType, result.PatchType())
+
+	unstructuredCurrent := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "App",
+		"metadata":   map[string]interface{}{"name": "app"},
+	}}
+	result, err = DefaultPatchMaker.Calculate(unstructuredCurrent, unstructuredCurrent.DeepCopy())
+	require.NoError(t, err)
+	assert.Equal(t, types.MergePatchType, result.PatchType())
+}
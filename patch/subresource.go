@@ -0,0 +1,227 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// WithStatusInMainResource makes Calculate compare the status like the other fields. By default the status is left out,
+// since the API server ignores it in the updates of the objects having a status subresource, see CalculateStatus.
+// This option is meant for custom resources without a status subresource.
+func WithStatusInMainResource() PatchMakerOption {
+	return func(p *PatchMaker) {
+		p.statusInMainResource = true
+	}
+}
+
+// withoutSubresources removes the fields updated through subresources from the document.
+func (p *PatchMaker) withoutSubresources(document []byte) ([]byte, error) {
+	if p.statusInMainResource || len(document) == 0 {
+		return document, nil
+	}
+	return deleteStatusField(document)
+}
+
+// CalculateStatus compares only the status of the objects and returns the patch to send to the status subresource,
+// a strategic merge patch for the typed objects and a JSON merge patch for the unstructured ones. The status is owned
+// by the controller as a whole, so the patch is calculated from the current status only, the original configuration
+// isn't used. An empty patch is returned when the modified object has no status.
+func (p *PatchMaker) CalculateStatus(currentObject, modifiedObject runtime.Object) (*PatchResult, error) {
+	currentOrg, err := p.jsonConfig.Marshal(currentObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert current object to byte sequence")
+	}
+
+	current, err := statusDocument(currentObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get status of current object")
+	}
+	modified, err := statusDocument(modifiedObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get status of modified object")
+	}
+
+	patch := []byte("{}")
+	patchedCurrent := currentOrg
+	patchType := types.MergePatchType
+	if string(modified) != "{}" {
+		switch currentObject.(type) {
+		case *unstructured.Unstructured:
+			patch, patchedCurrent, err = p.unstructuredJsonMergePatch(current, modified, current, currentOrg)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to generate status merge patch")
+			}
+		default:
+			patchType = types.StrategicMergePatchType
+			patch, err = p.strategicMergePatcher.CreateThreeWayMergePatch(current, modified, current, currentObject)
+			if err != nil {
+				return nil, errors.Wrap(err, "Failed to generate status strategic merge patch")
+			}
+			if string(patch) != "{}" {
+				patchedCurrent, err = p.strategicMergePatcher.StrategicMergePatch(currentOrg, patch, currentObject)
+				if err != nil {
+					return nil, errors.Wrap(err, "Failed to apply status patch")
+				}
+			}
+		}
+	}
+
+	patched, err := newObjectFromJSON(p.jsonConfig, currentObject, patchedCurrent)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create patched object")
+	}
+
+	result := &PatchResult{
+		Patch:    patch,
+		Current:  current,
+		Modified: modified,
+		Patched:  patched,
+
+		currentOrg:     currentOrg,
+		patchedCurrent: patchedCurrent,
+		patchType:      patchType,
+	}
+	if err := canonicalResult(result); err != nil {
+		return nil, errors.Wrap(err, "Failed to sort the keys of the result documents")
+	}
+
+	return result, nil
+}
+
+// CalculateScale compares the replicas of the objects and returns the JSON merge patch to send to the scale subresource,
+// e.g. to scale a workload whose other fields are managed elsewhere. Combined with IgnoreJSONPath(".spec.replicas"),
+// the main patch and the scale patch can be sent separately. An empty patch is returned when the modified object doesn't
+// set the replicas.
+func (p *PatchMaker) CalculateScale(currentObject, modifiedObject runtime.Object) (*PatchResult, error) {
+	currentOrg, err := p.jsonConfig.Marshal(currentObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert current object to byte sequence")
+	}
+
+	currentReplicas, err := replicasOf(currentOrg)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get replicas of current object")
+	}
+	modifiedJSON, err := p.jsonConfig.Marshal(modifiedObject)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert modified object to byte sequence")
+	}
+	modifiedReplicas, err := replicasOf(modifiedJSON)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get replicas of modified object")
+	}
+
+	current, err := scaleDocument(currentReplicas)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert current replicas to byte sequence")
+	}
+	modified, err := scaleDocument(modifiedReplicas)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to convert modified replicas to byte sequence")
+	}
+
+	patch := []byte("{}")
+	patchedCurrent := currentOrg
+	if modifiedReplicas != nil && !jsonNumbersEqual(currentReplicas, modifiedReplicas) {
+		patch = modified
+		patchedCurrent, err = p.jsonMergePatcher.MergePatch(currentOrg, patch)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to apply scale patch")
+		}
+	}
+
+	patched, err := newObjectFromJSON(p.jsonConfig, currentObject, patchedCurrent)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create patched object")
+	}
+
+	return &PatchResult{
+		Patch:    patch,
+		Current:  current,
+		Modified: modified,
+		Patched:  patched,
+
+		currentOrg:     currentOrg,
+		patchedCurrent: patchedCurrent,
+		patchType:      types.MergePatchType,
+	}, nil
+}
+
+// statusDocument returns a document holding only the status of the object, without null values.
+func statusDocument(obj runtime.Object) ([]byte, error) {
+	data, err := json.ConfigCompatibleWithStandardLibrary.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	var resource map[string]interface{}
+	if err := json.Unmarshal(data, &resource); err != nil {
+		return nil, err
+	}
+
+	document := map[string]interface{}{}
+	if status, ok := resource["status"]; ok && status != nil {
+		document["status"] = status
+	}
+	data, err = json.ConfigCompatibleWithStandardLibrary.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	data, err = DeleteNullInJsonBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	// An empty status is no status
+	if string(data) == `{"status":{}}` {
+		return []byte("{}"), nil
+	}
+	return data, nil
+}
+
+// replicasOf returns spec.replicas of the document, or nil when it isn't set.
+func replicasOf(document []byte) (interface{}, error) {
+	var resource map[string]interface{}
+	if err := json.Unmarshal(document, &resource); err != nil {
+		return nil, err
+	}
+	spec, ok := resource["spec"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	return spec["replicas"], nil
+}
+
+// scaleDocument returns the document of a Scale object with the given replicas.
+func scaleDocument(replicas interface{}) ([]byte, error) {
+	if replicas == nil {
+		return []byte("{}"), nil
+	}
+	return json.ConfigCompatibleWithStandardLibrary.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"replicas": replicas},
+	})
+}
+
+func jsonNumbersEqual(a, b interface{}) bool {
+	aNumber, ok := a.(float64)
+	if !ok {
+		return false
+	}
+	bNumber, ok := b.(float64)
+	return ok && aNumber == bNumber
+}
@@ -0,0 +1,119 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCalculateStatus(t *testing.T) {
+	newPod := func(phase corev1.PodPhase, conditions ...corev1.PodCondition) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "pod",
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "app", Image: "nginx"}},
+			},
+			Status: corev1.PodStatus{
+				Phase:      phase,
+				Conditions: conditions,
+			},
+		}
+	}
+	ready := corev1.PodCondition{Type: corev1.PodReady, Status: corev1.ConditionTrue}
+	scheduled := corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}
+
+	current := newPod(corev1.PodPending, scheduled)
+	mustAnnotate(current)
+
+	patch, err := DefaultPatchMaker.(SubresourceMaker).CalculateStatus(current, newPod(corev1.PodPending, scheduled))
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.(SubresourceMaker).CalculateStatus(current, newPod(corev1.PodRunning, scheduled, ready))
+	assert.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+	assert.NotContains(t, string(patch.Patch), "spec")
+	assert.Equal(t, corev1.PodRunning, patch.Patched.(*corev1.Pod).Status.Phase)
+	assert.Len(t, patch.Patched.(*corev1.Pod).Status.Conditions, 2)
+
+	// The main patch leaves the status out
+	patch, err = DefaultPatchMaker.Calculate(current, newPod(corev1.PodRunning, scheduled, ready))
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	// No status, no patch
+	patch, err = DefaultPatchMaker.(SubresourceMaker).CalculateStatus(current, newPod(""))
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+}
+
+func TestCalculateStatusUnstructured(t *testing.T) {
+	newFoo := func(status map[string]interface{}) *unstructured.Unstructured {
+		foo := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Foo",
+			"metadata": map[string]interface{}{
+				"name": "foo",
+			},
+		}}
+		if status != nil {
+			foo.Object["status"] = status
+		}
+		return foo
+	}
+
+	current := newFoo(map[string]interface{}{"ready": false, "observedGeneration": int64(1)})
+
+	patch, err := DefaultPatchMaker.(SubresourceMaker).CalculateStatus(current, newFoo(map[string]interface{}{"ready": true, "observedGeneration": int64(1)}))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"status":{"ready":true}}`, string(patch.Patch))
+}
+
+func TestCalculateScale(t *testing.T) {
+	newDeployment := func(replicas *int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: v1.ObjectMeta{
+				Name: "deployment",
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: replicas,
+			},
+		}
+	}
+	int32Ptr := func(i int32) *int32 { return &i }
+
+	current := newDeployment(int32Ptr(1))
+
+	patch, err := DefaultPatchMaker.(SubresourceMaker).CalculateScale(current, newDeployment(int32Ptr(1)))
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.(SubresourceMaker).CalculateScale(current, newDeployment(nil))
+	assert.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+
+	patch, err = DefaultPatchMaker.(SubresourceMaker).CalculateScale(current, newDeployment(int32Ptr(3)))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"spec":{"replicas":3}}`, string(patch.Patch))
+	assert.Equal(t, int32(3), *patch.Patched.(*appsv1.Deployment).Spec.Replicas)
+}
@@ -0,0 +1,51 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TypedPatchResult is a PatchResult holding the patched object with the type of the compared objects.
+type TypedPatchResult[T runtime.Object] struct {
+	*PatchResult
+
+	// Patched shadows PatchResult.Patched with the concrete type.
+	Patched T
+}
+
+// CalculateTyped calculates the patch with the DefaultPatchMaker and returns the patched object as T.
+func CalculateTyped[T runtime.Object](current, modified T, opts ...CalculateOption) (*TypedPatchResult[T], error) {
+	return CalculateTypedWith(DefaultPatchMaker, current, modified, opts...)
+}
+
+// CalculateTypedWith calculates the patch with the given maker and returns the patched object as T.
+func CalculateTypedWith[T runtime.Object](maker Maker, current, modified T, opts ...CalculateOption) (*TypedPatchResult[T], error) {
+	result, err := maker.Calculate(current, modified, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, ok := result.Patched.(T)
+	if !ok {
+		return nil, errors.Errorf("patched object has type %T instead of %T", result.Patched, patched)
+	}
+
+	return &TypedPatchResult[T]{
+		PatchResult: result,
+		Patched:     patched,
+	}, nil
+}
@@ -0,0 +1,52 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestCalculateTyped(t *testing.T) {
+	current := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name: "config",
+		},
+		Data: map[string]string{
+			"a": "b",
+		},
+	}
+	modified := current.DeepCopy()
+	modified.Data["a"] = "c"
+
+	result, err := CalculateTyped(current, modified)
+	assert.NoError(t, err)
+	assert.False(t, result.IsEmpty())
+	assert.Equal(t, "c", result.Patched.Data["a"])
+	assert.Equal(t, result.Patched, result.PatchResult.Patched)
+
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Foo",
+	}}
+	unstructuredResult, err := CalculateTypedWith(DefaultPatchMaker, u, u)
+	assert.NoError(t, err)
+	assert.True(t, unstructuredResult.IsEmpty())
+	assert.Equal(t, "Foo", unstructuredResult.Patched.GetKind())
+}
@@ -0,0 +1,160 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"bytes"
+
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// withTypeMeta sets the apiVersion and kind of the GVK first in the JSON object document when it doesn't carry a kind,
+// typed objects being usually marshalled without TypeMeta, so the options can tell the kind of the documents they
+// receive. It returns the prefix added to the document, which is empty when the document is left as is.
+func withTypeMeta(document []byte, gvk schema.GroupVersionKind) ([]byte, []byte, error) {
+	if gvk.Kind == "" || len(document) == 0 || document[0] != '{' || json.Get(document, "kind").ToString() != "" {
+		return document, nil, nil
+	}
+
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	typeMeta, err := json.ConfigCompatibleWithStandardLibrary.Marshal(struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}{apiVersion, kind})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not marshal type meta")
+	}
+	prefix := typeMeta[:len(typeMeta)-1]
+
+	withTypeMeta := make([]byte, 0, len(prefix)+len(document))
+	withTypeMeta = append(withTypeMeta, prefix...)
+	if rest := bytes.TrimLeft(document[1:], " \t\r\n"); len(rest) > 0 && rest[0] != '}' {
+		withTypeMeta = append(withTypeMeta, ',')
+	}
+	withTypeMeta = append(withTypeMeta, document[1:]...)
+	return withTypeMeta, prefix, nil
+}
+
+// withoutTypeMeta removes the apiVersion and kind set by withTypeMeta with the given prefix.
+func withoutTypeMeta(document, prefix []byte) ([]byte, error) {
+	if len(prefix) == 0 {
+		return document, nil
+	}
+
+	// The options left the beginning of the document untouched
+	if bytes.HasPrefix(document, prefix) {
+		rest := document[len(prefix):]
+		if len(rest) > 0 && rest[0] == ',' {
+			rest = rest[1:]
+		}
+		return append([]byte{'{'}, rest...), nil
+	}
+
+	var resource map[string]interface{}
+	decoder := json.ConfigCompatibleWithStandardLibrary.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	if err := decoder.Decode(&resource); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal byte sequence")
+	}
+	delete(resource, "apiVersion")
+	delete(resource, "kind")
+
+	document, err := json.ConfigCompatibleWithStandardLibrary.Marshal(resource)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal byte sequence")
+	}
+	return document, nil
+}
+
+// applyWithTypeMeta applies the options to the documents of objects of the given kinds, with the apiVersion and kind
+// set on the documents missing them while the options run.
+func applyWithTypeMeta(currentGVK, modifiedGVK schema.GroupVersionKind, current, modified []byte, apply func(current, modified []byte) ([]byte, []byte, error)) ([]byte, []byte, error) {
+	current, currentPrefix, err := withTypeMeta(current, currentGVK)
+	if err != nil {
+		return nil, nil, err
+	}
+	modified, modifiedPrefix, err := withTypeMeta(modified, modifiedGVK)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, modified, err = apply(current, modified)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, err = withoutTypeMeta(current, currentPrefix)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not remove the type meta of current")
+	}
+	modified, err = withoutTypeMeta(modified, modifiedPrefix)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not remove the type meta of modified")
+	}
+	return current, modified, nil
+}
+
+// resourceGroupKind returns the group kind of the resource from its apiVersion and kind.
+func resourceGroupKind(resource map[string]interface{}) schema.GroupKind {
+	apiVersion, _ := resource["apiVersion"].(string)
+	kind, _ := resource["kind"].(string)
+	groupVersion, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return schema.GroupKind{}
+	}
+	return groupVersion.WithKind(kind).GroupKind()
+}
+
+// hasGroupKind tells whether the resource has one of the group kinds. The options receive the kind of typed objects
+// from Calculate, see withTypeMeta.
+func hasGroupKind(resource map[string]interface{}, groupKinds ...schema.GroupKind) bool {
+	resourceGroupKind := resourceGroupKind(resource)
+	if resourceGroupKind.Kind == "" {
+		return false
+	}
+	for _, groupKind := range groupKinds {
+		if resourceGroupKind == groupKind {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyOptions marshals the objects and applies the options to their documents like Calculate does, the options
+// receiving the apiVersion and kind of typed objects, resolved from the client-go scheme.
+func ApplyOptions(current, modified runtime.Object, opts ...CalculateOption) ([]byte, []byte, error) {
+	currentDocument, err := json.ConfigCompatibleWithStandardLibrary.Marshal(current)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to convert current object to byte sequence")
+	}
+	modifiedDocument, err := json.ConfigCompatibleWithStandardLibrary.Marshal(modified)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Failed to convert modified object to byte sequence")
+	}
+
+	return applyWithTypeMeta(objectGroupVersionKind(current), objectGroupVersionKind(modified), currentDocument, modifiedDocument, func(current, modified []byte) ([]byte, []byte, error) {
+		var err error
+		for _, opt := range opts {
+			current, modified, err = opt(current, modified)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "Failed to apply option function")
+			}
+		}
+		return current, modified, nil
+	})
+}
@@ -0,0 +1,136 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWithTypeMeta(t *testing.T) {
+	gvk := appsv1.SchemeGroupVersion.WithKind("Deployment")
+
+	tests := []struct {
+		name     string
+		document string
+		want     string
+	}{
+		{
+			name:     "kindless",
+			document: `{"metadata":{"name":"app"}}`,
+			want:     `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"app"}}`,
+		},
+		{
+			name:     "empty",
+			document: `{}`,
+			want:     `{"apiVersion":"apps/v1","kind":"Deployment"}`,
+		},
+		{
+			name:     "with kind",
+			document: `{"apiVersion":"example.com/v1","kind":"App"}`,
+			want:     `{"apiVersion":"example.com/v1","kind":"App"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document, prefix, err := withTypeMeta([]byte(tt.document), gvk)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(document))
+
+			// Untouched by the options
+			restored, err := withoutTypeMeta(document, prefix)
+			require.NoError(t, err)
+			assert.Equal(t, tt.document, string(restored))
+
+			// Rewritten by the options
+			resource := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(document, &resource))
+			resource["spec"] = map[string]interface{}{"replicas": 9007199254740993}
+			rewritten, err := json.Marshal(resource)
+			require.NoError(t, err)
+			restored, err = withoutTypeMeta(rewritten, prefix)
+			require.NoError(t, err)
+			assert.Contains(t, string(restored), `"replicas":9007199254740993`)
+			assert.Equal(t, tt.name == "with kind", json.Get(restored, "kind").ToString() != "")
+		})
+	}
+}
+
+func TestCalculateGivesTheKindToTheOptions(t *testing.T) {
+	var kinds []schema.GroupKind
+	recordKind := func(current, modified []byte) ([]byte, []byte, error) {
+		resource := map[string]interface{}{}
+		if err := json.Unmarshal(modified, &resource); err != nil {
+			return nil, nil, err
+		}
+		kinds = append(kinds, resourceGroupKind(resource))
+		return current, modified, nil
+	}
+
+	current := &appsv1.Deployment{ObjectMeta: v1.ObjectMeta{Name: "app", Namespace: "default"}}
+	mustAnnotate(current)
+	result, err := DefaultPatchMaker.Calculate(current, current.DeepCopy(), recordKind)
+	require.NoError(t, err)
+	assert.Equal(t, []schema.GroupKind{{Group: "apps", Kind: "Deployment"}}, kinds)
+
+	// The documents of the result don't carry the kind added for the options
+	assert.True(t, result.IsEmpty())
+	assert.Empty(t, json.Get(result.Current, "kind").ToString())
+	assert.Empty(t, json.Get(result.Modified, "kind").ToString())
+
+	configMap := &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: "config"}}
+	_, err = DefaultPatchMaker.Calculate(configMap, configMap, recordKind)
+	require.NoError(t, err)
+	assert.Equal(t, schema.GroupKind{Kind: "ConfigMap"}, kinds[1])
+}
+
+func TestHasGroupKind(t *testing.T) {
+	pdb := map[string]interface{}{"apiVersion": "policy/v1beta1", "kind": "PodDisruptionBudget"}
+	assert.True(t, hasGroupKind(pdb, schema.GroupKind{Group: "policy", Kind: "PodDisruptionBudget"}))
+	assert.False(t, hasGroupKind(pdb, schema.GroupKind{Kind: "PodDisruptionBudget"}))
+	assert.False(t, hasGroupKind(map[string]interface{}{"spec": map[string]interface{}{}}, schema.GroupKind{}))
+}
+
+func TestApplyOptions(t *testing.T) {
+	current := &corev1.Service{ObjectMeta: v1.ObjectMeta{Name: "service"}}
+	modified := current.DeepCopy()
+	modified.Spec.ClusterIP = "10.0.0.1"
+
+	var groupKinds []schema.GroupKind
+	recordKind := func(current, modified []byte) ([]byte, []byte, error) {
+		for _, document := range [][]byte{current, modified} {
+			resource := map[string]interface{}{}
+			if err := json.Unmarshal(document, &resource); err != nil {
+				return nil, nil, err
+			}
+			groupKinds = append(groupKinds, resourceGroupKind(resource))
+		}
+		return current, modified, nil
+	}
+
+	currentDocument, modifiedDocument, err := ApplyOptions(current, modified, recordKind)
+	require.NoError(t, err)
+	assert.Equal(t, []schema.GroupKind{{Kind: "Service"}, {Kind: "Service"}}, groupKinds)
+	assert.Empty(t, json.Get(currentDocument, "kind").ToString())
+	assert.Equal(t, "10.0.0.1", json.Get(modifiedDocument, "spec", "clusterIP").ToString())
+}
@@ -0,0 +1,93 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"emperror.dev/errors"
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// CalculateFromYAML is Calculate for objects given as YAML or JSON manifests. The objects are decoded as typed objects
+// when their kind is registered in the defaulting scheme or the client-go scheme, so they are compared with strategic
+// merge semantics, and as unstructured objects otherwise. The kind is read from the manifests when gvk is empty.
+func (p *PatchMaker) CalculateFromYAML(currentYAML, modifiedYAML []byte, gvk schema.GroupVersionKind, opts ...CalculateOption) (*PatchResult, error) {
+	currentObject, err := p.decodeYAML(currentYAML, gvk)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to decode current object")
+	}
+	if gvk.Empty() {
+		gvk = currentObject.GetObjectKind().GroupVersionKind()
+	}
+	modifiedObject, err := p.decodeYAML(modifiedYAML, gvk)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to decode modified object")
+	}
+
+	return p.Calculate(currentObject, modifiedObject, opts...)
+}
+
+// PatchYAML returns the patch as YAML.
+func (p *PatchResult) PatchYAML() ([]byte, error) {
+	data, err := yaml.JSONToYAML(p.Patch)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not convert patch to YAML")
+	}
+	return data, nil
+}
+
+// decodeYAML decodes a manifest into a typed object of the given kind when it is registered, or into an unstructured object.
+func (p *PatchMaker) decodeYAML(data []byte, gvk schema.GroupVersionKind) (runtime.Object, error) {
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(data, &u.Object); err != nil {
+		return nil, err
+	}
+	if u.Object == nil {
+		return nil, errors.New("empty manifest")
+	}
+	if gvk.Empty() {
+		gvk = u.GroupVersionKind()
+	} else {
+		u.SetGroupVersionKind(gvk)
+	}
+	if gvk.Kind == "" {
+		return nil, errors.New("the kind of the object is unknown")
+	}
+
+	for _, scheme := range []*runtime.Scheme{p.defaultingScheme, clientgoscheme.Scheme} {
+		if scheme == nil || !scheme.Recognizes(gvk) {
+			continue
+		}
+		typed, err := scheme.New(gvk)
+		if err != nil {
+			return nil, err
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+			return nil, err
+		}
+		return typed, nil
+	}
+
+	return u, nil
+}
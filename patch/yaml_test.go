@@ -0,0 +1,79 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCalculateFromYAML(t *testing.T) {
+	current := []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1
+      - name: sidecar
+        image: proxy:1
+`)
+	modified := []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:2
+`)
+
+	// The containers are merged by name, the sidecar set by others is kept
+	result, err := DefaultPatchMaker.(YAMLMaker).CalculateFromYAML(current, modified, schema.GroupVersionKind{})
+	require.NoError(t, err)
+	assert.IsType(t, &appsv1.Deployment{}, result.Patched)
+	assert.Len(t, result.Patched.(*appsv1.Deployment).Spec.Template.Spec.Containers, 2)
+
+	data, err := result.PatchYAML()
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "image: app:2")
+
+	// Unknown kinds are unstructured
+	result, err = DefaultPatchMaker.(YAMLMaker).CalculateFromYAML(
+		[]byte(`{"metadata":{"name":"app"},"spec":{"replicas":1}}`),
+		[]byte("metadata:\n  name: app\nspec:\n  replicas: 2\n"),
+		schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "App"},
+	)
+	require.NoError(t, err)
+	assert.IsType(t, &unstructured.Unstructured{}, result.Patched)
+	data, err = result.PatchYAML()
+	require.NoError(t, err)
+	assert.Equal(t, "spec:\n  replicas: 2\n", string(data))
+
+	_, err = DefaultPatchMaker.(YAMLMaker).CalculateFromYAML([]byte(""), modified, schema.GroupVersionKind{})
+	assert.Error(t, err)
+}
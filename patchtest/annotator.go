@@ -0,0 +1,139 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package patchtest provides fakes and helpers to test controllers using the patch package, e.g. annotators
+// recording or failing their calls, given to a patch maker with patch.WithLastAppliedAnnotator, and golden files.
+package patchtest
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// Method names of the recorded calls.
+const (
+	GetOriginalConfiguration         = "GetOriginalConfiguration"
+	SetOriginalConfiguration         = "SetOriginalConfiguration"
+	SetLastAppliedAnnotation         = "SetLastAppliedAnnotation"
+	SetLastAppliedAnnotationToObject = "SetLastAppliedAnnotationToObject"
+)
+
+// Call is a call to an annotator.
+type Call struct {
+	Method string
+	// Object is the object the configuration is read from or set on.
+	Object runtime.Object
+	// Original is the configuration read or set, if any.
+	Original []byte
+	Err      error
+}
+
+// RecordingAnnotator records the calls to the annotator it wraps.
+type RecordingAnnotator struct {
+	annotator patch.LastAppliedAnnotator
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ patch.LastAppliedAnnotator = &RecordingAnnotator{}
+
+// NewRecordingAnnotator wraps the annotator, patch.DefaultAnnotator if nil.
+func NewRecordingAnnotator(annotator patch.LastAppliedAnnotator) *RecordingAnnotator {
+	if annotator == nil {
+		annotator = patch.DefaultAnnotator
+	}
+	return &RecordingAnnotator{annotator: annotator}
+}
+
+// Calls returns the calls recorded so far.
+func (a *RecordingAnnotator) Calls() []Call {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Call{}, a.calls...)
+}
+
+// CallsOf returns the calls of the method recorded so far.
+func (a *RecordingAnnotator) CallsOf(method string) []Call {
+	var calls []Call
+	for _, call := range a.Calls() {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Reset forgets the recorded calls.
+func (a *RecordingAnnotator) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls = nil
+}
+
+func (a *RecordingAnnotator) record(call Call) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.calls = append(a.calls, call)
+}
+
+func (a *RecordingAnnotator) GetOriginalConfiguration(obj runtime.Object) ([]byte, error) {
+	original, err := a.annotator.GetOriginalConfiguration(obj)
+	a.record(Call{Method: GetOriginalConfiguration, Object: obj, Original: original, Err: err})
+	return original, err
+}
+
+func (a *RecordingAnnotator) SetOriginalConfiguration(obj runtime.Object, original []byte) error {
+	err := a.annotator.SetOriginalConfiguration(obj, original)
+	a.record(Call{Method: SetOriginalConfiguration, Object: obj, Original: original, Err: err})
+	return err
+}
+
+func (a *RecordingAnnotator) SetLastAppliedAnnotation(obj runtime.Object) error {
+	err := a.annotator.SetLastAppliedAnnotation(obj)
+	a.record(Call{Method: SetLastAppliedAnnotation, Object: obj, Err: err})
+	return err
+}
+
+func (a *RecordingAnnotator) SetLastAppliedAnnotationToObject(objModified runtime.Object, objExpected runtime.Object) error {
+	err := a.annotator.SetLastAppliedAnnotationToObject(objModified, objExpected)
+	a.record(Call{Method: SetLastAppliedAnnotationToObject, Object: objModified, Err: err})
+	return err
+}
+
+// FailingAnnotator fails every call with Err.
+type FailingAnnotator struct {
+	Err error
+}
+
+var _ patch.LastAppliedAnnotator = FailingAnnotator{}
+
+func (a FailingAnnotator) GetOriginalConfiguration(runtime.Object) ([]byte, error) {
+	return nil, a.Err
+}
+
+func (a FailingAnnotator) SetOriginalConfiguration(runtime.Object, []byte) error {
+	return a.Err
+}
+
+func (a FailingAnnotator) SetLastAppliedAnnotation(runtime.Object) error {
+	return a.Err
+}
+
+func (a FailingAnnotator) SetLastAppliedAnnotationToObject(runtime.Object, runtime.Object) error {
+	return a.Err
+}
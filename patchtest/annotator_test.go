@@ -0,0 +1,75 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patchtest
+
+import (
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+func newConfigMap(value string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "config",
+			Namespace: "default",
+		},
+		Data: map[string]string{"key": value},
+	}
+}
+
+func newPatchMaker(annotator patch.LastAppliedAnnotator) patch.Maker {
+	return patch.NewPatchMaker(nil, &patch.K8sStrategicMergePatcher{}, &patch.BaseJSONMergePatcher{}, patch.WithLastAppliedAnnotator(annotator))
+}
+
+func TestRecordingAnnotator(t *testing.T) {
+	annotator := NewRecordingAnnotator(nil)
+	current := newConfigMap("a")
+	require.NoError(t, annotator.SetLastAppliedAnnotation(current))
+
+	result, err := newPatchMaker(annotator).Calculate(current, newConfigMap("b"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"key":"b"}}`, string(result.Patch))
+
+	assert.Len(t, annotator.CallsOf(SetLastAppliedAnnotation), 1)
+	reads := annotator.CallsOf(GetOriginalConfiguration)
+	require.Len(t, reads, 1)
+	assert.Contains(t, string(reads[0].Original), `"key":"a"`)
+	annotations := annotator.CallsOf(SetLastAppliedAnnotationToObject)
+	require.Len(t, annotations, 1)
+	assert.Same(t, result.Patched, annotations[0].Object)
+
+	original, err := patch.DefaultAnnotator.GetOriginalConfiguration(result.Patched.(*v1.ConfigMap))
+	require.NoError(t, err)
+	assert.Contains(t, string(original), `"key":"b"`)
+
+	annotator.Reset()
+	assert.Empty(t, annotator.Calls())
+}
+
+func TestFailingAnnotator(t *testing.T) {
+	annotator := FailingAnnotator{Err: errors.New("annotator failure")}
+
+	_, err := newPatchMaker(annotator).Calculate(newConfigMap("a"), newConfigMap("b"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "annotator failure")
+	require.Error(t, patch.SetLastAppliedConfiguration(annotator, newConfigMap("a")))
+}
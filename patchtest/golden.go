@@ -0,0 +1,129 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patchtest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// UpdateGoldenEnv is the environment variable making the golden helpers write the golden files instead of comparing
+// them, e.g. UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// SnapshotDir is the directory of the golden files of SnapshotPatch.
+const SnapshotDir = "testdata"
+
+// AssertEmptyPatch calculates the patch between the objects and checks that it is empty, e.g. to verify that a desired
+// object matches the object returned by the API server.
+func AssertEmptyPatch(t testing.TB, maker patch.Maker, current, modified runtime.Object, opts ...patch.CalculateOption) *patch.PatchResult {
+	t.Helper()
+
+	result, err := maker.Calculate(current, modified, opts...)
+	if err != nil {
+		t.Fatalf("could not calculate patch: %v", err)
+	}
+	if !result.IsEmpty() {
+		t.Errorf("patch is not empty: %s", result.Patch)
+	}
+	return result
+}
+
+// SnapshotPatch calculates the patch between the objects and compares it with the golden file of the test, named after
+// the test in SnapshotDir, see AssertGoldenJSON.
+func SnapshotPatch(t testing.TB, maker patch.Maker, current, modified runtime.Object, opts ...patch.CalculateOption) *patch.PatchResult {
+	t.Helper()
+
+	result, err := maker.Calculate(current, modified, opts...)
+	if err != nil {
+		t.Fatalf("could not calculate patch: %v", err)
+	}
+	AssertGoldenPatch(t, result, SnapshotFile(t))
+	return result
+}
+
+// SnapshotFile returns the golden file of the test used by SnapshotPatch, e.g. testdata/TestReconcile/deployment.json
+// for the subtest deployment of TestReconcile.
+func SnapshotFile(t testing.TB) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '\\', ':', '*', '?', '"', '<', '>', '|', ' ':
+			return '_'
+		}
+		return r
+	}, t.Name())
+	return filepath.Join(SnapshotDir, filepath.FromSlash(name)+".json")
+}
+
+// AssertGoldenPatch checks that the patch of the result is the JSON document of the golden file. The documents are
+// compared indented, so the golden file can be edited by hand.
+func AssertGoldenPatch(t testing.TB, result *patch.PatchResult, goldenFile string) {
+	t.Helper()
+	AssertGoldenJSON(t, result.Patch, goldenFile)
+}
+
+// AssertGoldenJSON checks that the JSON document is the one of the golden file, which is written instead when
+// UpdateGoldenEnv is set.
+func AssertGoldenJSON(t testing.TB, document []byte, goldenFile string) {
+	t.Helper()
+
+	indented, err := indentJSON(document)
+	if err != nil {
+		t.Fatalf("invalid JSON document: %v", err)
+	}
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(goldenFile), 0o755); err != nil {
+			t.Fatalf("could not create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(goldenFile, indented, 0o644); err != nil {
+			t.Fatalf("could not write golden file: %v", err)
+		}
+		return
+	}
+
+	golden, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatalf("could not read golden file, set %s to write it: %v", UpdateGoldenEnv, err)
+	}
+	golden, err = indentJSON(golden)
+	if err != nil {
+		t.Fatalf("invalid golden file %s: %v", goldenFile, err)
+	}
+	if !bytes.Equal(golden, indented) {
+		t.Errorf("document differs from golden file %s, set %s to update it\nexpected:\n%s\nactual:\n%s", goldenFile, UpdateGoldenEnv, golden, indented)
+	}
+}
+
+// indentJSON returns the document indented with sorted keys.
+func indentJSON(document []byte) ([]byte, error) {
+	var value interface{}
+	if err := json.Unmarshal(document, &value); err != nil {
+		return nil, err
+	}
+	indented, err := json.ConfigCompatibleWithStandardLibrary.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(indented, '\n'), nil
+}
@@ -0,0 +1,70 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patchtest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+func TestAssertGoldenPatch(t *testing.T) {
+	current := newConfigMap("a")
+	require.NoError(t, patch.DefaultAnnotator.SetLastAppliedAnnotation(current))
+	result, err := patch.DefaultPatchMaker.Calculate(current, newConfigMap("b"))
+	require.NoError(t, err)
+
+	AssertGoldenPatch(t, result, filepath.Join("testdata", "configmap.json"))
+
+	goldenFile := filepath.Join(t.TempDir(), "patch.json")
+	t.Setenv(UpdateGoldenEnv, "1")
+	AssertGoldenPatch(t, result, goldenFile)
+	golden, err := os.ReadFile(goldenFile)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":{"key":"b"}}`, string(golden))
+
+	t.Setenv(UpdateGoldenEnv, "")
+	recorder := &testing.T{}
+	AssertGoldenJSON(recorder, []byte(`{"data":{"key":"c"}}`), goldenFile)
+	assert.True(t, recorder.Failed())
+}
+
+func TestAssertEmptyPatch(t *testing.T) {
+	current := newConfigMap("a")
+	require.NoError(t, patch.DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	result := AssertEmptyPatch(t, patch.DefaultPatchMaker, current, newConfigMap("a"))
+	assert.True(t, result.IsEmpty())
+
+	recorder := &testing.T{}
+	AssertEmptyPatch(recorder, patch.DefaultPatchMaker, current, newConfigMap("b"))
+	assert.True(t, recorder.Failed())
+}
+
+func TestSnapshotPatch(t *testing.T) {
+	current := newConfigMap("a")
+	require.NoError(t, patch.DefaultAnnotator.SetLastAppliedAnnotation(current))
+
+	t.Run("configmap", func(t *testing.T) {
+		assert.Equal(t, filepath.Join("testdata", "TestSnapshotPatch", "configmap.json"), SnapshotFile(t))
+		result := SnapshotPatch(t, patch.DefaultPatchMaker, current, newConfigMap("b"))
+		assert.JSONEq(t, `{"data":{"key":"b"}}`, string(result.Patch))
+	})
+}
@@ -0,0 +1,52 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patchtest
+
+import (
+	"emperror.dev/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+// CheckIdempotent checks that the patches of the maker converge for the object: the object is compared with itself,
+// then the patched object with the object, which must give an empty patch. A patch that is never empty makes the
+// controllers update their objects on every reconciliation.
+func CheckIdempotent(maker patch.Maker, obj runtime.Object, opts ...patch.CalculateOption) error {
+	return CheckConverges(maker, obj.DeepCopyObject(), obj, opts...)
+}
+
+// CheckConverges checks that once the patch between the objects is applied on the current object, the patch between the
+// patched object and the modified object is empty.
+func CheckConverges(maker patch.Maker, current, modified runtime.Object, opts ...patch.CalculateOption) error {
+	result, err := maker.Calculate(current, modified.DeepCopyObject(), opts...)
+	if err != nil {
+		return errors.Wrap(err, "could not calculate patch")
+	}
+	patched, ok := result.Patched.(runtime.Object)
+	if !ok {
+		return errors.Errorf("patched object is a %T", result.Patched)
+	}
+
+	result, err = maker.Calculate(patched, modified.DeepCopyObject(), opts...)
+	if err != nil {
+		return errors.Wrap(err, "could not calculate patch of patched object")
+	}
+	if !result.IsEmpty() {
+		return errors.Errorf("patch of patched object is not empty: %s", result.Patch)
+	}
+
+	return nil
+}
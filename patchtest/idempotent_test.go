@@ -0,0 +1,80 @@
+// Copyright © 2022 Banzai Cloud
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package patchtest
+
+import (
+	"testing"
+
+	json "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/disaster37/k8s-objectmatcher/patch"
+)
+
+func TestCheckIdempotent(t *testing.T) {
+	require.NoError(t, CheckIdempotent(patch.DefaultPatchMaker, newConfigMap("a")))
+	require.NoError(t, CheckConverges(patch.DefaultPatchMaker, newConfigMap("a"), newConfigMap("b")))
+
+	// An option changing the modified object only never converges
+	nonConverging := func(current, modified []byte) ([]byte, []byte, error) {
+		return current, []byte(`{"data":{"key":"c"}}`), nil
+	}
+	err := CheckIdempotent(patch.DefaultPatchMaker, newConfigMap("a"), nonConverging)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not empty")
+}
+
+func FuzzCheckIdempotentConfigMap(f *testing.F) {
+	f.Add("key", "value", "app", "nginx")
+	f.Add("", "", "", "")
+	f.Add("a.b", "null", "example.com/name", "{}")
+
+	f.Fuzz(func(t *testing.T, key, value, label, labelValue string) {
+		configMap := newConfigMap(value)
+		configMap.Data = map[string]string{key: value}
+		configMap.Labels = map[string]string{label: labelValue}
+
+		if err := CheckIdempotent(patch.DefaultPatchMaker, configMap); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzCheckIdempotentUnstructured(f *testing.F) {
+	f.Add([]byte(`{"replicas":1,"template":{"containers":[{"name":"app","image":"nginx"}]}}`))
+	f.Add([]byte(`{"list":[1,"a",null,{}],"empty":{},"nested":{"null":null}}`))
+
+	f.Fuzz(func(t *testing.T, spec []byte) {
+		var content map[string]interface{}
+		if err := json.Unmarshal(spec, &content); err != nil || content == nil {
+			t.Skip()
+		}
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "App",
+			"metadata": map[string]interface{}{
+				"name":      "test",
+				"namespace": "default",
+			},
+			"spec": content,
+		}}
+
+		if err := CheckIdempotent(patch.DefaultPatchMaker, obj); err != nil {
+			t.Fatal(err)
+		}
+	})
+}